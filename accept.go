@@ -0,0 +1,42 @@
+package ranger
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrNotSupported is returned when a resource doesn't accept range requests.
+var ErrNotSupported = errors.New("ranger: ranges not supported")
+
+// AcceptsRanges reports whether a response header advertises support for
+// range requests, through its Accept-Ranges field. It returns true unless the
+// field is 'none'.
+//
+// If there is no Accept-Ranges field, support is unknown, and AcceptsRanges
+// assumes that ranges are not supported.
+func AcceptsRanges(h http.Header) bool {
+	values := h.Values("Accept-Ranges")
+	if len(values) == 0 {
+		return false
+	}
+	accepts := false
+	for _, v := range values {
+		for _, unit := range strings.Split(v, ",") {
+			unit = strings.TrimSpace(unit)
+			if strings.EqualFold(unit, "none") {
+				return false
+			}
+			if unit != "" {
+				accepts = true
+			}
+		}
+	}
+	return accepts
+}
+
+// SetAcceptRanges advertises support for byte ranges by setting the
+// Accept-Ranges field of h to 'bytes'.
+func SetAcceptRanges(h http.Header) {
+	h.Set("Accept-Ranges", "bytes")
+}
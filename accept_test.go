@@ -0,0 +1,56 @@
+package ranger
+
+import (
+	"net/http"
+	"testing"
+)
+
+type acceptsRangesTest struct {
+	Header   http.Header
+	Expected bool
+}
+
+func TestAcceptsRanges(t *testing.T) {
+	tests := []acceptsRangesTest{
+		{ // bytes
+			Header:   http.Header{"Accept-Ranges": {"bytes"}},
+			Expected: true,
+		},
+		{ // some other unit
+			Header:   http.Header{"Accept-Ranges": {"items"}},
+			Expected: true,
+		},
+		{ // none
+			Header:   http.Header{"Accept-Ranges": {"none"}},
+			Expected: false,
+		},
+		{ // none, differently cased
+			Header:   http.Header{"Accept-Ranges": {"None"}},
+			Expected: false,
+		},
+		{ // absent
+			Header:   http.Header{},
+			Expected: false,
+		},
+		{ // empty
+			Header:   http.Header{"Accept-Ranges": {""}},
+			Expected: false,
+		},
+	}
+	for i, test := range tests {
+		if got, want := AcceptsRanges(test.Header), test.Expected; got != want {
+			t.Errorf("test %d: bad result: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSetAcceptRanges(t *testing.T) {
+	h := http.Header{}
+	SetAcceptRanges(h)
+	if got, want := h.Get("Accept-Ranges"), "bytes"; got != want {
+		t.Errorf("bad header: got %q, want %q", got, want)
+	}
+	if !AcceptsRanges(h) {
+		t.Error("AcceptsRanges is false after SetAcceptRanges")
+	}
+}
@@ -0,0 +1,105 @@
+package ranger
+
+import (
+	"sync"
+	"time"
+)
+
+// ChunkStrategy chooses how many bytes a Downloader fetches with each request.
+// Each of a Downloader's connections asks it in turn, so that the size can
+// follow what that connection has managed so far.
+type ChunkStrategy interface {
+	// ChunkSize returns the size of the next chunk to fetch on a connection,
+	// given the size of the last chunk it fetched, how long that took, and
+	// the error it failed with, if any. For a connection's first chunk, last
+	// is zero.
+	ChunkSize(last int64, took time.Duration, err error) int64
+}
+
+// FixedChunks is a ChunkStrategy that always fetches chunks of the same size.
+type FixedChunks int64
+
+// ChunkSize returns c.
+func (c FixedChunks) ChunkSize(last int64, took time.Duration, err error) int64 {
+	return int64(c)
+}
+
+// AdaptiveChunks is a ChunkStrategy that starts small, and sizes each chunk
+// so that fetching it should take about Target, judging by how fast the last
+// one was fetched. Fast links soon fetch large chunks, cutting the overhead of
+// making requests, while slow ones keep to small chunks that are quick to
+// retry. The zero value is ready to use.
+type AdaptiveChunks struct {
+	// Min and Max bound the size of a chunk. Each connection starts with
+	// chunks of Min bytes. If they're zero, 64KB and 16MB are used.
+	Min, Max int64
+
+	// Target is how long fetching a chunk should take. If it's zero, 2
+	// seconds is used.
+	Target time.Duration
+}
+
+// ChunkSize returns the size for the next chunk. It grows or shrinks by at
+// most a factor of two at a time, so that a single fast or slow request doesn't
+// throw it too far, and halves after an error.
+func (a AdaptiveChunks) ChunkSize(last int64, took time.Duration, err error) int64 {
+	lo, hi, target := a.Min, a.Max, a.Target
+	if lo <= 0 {
+		lo = 64 << 10
+	}
+	if hi <= 0 {
+		hi = 16 << 20
+	}
+	hi = max(hi, lo)
+	if target <= 0 {
+		target = 2 * time.Second
+	}
+	var n int64
+	switch {
+	case last <= 0:
+		return lo
+	case err != nil:
+		n = last / 2
+	case took <= 0:
+		n = hi
+	default:
+		n = int64(float64(last) * float64(target) / float64(took))
+		n = min(max(n, last/2), last*2)
+	}
+	return min(max(n, lo), hi)
+}
+
+// chunkQueue hands out chunks of whatever size is asked for from the ranges
+// that are left to fetch, in order.
+type chunkQueue struct {
+	mu   sync.Mutex
+	gaps []Range
+}
+
+// next returns the next chunk of at most size bytes, or false if there are
+// none left.
+func (q *chunkQueue) next(size int64) (Range, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.gaps) == 0 {
+		return Range{}, false
+	}
+	size = max(size, 1)
+	gap := q.gaps[0]
+	if gap.Len() <= size {
+		q.gaps = q.gaps[1:]
+		return gap, true
+	}
+	chunk := Range{Start: gap.Start, Stop: gap.Start + size - 1}
+	q.gaps[0].Start += size
+	return chunk, true
+}
+
+// drain returns the ranges left to fetch, and empties q.
+func (q *chunkQueue) drain() []Range {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	gaps := q.gaps
+	q.gaps = nil
+	return gaps
+}
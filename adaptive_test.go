@@ -0,0 +1,137 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type chunkSizeTest struct {
+	Strategy AdaptiveChunks
+	Last     int64
+	Took     time.Duration
+	Err      error
+	Expected int64
+}
+
+func TestAdaptiveChunks(t *testing.T) {
+	strategy := AdaptiveChunks{Min: 100, Max: 1000, Target: time.Second}
+	tests := []chunkSizeTest{
+		{ // first chunk
+			Strategy: strategy,
+			Expected: 100,
+		},
+		{ // on target
+			Strategy: strategy,
+			Last:     300,
+			Took:     time.Second,
+			Expected: 300,
+		},
+		{ // fast, grows by at most double
+			Strategy: strategy,
+			Last:     300,
+			Took:     time.Millisecond,
+			Expected: 600,
+		},
+		{ // a little fast
+			Strategy: strategy,
+			Last:     300,
+			Took:     time.Second * 3 / 4,
+			Expected: 400,
+		},
+		{ // slow, shrinks by at most half
+			Strategy: strategy,
+			Last:     600,
+			Took:     time.Minute,
+			Expected: 300,
+		},
+		{ // failed
+			Strategy: strategy,
+			Last:     600,
+			Took:     time.Millisecond,
+			Err:      errors.New("oops"),
+			Expected: 300,
+		},
+		{ // bounded above
+			Strategy: strategy,
+			Last:     800,
+			Took:     time.Millisecond,
+			Expected: 1000,
+		},
+		{ // bounded below
+			Strategy: strategy,
+			Last:     150,
+			Took:     time.Minute,
+			Expected: 100,
+		},
+		{ // defaults
+			Expected: 64 << 10,
+		},
+	}
+	for i, test := range tests {
+		got := test.Strategy.ChunkSize(test.Last, test.Took, test.Err)
+		if got != test.Expected {
+			t.Errorf("test %d: bad chunk size: got %d, want %d", i, got, test.Expected)
+		}
+	}
+}
+
+func TestDownloaderChunking(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	srv, _ := countingServer(t, content)
+	var mu sync.Mutex
+	var sizes []int64
+	d := &Downloader{
+		Client:   srv.Client(),
+		Workers:  1,
+		Chunking: AdaptiveChunks{Min: 100, Max: 4000, Target: time.Hour},
+		Hooks: Hooks{OnFetched: func(chunk Range, d time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			sizes = append(sizes, chunk.Len())
+		}},
+	}
+	dst := memFile(make([]byte, len(content)))
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	// Every chunk is fetched far faster than the target, so each is twice
+	// the size of the last, up to the maximum.
+	want := []int64{100, 200, 400, 800, 1600, 3200, 3700}
+	if got := sizes; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad chunk sizes: got %v, want %v", got, want)
+	}
+}
+
+func TestDownloaderChunkingCanceled(t *testing.T) {
+	srv, requests := countingServer(t, strings.Repeat("0123456789", 100))
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Downloader{
+		Client:   srv.Client(),
+		Workers:  1,
+		Chunking: AdaptiveChunks{Min: 10},
+		Hooks: Hooks{OnFetched: func(chunk Range, d time.Duration, err error) {
+			cancel()
+		}},
+	}
+	_, err := d.Download(ctx, srv.URL, memFile(make([]byte, 1000)), nil)
+	var rerr *RangeError
+	if !errors.As(err, &rerr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("bad error: got %v, want a *RangeError for context.Canceled", err)
+	}
+	// The rest of the download is abandoned after the first chunk.
+	if got, want := rerr.Range, (Range{Start: 10, Stop: 999}); got != want {
+		t.Errorf("bad range: got %v, want %v", got, want)
+	}
+	if got, want := atomic.LoadInt64(requests), int64(2); got != want {
+		t.Errorf("bad number of requests: got %d, want %d", got, want)
+	}
+}
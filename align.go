@@ -0,0 +1,67 @@
+package ranger
+
+// Aligned is a range expanded outward to block boundaries, for backends that
+// can only read whole blocks, such as encrypted blobs, erasure-coded stripes
+// and page caches, along with how much to trim from either end of it to get
+// back the bytes that were asked for.
+type Aligned struct {
+	// Range is the aligned range to read.
+	Range Range
+
+	// Head and Tail are the number of bytes to trim from the start and end
+	// of Range: the bytes asked for are data[Head:len(data)-Tail], if data
+	// holds Range.
+	Head, Tail int64
+}
+
+// AlignTo expands r outward to the boundaries of blocks of blockSize bytes,
+// in content of length bytes. The final block holds whatever remains, so the
+// aligned range stops at the end of the content, rather than at the end of a
+// block. If length is negative, it's unknown, and the range is aligned to a
+// whole block. If blockSize isn't greater than 1, r is already aligned.
+func (r Range) AlignTo(blockSize, length int64) Aligned {
+	if blockSize <= 1 || r.Start > r.Stop {
+		return Aligned{Range: r}
+	}
+	a := r.alignTo(blockSize, length)
+	return Aligned{Range: a, Head: r.Start - a.Start, Tail: a.Stop - r.Stop}
+}
+
+func (r Range) alignTo(blockSize, length int64) Range {
+	start := max(r.Start, 0) / blockSize * blockSize
+	stop := (r.Stop/blockSize+1)*blockSize - 1
+	if length >= 0 {
+		stop = max(min(stop, length-1), r.Stop)
+	}
+	return Range{Start: start, Stop: stop}
+}
+
+// AlignTo expands every range in s outward to block boundaries, as
+// Range.AlignTo does, and returns the set of aligned ranges, which are merged
+// wherever they share a block. It also returns how to get back each of the
+// ranges of s: trims[i] is the aligned range holding s.Ranges()[i], and how
+// much to trim from either end of it.
+func (s RangeSet) AlignTo(blockSize, length int64) (aligned RangeSet, trims []Aligned) {
+	if blockSize <= 1 {
+		trims = make([]Aligned, len(s.ranges))
+		for i, r := range s.ranges {
+			trims[i] = Aligned{Range: r}
+		}
+		return s, trims
+	}
+	blocks := make([]Range, len(s.ranges))
+	for i, r := range s.ranges {
+		blocks[i] = r.alignTo(blockSize, length)
+	}
+	aligned = RangeSet{ranges: mergeRanges(blocks)}
+	trims = make([]Aligned, len(s.ranges))
+	j := 0
+	for i, r := range s.ranges {
+		for aligned.ranges[j].Stop < r.Stop {
+			j++
+		}
+		a := aligned.ranges[j]
+		trims[i] = Aligned{Range: a, Head: r.Start - a.Start, Tail: a.Stop - r.Stop}
+	}
+	return aligned, trims
+}
@@ -0,0 +1,83 @@
+package ranger
+
+import (
+	"reflect"
+	"testing"
+)
+
+type alignTest struct {
+	Range     Range
+	BlockSize int64
+	Length    int64
+	Expected  Aligned
+}
+
+func TestRangeAlignTo(t *testing.T) {
+	tests := []alignTest{
+		{ // within a block
+			Range:     Range{Start: 5, Stop: 9},
+			BlockSize: 16,
+			Length:    100,
+			Expected:  Aligned{Range: Range{Start: 0, Stop: 15}, Head: 5, Tail: 6},
+		},
+		{ // across blocks
+			Range:     Range{Start: 20, Stop: 40},
+			BlockSize: 16,
+			Length:    100,
+			Expected:  Aligned{Range: Range{Start: 16, Stop: 47}, Head: 4, Tail: 7},
+		},
+		{ // already aligned
+			Range:     Range{Start: 16, Stop: 31},
+			BlockSize: 16,
+			Length:    100,
+			Expected:  Aligned{Range: Range{Start: 16, Stop: 31}},
+		},
+		{ // clamped to the length
+			Range:     Range{Start: 90, Stop: 97},
+			BlockSize: 16,
+			Length:    100,
+			Expected:  Aligned{Range: Range{Start: 80, Stop: 99}, Head: 10, Tail: 2},
+		},
+		{ // unknown length
+			Range:     Range{Start: 90, Stop: 95},
+			BlockSize: 16,
+			Length:    -1,
+			Expected:  Aligned{Range: Range{Start: 80, Stop: 95}, Head: 10},
+		},
+		{ // no blocks
+			Range:     Range{Start: 3, Stop: 7},
+			BlockSize: 0,
+			Length:    100,
+			Expected:  Aligned{Range: Range{Start: 3, Stop: 7}},
+		},
+	}
+	for i, test := range tests {
+		if got, want := test.Range.AlignTo(test.BlockSize, test.Length), test.Expected; got != want {
+			t.Errorf("test %d: bad alignment: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestRangeSetAlignTo(t *testing.T) {
+	s := NewRangeSet(Range{Start: 2, Stop: 3}, Range{Start: 10, Stop: 12}, Range{Start: 40, Stop: 41})
+	aligned, trims := s.AlignTo(8, 44)
+	if got, want := aligned.Ranges(), []Range{{Start: 0, Stop: 15}, {Start: 40, Stop: 43}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad aligned ranges: got %+v, want %+v", got, want)
+	}
+	want := []Aligned{
+		{Range: Range{Start: 0, Stop: 15}, Head: 2, Tail: 12},
+		{Range: Range{Start: 0, Stop: 15}, Head: 10, Tail: 3},
+		{Range: Range{Start: 40, Stop: 43}, Head: 0, Tail: 2},
+	}
+	if got := trims; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad trims: got %+v, want %+v", got, want)
+	}
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGH")
+	for i, r := range s.Ranges() {
+		a := trims[i]
+		data := content[a.Range.Start : a.Range.Stop+1]
+		if got, want := string(data[a.Head:int64(len(data))-a.Tail]), string(content[r.Start:r.Stop+1]); got != want {
+			t.Errorf("range %v: bad bytes: got %q, want %q", r, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package ranger
+
+// ParseBytes parses a single Range header value, such as 'bytes=0-99,200-',
+// given as a []byte, with an optional 'bytes=' prefix, just as Parse would. It
+// never panics, whatever value holds, which makes it the entry point for
+// fuzzing the parser.
+func ParseBytes(value []byte, contentLen int64) ([]Range, error) {
+	ranges, err := AppendParse(nil, value, "bytes=", contentLen)
+	if err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// AppendParse parses a single Range header value, such as 'bytes=0-99,200-',
+// and appends the ranges to dst, merged and sorted as by Parse. It accepts the
+// value as a []byte, and allocates nothing beyond growing dst, so a proxy that
+// parses every request can reuse one slice between them:
+//
+//	ranges, err = ranger.AppendParse(ranges[:0], value, "bytes=", size)
+//
+// Only the appended ranges are merged; dst is left as it was. If there's an
+// error, dst is returned along with it, and errors are as for Parse.
+func AppendParse(dst []Range, value []byte, prefix string, contentLen int64) ([]Range, error) {
+	if len(value) >= len(prefix) && string(value[:len(prefix)]) == prefix {
+		value = value[len(prefix):]
+	}
+	n := len(dst)
+	result := dst
+	for {
+		if len(result)-n == MaxRanges {
+			return dst, ErrLimit
+		}
+		spec := value
+		i := indexByte(value, ',')
+		if i >= 0 {
+			spec, value = value[:i], value[i+1:]
+		}
+		spec = trimOWS(spec)
+		s, err := specAt(spec, len(result)-n)
+		if err != nil {
+			return dst, err
+		}
+		r, err := resolveAt(s, spec, len(result)-n, contentLen)
+		if err != nil {
+			return dst, err
+		}
+		result = append(result, r)
+		if i < 0 {
+			break
+		}
+	}
+	return append(result[:n], mergeRanges(result[n:])...), nil
+}
@@ -0,0 +1,100 @@
+package ranger
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAppendParse(t *testing.T) {
+	values := []string{
+		"bytes=0-99",
+		"bytes=50-99,0-59",
+		"bytes=200-299,0-99,100-149",
+		"bytes=-500",
+		"bytes=900-",
+		"bytes=0-1000",
+		"bytes=99-0",
+		"bytes=0-9,",
+		"bytes=",
+		"bytes=abc",
+		"bytes=00-9",
+		"bytes=0-99999999999999999999",
+		"0-99",
+	}
+	for _, v := range values {
+		ranges, err := AppendParse(nil, []byte(v), "bytes=", 1000)
+		wantRanges, wantErr := Parse([]string{v}, "bytes=", 1000)
+		if got, want := fmt.Sprintf("%v", err), fmt.Sprintf("%v", wantErr); got != want {
+			t.Errorf("%q: bad error: got %q, want %q", v, got, want)
+		}
+		if got, want := ranges, wantRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: bad ranges: got %+v, want %+v", v, got, want)
+		}
+	}
+}
+
+func FuzzParseBytes(f *testing.F) {
+	for _, v := range []string{"bytes=0-99", "bytes=50-99,0-59", "bytes=-500", "bytes=900-", "bytes=+5-9", "bytes=0-99999999999999999999", "0-9, 20-"} {
+		f.Add([]byte(v), int64(1000))
+	}
+	f.Fuzz(func(t *testing.T, value []byte, size int64) {
+		ranges, err := ParseBytes(value, size)
+		wantRanges, wantErr := Parse([]string{string(value)}, "bytes=", size)
+		if got, want := fmt.Sprintf("%v", err), fmt.Sprintf("%v", wantErr); got != want {
+			t.Fatalf("%q: bad error: got %q, want %q", value, got, want)
+		}
+		if got, want := ranges, wantRanges; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: bad ranges: got %+v, want %+v", value, got, want)
+		}
+		for _, r := range ranges {
+			if r.Start < 0 || r.Start > r.Stop || r.Stop >= size {
+				t.Fatalf("%q: range %v outside of %d bytes", value, r, size)
+			}
+		}
+	})
+}
+
+func TestAppendParseKeepsDst(t *testing.T) {
+	dst := []Range{{Start: 500, Stop: 599}}
+	ranges, err := AppendParse(dst, []byte("bytes=50-99,0-59"), "bytes=", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ranges, []Range{{Start: 500, Stop: 599}, {Start: 0, Stop: 99}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+	ranges, err = AppendParse(dst, []byte("bytes=0-9,x"), "bytes=", 1000)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if got, want := ranges, dst; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+}
+
+func TestAppendParseAllocs(t *testing.T) {
+	value := []byte("bytes=200-299,0-99,50-149")
+	dst := make([]Range, 0, 8)
+	allocs := testing.AllocsPerRun(100, func() {
+		var err error
+		if dst, err = AppendParse(dst[:0], value, "bytes=", 1000); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("bad allocations: got %v, want 0", allocs)
+	}
+}
+
+func BenchmarkAppendParse(b *testing.B) {
+	value := []byte("bytes=200-299,0-99,50-149")
+	dst := make([]Range, 0, 8)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if dst, err = AppendParse(dst[:0], value, "bytes=", 1000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ranger
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Assembler assembles content of a known length from ranges that arrive in any
+// order, writing each to the right offset of an io.WriterAt, such as an
+// *os.File. Bytes that have already been written, or are being written, are
+// skipped, so overlapping ranges are only written once. It's safe for
+// concurrent use by multiple goroutines, which write in parallel.
+type Assembler struct {
+	dst     io.WriterAt
+	tracker *Tracker
+
+	mu      sync.Mutex
+	pending RangeSet // ranges being written
+}
+
+// NewAssembler returns an Assembler that writes content of length bytes to
+// dst.
+func NewAssembler(dst io.WriterAt, length int64) *Assembler {
+	return &Assembler{dst: dst, tracker: NewTracker(length)}
+}
+
+// Write writes data, the content of r, to the destination. Only the parts of r
+// that haven't already been written are written again. data must be exactly
+// r.Len() bytes, and r must fall within the content.
+func (a *Assembler) Write(r Range, data []byte) error {
+	if int64(len(data)) != r.Len() || r.Start > r.Stop {
+		return fmt.Errorf("ranger: %d bytes of data for range %v", len(data), r)
+	}
+	if r.Start < 0 || r.Stop >= a.tracker.Length() {
+		return fmt.Errorf("%w: range %v outside of %d bytes", ErrUnsatisfiable, r, a.tracker.Length())
+	}
+
+	// Claim the parts of r that no one else has written or is writing.
+	a.mu.Lock()
+	todo := NewRangeSet(r).Subtract(a.tracker.Completed()).Subtract(a.pending)
+	a.pending = a.pending.Union(todo)
+	a.mu.Unlock()
+
+	var err error
+	written := todo
+	for _, w := range todo.ranges {
+		off := w.Start - r.Start
+		if _, err = a.dst.WriteAt(data[off:off+w.Len()], w.Start); err != nil {
+			written = todo.Intersect(NewRangeSet(Range{Start: r.Start, Stop: w.Start - 1}))
+			break
+		}
+	}
+
+	a.mu.Lock()
+	for _, w := range written.ranges {
+		a.tracker.Mark(w)
+	}
+	a.pending = a.pending.Subtract(todo)
+	a.mu.Unlock()
+	return err
+}
+
+// Tracker returns the tracker that records which ranges have been written.
+func (a *Assembler) Tracker() *Tracker {
+	return a.tracker
+}
+
+// Complete reports whether all of the content has been written.
+func (a *Assembler) Complete() bool {
+	select {
+	case <-a.tracker.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// Missing returns the ranges that haven't been written yet.
+func (a *Assembler) Missing() []Range {
+	return a.tracker.Missing()
+}
+
+// Flush commits the content written so far to stable storage, if the
+// destination has a Sync method, as an *os.File does.
+func (a *Assembler) Flush() error {
+	if s, ok := a.dst.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package ranger
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingWriterAt counts the bytes written to a memFile.
+type countingWriterAt struct {
+	memFile
+	n int64
+}
+
+func (c *countingWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	atomic.AddInt64(&c.n, int64(len(p)))
+	return c.memFile.WriteAt(p, off)
+}
+
+func TestAssembler(t *testing.T) {
+	content := "0123456789"
+	dst := &countingWriterAt{memFile: memFile(strings.Repeat(".", 10))}
+	a := NewAssembler(dst, 10)
+	for _, r := range []Range{{Start: 5, Stop: 9}, {Start: 0, Stop: 2}, {Start: 1, Stop: 6}} {
+		if err := a.Write(r, []byte(content[r.Start:r.Stop+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := a.Missing(), []Range(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("bad missing ranges: got %+v, want %+v", got, want)
+	}
+	if !a.Complete() {
+		t.Error("not complete")
+	}
+	if got, want := string(dst.memFile), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := dst.n, int64(10); got != want {
+		t.Errorf("overlaps written again: got %d bytes written, want %d", got, want)
+	}
+}
+
+func TestAssemblerConcurrent(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	path := filepath.Join(t.TempDir(), "assembled")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a := NewAssembler(f, int64(len(content)))
+	var ranges []Range
+	for i := 0; i < 200; i++ {
+		start := rand.Int63n(int64(len(content)))
+		ranges = append(ranges, Range{Start: start, Stop: min(start+rand.Int63n(50), int64(len(content))-1)})
+	}
+	ranges = append(ranges, Chunks(int64(len(content)), 64)...)
+	var wg sync.WaitGroup
+	for _, r := range ranges {
+		wg.Add(1)
+		go func(r Range) {
+			defer wg.Done()
+			if err := a.Write(r, []byte(content[r.Start:r.Stop+1])); err != nil {
+				t.Error(err)
+			}
+		}(r)
+	}
+	wg.Wait()
+	if !a.Complete() {
+		t.Fatalf("not complete: missing %+v", a.Missing())
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
+
+func TestAssemblerErrors(t *testing.T) {
+	a := NewAssembler(memFile(make([]byte, 10)), 10)
+	if err := a.Write(Range{Start: 0, Stop: 4}, []byte("0123")); err == nil {
+		t.Error("short data accepted")
+	}
+	if err := a.Write(Range{Start: 8, Stop: 10}, []byte("890")); !errors.Is(err, ErrUnsatisfiable) {
+		t.Errorf("bad error: got %v, want %v", err, ErrUnsatisfiable)
+	}
+}
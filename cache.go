@@ -0,0 +1,394 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxObjectSize is the size of the largest object a CachingProxy keeps,
+// if its MaxObjectSize is zero.
+const DefaultMaxObjectSize = 64 << 20
+
+// DefaultMaxBytes is the most bytes a CachingProxy keeps of all of its objects
+// together, if its MaxBytes is zero.
+const DefaultMaxBytes = 256 << 20
+
+// DefaultMaxObjects is the most objects a CachingProxy keeps, if its
+// MaxObjects is zero.
+const DefaultMaxObjects = 1024
+
+// cacheChunkSize is the size of the chunks a CachingProxy keeps an object in.
+// Each is allocated when the first of its bytes is fetched, so an object
+// that's only read in part takes only as much memory as the chunks read.
+const cacheChunkSize = 1 << 20
+
+// CachingProxy is an http.Handler that proxies GET and HEAD requests to an
+// upstream server, keeping the byte ranges it fetches in memory. It answers
+// Range requests from the ranges it has, and fetches only the missing ones
+// from upstream, with a ranged GET for each gap.
+//
+// Objects are keyed by URL alone, including the query. Each is kept with the
+// validators, ETag and Last-Modified, that upstream gave for it when it was
+// first requested, but they're no part of the key: an object whose
+// validators change upstream replaces the one kept for its URL. Since clients
+// choose the URLs, what's kept is bounded by MaxBytes and MaxObjects, and once
+// either is exceeded, the objects used least recently are evicted.
+//
+// Kept ranges are served without asking upstream. Every upstream request,
+// for a range not kept, is conditional on the validators, with If-Range, so
+// if an object has changed, what's been kept of it is discarded, and it's
+// fetched afresh. An object whose ranges are all kept isn't revalidated until
+// it's evicted, or, with MaxAge, until it's older than that, when upstream is
+// probed for it again.
+//
+// Conditional requests are evaluated against the kept validators before
+// anything is fetched, so a request answered with a 304 or 412 fetches no
+// bytes of the object, and only the ranges that will be served are fetched.
+//
+// Upstream must support byte ranges. Requests with other methods get a 405.
+type CachingProxy struct {
+	// Client makes the upstream requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Upstream is the base URL of the upstream server. The path and query of
+	// each request are appended to it.
+	Upstream string
+
+	// MaxObjectSize is the size of the largest object to keep. Larger objects
+	// are proxied without being kept. If zero, DefaultMaxObjectSize is used.
+	MaxObjectSize int64
+
+	// MaxBytes is the most bytes to keep of all objects together. If zero,
+	// DefaultMaxBytes is used. Objects larger than it aren't kept.
+	MaxBytes int64
+
+	// MaxObjects is the most objects to keep. If zero, DefaultMaxObjects is
+	// used.
+	MaxObjects int
+
+	// MaxAge, if set, is how long an object is kept before upstream is probed
+	// for it again, and what's been kept of it is discarded, so that a change
+	// upstream is noticed even if every range asked for is kept.
+	MaxAge time.Duration
+
+	// NewCache, if set, returns a PartialCache for each object, which keeps
+	// what's fetched of it within the cache's budget, rather than keeping all
+	// of it. MaxObjectSize is then ignored, so objects of any size are kept
+	// in part. The bytes each cache holds count against MaxBytes.
+	NewCache func() *PartialCache
+
+	// Options configure how the responses are served.
+	Options []ServeOption
+
+	mu      sync.Mutex
+	objects map[string]*cachedObject
+	clock   uint64
+}
+
+// cachedObject is what a CachingProxy has kept of an upstream object.
+type cachedObject struct {
+	hr     *HTTPReader
+	probed time.Time
+
+	cache *PartialCache // if set, chunks are unused
+	keep  bool          // whether the object is small enough to keep in chunks
+
+	lastUsed uint64 // guarded by the CachingProxy's mu
+	kept     int64  // bytes allocated to chunks, updated atomically
+
+	mu     sync.Mutex
+	chunks map[int64][]byte // by index
+	have   RangeSet
+}
+
+func (p *CachingProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	url := p.Upstream + r.URL.RequestURI()
+	o, err := p.object(r.Context(), url)
+	if err == nil && r.Method == http.MethodGet {
+		err = o.fill(r.Context(), o.wanted(r, p.config(o)))
+		if errors.Is(err, ErrResourceChanged) {
+			p.forget(url, o)
+			if o, err = p.object(r.Context(), url); err == nil {
+				err = o.fill(r.Context(), o.wanted(r, p.config(o)))
+			}
+		}
+	}
+	if err != nil {
+		proxyError(w, err)
+		return
+	}
+	p.mu.Lock()
+	p.evict()
+	p.mu.Unlock()
+	_ = serve(w, r, readerAtContent{objectReader{ctx: r.Context(), o: o}, o.hr.Size()}, p.config(o))
+}
+
+// config returns the serveConfig to serve o with: p's Options, with the
+// validators and content type upstream gave for o.
+func (p *CachingProxy) config(o *cachedObject) *serveConfig {
+	cfg := newServeConfig(p.Options)
+	if cfg.contentType == "" {
+		cfg.contentType = o.hr.contentType
+	}
+	if cfg.contentType == "" {
+		cfg.contentType = "application/octet-stream"
+	}
+	cfg.etag = o.hr.validators.etag
+	cfg.modtime = o.hr.ModTime()
+	return cfg
+}
+
+// object returns the object at url, probing upstream for it with ctx if it's
+// not kept, or was probed more than MaxAge ago.
+func (p *CachingProxy) object(ctx context.Context, url string) (*cachedObject, error) {
+	p.mu.Lock()
+	o := p.objects[url]
+	if o != nil && p.MaxAge > 0 && time.Since(o.probed) >= p.MaxAge {
+		delete(p.objects, url)
+		o = nil
+	}
+	if o != nil {
+		p.touch(o)
+	}
+	p.mu.Unlock()
+	if o != nil {
+		return o, nil
+	}
+	hr, err := NewHTTPReaderContext(ctx, p.Client, url)
+	if err != nil {
+		return nil, err
+	}
+	o = &cachedObject{hr: hr, probed: time.Now()}
+	maxSize := p.MaxObjectSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxObjectSize
+	}
+	if p.NewCache != nil {
+		o.cache = p.NewCache()
+	} else if hr.Size() <= min(maxSize, p.maxBytes()) {
+		o.keep = true
+		o.chunks = make(map[int64][]byte)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if kept := p.objects[url]; kept != nil {
+		p.touch(kept)
+		return kept, nil
+	}
+	if p.objects == nil {
+		p.objects = make(map[string]*cachedObject)
+	}
+	p.objects[url] = o
+	p.touch(o)
+	p.evict()
+	return o, nil
+}
+
+// touch marks o as the object used most recently. p.mu must be held.
+func (p *CachingProxy) touch(o *cachedObject) {
+	p.clock++
+	o.lastUsed = p.clock
+}
+
+// evict evicts the objects used least recently, until those kept are within
+// MaxBytes and MaxObjects. Requests already being served from an evicted
+// object carry on with it. p.mu must be held.
+func (p *CachingProxy) evict() {
+	maxObjects := p.MaxObjects
+	if maxObjects == 0 {
+		maxObjects = DefaultMaxObjects
+	}
+	total := int64(0)
+	for _, o := range p.objects {
+		total += o.size()
+	}
+	for len(p.objects) > 0 && (len(p.objects) > maxObjects || total > p.maxBytes()) {
+		var oldestURL string
+		var oldest *cachedObject
+		for url, o := range p.objects {
+			if oldest == nil || o.lastUsed < oldest.lastUsed {
+				oldestURL, oldest = url, o
+			}
+		}
+		total -= oldest.size()
+		delete(p.objects, oldestURL)
+	}
+}
+
+func (p *CachingProxy) maxBytes() int64 {
+	if p.MaxBytes == 0 {
+		return DefaultMaxBytes
+	}
+	return p.MaxBytes
+}
+
+// size returns the number of bytes kept of o.
+func (o *cachedObject) size() int64 {
+	if o.cache != nil {
+		return o.cache.Len()
+	}
+	return atomic.LoadInt64(&o.kept)
+}
+
+// forget discards o, if it's still what's kept for url.
+func (p *CachingProxy) forget(url string, o *cachedObject) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.objects[url] == o {
+		delete(p.objects, url)
+	}
+}
+
+// wanted returns the ranges of o that r will be served with cfg, as serve
+// negotiates them: the ones it asks for, all of o if it gets a 200, or none
+// if its preconditions or ranges mean it gets none of o.
+func (o *cachedObject) wanted(r *http.Request, cfg *serveConfig) []Range {
+	size := o.hr.Size()
+	if size == 0 {
+		return nil
+	}
+	switch d := negotiate(r, size, cfg); d.Status {
+	case http.StatusOK:
+		return []Range{{Start: 0, Stop: size - 1}}
+	case http.StatusPartialContent:
+		return d.Ranges
+	}
+	return nil
+}
+
+// fill fetches the parts of ranges that o doesn't have yet from upstream.
+// Fetches for an object are serialized, so each part is fetched once.
+func (o *cachedObject) fill(ctx context.Context, ranges []Range) error {
+	if o.cache != nil {
+		return o.fillCache(ctx, ranges)
+	}
+	if !o.keep {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, r := range NewRangeSet(ranges...).Subtract(o.have).Ranges() {
+		b, err := o.hr.fetch(ctx, r)
+		if err != nil {
+			return err
+		}
+		o.store(b, r.Start)
+		o.have = o.have.Union(NewRangeSet(r))
+	}
+	return nil
+}
+
+// store copies b into o's chunks, at offset off in the object, allocating
+// the chunks it falls in that haven't been. o.mu must be held.
+func (o *cachedObject) store(b []byte, off int64) {
+	for len(b) > 0 {
+		i := off / cacheChunkSize
+		chunk := o.chunks[i]
+		if chunk == nil {
+			chunk = make([]byte, min(cacheChunkSize, o.hr.Size()-i*cacheChunkSize))
+			o.chunks[i] = chunk
+			atomic.AddInt64(&o.kept, int64(len(chunk)))
+		}
+		n := copy(chunk[off-i*cacheChunkSize:], b)
+		b, off = b[n:], off+int64(n)
+	}
+}
+
+// load copies the bytes at offset off in the object from o's chunks into p.
+// They must have been stored already. o.mu must be held.
+func (o *cachedObject) load(p []byte, off int64) int {
+	n := 0
+	for n < len(p) {
+		i := (off + int64(n)) / cacheChunkSize
+		n += copy(p[n:], o.chunks[i][off+int64(n)-i*cacheChunkSize:])
+	}
+	return n
+}
+
+// fillCache fetches the parts of ranges that o's cache doesn't have from
+// upstream, and puts them in it.
+func (o *cachedObject) fillCache(ctx context.Context, ranges []Range) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, r := range NewRangeSet(ranges...).Subtract(o.cache.Have()).Ranges() {
+		b, err := o.hr.fetch(ctx, r)
+		if err != nil {
+			return err
+		}
+		o.cache.Put(r, b)
+	}
+	return nil
+}
+
+// readAt reads from what's kept of o, fetching any missing part from
+// upstream with ctx. Objects too large to keep are read from upstream
+// directly. Parts missing from o's cache, such as those it has evicted, are
+// fetched again and put back.
+func (o *cachedObject) readAt(ctx context.Context, p []byte, off int64) (int, error) {
+	if !o.keep && o.cache == nil {
+		return o.hr.ReadAtContext(ctx, p, off)
+	}
+	size := o.hr.Size()
+	if off < 0 || off >= size {
+		return 0, io.EOF
+	}
+	r := Range{Start: off, Stop: min(off+int64(len(p)), size) - 1}
+	var n int
+	if o.cache != nil {
+		var err error
+		if n, err = o.cache.ReadAt(p[:r.Len()], off); err != nil {
+			b, err := o.hr.fetch(ctx, r)
+			if err != nil {
+				return 0, err
+			}
+			o.cache.Put(r, b)
+			n = copy(p, b)
+		}
+	} else {
+		if err := o.fill(ctx, []Range{r}); err != nil {
+			return 0, err
+		}
+		o.mu.Lock()
+		n = o.load(p[:r.Len()], off)
+		o.mu.Unlock()
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// objectReader reads an object for a single request, so that what it fetches
+// from upstream is abandoned if the request is.
+type objectReader struct {
+	ctx context.Context
+	o   *cachedObject
+}
+
+func (r objectReader) ReadAt(p []byte, off int64) (int, error) {
+	return r.o.readAt(r.ctx, p, off)
+}
+
+// proxyError replies with the status upstream gave for a missing or forbidden
+// object, or else a 502.
+func proxyError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusNotFound, http.StatusGone, http.StatusUnauthorized, http.StatusForbidden:
+			status = se.StatusCode
+		}
+	}
+	http.Error(w, http.StatusText(status), status)
+}
@@ -0,0 +1,247 @@
+package ranger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type cachingProxyTest struct {
+	Range            string
+	ExpectedStatus   int
+	ExpectedBody     string
+	ExpectedUpstream []string
+}
+
+func TestCachingProxy(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	rr := &rangeRecorder{h: Handler(strings.NewReader(content), int64(len(content)))}
+	srv := httptest.NewServer(rr)
+	defer srv.Close()
+	proxy := &CachingProxy{Client: srv.Client(), Upstream: srv.URL}
+	tests := []cachingProxyTest{
+		{ // first request probes, then fetches the range
+			Range:            "bytes=10-19",
+			ExpectedStatus:   http.StatusPartialContent,
+			ExpectedBody:     content[10:20],
+			ExpectedUpstream: []string{"bytes=0-0", "bytes=10-19"},
+		},
+		{ // only the missing part is fetched
+			Range:            "bytes=15-24",
+			ExpectedStatus:   http.StatusPartialContent,
+			ExpectedBody:     content[15:25],
+			ExpectedUpstream: []string{"bytes=20-24"},
+		},
+		{ // all cached
+			Range:          "bytes=10-24",
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   content[10:25],
+		},
+		{ // all of it fetches the gaps
+			ExpectedStatus:   http.StatusOK,
+			ExpectedBody:     content,
+			ExpectedUpstream: []string{"bytes=0-9", "bytes=25-99"},
+		},
+		{ // unsatisfiable
+			Range:          "bytes=100-",
+			ExpectedStatus: http.StatusRequestedRangeNotSatisfiable,
+			ExpectedBody:   "",
+		},
+	}
+	for i, test := range tests {
+		rr.ranges = nil
+		req := httptest.NewRequest("GET", "/file", nil)
+		if test.Range != "" {
+			req.Header.Set("Range", test.Range)
+		}
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		if got, want := w.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := w.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+		if got, want := rr.ranges, test.ExpectedUpstream; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad upstream requests: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+type cachingProxyConditionalTest struct {
+	Header           http.Header
+	ExpectedStatus   int
+	ExpectedUpstream []string
+}
+
+func TestCachingProxyConditional(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	rr := &rangeRecorder{h: Handler(strings.NewReader(content), int64(len(content)), WithETag(`"v1"`))}
+	srv := httptest.NewServer(rr)
+	defer srv.Close()
+	proxy := &CachingProxy{Client: srv.Client(), Upstream: srv.URL}
+	tests := []cachingProxyConditionalTest{
+		{ // not modified, so nothing but the probe
+			Header:           http.Header{"If-None-Match": {`"v1"`}},
+			ExpectedStatus:   http.StatusNotModified,
+			ExpectedUpstream: []string{"bytes=0-0"},
+		},
+		{ // precondition failed, so nothing
+			Header:         http.Header{"Range": {"bytes=10-19"}, "If-Match": {`"v0"`}},
+			ExpectedStatus: http.StatusPreconditionFailed,
+		},
+		{ // modified, so only the range served
+			Header:           http.Header{"Range": {"bytes=10-19"}, "If-None-Match": {`"v0"`}},
+			ExpectedStatus:   http.StatusPartialContent,
+			ExpectedUpstream: []string{"bytes=10-19"},
+		},
+		{ // If-Range doesn't match, so all of it
+			Header:           http.Header{"Range": {"bytes=10-19"}, "If-Range": {`"v0"`}},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedUpstream: []string{"bytes=0-9", "bytes=20-99"},
+		},
+	}
+	for i, test := range tests {
+		rr.ranges = nil
+		req := httptest.NewRequest("GET", "/file", nil)
+		req.Header = test.Header
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		if got, want := w.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := rr.ranges, test.ExpectedUpstream; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad upstream requests: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestCachingProxyResourceChanged(t *testing.T) {
+	cs := &changingServer{useETag: true}
+	srv := httptest.NewServer(cs)
+	defer srv.Close()
+	proxy := &CachingProxy{Client: srv.Client(), Upstream: srv.URL}
+	get := func(rng string) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Range", rng)
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	if got, want := get("bytes=0-4"), "00000"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	cs.change()
+	if got, want := get("bytes=3-7"), "11111"; got != want {
+		t.Errorf("bad body after change: got %q, want %q", got, want)
+	}
+}
+
+func TestCachingProxyErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	proxy := &CachingProxy{Client: srv.Client(), Upstream: srv.URL}
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if got, want := w.Code, http.StatusNotFound; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/missing", nil))
+	if got, want := w.Code, http.StatusMethodNotAllowed; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+}
+
+func TestCachingProxyCanceled(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	rr := &rangeRecorder{h: Handler(strings.NewReader(content), int64(len(content)))}
+	srv := httptest.NewServer(rr)
+	defer srv.Close()
+	proxy := &CachingProxy{Client: srv.Client(), Upstream: srv.URL}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/file", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if got, want := w.Code, http.StatusBadGateway; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if len(rr.ranges) != 0 {
+		t.Errorf("bad upstream requests: got %v, want none", rr.ranges)
+	}
+}
+
+func TestCachingProxyEviction(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	rr := &rangeRecorder{h: Handler(strings.NewReader(content), int64(len(content)))}
+	srv := httptest.NewServer(rr)
+	defer srv.Close()
+	get := func(proxy *CachingProxy, target string) {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Range", "bytes=0-9")
+		proxy.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	proxy := &CachingProxy{Client: srv.Client(), Upstream: srv.URL, MaxObjects: 2}
+	for _, target := range []string{"/file?a", "/file?b", "/file?c"} {
+		get(proxy, target)
+	}
+	if got, want := len(proxy.objects), 2; got != want {
+		t.Errorf("bad number of objects: got %d, want %d", got, want)
+	}
+	rr.ranges = nil
+	get(proxy, "/file?a")
+	if got, want := rr.ranges, []string{"bytes=0-0", "bytes=0-9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad upstream requests for an evicted object: got %q, want %q", got, want)
+	}
+	rr.ranges = nil
+	get(proxy, "/file?c")
+	if len(rr.ranges) != 0 {
+		t.Errorf("bad upstream requests for a kept object: got %q, want none", rr.ranges)
+	}
+
+	proxy = &CachingProxy{Client: srv.Client(), Upstream: srv.URL, MaxBytes: 150}
+	get(proxy, "/file?a")
+	get(proxy, "/file?b")
+	if _, ok := proxy.objects[srv.URL+"/file?a"]; ok || len(proxy.objects) != 1 {
+		t.Errorf("bad objects: got %d, want only the last", len(proxy.objects))
+	}
+}
+
+func TestCachingProxyChunks(t *testing.T) {
+	content := strings.Repeat("0123456789", 3*cacheChunkSize/10)
+	srv := httptest.NewServer(Handler(strings.NewReader(content), int64(len(content))))
+	defer srv.Close()
+	proxy := &CachingProxy{Client: srv.Client(), Upstream: srv.URL}
+	req := httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=1048570-1048579")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if got, want := w.Body.String(), content[1048570:1048580]; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if got, want := proxy.objects[srv.URL+"/file"].size(), int64(2*cacheChunkSize); got != want {
+		t.Errorf("bad size kept: got %d, want %d", got, want)
+	}
+}
+
+func TestCachingProxyMaxAge(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	rr := &rangeRecorder{h: Handler(strings.NewReader(content), int64(len(content)))}
+	srv := httptest.NewServer(rr)
+	defer srv.Close()
+	proxy := &CachingProxy{Client: srv.Client(), Upstream: srv.URL, MaxAge: time.Nanosecond}
+	for i := 0; i < 2; i++ {
+		rr.ranges = nil
+		req := httptest.NewRequest("GET", "/file", nil)
+		req.Header.Set("Range", "bytes=0-9")
+		proxy.ServeHTTP(httptest.NewRecorder(), req)
+		if got, want := rr.ranges, []string{"bytes=0-0", "bytes=0-9"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("request %d: bad upstream requests: got %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package ranger
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Chunks splits a resource of maxLen bytes into contiguous ranges of
+// chunkSize bytes, covering it from start to finish. The final range holds
+// whatever remains, and is never empty.
+//
+// If maxLen or chunkSize are not positive, Chunks returns nil.
+func Chunks(maxLen, chunkSize int64) []Range {
+	if maxLen <= 0 || chunkSize <= 0 {
+		return nil
+	}
+	result := make([]Range, 0, (maxLen+chunkSize-1)/chunkSize)
+	for start := int64(0); start < maxLen; start += chunkSize {
+		stop := start + chunkSize - 1
+		if stop >= maxLen {
+			stop = maxLen - 1
+		}
+		result = append(result, Range{Start: start, Stop: stop})
+	}
+	return result
+}
+
+// Chunks splits r into contiguous ranges of chunkSize bytes, from r.Start
+// onwards, just as Chunks splits a whole resource. The final range holds
+// whatever remains.
+//
+// If r is reversed or chunkSize is not positive, Chunks returns nil.
+func (r Range) Chunks(chunkSize int64) []Range {
+	chunks := Chunks(r.Len(), chunkSize)
+	for i := range chunks {
+		chunks[i] = chunks[i].Shift(r.Start)
+	}
+	return chunks
+}
+
+// SplitN splits r into n contiguous ranges, as nearly equal in length as they
+// can be: the first few are a byte longer than the rest if r doesn't divide
+// evenly. If r has fewer than n bytes, it's split into ranges of one byte
+// each. To split a whole resource of length bytes, use
+// Range{Start: 0, Stop: length - 1}.SplitN(n).
+//
+// If r is reversed or n is not positive, SplitN returns nil.
+func (r Range) SplitN(n int) []Range {
+	length := r.Len()
+	if length == 0 || n <= 0 {
+		return nil
+	}
+	pieces := min(int64(n), length)
+	size, extra := length/pieces, length%pieces
+	result := make([]Range, 0, pieces)
+	start := r.Start
+	for i := int64(0); i < pieces; i++ {
+		stop := start + size - 1
+		if i < extra {
+			stop++
+		}
+		result = append(result, Range{Start: start, Stop: stop})
+		start = stop + 1
+	}
+	return result
+}
+
+// ChunksHeader is like Chunks, but takes the length of the resource from the
+// Content-Length field of a response header, such as one returned for a HEAD
+// request.
+//
+// If the header doesn't advertise support for range requests, ErrNotSupported
+// is returned; see AcceptsRanges. If it has no valid Content-Length, Error is
+// returned.
+func ChunksHeader(h http.Header, chunkSize int64) ([]Range, error) {
+	if !AcceptsRanges(h) {
+		return nil, ErrNotSupported
+	}
+	maxLen, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	if err != nil || maxLen < 0 {
+		return nil, Error
+	}
+	return Chunks(maxLen, chunkSize), nil
+}
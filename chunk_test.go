@@ -0,0 +1,216 @@
+package ranger
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type chunksTest struct {
+	MaxLen         int64
+	ChunkSize      int64
+	ExpectedRanges []Range
+}
+
+func TestChunks(t *testing.T) {
+	tests := []chunksTest{
+		{ // final chunk is the remainder
+			MaxLen:    250,
+			ChunkSize: 100,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 99},
+				{Start: 100, Stop: 199},
+				{Start: 200, Stop: 249},
+			},
+		},
+		{ // exact multiple has no empty trailing chunk
+			MaxLen:    200,
+			ChunkSize: 100,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 99},
+				{Start: 100, Stop: 199},
+			},
+		},
+		{ // chunk size larger than content
+			MaxLen:    50,
+			ChunkSize: 100,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 49},
+			},
+		},
+		{ // chunk size equal to content
+			MaxLen:    100,
+			ChunkSize: 100,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 99},
+			},
+		},
+		{ // zero chunk size
+			MaxLen:    100,
+			ChunkSize: 0,
+		},
+		{ // negative chunk size
+			MaxLen:    100,
+			ChunkSize: -1,
+		},
+		{ // empty content
+			MaxLen:    0,
+			ChunkSize: 100,
+		},
+	}
+	for i, test := range tests {
+		if got, want := Chunks(test.MaxLen, test.ChunkSize), test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type chunksHeaderTest struct {
+	Header         http.Header
+	ChunkSize      int64
+	ExpectedRanges []Range
+	ExpectedError  string
+}
+
+func TestChunksHeader(t *testing.T) {
+	tests := []chunksHeaderTest{
+		{ // ranges accepted
+			Header: http.Header{
+				"Accept-Ranges":  {"bytes"},
+				"Content-Length": {"150"},
+			},
+			ChunkSize: 100,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 99},
+				{Start: 100, Stop: 149},
+			},
+			ExpectedError: "<nil>",
+		},
+		{ // ranges refused
+			Header: http.Header{
+				"Accept-Ranges":  {"none"},
+				"Content-Length": {"150"},
+			},
+			ChunkSize:     100,
+			ExpectedError: "ranger: ranges not supported",
+		},
+		{ // ranges not advertised
+			Header: http.Header{
+				"Content-Length": {"150"},
+			},
+			ChunkSize:     100,
+			ExpectedError: "ranger: ranges not supported",
+		},
+		{ // no content length
+			Header: http.Header{
+				"Accept-Ranges": {"bytes"},
+			},
+			ChunkSize:     100,
+			ExpectedError: "invalid range",
+		},
+	}
+	for i, test := range tests {
+		ranges, err := ChunksHeader(test.Header, test.ChunkSize)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type rangeChunksTest struct {
+	Range          Range
+	ChunkSize      int64
+	ExpectedRanges []Range
+}
+
+func TestRangeChunks(t *testing.T) {
+	tests := []rangeChunksTest{
+		{ // final chunk is the remainder
+			Range:     Range{Start: 100, Stop: 349},
+			ChunkSize: 100,
+			ExpectedRanges: []Range{
+				{Start: 100, Stop: 199},
+				{Start: 200, Stop: 299},
+				{Start: 300, Stop: 349},
+			},
+		},
+		{ // single byte
+			Range:          Range{Start: 7, Stop: 7},
+			ChunkSize:      100,
+			ExpectedRanges: []Range{{Start: 7, Stop: 7}},
+		},
+		{ // reversed
+			Range:     Range{Start: 7, Stop: 6},
+			ChunkSize: 100,
+		},
+		{ // zero chunk size
+			Range:     Range{Start: 0, Stop: 99},
+			ChunkSize: 0,
+		},
+	}
+	for i, test := range tests {
+		if got, want := test.Range.Chunks(test.ChunkSize), test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type splitNTest struct {
+	Range          Range
+	N              int
+	ExpectedRanges []Range
+}
+
+func TestRangeSplitN(t *testing.T) {
+	tests := []splitNTest{
+		{ // divides evenly
+			Range: Range{Start: 0, Stop: 99},
+			N:     4,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 24},
+				{Start: 25, Stop: 49},
+				{Start: 50, Stop: 74},
+				{Start: 75, Stop: 99},
+			},
+		},
+		{ // remainder goes to the first pieces
+			Range: Range{Start: 10, Stop: 19},
+			N:     3,
+			ExpectedRanges: []Range{
+				{Start: 10, Stop: 13},
+				{Start: 14, Stop: 16},
+				{Start: 17, Stop: 19},
+			},
+		},
+		{ // fewer bytes than pieces
+			Range: Range{Start: 0, Stop: 1},
+			N:     5,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 0},
+				{Start: 1, Stop: 1},
+			},
+		},
+		{ // one piece
+			Range:          Range{Start: 5, Stop: 9},
+			N:              1,
+			ExpectedRanges: []Range{{Start: 5, Stop: 9}},
+		},
+		{ // zero pieces
+			Range: Range{Start: 0, Stop: 9},
+			N:     0,
+		},
+		{ // reversed
+			Range: Range{Start: 9, Stop: 0},
+			N:     2,
+		},
+	}
+	for i, test := range tests {
+		if got, want := test.Range.SplitN(test.N), test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
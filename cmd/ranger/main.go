@@ -0,0 +1,225 @@
+// Command ranger downloads files with parallel, resumable range requests.
+//
+// Usage:
+//
+//	ranger get [flags] URL
+//
+// The file is fetched in chunks, with several requests at once, and saved to
+// the file named by -o, or else by the last element of the URL's path. If the
+// download is interrupted, running the same command again resumes it, as long
+// as the file hasn't changed on the server. With -ranges, only the given byte
+// ranges are fetched, such as '0-99,-500', and written at their offsets in the
+// file, leaving the rest of it empty; running the same command again resumes
+// that too.
+//
+// The flags are:
+//
+//	-o file
+//		the file to save to
+//	-c n
+//		how many requests to make at once (default 4)
+//	-chunk bytes
+//		how many bytes to fetch with each request (default 1MB)
+//	-retries n
+//		how many more times to try a chunk when a request for it fails
+//		(default 3)
+//	-ranges list
+//		the byte ranges to fetch, instead of the whole file
+//	-sha256 hex
+//		the SHA-256 checksum the downloaded file must have
+//	-q
+//		don't display progress
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/echlebek/ranger"
+)
+
+const usage = "usage: ranger get [flags] URL"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err := run(ctx, os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "ranger:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command with the given arguments, writing progress and usage
+// messages to stderr.
+func run(ctx context.Context, args []string, stderr io.Writer) error {
+	if len(args) == 0 || args[0] != "get" {
+		fmt.Fprintln(stderr, usage)
+		return flag.ErrHelp
+	}
+	return get(ctx, args[1:], stderr)
+}
+
+func get(ctx context.Context, args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, usage)
+		flags.PrintDefaults()
+	}
+	out := flags.String("o", "", "the `file` to save to")
+	workers := flags.Int("c", 4, "how many requests to make at once")
+	chunkSize := flags.Int64("chunk", 1<<20, "how many `bytes` to fetch with each request")
+	retries := flags.Int("retries", 3, "how many more times to try a chunk when a request for it fails")
+	ranges := flags.String("ranges", "", "the `list` of byte ranges to fetch, instead of the whole file")
+	sum := flags.String("sha256", "", "the SHA-256 checksum, in `hex`, the downloaded file must have")
+	quiet := flags.Bool("q", false, "don't display progress")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	rawURL := flags.Arg(0)
+	if *out == "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		*out = path.Base(u.Path)
+		if *out == "/" || *out == "." {
+			return errors.New("can't name the file after the URL; use -o")
+		}
+	}
+	if *sum != "" && *ranges != "" {
+		return errors.New("-sha256 can't be used with -ranges")
+	}
+
+	d := &ranger.Downloader{
+		ChunkSize: *chunkSize,
+		Workers:   *workers,
+		Retry:     ranger.RetryPolicy{Attempts: *retries + 1, Backoff: time.Second},
+	}
+	var p *progressLine
+	if !*quiet {
+		p = &progressLine{w: stderr}
+		d.OnProgress = p.show
+	}
+	var err error
+	if *ranges == "" {
+		err = d.DownloadFile(ctx, rawURL, *out)
+	} else {
+		err = getRanges(ctx, d, rawURL, *out, *ranges)
+	}
+	if p != nil {
+		p.done()
+	}
+	if err != nil {
+		return err
+	}
+	if *sum != "" {
+		return verify(*out, *sum)
+	}
+	return nil
+}
+
+// getRanges fetches just the given ranges of the resource at rawURL, and writes
+// them at their offsets in the file at name, resuming where a previous run
+// for the same ranges left off.
+func getRanges(ctx context.Context, d *ranger.Downloader, rawURL, name, spec string) error {
+	specs, err := ranger.ParseSpecs([]string{spec}, "")
+	if err != nil {
+		return fmt.Errorf("bad -ranges: %w", err)
+	}
+	err = d.DownloadFileRanges(ctx, rawURL, name, specs)
+	var se *ranger.SpecError
+	if errors.As(err, &se) {
+		return fmt.Errorf("bad -ranges: %w", err)
+	}
+	return err
+}
+
+// verify checks that the file at name has the SHA-256 checksum want.
+func verify(name, want string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%s: bad checksum: got %s, want %s", name, got, want)
+	}
+	return nil
+}
+
+// progressLine displays the progress of a download on a single line, redrawn
+// at most a few times a second.
+type progressLine struct {
+	w io.Writer
+
+	mu    sync.Mutex
+	last  time.Time
+	shown bool
+}
+
+func (p *progressLine) show(pr ranger.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now := time.Now(); now.Sub(p.last) >= 200*time.Millisecond || pr.Completed == pr.Total {
+		p.last = now
+	} else {
+		return
+	}
+	percent := 100.0
+	if pr.Total > 0 {
+		percent = 100 * float64(pr.Completed) / float64(pr.Total)
+	}
+	line := fmt.Sprintf("%s / %s (%.0f%%) %s/s", formatBytes(float64(pr.Completed)), formatBytes(float64(pr.Total)), percent, formatBytes(pr.Rate))
+	if pr.ETA > 0 {
+		line += " ETA " + pr.ETA.Round(time.Second).String()
+	}
+	fmt.Fprintf(p.w, "\r%-60s", line)
+	p.shown = true
+}
+
+// done ends the progress line, if anything was shown.
+func (p *progressLine) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.shown {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// formatBytes formats a number of bytes with a binary unit, such as '1.5 MiB'.
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
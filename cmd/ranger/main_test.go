@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/echlebek/ranger"
+)
+
+type getTest struct {
+	Args            []string
+	ExpectedContent string
+	ExpectedError   string
+}
+
+func TestGet(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Etag", `"v1"`)
+		ranger.ServeRanges(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+	sum := sha256.Sum256([]byte(content))
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	tests := []getTest{
+		{ // whole file
+			Args:            []string{"get", "-q", "-c", "3", "-chunk", "1000", "-o", out, srv.URL + "/file"},
+			ExpectedContent: content,
+			ExpectedError:   "<nil>",
+		},
+		{ // checksum
+			Args:            []string{"get", "-q", "-o", out, "-sha256", hex.EncodeToString(sum[:]), srv.URL + "/file"},
+			ExpectedContent: content,
+			ExpectedError:   "<nil>",
+		},
+		{ // bad checksum
+			Args:            []string{"get", "-q", "-o", out, "-sha256", "00", srv.URL + "/file"},
+			ExpectedContent: content,
+			ExpectedError:   out + ": bad checksum: got " + hex.EncodeToString(sum[:]) + ", want 00",
+		},
+		{ // some ranges
+			Args:            []string{"get", "-q", "-o", out, "-ranges", "0-1,-2", srv.URL + "/file"},
+			ExpectedContent: "01" + strings.Repeat("\x00", 9996) + "89",
+			ExpectedError:   "<nil>",
+		},
+		{ // bad ranges
+			Args:          []string{"get", "-q", "-o", out, "-ranges", "x", srv.URL + "/file"},
+			ExpectedError: `bad -ranges: invalid range: malformed: range 0 "x": missing '-'`,
+		},
+		{ // ranges past the end
+			Args:          []string{"get", "-q", "-o", out, "-ranges", "20000-", srv.URL + "/file"},
+			ExpectedError: `bad -ranges: invalid range: unsatisfiable: range 0 "20000-": outside the content`,
+		},
+		{ // no URL
+			Args:          []string{"get", "-q"},
+			ExpectedError: "flag: help requested",
+		},
+		{ // no command
+			Args:          []string{"fetch", srv.URL},
+			ExpectedError: "flag: help requested",
+		},
+	}
+	for i, test := range tests {
+		os.Remove(out)
+		var stderr bytes.Buffer
+		err := run(context.Background(), test.Args, &stderr)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %s, want %s", i, got, want)
+		}
+		if test.ExpectedContent == "" {
+			continue
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if got, want := string(b), test.ExpectedContent; got != want {
+			t.Errorf("test %d: bad content: got %d bytes, want %d", i, len(got), len(want))
+		}
+	}
+}
+
+func TestGetProgress(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	srv := httptest.NewServer(ranger.Handler(strings.NewReader(content), int64(len(content))))
+	defer srv.Close()
+	dir := t.TempDir()
+	var stderr bytes.Buffer
+	if err := run(context.Background(), []string{"get", "-chunk", "100", "-o", filepath.Join(dir, "out"), srv.URL}, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stderr.String(), "1000 B / 1000 B (100%)"; !strings.Contains(got, want) {
+		t.Errorf("bad progress: got %q, want it to contain %q", got, want)
+	}
+}
+
+type formatBytesTest struct {
+	Bytes    float64
+	Expected string
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []formatBytesTest{
+		{ // bytes
+			Bytes:    1000,
+			Expected: "1000 B",
+		},
+		{ // kibibytes
+			Bytes:    1536,
+			Expected: "1.5 KiB",
+		},
+		{ // mebibytes
+			Bytes:    3 << 20,
+			Expected: "3.0 MiB",
+		},
+	}
+	for i, test := range tests {
+		if got := formatBytes(test.Bytes); got != test.Expected {
+			t.Errorf("test %d: bad format: got %q, want %q", i, got, test.Expected)
+		}
+	}
+}
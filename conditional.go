@@ -0,0 +1,93 @@
+package ranger
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CheckPreconditions evaluates the conditional headers of a request, other
+// than If-Range, against the current validators of the content, its ETag and
+// modification time, in the order RFC 7232 requires. It returns the status a
+// server should reply with instead of serving the content,
+// http.StatusNotModified or http.StatusPreconditionFailed, or 0 if the
+// request should be served. A Range header should only be honoured after
+// that, subject to EvaluateIfRange.
+//
+// If etag is empty, or modtime is the zero time, the content has no such
+// validator, and the fields that depend on it are evaluated accordingly.
+func CheckPreconditions(r *http.Request, etag string, modtime time.Time) int {
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" && !modtime.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && modtime.Truncate(time.Second).After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	getOrHead := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if matchETag(inm, etag, true) {
+			if getOrHead {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && getOrHead && !modtime.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !modtime.Truncate(time.Second).After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// matchETag reports whether etag matches any of the entity tags in list, the
+// value of an If-Match or If-None-Match field. '*' matches any current
+// representation, so it matches even content with no etag. With weak
+// comparison, as for If-None-Match, tags match if their opaque parts do;
+// otherwise, neither may be weak.
+func matchETag(list, etag string, weak bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	for list != "" {
+		list = strings.TrimLeft(list, " \t,")
+		if list == "" {
+			break
+		}
+		tag, rest, ok := scanETag(list)
+		if !ok {
+			return false
+		}
+		if weak && strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+		if !weak && tag == etag && !strings.HasPrefix(tag, "W/") {
+			return true
+		}
+		list = rest
+	}
+	return false
+}
+
+// scanETag scans the entity tag at the start of s, returning it and the rest
+// of s.
+func scanETag(s string) (tag, rest string, ok bool) {
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s) <= start || s[start] != '"' {
+		return "", "", false
+	}
+	end := strings.IndexByte(s[start+1:], '"')
+	if end < 0 {
+		return "", "", false
+	}
+	end += start + 2
+	return s[:end], s[end:], true
+}
@@ -0,0 +1,154 @@
+package ranger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type preconditionTest struct {
+	Method         string
+	Header         map[string]string
+	ETag           string
+	ExpectedStatus int
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	modtime := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	before := modtime.Add(-time.Hour).Format(http.TimeFormat)
+	at := modtime.Format(http.TimeFormat)
+	tests := []preconditionTest{
+		{ // no preconditions
+			ETag: `"a"`,
+		},
+		{ // If-Match matches
+			Header: map[string]string{"If-Match": `"b", "a"`},
+			ETag:   `"a"`,
+		},
+		{ // If-Match star
+			Header: map[string]string{"If-Match": `*`},
+			ETag:   `"a"`,
+		},
+		{ // If-Match doesn't match
+			Header:         map[string]string{"If-Match": `"b"`},
+			ETag:           `"a"`,
+			ExpectedStatus: http.StatusPreconditionFailed,
+		},
+		{ // If-Match needs a strong match
+			Header:         map[string]string{"If-Match": `W/"a"`},
+			ETag:           `W/"a"`,
+			ExpectedStatus: http.StatusPreconditionFailed,
+		},
+		{ // If-Match star without an etag
+			Header: map[string]string{"If-Match": `*`},
+		},
+		{ // If-Match without an etag
+			Header:         map[string]string{"If-Match": `"a"`},
+			ExpectedStatus: http.StatusPreconditionFailed,
+		},
+		{ // If-Unmodified-Since, modified since
+			Header:         map[string]string{"If-Unmodified-Since": before},
+			ExpectedStatus: http.StatusPreconditionFailed,
+		},
+		{ // If-Unmodified-Since, not modified since
+			Header: map[string]string{"If-Unmodified-Since": at},
+		},
+		{ // If-Match takes precedence over If-Unmodified-Since
+			Header: map[string]string{"If-Match": `"a"`, "If-Unmodified-Since": before},
+			ETag:   `"a"`,
+		},
+		{ // If-None-Match matches weakly
+			Header:         map[string]string{"If-None-Match": `W/"a"`},
+			ETag:           `"a"`,
+			ExpectedStatus: http.StatusNotModified,
+		},
+		{ // If-None-Match star
+			Header:         map[string]string{"If-None-Match": `*`},
+			ETag:           `"a"`,
+			ExpectedStatus: http.StatusNotModified,
+		},
+		{ // If-None-Match star without an etag
+			Header:         map[string]string{"If-None-Match": `*`},
+			ExpectedStatus: http.StatusNotModified,
+		},
+		{ // If-None-Match star without an etag, for a PUT
+			Method:         "PUT",
+			Header:         map[string]string{"If-None-Match": `*`},
+			ExpectedStatus: http.StatusPreconditionFailed,
+		},
+		{ // If-None-Match matches, for a PUT
+			Method:         "PUT",
+			Header:         map[string]string{"If-None-Match": `"a"`},
+			ETag:           `"a"`,
+			ExpectedStatus: http.StatusPreconditionFailed,
+		},
+		{ // If-None-Match doesn't match
+			Header: map[string]string{"If-None-Match": `"b"`, "If-Modified-Since": at},
+			ETag:   `"a"`,
+		},
+		{ // If-Modified-Since, not modified
+			Header:         map[string]string{"If-Modified-Since": at},
+			ExpectedStatus: http.StatusNotModified,
+		},
+		{ // If-Modified-Since, modified
+			Header: map[string]string{"If-Modified-Since": before},
+		},
+		{ // If-Modified-Since only applies to GET and HEAD
+			Method: "POST",
+			Header: map[string]string{"If-Modified-Since": at},
+		},
+	}
+	for i, test := range tests {
+		method := test.Method
+		if method == "" {
+			method = "GET"
+		}
+		req := httptest.NewRequest(method, "/", nil)
+		for k, v := range test.Header {
+			req.Header.Set(k, v)
+		}
+		if got, want := CheckPreconditions(req, test.ETag, modtime), test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestHandlerConditional(t *testing.T) {
+	modtime := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	h := Handler(strings.NewReader("0123456789"), 10, WithETag(`"v1"`), WithModTime(modtime))
+	tests := map[string]int{
+		"If-None-Match":       http.StatusNotModified,
+		"If-Match":            http.StatusPartialContent,
+		"If-Unmodified-Since": http.StatusPartialContent,
+	}
+	values := map[string]string{
+		"If-None-Match":       `"v1"`,
+		"If-Match":            `"v1"`,
+		"If-Unmodified-Since": modtime.Format(http.TimeFormat),
+	}
+	for field, want := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Range", "bytes=0-4")
+		req.Header.Set(field, values[field])
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Code; got != want {
+			t.Errorf("%s: bad status: got %d, want %d", field, got, want)
+		}
+		if got, want := rec.Header().Get("Etag"), `"v1"`; got != want {
+			t.Errorf("%s: bad etag: got %q, want %q", field, got, want)
+		}
+		if got, want := rec.Header().Get("Last-Modified"), modtime.Format(http.TimeFormat); got != want {
+			t.Errorf("%s: bad last modified: got %q, want %q", field, got, want)
+		}
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-Match", `"v0"`)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusPreconditionFailed; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package ranger
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Content is what ContentHandler serves ranges of. It suits content that can't
+// be read with an io.ReaderAt, such as rows in a database, objects in an
+// object store, or content generated on the fly, which is better read one
+// whole range at a time.
+type Content interface {
+	// Size returns the length of the content, in bytes.
+	Size() int64
+
+	// ReadRange returns a reader for exactly the bytes of r, which always
+	// falls within the content. The reader is closed once the range has been
+	// served, or the response is abandoned. ReadRange may be called
+	// concurrently, for different requests.
+	ReadRange(ctx context.Context, r Range) (io.ReadCloser, error)
+}
+
+// ContentValidators may be implemented by a Content to give its validators,
+// which are used as WithETag and WithModTime would use them, unless those
+// options are given too.
+type ContentValidators interface {
+	// Validators returns the entity tag of the content, or "" if it has none,
+	// and the time it was last modified, or the zero time if that's unknown.
+	Validators() (etag string, modtime time.Time)
+}
+
+// ContentHandler returns an http.Handler that serves c, just as Handler serves
+// an io.ReaderAt. The size and validators of c are asked for afresh with each
+// request, so they may change between requests.
+func ContentHandler(c Content, opts ...ServeOption) http.Handler {
+	cfg := newServeConfig(opts)
+	if cfg.contentType == "" {
+		cfg.contentType = "application/octet-stream"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := cfg
+		if v, ok := c.(ContentValidators); ok {
+			etag, modtime := v.Validators()
+			cfg = cfg.withValidators(etag, modtime)
+		}
+		_ = serve(w, r, c, cfg)
+	})
+}
+
+// withValidators returns a copy of c with the given validators, unless c has
+// its own.
+func (c *serveConfig) withValidators(etag string, modtime time.Time) *serveConfig {
+	cp := *c
+	if cp.etag == "" {
+		cp.etag = etag
+	}
+	if cp.modtime.IsZero() {
+		cp.modtime = modtime
+	}
+	return &cp
+}
+
+// readerAtContent adapts an io.ReaderAt of size bytes to a Content.
+type readerAtContent struct {
+	src  io.ReaderAt
+	size int64
+}
+
+func (c readerAtContent) Size() int64 {
+	return c.size
+}
+
+func (c readerAtContent) ReadRange(ctx context.Context, r Range) (io.ReadCloser, error) {
+	return io.NopCloser(NewReaderContext(ctx, c.src, []Range{r})), nil
+}
+
+// fileContent is the Content of a file opened for a single request, which is
+// read with Seek and Read, rather than ReadAt, so that a single range can be
+// sent with sendfile where the platform has it.
+type fileContent struct {
+	readerAtContent
+	f *os.File
+}
+
+// copyRange copies the range r of the file to w.
+func (c fileContent) copyRange(w io.Writer, r Range) error {
+	if _, err := c.f.Seek(r.Start, io.SeekStart); err != nil {
+		return err
+	}
+	n, err := io.Copy(w, io.LimitReader(c.f, r.Len()))
+	if err == nil && n < r.Len() {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
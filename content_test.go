@@ -0,0 +1,126 @@
+package ranger
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// generatedContent generates its content, a repeating string, one range at a
+// time, and records the ranges it's asked for.
+type generatedContent struct {
+	pattern string
+	size    int64
+	etag    string
+
+	mu     sync.Mutex
+	ranges []Range
+	open   int
+}
+
+func (c *generatedContent) Size() int64 {
+	return c.size
+}
+
+func (c *generatedContent) ReadRange(ctx context.Context, r Range) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ranges = append(c.ranges, r)
+	c.open++
+	var b strings.Builder
+	for i := r.Start; i <= r.Stop; i++ {
+		b.WriteByte(c.pattern[i%int64(len(c.pattern))])
+	}
+	return &generatedBody{Reader: strings.NewReader(b.String()), c: c}, nil
+}
+
+func (c *generatedContent) Validators() (string, time.Time) {
+	return c.etag, time.Time{}
+}
+
+type generatedBody struct {
+	*strings.Reader
+	c *generatedContent
+}
+
+func (b *generatedBody) Close() error {
+	b.c.mu.Lock()
+	defer b.c.mu.Unlock()
+	b.c.open--
+	return nil
+}
+
+type contentHandlerTest struct {
+	Range          string
+	IfNoneMatch    string
+	ExpectedStatus int
+	ExpectedBody   string
+	ExpectedRanges []Range
+}
+
+func TestContentHandler(t *testing.T) {
+	c := &generatedContent{pattern: "0123456789", size: 100, etag: `"v1"`}
+	h := ContentHandler(c, WithBoundary("XYZ"))
+	tests := []contentHandlerTest{
+		{ // no range
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   strings.Repeat("0123456789", 10),
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+		},
+		{ // single range
+			Range:          "bytes=15-24",
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   "5678901234",
+			ExpectedRanges: []Range{{Start: 15, Stop: 24}},
+		},
+		{ // several ranges, each read once
+			Range:          "bytes=0-1,50-51",
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody: "--XYZ\r\nContent-Range: bytes 0-1/100\r\nContent-Type: application/octet-stream\r\n\r\n01" +
+				"\r\n--XYZ\r\nContent-Range: bytes 50-51/100\r\nContent-Type: application/octet-stream\r\n\r\n01" +
+				"\r\n--XYZ--\r\n",
+			ExpectedRanges: []Range{{Start: 0, Stop: 1}, {Start: 50, Stop: 51}},
+		},
+		{ // unsatisfiable
+			Range:          "bytes=100-",
+			ExpectedStatus: http.StatusRequestedRangeNotSatisfiable,
+		},
+		{ // validators from the content
+			IfNoneMatch:    `"v1"`,
+			ExpectedStatus: http.StatusNotModified,
+		},
+	}
+	for i, test := range tests {
+		c.ranges = nil
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.Range != "" {
+			req.Header.Set("Range", test.Range)
+		}
+		if test.IfNoneMatch != "" {
+			req.Header.Set("If-None-Match", test.IfNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if got, want := w.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := w.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+		if got, want := c.ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges read: got %v, want %v", i, got, want)
+		}
+		if got, want := w.Header().Get("Etag"), `"v1"`; got != want {
+			t.Errorf("test %d: bad etag: got %q, want %q", i, got, want)
+		}
+		if c.open != 0 {
+			t.Errorf("test %d: %d readers left open", i, c.open)
+		}
+	}
+}
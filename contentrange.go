@@ -0,0 +1,148 @@
+package ranger
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrContentRange is returned when a Content-Range field is malformed, or
+// doesn't describe the expected range.
+var ErrContentRange = errors.New("ranger: invalid content-range")
+
+// ContentRange is a parsed Content-Range field.
+type ContentRange struct {
+	// Range is the range of the content that was sent. It is the zero Range
+	// if Unsatisfied is true.
+	Range Range
+
+	// Length is the complete length of the content, or -1 if the sender
+	// doesn't know it.
+	Length int64
+
+	// Unsatisfied is true for the 'bytes */length' form, which is sent with
+	// a 416 to report the length of the content without sending any of it.
+	Unsatisfied bool
+}
+
+// String formats cr as the value of a Content-Range field. It's the inverse of
+// ParseContentRangeValue.
+func (cr ContentRange) String() string {
+	length := "*"
+	if cr.Length >= 0 {
+		length = strconv.FormatInt(cr.Length, 10)
+	}
+	if cr.Unsatisfied {
+		return "bytes */" + length
+	}
+	return "bytes " + strconv.FormatInt(cr.Range.Start, 10) + "-" + strconv.FormatInt(cr.Range.Stop, 10) + "/" + length
+}
+
+// ContentRange returns the value of the Content-Range field for a 206 response
+// carrying r, out of content of total bytes. If total is negative, the length
+// is given as '*', meaning unknown.
+func (r Range) ContentRange(total int64) string {
+	return ContentRange{Range: r, Length: max(total, -1)}.String()
+}
+
+// UnsatisfiedContentRange returns the value of the Content-Range field for a
+// 416 response, for content of total bytes: 'bytes */total'.
+func UnsatisfiedContentRange(total int64) string {
+	return ContentRange{Length: total, Unsatisfied: true}.String()
+}
+
+// ParseContentRangeValue parses the value of a Content-Range field. It accepts
+// the forms 'bytes first-last/length', 'bytes first-last/*' and
+// 'bytes */length'.
+//
+// If the value is malformed, an error wrapping ErrContentRange is returned.
+func ParseContentRangeValue(v string) (ContentRange, error) {
+	bad := fmt.Errorf("%w: %q", ErrContentRange, v)
+	rest, ok := strings.CutPrefix(v, "bytes ")
+	if !ok {
+		return ContentRange{}, bad
+	}
+	rng, size, ok := strings.Cut(rest, "/")
+	if !ok {
+		return ContentRange{}, bad
+	}
+	var cr ContentRange
+	if size == "*" {
+		cr.Length = -1
+	} else if cr.Length, ok = parseDigits(size); !ok {
+		return ContentRange{}, bad
+	}
+	if rng == "*" {
+		if cr.Length < 0 {
+			return ContentRange{}, bad
+		}
+		cr.Unsatisfied = true
+		return cr, nil
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return ContentRange{}, bad
+	}
+	if cr.Range.Start, ok = parseDigits(first); !ok {
+		return ContentRange{}, bad
+	}
+	if cr.Range.Stop, ok = parseDigits(last); !ok || cr.Range.Start > cr.Range.Stop {
+		return ContentRange{}, bad
+	}
+	if cr.Length >= 0 && cr.Range.Stop >= cr.Length {
+		return ContentRange{}, bad
+	}
+	return cr, nil
+}
+
+// ParseContentRange parses the Content-Range field of a response header, of
+// the form 'bytes first-last/total', as sent with a 206 response. If the total
+// length is '*', meaning that the server doesn't know it, total is -1.
+//
+// If the field is missing or malformed, or is of the 'bytes */total' form, an
+// error wrapping ErrContentRange is returned. Use ParseContentRangeValue to
+// parse any form.
+func ParseContentRange(h http.Header) (r Range, total int64, err error) {
+	v := h.Get("Content-Range")
+	cr, err := ParseContentRangeValue(v)
+	if err != nil {
+		return Range{}, 0, err
+	}
+	if cr.Unsatisfied {
+		return Range{}, 0, fmt.Errorf("%w: %q", ErrContentRange, v)
+	}
+	return cr.Range, cr.Length, nil
+}
+
+// VerifyContentRange checks that the Content-Range field of a response header
+// describes exactly the range want. Some servers ignore or misreport ranges,
+// so clients should check what they were actually sent.
+//
+// If the field is malformed or describes a different range, an error wrapping
+// ErrContentRange is returned.
+func VerifyContentRange(h http.Header, want Range) error {
+	got, _, err := ParseContentRange(h)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w: got bytes %d-%d, want bytes %d-%d", ErrContentRange, got.Start, got.Stop, want.Start, want.Stop)
+	}
+	return nil
+}
+
+// parseDigits parses a non-negative decimal integer, made up of digits only.
+func parseDigits(s string) (int64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,154 @@
+package ranger
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type contentRangeTest struct {
+	ContentRange  string
+	ExpectedRange Range
+	ExpectedTotal int64
+	ExpectedError string
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []contentRangeTest{
+		{ // complete
+			ContentRange:  "bytes 0-99/1234",
+			ExpectedRange: Range{Start: 0, Stop: 99},
+			ExpectedTotal: 1234,
+			ExpectedError: "<nil>",
+		},
+		{ // unknown total
+			ContentRange:  "bytes 100-199/*",
+			ExpectedRange: Range{Start: 100, Stop: 199},
+			ExpectedTotal: -1,
+			ExpectedError: "<nil>",
+		},
+		{ // missing
+			ExpectedError: `ranger: invalid content-range: ""`,
+		},
+		{ // wrong unit
+			ContentRange:  "items 0-99/1234",
+			ExpectedError: `ranger: invalid content-range: "items 0-99/1234"`,
+		},
+		{ // unsatisfied range
+			ContentRange:  "bytes */1234",
+			ExpectedError: `ranger: invalid content-range: "bytes */1234"`,
+		},
+		{ // reversed
+			ContentRange:  "bytes 99-0/1234",
+			ExpectedError: `ranger: invalid content-range: "bytes 99-0/1234"`,
+		},
+		{ // past the end
+			ContentRange:  "bytes 0-99/99",
+			ExpectedError: `ranger: invalid content-range: "bytes 0-99/99"`,
+		},
+		{ // no total
+			ContentRange:  "bytes 0-99",
+			ExpectedError: `ranger: invalid content-range: "bytes 0-99"`,
+		},
+		{ // signed
+			ContentRange:  "bytes +0-99/1234",
+			ExpectedError: `ranger: invalid content-range: "bytes +0-99/1234"`,
+		},
+	}
+	for i, test := range tests {
+		h := http.Header{}
+		if test.ContentRange != "" {
+			h.Set("Content-Range", test.ContentRange)
+		}
+		r, total, err := ParseContentRange(h)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if err != nil && !errors.Is(err, ErrContentRange) {
+			t.Errorf("test %d: error doesn't wrap ErrContentRange: %v", i, err)
+		}
+		if got, want := r, test.ExpectedRange; got != want {
+			t.Errorf("test %d: bad range: got %+v, want %+v", i, got, want)
+		}
+		if got, want := total, test.ExpectedTotal; got != want {
+			t.Errorf("test %d: bad total: got %d, want %d", i, got, want)
+		}
+	}
+}
+
+type contentRangeValueTest struct {
+	Value         string
+	Expected      ContentRange
+	ExpectedError string
+}
+
+func TestParseContentRangeValue(t *testing.T) {
+	tests := []contentRangeValueTest{
+		{ // complete
+			Value:         "bytes 0-99/1234",
+			Expected:      ContentRange{Range: Range{Start: 0, Stop: 99}, Length: 1234},
+			ExpectedError: "<nil>",
+		},
+		{ // unknown length
+			Value:         "bytes 0-99/*",
+			Expected:      ContentRange{Range: Range{Start: 0, Stop: 99}, Length: -1},
+			ExpectedError: "<nil>",
+		},
+		{ // unsatisfied
+			Value:         "bytes */1234",
+			Expected:      ContentRange{Length: 1234, Unsatisfied: true},
+			ExpectedError: "<nil>",
+		},
+		{ // unsatisfied with unknown length
+			Value:         "bytes */*",
+			ExpectedError: `ranger: invalid content-range: "bytes */*"`,
+		},
+		{ // empty length
+			Value:         "bytes 0-99/",
+			ExpectedError: `ranger: invalid content-range: "bytes 0-99/"`,
+		},
+		{ // no range
+			Value:         "bytes /1234",
+			ExpectedError: `ranger: invalid content-range: "bytes /1234"`,
+		},
+	}
+	for i, test := range tests {
+		cr, err := ParseContentRangeValue(test.Value)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := cr, test.Expected; got != want {
+			t.Errorf("test %d: bad content range: got %+v, want %+v", i, got, want)
+		}
+		if err == nil {
+			if got, want := cr.String(), test.Value; got != want {
+				t.Errorf("test %d: bad string: got %q, want %q", i, got, want)
+			}
+		}
+	}
+}
+
+func TestVerifyContentRange(t *testing.T) {
+	h := http.Header{"Content-Range": {"bytes 100-199/1000"}}
+	if err := VerifyContentRange(h, Range{Start: 100, Stop: 199}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := VerifyContentRange(h, Range{Start: 100, Stop: 299})
+	if got, want := fmt.Sprintf("%v", err), "ranger: invalid content-range: got bytes 100-199, want bytes 100-299"; got != want {
+		t.Errorf("bad error: got %q, want %q", got, want)
+	}
+}
+
+func TestRangeContentRange(t *testing.T) {
+	r := Range{Start: 100, Stop: 199}
+	if got, want := r.ContentRange(1000), "bytes 100-199/1000"; got != want {
+		t.Errorf("bad content range: got %q, want %q", got, want)
+	}
+	if got, want := r.ContentRange(-1), "bytes 100-199/*"; got != want {
+		t.Errorf("bad content range: got %q, want %q", got, want)
+	}
+	if got, want := UnsatisfiedContentRange(1000), "bytes */1000"; got != want {
+		t.Errorf("bad content range: got %q, want %q", got, want)
+	}
+}
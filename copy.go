@@ -0,0 +1,87 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// CopyOptions configures CopyRanges. The zero value copies one range at a
+// time, with a 32KB buffer.
+type CopyOptions struct {
+	// Concurrency is the most ranges to copy at once. If it's zero, ranges
+	// are copied one at a time.
+	Concurrency int
+
+	// BufferSize is the size of the buffer used to copy each range. If it's
+	// zero, 32KB is used.
+	BufferSize int
+
+	// Order is the order to start copying the ranges in, such as SeekOrder
+	// for a source that's slow to seek. By default, they're copied in the
+	// order they're given.
+	Order Order
+}
+
+// RangeError records an error copying or fetching a single range.
+type RangeError struct {
+	Range Range
+	Err   error
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("range %v: %v", e.Range, e.Err)
+}
+
+func (e *RangeError) Unwrap() error {
+	return e.Err
+}
+
+// CopyRanges copies the given ranges from src to the same offsets in dst, and
+// nothing else, such as when repairing the damaged regions of a file. Failing
+// to copy one range doesn't stop the others from being copied. Every failure
+// is reported as a *RangeError, and they're joined together, in the order of
+// the ranges, as by errors.Join.
+//
+// Once ctx is done, no more ranges are started, and those that weren't
+// copied fail with ctx.Err().
+func CopyRanges(ctx context.Context, dst io.WriterAt, src io.ReaderAt, ranges []Range, opts CopyOptions) error {
+	concurrency := max(opts.Concurrency, 1)
+	bufSize := opts.BufferSize
+	if bufSize <= 0 {
+		bufSize = 32 << 10
+	}
+	errs := make([]error, len(ranges))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, i := range opts.Order.indices(ranges) {
+		r := ranges[i]
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			errs[i] = &RangeError{Range: r, Err: err}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, r Range) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := copyRange(ctx, dst, src, r, bufSize); err != nil {
+				errs[i] = &RangeError{Range: r, Err: err}
+			}
+		}(i, r)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func copyRange(ctx context.Context, dst io.WriterAt, src io.ReaderAt, r Range, bufSize int) error {
+	w := io.NewOffsetWriter(dst, r.Start)
+	buf := make([]byte, min(int64(bufSize), max(r.Len(), 1)))
+	_, err := io.CopyBuffer(w, NewReaderContext(ctx, src, []Range{r}), buf)
+	return err
+}
@@ -0,0 +1,97 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// memFile is an in-memory io.WriterAt.
+type memFile []byte
+
+func (m memFile) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(m)) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(m[off:], p), nil
+}
+
+func TestCopyRanges(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 3} {
+		dst := memFile(strings.Repeat(".", 10))
+		src := strings.NewReader("0123456789")
+		ranges := []Range{{Start: 0, Stop: 1}, {Start: 5, Stop: 6}, {Start: 9, Stop: 9}}
+		opts := CopyOptions{Concurrency: concurrency, BufferSize: 1}
+		if err := CopyRanges(context.Background(), dst, src, ranges, opts); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(dst), "01...56..9"; got != want {
+			t.Errorf("concurrency %d: bad content: got %q, want %q", concurrency, got, want)
+		}
+	}
+}
+
+func TestCopyRangesErrors(t *testing.T) {
+	dst := memFile(strings.Repeat(".", 10))
+	src := strings.NewReader("01234")
+	ranges := []Range{{Start: 0, Stop: 1}, {Start: 3, Stop: 6}, {Start: 8, Stop: 9}}
+	err := CopyRanges(context.Background(), dst, src, ranges, CopyOptions{Concurrency: 2})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("bad error: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	var failed []Range
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var rerr *RangeError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("not a RangeError: %v", err)
+		}
+		failed = append(failed, rerr.Range)
+	}
+	if got, want := len(failed), 2; got != want {
+		t.Fatalf("bad number of errors: got %d, want %d", got, want)
+	}
+	if got, want := failed[0], ranges[1]; got != want {
+		t.Errorf("bad failed range: got %v, want %v", got, want)
+	}
+	if got, want := failed[1], ranges[2]; got != want {
+		t.Errorf("bad failed range: got %v, want %v", got, want)
+	}
+	if got, want := string(dst[:2]), "01"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
+
+func TestCopyRangesContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dst := memFile(make([]byte, 10))
+	err := CopyRanges(ctx, dst, strings.NewReader("0123456789"), []Range{{Start: 0, Stop: 9}}, CopyOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("bad error: got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCopyRangesFile(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("xxxxxxxxxx"); err != nil {
+		t.Fatal(err)
+	}
+	err = CopyRanges(context.Background(), f, strings.NewReader("0123456789"), []Range{{Start: 2, Stop: 3}}, CopyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "xx23xxxxxx"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package ranger
+
+// ParsedSpec is a single range of a header, as it was written, and what it was
+// resolved to.
+type ParsedSpec struct {
+	// Raw is the range as it was written, such as '100-', without the
+	// whitespace around it.
+	Raw string
+
+	// Index is the position of the range among all of those in the header,
+	// counting from 0, as for a SpecError.
+	Index int
+
+	// Spec is the range as it was parsed; its Kind says how it was written.
+	Spec RangeSpec
+
+	// Range is what the range was resolved to, clamped or truncated if it was.
+	Range Range
+}
+
+// ParseDetail is the result of ParseDetailed: the ranges to serve, and every
+// range that went into them, as it was written, for logging, auditing, and
+// debugging what clients ask for.
+type ParseDetail struct {
+	// Ranges are the ranges to serve, as Parse would return them.
+	Ranges []Range
+
+	// Specs are the ranges as they were written, in the order they were
+	// written. Ranges dropped by ParseOptions.Clamp aren't included.
+	Specs []ParsedSpec
+}
+
+// Sources returns the specs that contributed to d.Ranges[i]: those whose
+// ranges it covers, in the order they were written.
+func (d ParseDetail) Sources(i int) []ParsedSpec {
+	var result []ParsedSpec
+	for _, s := range d.Specs {
+		if in, ok := d.Ranges[i].Intersect(s.Range); ok && in == s.Range {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// ParseDetailed parses ranges like Parse, but also returns each of them as it
+// was written.
+func ParseDetailed(ranges []string, prefix string, contentLen int64) (ParseDetail, error) {
+	return ParseOptions{}.ParseDetailed(ranges, prefix, contentLen)
+}
+
+// ParseDetailed parses ranges like o.Parse, but also returns each of them as
+// it was written.
+func (o ParseOptions) ParseDetailed(ranges []string, prefix string, contentLen int64) (ParseDetail, error) {
+	specs := make([]ParsedSpec, 0, len(ranges))
+	result, err := o.parse(nil, ranges, prefix, contentLen, &specs)
+	if err != nil {
+		return ParseDetail{}, err
+	}
+	return ParseDetail{Ranges: result, Specs: specs}, nil
+}
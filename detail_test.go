@@ -0,0 +1,53 @@
+package ranger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDetailed(t *testing.T) {
+	d, err := ParseDetailed([]string{"bytes=90-, 0-9,-5,5-14"}, "bytes=", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRanges := []Range{{Start: 0, Stop: 14}, {Start: 90, Stop: 99}}
+	if got := d.Ranges; !reflect.DeepEqual(got, wantRanges) {
+		t.Errorf("bad ranges: got %v, want %v", got, wantRanges)
+	}
+	wantSpecs := []ParsedSpec{
+		{Raw: "90-", Index: 0, Spec: RangeSpec{First: 90, Last: -1}, Range: Range{Start: 90, Stop: 99}},
+		{Raw: "0-9", Index: 1, Spec: RangeSpec{First: 0, Last: 9}, Range: Range{Start: 0, Stop: 9}},
+		{Raw: "-5", Index: 2, Spec: RangeSpec{First: -1, Last: 5}, Range: Range{Start: 95, Stop: 99}},
+		{Raw: "5-14", Index: 3, Spec: RangeSpec{First: 5, Last: 14}, Range: Range{Start: 5, Stop: 14}},
+	}
+	if got := d.Specs; !reflect.DeepEqual(got, wantSpecs) {
+		t.Errorf("bad specs: got %+v, want %+v", got, wantSpecs)
+	}
+	if got, want := d.Sources(0), []ParsedSpec{wantSpecs[1], wantSpecs[3]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad sources of %v: got %+v, want %+v", d.Ranges[0], got, want)
+	}
+	if got, want := d.Sources(1), []ParsedSpec{wantSpecs[0], wantSpecs[2]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad sources of %v: got %+v, want %+v", d.Ranges[1], got, want)
+	}
+	var kinds []string
+	for _, s := range d.Specs {
+		kinds = append(kinds, s.Spec.Kind().String())
+	}
+	if got, want := kinds, []string{"open", "closed", "suffix", "closed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad kinds: got %v, want %v", got, want)
+	}
+}
+
+func TestParseDetailedClamp(t *testing.T) {
+	d, err := ParseOptions{Clamp: true}.ParseDetailed([]string{"bytes=200-,50-150"}, "bytes=", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ParsedSpec{{Raw: "50-150", Index: 1, Spec: RangeSpec{First: 50, Last: 150}, Range: Range{Start: 50, Stop: 99}}}
+	if got := d.Specs; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad specs: got %+v, want %+v", got, want)
+	}
+	if _, err := ParseDetailed([]string{"bytes=x"}, "bytes=", 100); err == nil {
+		t.Error("malformed range parsed")
+	}
+}
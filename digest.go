@@ -0,0 +1,67 @@
+package ranger
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// ErrIntegrity is returned when the bytes of a range don't match a digest
+// given for them.
+var ErrIntegrity = errors.New("ranger: integrity check failed")
+
+// digestAlgorithms are the algorithms of a Content-Digest field that
+// VerifyDigest can check.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// VerifyDigest checks the bytes of a range, data, against the digests given
+// in the header of the response that carried them: the Content-Digest field
+// of RFC 9530, with the sha-256 or sha-512 algorithm, and the older
+// Content-MD5. In a 206 response, both cover the bytes of the part, rather
+// than the whole representation. The Repr-Digest field covers the whole
+// representation, so it can't be checked one range at a time, and is ignored.
+//
+// It has the signature of Downloader.Verify. If the bytes don't match, an
+// error wrapping ErrIntegrity is returned. If the header gives no digest
+// VerifyDigest can check, or a malformed one, it returns nil, as there's
+// nothing to check them against.
+func VerifyDigest(r Range, data []byte, h http.Header) error {
+	for _, field := range h.Values("Content-Digest") {
+		for _, member := range strings.Split(field, ",") {
+			alg, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+			newHash := digestAlgorithms[strings.ToLower(alg)]
+			if !ok || newHash == nil || len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+				continue
+			}
+			want, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+			if err != nil {
+				continue
+			}
+			if !digestMatches(newHash(), data, want) {
+				return fmt.Errorf("%w: range %v: %s digest mismatch", ErrIntegrity, r, alg)
+			}
+		}
+	}
+	if v := h.Get("Content-Md5"); v != "" {
+		want, err := base64.StdEncoding.DecodeString(v)
+		if err == nil && !digestMatches(md5.New(), data, want) {
+			return fmt.Errorf("%w: range %v: Content-MD5 mismatch", ErrIntegrity, r)
+		}
+	}
+	return nil
+}
+
+func digestMatches(h hash.Hash, data, want []byte) bool {
+	h.Write(data)
+	return bytes.Equal(h.Sum(nil), want)
+}
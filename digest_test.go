@@ -0,0 +1,114 @@
+package ranger
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type verifyDigestTest struct {
+	Header        http.Header
+	ExpectedError string
+}
+
+func TestVerifyDigest(t *testing.T) {
+	data := []byte("hello")
+	sha := sha256.Sum256(data)
+	sum := md5.Sum(data)
+	good := ":" + base64.StdEncoding.EncodeToString(sha[:]) + ":"
+	bad := ":" + base64.StdEncoding.EncodeToString(make([]byte, 32)) + ":"
+	tests := []verifyDigestTest{
+		{ // matching Content-Digest
+			Header:        http.Header{"Content-Digest": {"sha-256=" + good}},
+			ExpectedError: "<nil>",
+		},
+		{ // mismatched Content-Digest
+			Header:        http.Header{"Content-Digest": {"sha-256=" + bad}},
+			ExpectedError: "ranger: integrity check failed: range 0-4: sha-256 digest mismatch",
+		},
+		{ // unsupported algorithms are skipped
+			Header:        http.Header{"Content-Digest": {"unixsum=:AAAA:, sha-256=" + good}},
+			ExpectedError: "<nil>",
+		},
+		{ // matching Content-MD5
+			Header:        http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])}},
+			ExpectedError: "<nil>",
+		},
+		{ // mismatched Content-MD5
+			Header:        http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(make([]byte, 16))}},
+			ExpectedError: "ranger: integrity check failed: range 0-4: Content-MD5 mismatch",
+		},
+		{ // Repr-Digest covers the whole representation, so it's ignored
+			Header:        http.Header{"Repr-Digest": {"sha-256=" + bad}},
+			ExpectedError: "<nil>",
+		},
+		{ // no digest
+			Header:        http.Header{},
+			ExpectedError: "<nil>",
+		},
+	}
+	for i, test := range tests {
+		err := VerifyDigest(Range{Start: 0, Stop: 4}, data, test.Header)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+// corruptingHandler serves content with a Content-Digest for each range, but
+// corrupts the bytes of the first response for every range.
+type corruptingHandler struct {
+	content string
+	mu      sync.Mutex
+	seen    map[string]bool
+}
+
+func (c *corruptingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ranges, err := ParseRequest(r, int64(len(c.content)))
+	if err != nil || len(ranges) != 1 {
+		http.Error(w, "bad range", http.StatusBadRequest)
+		return
+	}
+	part := []byte(c.content[ranges[0].Start : ranges[0].Stop+1])
+	sum := sha256.Sum256(part)
+	c.mu.Lock()
+	seen := c.seen[r.Header.Get("Range")]
+	c.seen[r.Header.Get("Range")] = true
+	c.mu.Unlock()
+	if !seen {
+		part[0] ^= 0xff
+	}
+	w.Header().Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+	w.Header().Set("Content-Range", ranges[0].ContentRange(int64(len(c.content))))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(part)
+}
+
+func TestDownloaderVerify(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	ch := &corruptingHandler{content: content, seen: map[string]bool{}}
+	srv := httptest.NewServer(ch)
+	defer srv.Close()
+	dst := memFile(make([]byte, 100))
+	d := &Downloader{Client: srv.Client(), ChunkSize: 30, Verify: VerifyDigest}
+	_, err := d.Download(context.Background(), srv.URL, dst, nil)
+	if !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("bad error: got %v, want %v", err, ErrIntegrity)
+	}
+	ch.seen = map[string]bool{}
+	d.Retries = 1
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,404 @@
+package ranger
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Downloader fetches a remote resource in chunks, with several concurrent
+// range requests. The zero value is ready to use.
+type Downloader struct {
+	// Client is used to make requests. If it's nil, http.DefaultClient is
+	// used.
+	Client *http.Client
+
+	// ChunkSize is the most bytes to fetch with a single request. If it's
+	// zero, 1MB is used. It's ignored if Chunking is set.
+	ChunkSize int64
+
+	// Chunking, if set, chooses the size of each chunk instead, such as with
+	// AdaptiveChunks, so that it can change as the download goes on.
+	Chunking ChunkStrategy
+
+	// Workers is the most requests to make at once. If it's zero, 4 are
+	// made.
+	Workers int
+
+	// Retries is how many more times to try fetching a chunk after a request
+	// for it fails, straight away. It's ignored if Retry.Attempts is set.
+	Retries int
+
+	// Retry says how many times to try fetching each chunk, how long to wait
+	// between tries, and which failures to retry. Only failures that
+	// IsRetryable reports are retried, unless Retry.Retryable says otherwise.
+	Retry RetryPolicy
+
+	// Hooks observe the chunks as they're fetched; only OnFetched and Tracer
+	// are used.
+	Hooks Hooks
+
+	// Verify, if set, checks each chunk as it's fetched, given the header of
+	// the response that carried it, such as with VerifyDigest. A chunk that
+	// fails is discarded, and fetched again, as many times as Retries allows.
+	Verify func(chunk Range, data []byte, h http.Header) error
+
+	// Limiter, if set, limits the rate of the whole download, across all of
+	// its connections. Sharing one Limiter between Downloaders limits them
+	// all together.
+	Limiter Limiter
+
+	// ConnLimiter, if set, is called once for each of the Workers, and the
+	// Limiter it returns limits the rate of that worker's connection. If it
+	// returns nil, the connection isn't limited.
+	ConnLimiter func() Limiter
+
+	// Progress, if set, measures the progress of each download, and may be
+	// read with its Snapshot method while the download runs. A ProgressMeter
+	// measures one download at a time.
+	Progress *ProgressMeter
+
+	// OnProgress, if set, is called with a snapshot of the progress of a
+	// download after each chunk is written. It may be called concurrently.
+	OnProgress func(Progress)
+
+	// Mirrors, if set, are the URLs of other servers with copies of the
+	// resource, for chunks to be fetched from along with the URL given to
+	// Download. Each mirror must serve the same representation: one whose
+	// length, ETag or Last-Modified date differs from the URL's is dropped
+	// with ErrMirrorMismatch. Chunks go to the sources that fetch them
+	// fastest; a chunk that fails from one source, or that takes several
+	// times longer than the fastest would, is fetched from another. A source
+	// whose first chunk fails is only used when the others fail too, and one
+	// that fails several chunks in a row, or on which the resource changes,
+	// is dropped. Each source gets as many tries at a chunk as Retry allows
+	// before the next is tried.
+	Mirrors []string
+
+	// OnMirrorDropped, if set, is called when a mirror is dropped from a
+	// download, with why: the error opening it, ErrMirrorMismatch, or the
+	// last error fetching from it.
+	OnMirrorDropped func(url string, err error)
+}
+
+// Download fetches the resource at url, and writes it to dst at the same
+// offsets. Progress is recorded in tr as each chunk is written. If tr is nil,
+// a new Tracker is created. Otherwise, only the ranges tr is missing are
+// fetched, so that an interrupted download can be resumed from a checkpoint;
+// tr must be for the resource's current length.
+//
+// Download returns the tracker along with any error, so that it can be saved
+// and resumed later. Every chunk that couldn't be fetched is reported as a
+// *RangeError, joined together as by errors.Join. If the resource changes
+// during the download, the error wraps ErrResourceChanged. Once ctx is done,
+// no more chunks are fetched, and those that weren't fail with ctx.Err().
+func (d *Downloader) Download(ctx context.Context, url string, dst io.WriterAt, tr *Tracker) (*Tracker, error) {
+	hr, err := NewHTTPReaderContext(ctx, d.Client, url)
+	if err != nil {
+		return tr, err
+	}
+	if tr == nil {
+		tr = NewTracker(hr.Size())
+	} else if tr.Length() != hr.Size() {
+		return tr, fmt.Errorf("ranger: download of %s: length is %d, tracker is for %d", url, hr.Size(), tr.Length())
+	}
+	return tr, d.download(ctx, d.openMirrors(ctx, hr), dst, tr, nil)
+}
+
+// download fetches the ranges tr is missing from ms, and writes them to dst.
+// If done isn't nil, it's called after each chunk is written and marked.
+func (d *Downloader) download(ctx context.Context, ms *mirrorSet, dst io.WriterAt, tr *Tracker, done func()) error {
+	strategy := d.Chunking
+	if strategy == nil {
+		chunkSize := d.ChunkSize
+		if chunkSize <= 0 {
+			chunkSize = 1 << 20
+		}
+		strategy = FixedChunks(chunkSize)
+	}
+	workers := d.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	q := &chunkQueue{gaps: tr.Missing()}
+	meter := d.Progress
+	if meter == nil && d.OnProgress != nil {
+		meter = NewProgressMeter()
+	}
+	if meter != nil {
+		meter.begin(tr)
+	}
+
+	var mu sync.Mutex
+	var errs []*RangeError
+	fail := func(r Range, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, &RangeError{Range: r, Err: err})
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var connLimiter Limiter
+			if d.ConnLimiter != nil {
+				connLimiter = d.ConnLimiter()
+			}
+			size := strategy.ChunkSize(0, 0, nil)
+			for {
+				if err := ctx.Err(); err != nil {
+					for _, gap := range q.drain() {
+						fail(gap, err)
+					}
+					return
+				}
+				chunk, ok := q.next(size)
+				if !ok {
+					return
+				}
+				start := time.Now()
+				err := d.fetchMirrors(ctx, ms, dst, chunk, connLimiter)
+				took := time.Since(start)
+				if d.Hooks.OnFetched != nil {
+					d.Hooks.OnFetched(chunk, took, err)
+				}
+				size = strategy.ChunkSize(chunk.Len(), took, err)
+				if err != nil {
+					fail(chunk, err)
+					continue
+				}
+				tr.Mark(chunk)
+				if meter != nil {
+					meter.update(tr)
+					if d.OnProgress != nil {
+						d.OnProgress(meter.Snapshot())
+					}
+				}
+				if done != nil {
+					done()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	slices.SortFunc(errs, func(a, b *RangeError) int {
+		return cmp.Compare(a.Range.Start, b.Range.Start)
+	})
+	joined := make([]error, len(errs))
+	for i, err := range errs {
+		joined[i] = err
+	}
+	return errors.Join(joined...)
+}
+
+// tracedFetch is fetch, in a span if d.Hooks has a Tracer.
+func (d *Downloader) tracedFetch(ctx context.Context, hr *HTTPReader, dst io.WriterAt, r Range, connLimiter Limiter) error {
+	if d.Hooks.Tracer == nil {
+		return d.fetch(ctx, hr, dst, r, connLimiter)
+	}
+	ctx, span := d.Hooks.Tracer.Start(ctx, SpanFetch, Attr{Key: AttrRange, Value: r.String()})
+	err := d.fetch(ctx, hr, dst, r, connLimiter)
+	if err == nil {
+		span.SetAttributes(Attr{Key: AttrBytes, Value: r.Len()})
+	}
+	span.End(err)
+	return err
+}
+
+// fetch fetches a single chunk, and writes it to dst, no faster than
+// d.Limiter and connLimiter allow.
+func (d *Downloader) fetch(ctx context.Context, hr *HTTPReader, dst io.WriterAt, r Range, connLimiter Limiter) error {
+	policy := d.Retry
+	if policy.Attempts == 0 {
+		policy.Attempts = d.Retries + 1
+	}
+	var b []byte
+	err := policy.do(ctx, func() error {
+		var h http.Header
+		var err error
+		b, h, err = hr.fetchHeader(ctx, r, d.Limiter, connLimiter)
+		if err == nil && d.Verify != nil {
+			err = d.Verify(r, b, h)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = dst.WriteAt(b, r.Start)
+	return err
+}
+
+// checkpoint is the state of a download, saved by DownloadFile.
+type checkpoint struct {
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	Ranges       string   `json:"ranges,omitempty"`
+	Tracker      *Tracker `json:"tracker"`
+}
+
+// DownloadFile downloads the resource at url to the file at path, like
+// Download, and can resume where it left off if it's interrupted.
+//
+// The progress of the download is checkpointed to a sidecar file, path with
+// '.ranger' appended, after every chunk, along with the resource's size and
+// validators. The file is synced before each checkpoint is saved, so that
+// after a crash, the checkpoint never lists ranges that aren't on disk. When
+// DownloadFile is called again for the same path, it checks that the resource
+// is unchanged, and only fetches the ranges that are still missing. If the
+// resource has changed, or has no validators to compare, the download starts
+// over. Once the download is complete, the sidecar file is removed.
+func (d *Downloader) DownloadFile(ctx context.Context, url, path string) error {
+	return d.downloadFile(ctx, url, path, nil)
+}
+
+// DownloadFileRanges downloads just the ranges in specs of the resource at
+// url to the file at path, like DownloadFile, writing each at its offset and
+// leaving the rest of the file empty. The download is checkpointed, and
+// resumed, as DownloadFile's is, as long as it's called again with the same
+// ranges; with others, it starts over. If any of the ranges fall outside of
+// the resource, ErrUnsatisfiable is returned, in a *SpecError, before
+// anything is fetched.
+func (d *Downloader) DownloadFileRanges(ctx context.Context, url, path string, specs []RangeSpec) error {
+	if len(specs) == 0 {
+		return errors.New("ranger: no ranges to download")
+	}
+	return d.downloadFile(ctx, url, path, specs)
+}
+
+// downloadFile downloads the ranges in specs of the resource at url, or all
+// of it if specs is nil, to the file at path, with a checkpoint to resume
+// from.
+func (d *Downloader) downloadFile(ctx context.Context, url, path string, specs []RangeSpec) error {
+	hr, err := NewHTTPReaderContext(ctx, d.Client, url)
+	if err != nil {
+		return err
+	}
+	tr := NewTracker(hr.Size())
+	var spec []string
+	if specs != nil {
+		ranges, err := ResolveSpecs(specs, hr.Size())
+		if err != nil {
+			return err
+		}
+		for _, r := range NewRangeSet(ranges...).Complement(hr.Size()).Ranges() {
+			tr.Mark(r)
+		}
+		for _, s := range specs {
+			spec = append(spec, s.String())
+		}
+	}
+	sidecar := path + ".ranger"
+	cp := checkpoint{ETag: hr.validators.etag, LastModified: hr.validators.lastModified, Ranges: strings.Join(spec, ","), Tracker: tr}
+	resumed := false
+	if old, err := readCheckpoint(sidecar); err == nil && old.resumable(cp, hr.Size()) {
+		cp.Tracker, resumed = old.Tracker, true
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if !resumed {
+		if err := f.Truncate(hr.Size()); err != nil {
+			return err
+		}
+	}
+
+	var mu sync.Mutex
+	var saveErr error
+	save := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		// The progress is taken before the file is synced, so that every
+		// range the checkpoint lists is on disk before the checkpoint is.
+		b, err := json.Marshal(cp)
+		if err == nil {
+			err = f.Sync()
+		}
+		if err == nil {
+			err = writeCheckpoint(sidecar, b)
+		}
+		if err != nil && saveErr == nil {
+			saveErr = err
+		}
+	}
+	save()
+	if err := d.download(ctx, d.openMirrors(ctx, hr), f, cp.Tracker, save); err != nil {
+		return err
+	}
+	if saveErr != nil {
+		return saveErr
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return os.Remove(sidecar)
+}
+
+// resumable reports whether a download checkpointed as c can be resumed, now
+// that the resource has the validators in cur, and size bytes, and the ranges
+// in cur are to be downloaded.
+func (c checkpoint) resumable(cur checkpoint, size int64) bool {
+	if c.Tracker == nil || c.Tracker.Length() != size || c.Ranges != cur.Ranges {
+		return false
+	}
+	if c.ETag == "" && c.LastModified == "" {
+		return false
+	}
+	return c.ETag == cur.ETag && c.LastModified == cur.LastModified
+}
+
+func readCheckpoint(path string) (checkpoint, error) {
+	var cp checkpoint
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cp, err
+	}
+	err = json.Unmarshal(b, &cp)
+	return cp, err
+}
+
+// writeCheckpoint writes the checkpoint b to path atomically and durably, so
+// that a crash can't leave a partly written checkpoint behind, or lose one
+// that was written. The directory is synced too, so that the rename survives
+// a crash, where the platform supports it.
+func writeCheckpoint(path string, b []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// Not every platform can sync a directory, Windows among them, so a
+	// failure to is ignored.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
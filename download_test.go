@@ -0,0 +1,227 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloader(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	srv, requests := countingServer(t, content)
+	d := &Downloader{Client: srv.Client(), ChunkSize: 64, Workers: 3}
+	dst := memFile(make([]byte, len(content)))
+	tr, err := d.Download(context.Background(), srv.URL, dst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := tr.Progress(), 1.0; got != want {
+		t.Errorf("bad progress: got %v, want %v", got, want)
+	}
+	// One request to learn the size, and one for each of the 16 chunks.
+	if got, want := atomic.LoadInt64(requests), int64(17); got != want {
+		t.Errorf("bad number of requests: got %d, want %d", got, want)
+	}
+}
+
+func TestDownloaderResume(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, requests := countingServer(t, content)
+	dst := memFile([]byte(content[:50] + strings.Repeat(".", 50)))
+	tr := NewTracker(100)
+	tr.Mark(Range{Start: 0, Stop: 49})
+	d := &Downloader{Client: srv.Client(), ChunkSize: 25}
+	if _, err := d.Download(context.Background(), srv.URL, dst, tr); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := atomic.LoadInt64(requests), int64(3); got != want {
+		t.Errorf("bad number of requests: got %d, want %d", got, want)
+	}
+	if _, err := d.Download(context.Background(), srv.URL, dst, NewTracker(99)); err == nil {
+		t.Error("tracker of the wrong length accepted")
+	}
+}
+
+// flakyHandler fails the first request for every range.
+type flakyHandler struct {
+	mu   sync.Mutex
+	seen map[string]bool
+	h    http.Handler
+}
+
+func (f *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	seen := f.seen[r.Header.Get("Range")]
+	f.seen[r.Header.Get("Range")] = true
+	f.mu.Unlock()
+	if !seen {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	f.h.ServeHTTP(w, r)
+}
+
+func TestDownloaderRetries(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	flaky := &flakyHandler{seen: map[string]bool{"bytes=0-0": true}, h: Handler(strings.NewReader(content), 100)}
+	srv := httptest.NewServer(flaky)
+	defer srv.Close()
+
+	dst := memFile(make([]byte, 100))
+	d := &Downloader{Client: srv.Client(), ChunkSize: 30}
+	tr, err := d.Download(context.Background(), srv.URL, dst, nil)
+	var rerr *RangeError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("bad error: got %v, want a *RangeError", err)
+	}
+	if got, want := tr.Missing(), []Range{{Start: 0, Stop: 99}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad missing ranges: got %+v, want %+v", got, want)
+	}
+
+	flaky.seen = map[string]bool{"bytes=0-0": true}
+	d.Retries = 1
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
+
+// resumeServer serves content with an ETag, failing requests for one range
+// while fail is set, and records the ranges requested.
+type resumeServer struct {
+	content string
+	etag    string
+	fail    string
+
+	mu        sync.Mutex
+	requested []string
+}
+
+func (s *resumeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requested = append(s.requested, r.Header.Get("Range"))
+	fail := s.fail
+	s.mu.Unlock()
+	if r.Header.Get("Range") == fail {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Etag", s.etag)
+	ServeRanges(w, r, "", time.Time{}, strings.NewReader(s.content))
+}
+
+func TestDownloaderDownloadFile(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	rs := &resumeServer{content: content, etag: `"v1"`, fail: "bytes=50-74"}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "download")
+	d := &Downloader{Client: srv.Client(), ChunkSize: 25, Workers: 1}
+
+	if err := d.DownloadFile(context.Background(), srv.URL, path); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := os.Stat(path + ".ranger"); err != nil {
+		t.Fatalf("no checkpoint: %s", err)
+	}
+	if _, err := os.Stat(path + ".ranger.tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary checkpoint left behind: %v", err)
+	}
+
+	rs.fail = ""
+	rs.requested = nil
+	if err := d.DownloadFile(context.Background(), srv.URL, path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rs.requested, []string{"bytes=0-0", "bytes=50-74"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges requested: got %q, want %q", got, want)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if _, err := os.Stat(path + ".ranger"); !os.IsNotExist(err) {
+		t.Errorf("checkpoint not removed: %v", err)
+	}
+}
+
+func TestDownloaderDownloadFileRanges(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	rs := &resumeServer{content: content, etag: `"v1"`, fail: "bytes=75-99"}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "download")
+	d := &Downloader{Client: srv.Client(), ChunkSize: 25, Workers: 1}
+	specs := []RangeSpec{{First: 0, Last: 9}, {First: -1, Last: 25}}
+
+	if err := d.DownloadFileRanges(context.Background(), srv.URL, path, specs); err == nil {
+		t.Fatal("expected an error")
+	}
+	rs.fail = ""
+	rs.requested = nil
+	if err := d.DownloadFileRanges(context.Background(), srv.URL, path, specs); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rs.requested, []string{"bytes=0-0", "bytes=75-99"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges requested: got %q, want %q", got, want)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), content[:10]+strings.Repeat("\x00", 65)+content[75:]; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if _, err := os.Stat(path + ".ranger"); !os.IsNotExist(err) {
+		t.Errorf("checkpoint not removed: %v", err)
+	}
+
+	err = d.DownloadFileRanges(context.Background(), srv.URL, path, []RangeSpec{{First: 100, Last: -1}})
+	if got, want := fmt.Sprintf("%v", err), `invalid range: unsatisfiable: range 0 "100-": outside the content`; got != want {
+		t.Errorf("bad error: got %s, want %s", got, want)
+	}
+}
+
+func TestDownloaderDownloadFileChanged(t *testing.T) {
+	rs := &resumeServer{content: strings.Repeat("a", 100), etag: `"v1"`, fail: "bytes=50-74"}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "download")
+	d := &Downloader{Client: srv.Client(), ChunkSize: 25, Workers: 1}
+	if err := d.DownloadFile(context.Background(), srv.URL, path); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	rs.content, rs.etag, rs.fail = strings.Repeat("b", 100), `"v2"`, ""
+	if err := d.DownloadFile(context.Background(), srv.URL, path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), rs.content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package ranger
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WithContentEncoding says that the content is stored encoded, such as
+// compressed with gzip, and sends it with the given Content-Encoding. Ranges
+// then apply to the encoded bytes, as RFC 7233 says they must, and the ETag
+// should be that of the encoded content, distinct from that of any other
+// encoding of it, so that caches don't mix ranges of one with the other.
+func WithContentEncoding(encoding string) ServeOption {
+	return func(c *serveConfig) {
+		c.encoding = encoding
+	}
+}
+
+// WithoutEncodedRanges refuses ranges of content that has a Content-Encoding,
+// whether from WithContentEncoding, or set in the response's header before
+// the handler is called, such as by compressing middleware. Such requests are
+// answered with all of the content, in a 200, and Accept-Ranges is 'none'.
+// Ranges of content that the middleware then encodes would be ranges of the
+// wrong bytes, and corrupt the client's download.
+func WithoutEncodedRanges() ServeOption {
+	return func(c *serveConfig) {
+		c.noEncodedRanges = true
+	}
+}
+
+// WithPrecompressed makes ServeFile serve a precompressed alternate of the file,
+// named with ext appended, such as '.gz', to clients that accept the given
+// encoding, such as 'gzip', if there is one. The alternate is sent with that
+// Content-Encoding, its own size, and ranges of its own bytes. It's sent with
+// its own modification time too, unless one is set with WithModTime, which
+// takes precedence for the alternate as for the file. An ETag set with
+// WithETag has the encoding appended, so that '"v1"' becomes '"v1-gzip"', to
+// keep the validators of the two apart. Every response has a Vary field for
+// Accept-Encoding.
+//
+// If it's given more than once, the first alternate that the client accepts,
+// and that exists, is served.
+func WithPrecompressed(encoding, ext string) ServeOption {
+	return func(c *serveConfig) {
+		c.precompressed = append(c.precompressed, precompressed{encoding: encoding, ext: ext})
+	}
+}
+
+// precompressed is an alternate of a file for ServeFile, set by
+// WithPrecompressed.
+type precompressed struct {
+	encoding string
+	ext      string
+}
+
+// alternate returns the name of the alternate of the file at path that c says
+// to serve to r, and c with its encoding and ETag, if there's one that r
+// accepts.
+func (c *serveConfig) alternate(r *http.Request, path string) (string, *serveConfig, bool) {
+	for _, p := range c.precompressed {
+		if !acceptsEncoding(r.Header.Get("Accept-Encoding"), p.encoding) {
+			continue
+		}
+		fi, err := os.Stat(path + p.ext)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		cp := *c
+		cp.encoding = p.encoding
+		cp.etag = encodedETag(c.etag, p.encoding)
+		return path + p.ext, &cp, true
+	}
+	return "", c, false
+}
+
+// refusesRanges reports whether c says to refuse ranges of content with the
+// given Content-Encoding.
+func (c *serveConfig) refusesRanges(encoding string) bool {
+	return c.noEncodedRanges && encoding != "" && !strings.EqualFold(encoding, "identity")
+}
+
+// acceptsEncoding reports whether the value of an Accept-Encoding field accepts
+// encoding, by name or with '*', with a non-zero quality.
+func acceptsEncoding(header, encoding string) bool {
+	star := false
+	for _, v := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(v, ";")
+		name = strings.TrimSpace(name)
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.EqualFold(strings.TrimSpace(k), "q") {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		switch {
+		case strings.EqualFold(name, encoding):
+			return q > 0
+		case name == "*":
+			star = q > 0
+		}
+	}
+	return star
+}
+
+// encodedETag returns etag with encoding appended, inside the quotes. If etag
+// isn't quoted, such as if it's empty, it's returned as it is.
+func encodedETag(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) || len(etag) < 2 {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
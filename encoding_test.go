@@ -0,0 +1,161 @@
+package ranger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type acceptsEncodingTest struct {
+	Header   string
+	Expected bool
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []acceptsEncodingTest{
+		{ // listed
+			Header:   "br, gzip",
+			Expected: true,
+		},
+		{ // with a quality
+			Header:   "gzip;q=0.5",
+			Expected: true,
+		},
+		{ // refused
+			Header:   "gzip;q=0, *",
+			Expected: false,
+		},
+		{ // any
+			Header:   "*",
+			Expected: true,
+		},
+		{ // not listed
+			Header:   "br",
+			Expected: false,
+		},
+		{ // none
+			Header:   "",
+			Expected: false,
+		},
+	}
+	for i, test := range tests {
+		if got, want := acceptsEncoding(test.Header, "gzip"), test.Expected; got != want {
+			t.Errorf("test %d: bad result: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestWithContentEncoding(t *testing.T) {
+	h := Handler(strings.NewReader("compressed"), 10, WithContentEncoding("gzip"))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusPartialContent; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Content-Encoding"), "gzip"; got != want {
+		t.Errorf("bad content encoding: got %q, want %q", got, want)
+	}
+	if got, want := rec.Body.String(), "comp"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+}
+
+func TestWithoutEncodedRanges(t *testing.T) {
+	encoded := Handler(strings.NewReader("compressed"), 10, WithContentEncoding("gzip"), WithoutEncodedRanges())
+	plain := Handler(strings.NewReader("compressed"), 10, WithoutEncodedRanges())
+	middleware := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		plain.ServeHTTP(w, r)
+	})
+	for i, h := range []http.Handler{encoded, middleware} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Range", "bytes=0-3")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusOK; got != want {
+			t.Errorf("handler %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := rec.Header().Get("Accept-Ranges"), "none"; got != want {
+			t.Errorf("handler %d: bad accept ranges: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Body.String(), "compressed"; got != want {
+			t.Errorf("handler %d: bad body: got %q, want %q", i, got, want)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	rec := httptest.NewRecorder()
+	plain.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusPartialContent; got != want {
+		t.Errorf("bad status without encoding: got %d, want %d", got, want)
+	}
+}
+
+type precompressedTest struct {
+	AcceptEncoding          string
+	ExpectedBody            string
+	ExpectedContentEncoding string
+	ExpectedETag            string
+}
+
+func TestServeFilePrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(path, []byte("plain text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".gz", []byte("gzipped"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []precompressedTest{
+		{ // accepted
+			AcceptEncoding:          "gzip, br",
+			ExpectedBody:            "gzip",
+			ExpectedContentEncoding: "gzip",
+			ExpectedETag:            `"v1-gzip"`,
+		},
+		{ // not accepted
+			AcceptEncoding: "br",
+			ExpectedBody:   "plai",
+			ExpectedETag:   `"v1"`,
+		},
+		{ // refused
+			AcceptEncoding: "gzip;q=0",
+			ExpectedBody:   "plai",
+			ExpectedETag:   `"v1"`,
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Range", "bytes=0-3")
+		req.Header.Set("Accept-Encoding", test.AcceptEncoding)
+		rec := httptest.NewRecorder()
+		if err := ServeFile(rec, req, path, WithETag(`"v1"`), WithPrecompressed("gzip", ".gz")); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := rec.Code, http.StatusPartialContent; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := rec.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Header().Get("Content-Encoding"), test.ExpectedContentEncoding; got != want {
+			t.Errorf("test %d: bad content encoding: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Header().Get("Etag"), test.ExpectedETag; got != want {
+			t.Errorf("test %d: bad etag: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Header().Get("Content-Type"), "text/plain; charset=utf-8"; got != want {
+			t.Errorf("test %d: bad content type: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Header().Get("Vary"), "Accept-Encoding"; got != want {
+			t.Errorf("test %d: bad vary: got %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ranger
+
+import (
+	"context"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ETagFunc makes the entity tag of content last modified at modtime, or the
+// zero time if that's unknown, for WithETagFunc. It returns "" if it can't make
+// one, such as from a modification time that's unknown.
+type ETagFunc func(ctx context.Context, content Content, modtime time.Time) (string, error)
+
+// WithETagFunc makes the ETag of the content with f, unless WithETag or the
+// content gives one, so that callers needn't work out validators themselves.
+// The ETag is sent in the ETag field, and used to evaluate If-Range,
+// If-Match and If-None-Match, as one set with WithETag is; a resumed download
+// is only as safe as its validator is stable.
+//
+// Handler makes the ETag once, with the first request, since its content
+// can't change; the other handlers make it afresh with each request, so f
+// should be cheap for them, as SizeModTimeETag is. If f fails, the request is
+// answered with a 500.
+func WithETagFunc(f ETagFunc) ServeOption {
+	return func(c *serveConfig) {
+		c.etagFunc = f
+	}
+}
+
+// SizeModTimeETag is an ETagFunc that makes a strong ETag from the size and
+// the modification time of the content, in hex, as many file servers do,
+// without reading any of it. Content with no modification time gets no ETag,
+// since its size alone is no validator.
+func SizeModTimeETag(ctx context.Context, content Content, modtime time.Time) (string, error) {
+	if modtime.IsZero() {
+		return "", nil
+	}
+	return `"` + strconv.FormatInt(modtime.UnixNano(), 16) + "-" + strconv.FormatInt(content.Size(), 16) + `"`, nil
+}
+
+// HashETag returns an ETagFunc that makes a strong ETag from the hash of all
+// of the content, with a hash from newHash, such as sha256.New. Since it
+// reads all of the content to make the ETag, it suits Handler, which makes it
+// once, far better than handlers that make it with each request.
+func HashETag(newHash func() hash.Hash) ETagFunc {
+	return func(ctx context.Context, content Content, modtime time.Time) (string, error) {
+		h := newHash()
+		if size := content.Size(); size > 0 {
+			rc, err := content.ReadRange(ctx, Range{Start: 0, Stop: size - 1})
+			if err != nil {
+				return "", err
+			}
+			defer rc.Close()
+			if _, err := io.Copy(h, rc); err != nil {
+				return "", err
+			}
+		}
+		return `"` + hex.EncodeToString(h.Sum(nil)) + `"`, nil
+	}
+}
+
+// WeakETag returns an ETagFunc that makes the ETag that f does, marked weak,
+// for content whose bytes may differ when its meaning doesn't. A weak ETag
+// still answers If-None-Match with a 304, but never matches If-Range, so that
+// a client can't resume a download across a change in the bytes.
+func WeakETag(f ETagFunc) ETagFunc {
+	return func(ctx context.Context, content Content, modtime time.Time) (string, error) {
+		etag, err := f(ctx, content, modtime)
+		if err != nil || etag == "" || strings.HasPrefix(etag, "W/") {
+			return etag, err
+		}
+		return "W/" + etag, nil
+	}
+}
+
+// onceETag returns an ETagFunc that makes the ETag with f once, and keeps it
+// once it has been made, for content that doesn't change. A failure isn't
+// kept, so the next request tries again.
+func onceETag(f ETagFunc) ETagFunc {
+	var mu sync.Mutex
+	var etag string
+	var done bool
+	return func(ctx context.Context, content Content, modtime time.Time) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return etag, nil
+		}
+		var err error
+		if etag, err = f(ctx, content, modtime); err != nil {
+			return "", err
+		}
+		done = true
+		return etag, nil
+	}
+}
+
+// withETag returns c with the ETag made by c's ETagFunc for src, if c has one,
+// and doesn't already have an ETag.
+func (c *serveConfig) withETag(ctx context.Context, src Content) (*serveConfig, error) {
+	if c.etag != "" || c.etagFunc == nil {
+		return c, nil
+	}
+	etag, err := c.etagFunc(ctx, src, c.modtime)
+	if err != nil {
+		return nil, err
+	}
+	cp := *c
+	cp.etag = etag
+	return &cp, nil
+}
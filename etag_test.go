@@ -0,0 +1,176 @@
+package ranger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type etagFuncTest struct {
+	Func         ETagFunc
+	ModTime      time.Time
+	ExpectedETag string
+}
+
+func TestETagFuncs(t *testing.T) {
+	modtime := time.Unix(0, 0x1234)
+	sum := sha256.Sum256([]byte("0123456789"))
+	hashed := `"` + hex.EncodeToString(sum[:]) + `"`
+	tests := []etagFuncTest{
+		{ // size and modtime
+			Func:         SizeModTimeETag,
+			ModTime:      modtime,
+			ExpectedETag: `"1234-a"`,
+		},
+		{ // no modtime
+			Func: SizeModTimeETag,
+		},
+		{ // hash
+			Func:         HashETag(sha256.New),
+			ExpectedETag: hashed,
+		},
+		{ // weak
+			Func:         WeakETag(SizeModTimeETag),
+			ModTime:      modtime,
+			ExpectedETag: `W/"1234-a"`,
+		},
+		{ // weak, with no etag
+			Func: WeakETag(SizeModTimeETag),
+		},
+	}
+	content := readerAtContent{strings.NewReader("0123456789"), 10}
+	for i, test := range tests {
+		etag, err := test.Func(context.Background(), content, test.ModTime)
+		if err != nil {
+			t.Fatalf("test %d: %s", i, err)
+		}
+		if got, want := etag, test.ExpectedETag; got != want {
+			t.Errorf("test %d: bad etag: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+// countingETag is an ETagFunc that makes etag, and counts the calls.
+type countingETag struct {
+	etag  string
+	err   error
+	calls int
+}
+
+func (c *countingETag) make(ctx context.Context, content Content, modtime time.Time) (string, error) {
+	c.calls++
+	return c.etag, c.err
+}
+
+type handlerETagTest struct {
+	Header         http.Header
+	ExpectedStatus int
+}
+
+func TestHandlerETagFunc(t *testing.T) {
+	f := &countingETag{etag: `"made"`}
+	h := Handler(strings.NewReader("0123456789"), 10, WithETagFunc(f.make))
+	tests := []handlerETagTest{
+		{ // no conditions
+			Header:         http.Header{"Range": {"bytes=0-4"}},
+			ExpectedStatus: http.StatusPartialContent,
+		},
+		{ // If-Range matches
+			Header:         http.Header{"Range": {"bytes=0-4"}, "If-Range": {`"made"`}},
+			ExpectedStatus: http.StatusPartialContent,
+		},
+		{ // If-Range doesn't match
+			Header:         http.Header{"Range": {"bytes=0-4"}, "If-Range": {`"other"`}},
+			ExpectedStatus: http.StatusOK,
+		},
+		{ // If-None-Match
+			Header:         http.Header{"If-None-Match": {`"made"`}},
+			ExpectedStatus: http.StatusNotModified,
+		},
+		{ // If-Match
+			Header:         http.Header{"If-Match": {`"other"`}},
+			ExpectedStatus: http.StatusPreconditionFailed,
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header = test.Header
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := rec.Header().Get("Etag"), `"made"`; got != want {
+			t.Errorf("test %d: bad etag: got %q, want %q", i, got, want)
+		}
+	}
+	if got, want := f.calls, 1; got != want {
+		t.Errorf("bad number of calls: got %d, want %d", got, want)
+	}
+}
+
+func TestHandlerWeakETagFunc(t *testing.T) {
+	f := &countingETag{etag: `"made"`}
+	h := Handler(strings.NewReader("0123456789"), 10, WithETagFunc(WeakETag(f.make)))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-4")
+	req.Header.Set("If-Range", `W/"made"`)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Etag"), `W/"made"`; got != want {
+		t.Errorf("bad etag: got %q, want %q", got, want)
+	}
+}
+
+func TestHandlerETagFuncOverridden(t *testing.T) {
+	f := &countingETag{etag: `"made"`}
+	h := Handler(strings.NewReader("0123456789"), 10, WithETag(`"v1"`), WithETagFunc(f.make))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got, want := rec.Header().Get("Etag"), `"v1"`; got != want {
+		t.Errorf("bad etag: got %q, want %q", got, want)
+	}
+	if f.calls != 0 {
+		t.Errorf("bad number of calls: got %d, want 0", f.calls)
+	}
+}
+
+func TestHandlerETagFuncError(t *testing.T) {
+	f := &countingETag{err: errors.New("boom")}
+	h := Handler(strings.NewReader("0123456789"), 10, WithETagFunc(f.make))
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if got, want := rec.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("bad status: got %d, want %d", got, want)
+		}
+	}
+	// Failures aren't kept.
+	if got, want := f.calls, 2; got != want {
+		t.Errorf("bad number of calls: got %d, want %d", got, want)
+	}
+}
+
+func TestContentHandlerETagFunc(t *testing.T) {
+	f := &countingETag{etag: `"made"`}
+	h := ContentHandler(readerAtContent{strings.NewReader("0123456789"), 10}, WithETagFunc(f.make))
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if got, want := rec.Header().Get("Etag"), `"made"`; got != want {
+			t.Errorf("bad etag: got %q, want %q", got, want)
+		}
+	}
+	if got, want := f.calls, 2; got != want {
+		t.Errorf("bad number of calls: got %d, want %d", got, want)
+	}
+}
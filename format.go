@@ -0,0 +1,119 @@
+package ranger
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// String formats r as a range, such as '0-99'.
+func (r Range) String() string {
+	return strconv.FormatInt(r.Start, 10) + "-" + strconv.FormatInt(r.Stop, 10)
+}
+
+// FormatOption configures how Format writes ranges.
+type FormatOption func(*formatConfig)
+
+type formatConfig struct {
+	length int64
+	open   bool
+	suffix bool
+}
+
+// WithOpenEnded makes Format write any range that reaches the end of content
+// of length bytes in the open-ended form, such as '100-'.
+func WithOpenEnded(length int64) FormatOption {
+	return func(c *formatConfig) {
+		c.length, c.open, c.suffix = length, true, false
+	}
+}
+
+// WithSuffix makes Format write any range that reaches the end of content of
+// length bytes in the suffix form, such as '-100'.
+func WithSuffix(length int64) FormatOption {
+	return func(c *formatConfig) {
+		c.length, c.open, c.suffix = length, false, true
+	}
+}
+
+// Format formats ranges as the value of a Range request header, such as
+// 'bytes=0-99,200-350'. The ranges are written in the order given. It's the
+// inverse of Parse.
+//
+// If ranges is empty, Format returns the empty string.
+func Format(ranges []Range, opts ...FormatOption) string {
+	if len(ranges) == 0 {
+		return ""
+	}
+	var cfg formatConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var b strings.Builder
+	b.WriteString("bytes=")
+	for i, r := range ranges {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		switch {
+		case cfg.open && r.Stop == cfg.length-1:
+			b.WriteString(strconv.FormatInt(r.Start, 10))
+			b.WriteByte('-')
+		case cfg.suffix && r.Stop == cfg.length-1:
+			b.WriteByte('-')
+			b.WriteString(strconv.FormatInt(r.Stop-r.Start+1, 10))
+		default:
+			b.WriteString(r.String())
+		}
+	}
+	return b.String()
+}
+
+// SetRange sets the Range field of req to ask for ranges, in the order given,
+// as Format writes them.
+//
+// Reversed ranges and ranges at negative offsets can't be written, and neither
+// can ranges that overlap, which RFC 7233 asks clients not to send, nor more
+// than MaxRanges of them, nor none at all. For those, req is left as it was,
+// and an error wrapping ErrMalformed or ErrLimit is returned.
+func SetRange(req *http.Request, ranges []Range) error {
+	if len(ranges) == 0 {
+		return fmt.Errorf("%w: no ranges", ErrMalformed)
+	}
+	if len(ranges) > MaxRanges {
+		return fmt.Errorf("%w: %d ranges", ErrLimit, len(ranges))
+	}
+	for _, r := range ranges {
+		if r.Start < 0 || r.Start > r.Stop {
+			return fmt.Errorf("%w: %v", ErrMalformed, r)
+		}
+	}
+	if overlaps := FindOverlaps(ranges); len(overlaps) > 0 {
+		return fmt.Errorf("%w: %v overlaps %v", ErrMalformed, overlaps[0][0], overlaps[0][1])
+	}
+	req.Header.Set("Range", Format(ranges))
+	return nil
+}
+
+// SetSuffixRange sets the Range field of req to ask for the last n bytes of
+// the content, as 'bytes=-n'. If n isn't positive, req is left as it was, and
+// an error wrapping ErrMalformed is returned.
+func SetSuffixRange(req *http.Request, n int64) error {
+	if n <= 0 {
+		return fmt.Errorf("%w: suffix of %d bytes", ErrMalformed, n)
+	}
+	req.Header.Set("Range", "bytes="+RangeSpec{First: -1, Last: n}.String())
+	return nil
+}
+
+// SetOpenRange sets the Range field of req to ask for the content from offset
+// start to its end, as 'bytes=start-'. If start is negative, req is left as it
+// was, and an error wrapping ErrMalformed is returned.
+func SetOpenRange(req *http.Request, start int64) error {
+	if start < 0 {
+		return fmt.Errorf("%w: negative offset %d", ErrMalformed, start)
+	}
+	req.Header.Set("Range", "bytes="+RangeSpec{First: start, Last: -1}.String())
+	return nil
+}
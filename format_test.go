@@ -0,0 +1,141 @@
+package ranger
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type formatTest struct {
+	Ranges   []Range
+	Options  []FormatOption
+	Expected string
+}
+
+func TestFormat(t *testing.T) {
+	tests := []formatTest{
+		{ // closed ranges
+			Ranges:   []Range{{Start: 0, Stop: 99}, {Start: 200, Stop: 350}},
+			Expected: "bytes=0-99,200-350",
+		},
+		{ // open-ended
+			Ranges:   []Range{{Start: 0, Stop: 99}, {Start: 200, Stop: 399}},
+			Options:  []FormatOption{WithOpenEnded(400)},
+			Expected: "bytes=0-99,200-",
+		},
+		{ // suffix
+			Ranges:   []Range{{Start: 0, Stop: 99}, {Start: 200, Stop: 399}},
+			Options:  []FormatOption{WithSuffix(400)},
+			Expected: "bytes=0-99,-200",
+		},
+		{ // range not reaching the end
+			Ranges:   []Range{{Start: 200, Stop: 398}},
+			Options:  []FormatOption{WithSuffix(400)},
+			Expected: "bytes=200-398",
+		},
+		{ // empty
+			Expected: "",
+		},
+	}
+	for i, test := range tests {
+		if got, want := Format(test.Ranges, test.Options...), test.Expected; got != want {
+			t.Errorf("test %d: bad format: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	ranges := []Range{{Start: 0, Stop: 99}, {Start: 200, Stop: 299}, {Start: 350, Stop: 399}}
+	for _, opt := range []FormatOption{WithOpenEnded(400), WithSuffix(400)} {
+		parsed, err := ParseHeaderSize(map[string][]string{"Range": {Format(ranges, opt)}}, 400)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := parsed, ranges; !reflect.DeepEqual(got, want) {
+			t.Errorf("bad ranges: got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRangeString(t *testing.T) {
+	if got, want := (Range{Start: 100, Stop: 200}).String(), "100-200"; got != want {
+		t.Errorf("bad string: got %q, want %q", got, want)
+	}
+}
+
+type setRangeTest struct {
+	Ranges        []Range
+	Expected      string
+	ExpectedError string
+}
+
+func TestSetRange(t *testing.T) {
+	tests := []setRangeTest{
+		{ // one range
+			Ranges:        []Range{{Start: 0, Stop: 99}},
+			Expected:      "bytes=0-99",
+			ExpectedError: "<nil>",
+		},
+		{ // several, in order given
+			Ranges:        []Range{{Start: 200, Stop: 299}, {Start: 0, Stop: 0}},
+			Expected:      "bytes=200-299,0-0",
+			ExpectedError: "<nil>",
+		},
+		{ // none
+			ExpectedError: "invalid range: malformed: no ranges",
+		},
+		{ // reversed
+			Ranges:        []Range{{Start: 10, Stop: 9}},
+			ExpectedError: "invalid range: malformed: 10-9",
+		},
+		{ // negative
+			Ranges:        []Range{{Start: -1, Stop: 9}},
+			ExpectedError: "invalid range: malformed: -1-9",
+		},
+		{ // overlapping
+			Ranges:        []Range{{Start: 0, Stop: 9}, {Start: 5, Stop: 15}},
+			ExpectedError: "invalid range: malformed: 0-9 overlaps 5-15",
+		},
+		{ // too many
+			Ranges:        make([]Range, MaxRanges+1),
+			ExpectedError: "invalid range: limit exceeded: 1001 ranges",
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		err := SetRange(req, test.Ranges)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %v, want %v", i, got, want)
+		}
+		if got, want := req.Header.Get("Range"), test.Expected; got != want {
+			t.Errorf("test %d: bad range: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestSetSuffixAndOpenRange(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if err := SetSuffixRange(req, 500); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.Header.Get("Range"), "bytes=-500"; got != want {
+		t.Errorf("bad range: got %q, want %q", got, want)
+	}
+	if err := SetOpenRange(req, 9500); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.Header.Get("Range"), "bytes=9500-"; got != want {
+		t.Errorf("bad range: got %q, want %q", got, want)
+	}
+	if err := SetSuffixRange(req, 0); !errors.Is(err, ErrMalformed) {
+		t.Errorf("bad error: got %v, want %v", err, ErrMalformed)
+	}
+	if err := SetOpenRange(req, -1); !errors.Is(err, ErrMalformed) {
+		t.Errorf("bad error: got %v, want %v", err, ErrMalformed)
+	}
+	if got, want := req.Header.Get("Range"), "bytes=9500-"; got != want {
+		t.Errorf("bad range after errors: got %q, want %q", got, want)
+	}
+}
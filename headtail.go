@@ -0,0 +1,176 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// fetchedPart is a range of a resource, and its bytes.
+type fetchedPart struct {
+	r    Range
+	data []byte
+}
+
+// FetchHeadTail fetches the first headN and the last tailN bytes of the
+// resource at url, and returns them along with its length, for sniffing the
+// format of a file whose index is at either end, such as the moov atom of an
+// MP4 or the end of central directory of a ZIP. If client is nil,
+// http.DefaultClient is used. If the resource is shorter than headN or tailN,
+// all of it is returned for each; where the two overlap, they share bytes.
+//
+// FetchHeadTail asks for both with a single request, as 'bytes=0-99,-100'. If
+// the server answers with just one of them, or with all of the resource, as
+// servers that don't serve multipart/byteranges often do, or refuses a head
+// longer than the resource, the rest is fetched with a request for each, and
+// only as much of the first response is read as is needed. If the resource
+// changes between the requests, the error is ErrResourceChanged, and if the
+// server doesn't serve byte ranges, and the resource is longer than the head,
+// it's ErrNotSupported. Any status other than 200, 206 or 416 gets a
+// *StatusError.
+func FetchHeadTail(ctx context.Context, client *http.Client, url string, headN, tailN int64) (head, tail []byte, size int64, err error) {
+	if headN <= 0 || tailN <= 0 {
+		return nil, nil, -1, errors.New("ranger: head and tail lengths must be positive")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := probeRequest(ctx, client, http.MethodGet, url, http.Header{"Range": {fmt.Sprintf("bytes=0-%d,-%d", headN-1, tailN)}})
+	if err != nil {
+		return nil, nil, -1, err
+	}
+	defer resp.Body.Close()
+	v := newValidators(resp.Header)
+	parts, size, err := readHeadTail(resp, headN)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+	if size < 0 {
+		// Only a suffix range gives the length of a resource whose length the
+		// first response didn't give.
+		part, total, err := fetchPart(ctx, client, url, fmt.Sprintf("bytes=-%d", tailN), v)
+		if err != nil {
+			return nil, nil, -1, err
+		}
+		parts = append(parts, part)
+		size = total
+	}
+	if size == 0 {
+		return []byte{}, []byte{}, 0, nil
+	}
+	headRange := Range{Start: 0, Stop: min(headN, size) - 1}
+	tailRange := Range{Start: size - min(tailN, size), Stop: size - 1}
+	if head = partBytes(parts, headRange); head == nil {
+		part, _, err := fetchPart(ctx, client, url, "bytes="+headRange.String(), v)
+		if err != nil {
+			return nil, nil, -1, err
+		}
+		parts = append(parts, part)
+		head = partBytes(parts, headRange)
+	}
+	if tail = partBytes(parts, tailRange); tail == nil {
+		part, _, err := fetchPart(ctx, client, url, "bytes="+tailRange.String(), v)
+		if err != nil {
+			return nil, nil, -1, err
+		}
+		parts = append(parts, part)
+		tail = partBytes(parts, tailRange)
+	}
+	if head == nil || tail == nil {
+		return nil, nil, -1, fmt.Errorf("%w: response doesn't cover the range asked for", ErrContentRange)
+	}
+	return head, tail, size, nil
+}
+
+// readHeadTail reads the parts of resp, the response to FetchHeadTail's first
+// request, and the length of the resource, or -1 if it doesn't say. Of a 200,
+// only the first headN bytes are read.
+func readHeadTail(resp *http.Response, headN int64) ([]fetchedPart, int64, error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		b, err := io.ReadAll(io.LimitReader(resp.Body, headN))
+		if err != nil {
+			return nil, -1, err
+		}
+		size := resp.ContentLength
+		if int64(len(b)) < headN {
+			size = int64(len(b))
+		}
+		if len(b) == 0 {
+			return nil, size, nil
+		}
+		return []fetchedPart{{r: Range{Start: 0, Stop: int64(len(b)) - 1}, data: b}}, size, nil
+	case http.StatusPartialContent:
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "multipart/byteranges" {
+			mr, err := NewMultipartResponseReader(resp)
+			if err != nil {
+				return nil, -1, err
+			}
+			var parts []fetchedPart
+			for {
+				r, pr, err := mr.NextPart()
+				if err == io.EOF {
+					return parts, mr.Length(), nil
+				} else if err != nil {
+					return nil, -1, err
+				}
+				b, err := io.ReadAll(pr)
+				if err != nil {
+					return nil, -1, err
+				}
+				parts = append(parts, fetchedPart{r: r, data: b})
+			}
+		}
+	}
+	ranges, size, err := ParseResponseRanges(resp)
+	if err != nil || len(ranges) == 0 {
+		return nil, size, err
+	}
+	b := make([]byte, ranges[0].Len())
+	if _, err := io.ReadFull(resp.Body, b); err != nil {
+		return nil, -1, err
+	}
+	return []fetchedPart{{r: ranges[0], data: b}}, size, nil
+}
+
+// fetchPart fetches the range in rangeValue, a value for the Range field, of
+// the resource at url, whose validators are v, and returns it along with the
+// length of the resource.
+func fetchPart(ctx context.Context, client *http.Client, url, rangeValue string, v validators) (fetchedPart, int64, error) {
+	resp, err := probeRequest(ctx, client, http.MethodGet, url, http.Header{"Range": {rangeValue}})
+	if err != nil {
+		return fetchedPart{}, -1, err
+	}
+	defer resp.Body.Close()
+	if v.changed(resp.Header) {
+		return fetchedPart{}, -1, ErrResourceChanged
+	}
+	if resp.StatusCode == http.StatusOK {
+		return fetchedPart{}, -1, ErrNotSupported
+	}
+	ranges, size, err := ParseResponseRanges(resp)
+	if err != nil {
+		return fetchedPart{}, -1, err
+	}
+	if len(ranges) == 0 {
+		return fetchedPart{}, -1, ErrResourceChanged
+	}
+	b := make([]byte, ranges[0].Len())
+	if _, err := io.ReadFull(resp.Body, b); err != nil {
+		return fetchedPart{}, -1, err
+	}
+	return fetchedPart{r: ranges[0], data: b}, size, nil
+}
+
+// partBytes returns the bytes of r, if one of parts holds all of them, or nil.
+func partBytes(parts []fetchedPart, r Range) []byte {
+	for _, p := range parts {
+		if in, ok := p.r.Intersect(r); ok && in == r {
+			return p.data[r.Start-p.r.Start : r.Stop-p.r.Start+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// rewritingServer serves content with Handler, rewriting the Range field of
+// each request first, as a server with partial support for ranges would
+// handle it, and counts the requests.
+type rewritingServer struct {
+	h        http.Handler
+	rewrite  func(string) string
+	requests int64
+}
+
+func (s *rewritingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.requests, 1)
+	if v := s.rewrite(r.Header.Get("Range")); v != "" {
+		r.Header.Set("Range", v)
+	} else {
+		r.Header.Del("Range")
+	}
+	s.h.ServeHTTP(w, r)
+}
+
+type fetchHeadTailTest struct {
+	Content          string
+	Rewrite          func(string) string
+	HeadN, TailN     int64
+	ExpectedHead     string
+	ExpectedTail     string
+	ExpectedError    string
+	ExpectedRequests int64
+}
+
+func TestFetchHeadTail(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	same := func(v string) string { return v }
+	tests := []fetchHeadTailTest{
+		{ // multipart
+			Content:          content,
+			Rewrite:          same,
+			HeadN:            10,
+			TailN:            15,
+			ExpectedHead:     content[:10],
+			ExpectedTail:     content[985:],
+			ExpectedError:    "<nil>",
+			ExpectedRequests: 1,
+		},
+		{ // overlapping, merged into one part
+			Content:          content[:15],
+			Rewrite:          same,
+			HeadN:            10,
+			TailN:            10,
+			ExpectedHead:     content[:10],
+			ExpectedTail:     content[5:15],
+			ExpectedError:    "<nil>",
+			ExpectedRequests: 1,
+		},
+		{ // shorter than both, clamped and merged into one part
+			Content:          content[:5],
+			Rewrite:          same,
+			HeadN:            10,
+			TailN:            10,
+			ExpectedHead:     content[:5],
+			ExpectedTail:     content[:5],
+			ExpectedError:    "<nil>",
+			ExpectedRequests: 1,
+		},
+		{ // only the first range is served
+			Content: content,
+			Rewrite: func(v string) string {
+				first, _, _ := strings.Cut(v, ",")
+				return first
+			},
+			HeadN:            10,
+			TailN:            15,
+			ExpectedHead:     content[:10],
+			ExpectedTail:     content[985:],
+			ExpectedError:    "<nil>",
+			ExpectedRequests: 2,
+		},
+		{ // several ranges get all of it, as from S3
+			Content: content,
+			Rewrite: func(v string) string {
+				if strings.Contains(v, ",") {
+					return ""
+				}
+				return v
+			},
+			HeadN:            10,
+			TailN:            15,
+			ExpectedHead:     content[:10],
+			ExpectedTail:     content[985:],
+			ExpectedError:    "<nil>",
+			ExpectedRequests: 2,
+		},
+		{ // no ranges
+			Content:          content,
+			Rewrite:          func(string) string { return "" },
+			HeadN:            10,
+			TailN:            15,
+			ExpectedError:    "ranger: ranges not supported",
+			ExpectedRequests: 2,
+		},
+		{ // no ranges, but short enough
+			Content:          content[:8],
+			Rewrite:          func(string) string { return "" },
+			HeadN:            10,
+			TailN:            15,
+			ExpectedHead:     content[:8],
+			ExpectedTail:     content[:8],
+			ExpectedError:    "<nil>",
+			ExpectedRequests: 1,
+		},
+		{ // empty
+			Rewrite:          same,
+			HeadN:            10,
+			TailN:            15,
+			ExpectedError:    "<nil>",
+			ExpectedRequests: 1,
+		},
+		{ // bad lengths
+			Content:       content,
+			Rewrite:       same,
+			HeadN:         0,
+			TailN:         15,
+			ExpectedError: "ranger: head and tail lengths must be positive",
+		},
+	}
+	for i, test := range tests {
+		rs := &rewritingServer{h: Handler(strings.NewReader(test.Content), int64(len(test.Content))), rewrite: test.Rewrite}
+		srv := httptest.NewServer(rs)
+		head, tail, size, err := FetchHeadTail(context.Background(), srv.Client(), srv.URL, test.HeadN, test.TailN)
+		srv.Close()
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := string(head), test.ExpectedHead; got != want {
+			t.Errorf("test %d: bad head: got %q, want %q", i, got, want)
+		}
+		if got, want := string(tail), test.ExpectedTail; got != want {
+			t.Errorf("test %d: bad tail: got %q, want %q", i, got, want)
+		}
+		if err == nil {
+			if got, want := size, int64(len(test.Content)); got != want {
+				t.Errorf("test %d: bad size: got %d, want %d", i, got, want)
+			}
+		}
+		if got, want := atomic.LoadInt64(&rs.requests), test.ExpectedRequests; got != want {
+			t.Errorf("test %d: bad number of requests: got %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestFetchHeadTailResourceChanged(t *testing.T) {
+	cs := &changingServer{useETag: true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Serve the head alone, then change before the tail is fetched.
+		if first, _, ok := strings.Cut(r.Header.Get("Range"), ","); ok {
+			r.Header.Set("Range", first)
+			cs.ServeHTTP(w, r)
+			cs.change()
+			return
+		}
+		cs.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	if _, _, _, err := FetchHeadTail(context.Background(), srv.Client(), srv.URL, 2, 2); !errors.Is(err, ErrResourceChanged) {
+		t.Errorf("bad error: got %v, want %v", err, ErrResourceChanged)
+	}
+}
+
+func TestFetchHeadTailStatus(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	var serr *StatusError
+	if _, _, _, err := FetchHeadTail(context.Background(), srv.Client(), srv.URL, 2, 2); !errors.As(err, &serr) {
+		t.Errorf("bad error: got %v, want a *StatusError", err)
+	}
+}
@@ -0,0 +1,65 @@
+package ranger
+
+import (
+	"net/http"
+	"time"
+)
+
+// Hooks are callbacks for observing ranges as they're parsed, served and
+// fetched, such as to count them with a metrics library. Any of them may be
+// nil. They may be called concurrently, and should return quickly.
+type Hooks struct {
+	// OnParsed is called by the serving handlers with the ranges parsed from
+	// a request's Range header.
+	OnParsed func(r *http.Request, ranges []Range)
+
+	// OnRejected is called by the serving handlers when a request's Range
+	// header is rejected, with the error from parsing it: wrapping
+	// ErrMalformed, ErrUnsatisfiable or ErrLimit.
+	OnRejected func(r *http.Request, err error)
+
+	// OnServed is called by the serving handlers once a response has been
+	// written, with its status, the number of bytes in its body, and how long
+	// it took to write.
+	OnServed func(r *http.Request, status int, bytes int64, d time.Duration)
+
+	// OnFetched is called by a Downloader for each chunk it fetches, with how
+	// long it took, including any retries, and the error if it failed.
+	OnFetched func(chunk Range, d time.Duration, err error)
+
+	// Tracer, if set, traces the serving handlers' requests, and the parsing
+	// of their Range headers, and each chunk a Downloader fetches, so that a
+	// slow request can be traced to the range that held it up. See SpanServe
+	// for the spans.
+	Tracer Tracer
+}
+
+// WithHooks sets hooks for observing the ranges requested and served.
+func WithHooks(hooks Hooks) ServeOption {
+	return func(c *serveConfig) {
+		c.hooks = hooks
+	}
+}
+
+// observedResponseWriter records the status and size of a response.
+type observedResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *observedResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *observedResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
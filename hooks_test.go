@@ -0,0 +1,169 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	var parsed [][]Range
+	var rejected []error
+	var statuses []int
+	var sizes []int64
+	hooks := Hooks{
+		OnParsed: func(r *http.Request, ranges []Range) {
+			parsed = append(parsed, ranges)
+		},
+		OnRejected: func(r *http.Request, err error) {
+			rejected = append(rejected, err)
+		},
+		OnServed: func(r *http.Request, status int, bytes int64, d time.Duration) {
+			statuses = append(statuses, status)
+			sizes = append(sizes, bytes)
+		},
+	}
+	h := Handler(strings.NewReader("0123456789"), 10, WithHooks(hooks))
+	for _, rng := range []string{"", "bytes=2-4", "bytes=99-", "bytes=x"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if got, want := parsed, [][]Range{{{Start: 2, Stop: 4}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad parsed ranges: got %v, want %v", got, want)
+	}
+	if got, want := len(rejected), 2; got != want {
+		t.Fatalf("bad number of rejections: got %d, want %d", got, want)
+	}
+	if !errors.Is(rejected[0], ErrUnsatisfiable) || !errors.Is(rejected[1], ErrMalformed) {
+		t.Errorf("bad rejections: %v", rejected)
+	}
+	if got, want := statuses, []int{200, 206, 416, 200}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad statuses: got %v, want %v", got, want)
+	}
+	if got, want := sizes, []int64{10, 3, 0, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad sizes: got %v, want %v", got, want)
+	}
+}
+
+func TestDownloaderHooks(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, _ := countingServer(t, content)
+	var mu sync.Mutex
+	var fetched []Range
+	d := &Downloader{Client: srv.Client(), ChunkSize: 30, Hooks: Hooks{
+		OnFetched: func(chunk Range, d time.Duration, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			fetched = append(fetched, chunk)
+			mu.Unlock()
+		},
+	}}
+	if _, err := d.Download(context.Background(), srv.URL, memFile(make([]byte, 100)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Merge(fetched), []Range{{Start: 0, Stop: 99}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad chunks fetched: got %v, want %v", got, want)
+	}
+	if got, want := len(fetched), 4; got != want {
+		t.Errorf("bad number of chunks: got %d, want %d", got, want)
+	}
+}
+
+// fakeTracer records the spans it starts.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+type fakeSpan struct {
+	name  string
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span) {
+	s := &fakeSpan{name: name, attrs: make(map[string]any)}
+	s.SetAttributes(attrs...)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func (s *fakeSpan) SetAttributes(attrs ...Attr) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *fakeSpan) End(err error) {
+	s.err, s.ended = err, true
+}
+
+func TestHooksTracer(t *testing.T) {
+	tracer := new(fakeTracer)
+	h := Handler(strings.NewReader("0123456789"), 10, WithHooks(Hooks{Tracer: tracer}))
+	for _, rng := range []string{"", "bytes=2-4,6-", "bytes=x"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	var names []string
+	for _, s := range tracer.spans {
+		names = append(names, s.name)
+		if !s.ended {
+			t.Errorf("span %s not ended", s.name)
+		}
+	}
+	if got, want := names, []string{SpanServe, SpanServe, SpanParse, SpanServe, SpanParse}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad spans: got %v, want %v", got, want)
+	}
+	if got, want := tracer.spans[1].attrs, map[string]any{AttrMethod: "GET", AttrStatus: 206, AttrBytes: tracer.spans[1].attrs[AttrBytes]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad serve attributes: got %v, want %v", got, want)
+	}
+	if got, want := tracer.spans[2].attrs, map[string]any{AttrRange: "bytes=2-4,6-", AttrRangeCount: 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad parse attributes: got %v, want %v", got, want)
+	}
+	if err := tracer.spans[4].err; !errors.Is(err, ErrMalformed) {
+		t.Errorf("bad parse error: got %v, want %v", err, ErrMalformed)
+	}
+}
+
+func TestDownloaderTracer(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, _ := countingServer(t, content)
+	tracer := new(fakeTracer)
+	d := &Downloader{Client: srv.Client(), ChunkSize: 40, Workers: 1, Hooks: Hooks{Tracer: tracer}}
+	if _, err := d.Download(context.Background(), srv.URL, memFile(make([]byte, 100)), nil); err != nil {
+		t.Fatal(err)
+	}
+	var ranges []any
+	var bytes int64
+	for _, s := range tracer.spans {
+		if s.name != SpanFetch || s.err != nil {
+			t.Errorf("bad span: %+v", s)
+		}
+		ranges = append(ranges, s.attrs[AttrRange])
+		bytes += s.attrs[AttrBytes].(int64)
+	}
+	if got, want := ranges, []any{"0-39", "40-79", "80-99"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+	if got, want := bytes, int64(100); got != want {
+		t.Errorf("bad bytes: got %d, want %d", got, want)
+	}
+}
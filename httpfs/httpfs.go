@@ -0,0 +1,84 @@
+// Package httpfs exposes remote files as an fs.FS, read lazily with HTTP
+// range requests.
+//
+// Files opened from an FS implement io.ReaderAt and io.Seeker as well as
+// fs.File, so that archive/zip, image decoders and anything else that accepts
+// an fs.FS can work on remote objects without downloading them in full.
+package httpfs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/echlebek/ranger"
+)
+
+// FS is a file system of the resources under a base URL. The name of a file is
+// its path relative to the base URL. HTTP has no way of listing directories,
+// so only files can be opened.
+type FS struct {
+	client  *http.Client
+	base    string
+	options []ranger.HTTPReaderOption
+}
+
+// New returns an FS for the resources under base, fetched with client, or
+// http.DefaultClient if client is nil. The options are used for every file
+// that's opened.
+func New(client *http.Client, base string, opts ...ranger.HTTPReaderOption) *FS {
+	return &FS{client: client, base: strings.TrimSuffix(base, "/"), options: opts}
+}
+
+// Open opens the named file. It makes a single range request, to learn the
+// size of the file; its content is only fetched as it's read. If the server
+// replies with a 404, the error wraps fs.ErrNotExist, and with a 401 or 403,
+// fs.ErrPermission.
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	u := f.base + "/" + (&url.URL{Path: name}).EscapedPath()
+	hr, err := ranger.NewHTTPReader(f.client, u, f.options...)
+	if err != nil {
+		var serr *ranger.StatusError
+		if errors.As(err, &serr) {
+			switch serr.StatusCode {
+			case http.StatusNotFound:
+				err = fs.ErrNotExist
+			case http.StatusUnauthorized, http.StatusForbidden:
+				err = fs.ErrPermission
+			}
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &File{HTTPReader: hr, name: name}, nil
+}
+
+// File is a remote file, opened by FS.Open.
+type File struct {
+	*ranger.HTTPReader
+	name string
+}
+
+// Stat returns the size and modification time of the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	return fileInfo{name: path.Base(f.name), size: f.Size(), modtime: f.ModTime()}, nil
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	modtime time.Time
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return 0o444 }
+func (fi fileInfo) ModTime() time.Time { return fi.modtime }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() any           { return nil }
@@ -0,0 +1,82 @@
+package httpfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFS(t *testing.T) {
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	w, err := zw.Create("inner.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "inside")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	modtime := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	files := fstest.MapFS{
+		"dir/hello.txt": {Data: []byte("hello, world"), ModTime: modtime},
+		"archive.zip":   {Data: zipped.Bytes(), ModTime: modtime},
+	}
+	srv := httptest.NewServer(http.FileServer(http.FS(files)))
+	defer srv.Close()
+	fsys := New(srv.Client(), srv.URL+"/")
+
+	b, err := fs.ReadFile(fsys, "dir/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "hello, world"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+
+	fi, err := fs.Stat(fsys, "dir/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Name(), "hello.txt"; got != want {
+		t.Errorf("bad name: got %q, want %q", got, want)
+	}
+	if got, want := fi.Size(), int64(12); got != want {
+		t.Errorf("bad size: got %d, want %d", got, want)
+	}
+	if got, want := fi.ModTime(), modtime; !got.Equal(want) {
+		t.Errorf("bad modtime: got %v, want %v", got, want)
+	}
+
+	f, err := fsys.Open("archive.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ra := f.(io.ReaderAt)
+	zr, err := zip.NewReader(ra, f.(*File).Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = fs.ReadFile(zr, "inner.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "inside"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+
+	if _, err := fsys.Open("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("bad error: got %v, want %v", err, fs.ErrNotExist)
+	}
+	if _, err := fsys.Open("../escape"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("bad error: got %v, want %v", err, fs.ErrInvalid)
+	}
+}
@@ -0,0 +1,418 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrResourceChanged is returned by an HTTPReader when the remote resource has
+// changed since the reader was created. Bytes read before and after the change
+// may come from different versions of the resource, and must not be mixed.
+var ErrResourceChanged = errors.New("ranger: resource changed")
+
+var errClosed = errors.New("ranger: reader closed")
+
+// HTTPReaderOption configures an HTTPReader.
+type HTTPReaderOption func(*HTTPReader)
+
+// WithReadAhead makes an HTTPReader fetch at least n bytes with each request,
+// and keep them to serve later reads. Readers that make many small, mostly
+// sequential reads, such as archive and columnar file readers, need far fewer
+// requests with read-ahead. By default, only the bytes asked for are fetched.
+func WithReadAhead(n int) HTTPReaderOption {
+	return func(h *HTTPReader) {
+		h.readAhead = max(n, 0)
+	}
+}
+
+// WithTailPrefetch makes an HTTPReader fetch the last n bytes of the resource
+// with the request it makes to learn its size, and keep them to serve later
+// reads. Formats that keep their index at the end, such as ZIP archives, can
+// then be opened with one request fewer.
+func WithTailPrefetch(n int64) HTTPReaderOption {
+	return func(h *HTTPReader) {
+		h.tail = max(n, 0)
+	}
+}
+
+// WithRetry makes an HTTPReader retry the requests ReadAt makes as p says. By
+// default, each is tried once.
+func WithRetry(p RetryPolicy) HTTPReaderOption {
+	return func(h *HTTPReader) {
+		h.retry = p
+	}
+}
+
+// WithCache makes an HTTPReader keep the ranges ReadAt fetches in c, and answer
+// later reads from c where it can. c must only ever hold bytes of the one
+// resource, as it was when the reader was created; a cache shared between
+// readers for the same resource must be discarded once it changes.
+func WithCache(c *PartialCache) HTTPReaderOption {
+	return func(h *HTTPReader) {
+		h.cache = c
+	}
+}
+
+// HTTPReader reads a remote resource with ranged GET requests, as an
+// io.ReaderAt, io.ReadSeeker and io.Closer. It lets code written for local
+// files, such as archive/zip, work on remote objects without downloading them
+// in full.
+//
+// The validators of the resource, its ETag and Last-Modified fields, are
+// captured when the reader is created, and every later request is made
+// conditional on them with If-Range. If the resource changes, reads fail with
+// ErrResourceChanged, rather than mixing bytes from two versions of it.
+//
+// ReadAt is safe for concurrent use, but Read and Seek share a single offset,
+// as they do for an *os.File.
+type HTTPReader struct {
+	client    *http.Client
+	url       string
+	size      int64
+	readAhead int
+	tail      int64
+	retry     RetryPolicy
+	cache     *PartialCache
+
+	// Content-Type of the resource, as given in the first response.
+	contentType string
+
+	// Validators of the resource, captured by the first request.
+	validators validators
+
+	mu     sync.Mutex
+	off    int64  // offset for Read and Seek
+	buf    []byte // read-ahead buffer
+	bufOff int64  // offset of buf in the resource
+	closed bool
+}
+
+// NewHTTPReader returns an HTTPReader for the resource at url, fetched with
+// client, or http.DefaultClient if client is nil. It makes a request for the
+// first byte of the resource to learn its size. If the server doesn't support
+// byte ranges, ErrNotSupported is returned.
+func NewHTTPReader(client *http.Client, url string, opts ...HTTPReaderOption) (*HTTPReader, error) {
+	return NewHTTPReaderContext(context.Background(), client, url, opts...)
+}
+
+// NewHTTPReaderContext is like NewHTTPReader, but makes the request to learn
+// the size of the resource with ctx, so it's abandoned once ctx is done.
+func NewHTTPReaderContext(ctx context.Context, client *http.Client, url string, opts ...HTTPReaderOption) (*HTTPReader, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	h := &HTTPReader{client: client, url: url}
+	for _, opt := range opts {
+		opt(h)
+	}
+	probe := RangeSpec{First: 0, Last: 0}
+	if h.tail > 0 {
+		probe = RangeSpec{First: -1, Last: h.tail}
+	}
+	resp, err := h.get(ctx, "bytes="+probe.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	h.validators = newValidators(resp.Header)
+	h.contentType = resp.Header.Get("Content-Type")
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		got, total, err := ParseContentRange(resp.Header)
+		if err != nil {
+			return nil, err
+		}
+		if total < 0 {
+			return nil, fmt.Errorf("ranger: GET %s: unknown length", url)
+		}
+		h.size = total
+		if h.tail > 0 {
+			want, err := probe.Resolve(total)
+			if err != nil {
+				return nil, err
+			}
+			if err := VerifyContentRange(resp.Header, want); err != nil {
+				return nil, err
+			}
+			h.buf = make([]byte, got.Len())
+			if _, err := io.ReadFull(resp.Body, h.buf); err != nil {
+				return nil, err
+			}
+			h.bufOff = got.Start
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Only an empty resource can't satisfy a request for its first or
+		// last bytes.
+		cr, err := ParseContentRangeValue(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, err
+		}
+		if cr.Length != 0 {
+			return nil, fmt.Errorf("%w: %q", ErrContentRange, resp.Header.Get("Content-Range"))
+		}
+	case http.StatusOK:
+		return nil, ErrNotSupported
+	default:
+		return nil, h.statusError(resp)
+	}
+	return h, nil
+}
+
+// Size returns the size of the resource.
+func (h *HTTPReader) Size() int64 {
+	return h.size
+}
+
+// ModTime returns the time the resource was last modified, according to its
+// Last-Modified field, or the zero time if it didn't have one.
+func (h *HTTPReader) ModTime() time.Time {
+	t, err := http.ParseTime(h.validators.lastModified)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ReadAt reads len(p) bytes from the resource, starting at offset off. As the
+// io.ReaderAt contract requires, it returns a non-nil error if it reads fewer
+// than len(p) bytes, which is io.EOF at the end of the resource. A read of no
+// bytes reads nothing, and returns 0, nil.
+func (h *HTTPReader) ReadAt(p []byte, off int64) (int, error) {
+	return h.ReadAtContext(context.Background(), p, off)
+}
+
+// ReadAtContext is like ReadAt, but makes any request it needs with ctx, so
+// that it's abandoned once ctx is done. The error then wraps ctx.Err().
+func (h *HTTPReader) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("ranger: negative offset")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off >= h.size {
+		if h.isClosed() {
+			return 0, errClosed
+		}
+		return 0, io.EOF
+	}
+	want := min(int64(len(p)), h.size-off)
+	n, err := h.readAt(ctx, p[:want], off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (h *HTTPReader) readAt(ctx context.Context, p []byte, off int64) (int, error) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return 0, errClosed
+	}
+	if off >= h.bufOff && off+int64(len(p)) <= h.bufOff+int64(len(h.buf)) {
+		n := copy(p, h.buf[off-h.bufOff:])
+		h.mu.Unlock()
+		return n, nil
+	}
+	h.mu.Unlock()
+	if h.cache != nil {
+		if n, err := h.cache.ReadAt(p, off); err == nil {
+			return n, nil
+		}
+	}
+
+	length := max(int64(len(p)), int64(h.readAhead))
+	r := Range{Start: off, Stop: min(off+length, h.size) - 1}
+	var b []byte
+	err := h.retry.do(ctx, func() error {
+		var err error
+		b, err = h.fetch(ctx, r)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if h.cache != nil {
+		h.cache.Put(r, b)
+	}
+	if h.readAhead > 0 {
+		h.mu.Lock()
+		h.buf, h.bufOff = b, off
+		h.mu.Unlock()
+	}
+	return copy(p, b), nil
+}
+
+// fetch fetches exactly the range r.
+func (h *HTTPReader) fetch(ctx context.Context, r Range) ([]byte, error) {
+	b, _, err := h.fetchHeader(ctx, r)
+	return b, err
+}
+
+// fetchHeader is fetch, also returning the header of the response. The body
+// is read no faster than limiters allow.
+func (h *HTTPReader) fetchHeader(ctx context.Context, r Range, limiters ...Limiter) ([]byte, http.Header, error) {
+	resp, err := h.get(ctx, Format([]Range{r}))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	resp.Body = limitBody(ctx, resp.Body, limiters...)
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPreconditionFailed {
+		return nil, nil, h.statusError(resp)
+	}
+	b, err := readPart(resp, r, h.validators)
+	return b, resp.Header, err
+}
+
+// readPart reads exactly the range r from the body of a response to a request
+// for it, which was made conditional on v.
+func readPart(resp *http.Response, r Range, v validators) ([]byte, error) {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The server supported ranges when the validators were captured, so
+		// if there was an If-Range precondition, it failed.
+		if v.ifRange() != "" {
+			return nil, ErrResourceChanged
+		}
+		return nil, ErrNotSupported
+	case http.StatusPreconditionFailed:
+		return nil, ErrResourceChanged
+	default:
+		err := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		if resp.Request != nil {
+			err.URL = resp.Request.URL.String()
+		}
+		return nil, err
+	}
+	if v.changed(resp.Header) {
+		return nil, ErrResourceChanged
+	}
+	if err := VerifyContentRange(resp.Header, r); err != nil {
+		return nil, err
+	}
+	b := make([]byte, r.Len())
+	if _, err := io.ReadFull(resp.Body, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// get makes a request with the given value for its Range header.
+func (h *HTTPReader) get(ctx context.Context, rangeValue string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", rangeValue)
+	if v := h.validators.ifRange(); v != "" {
+		req.Header.Set("If-Range", v)
+	}
+	return h.client.Do(req)
+}
+
+// validators are the validators of a resource, as captured from the first
+// response for it, for making later range requests conditional.
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+func newValidators(h http.Header) validators {
+	return validators{etag: h.Get("Etag"), lastModified: h.Get("Last-Modified")}
+}
+
+// ifRange returns the value of the If-Range field for requests after the
+// first: the ETag if it's strong, since weak ones can't be used with If-Range,
+// or else the Last-Modified date.
+func (v validators) ifRange() string {
+	if v.etag != "" && !strings.HasPrefix(v.etag, "W/") {
+		return v.etag
+	}
+	return v.lastModified
+}
+
+// changed reports whether the validators in a response header differ from
+// the ones captured by the first request. That catches servers that ignore
+// If-Range, as well as resources whose only validator is a weak ETag.
+func (v validators) changed(h http.Header) bool {
+	if etag := h.Get("Etag"); v.etag != "" && etag != "" && etag != v.etag {
+		return true
+	}
+	lastModified := h.Get("Last-Modified")
+	return v.lastModified != "" && lastModified != "" && lastModified != v.lastModified
+}
+
+func (h *HTTPReader) statusError(resp *http.Response) error {
+	return &StatusError{URL: h.url, StatusCode: resp.StatusCode, Status: resp.Status}
+}
+
+// StatusError is returned when a request for a remote resource gets a response
+// with an unexpected status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ranger: GET %s: %s", e.URL, e.Status)
+}
+
+// Read reads up to len(p) bytes from the current offset, and advances it.
+func (h *HTTPReader) Read(p []byte) (int, error) {
+	h.mu.Lock()
+	off := h.off
+	h.mu.Unlock()
+	n, err := h.ReadAt(p, off)
+	h.mu.Lock()
+	h.off = off + int64(n)
+	h.mu.Unlock()
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read, as for io.Seeker.
+func (h *HTTPReader) Seek(offset int64, whence int) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += h.off
+	case io.SeekEnd:
+		offset += h.size
+	default:
+		return 0, errors.New("ranger: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("ranger: negative offset")
+	}
+	h.off = offset
+	return offset, nil
+}
+
+// Close releases the read-ahead buffer. Reads after Close fail.
+func (h *HTTPReader) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	h.buf = nil
+	return nil
+}
+
+func (h *HTTPReader) isClosed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closed
+}
@@ -0,0 +1,252 @@
+package ranger
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer serves content with Handler, and counts the requests.
+func countingServer(t *testing.T, content string) (*httptest.Server, *int64) {
+	var n int64
+	h := Handler(strings.NewReader(content), int64(len(content)))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&n, 1)
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+func TestHTTPReaderReadAt(t *testing.T) {
+	srv, _ := countingServer(t, "0123456789")
+	hr, err := NewHTTPReader(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hr.Close()
+	if got, want := hr.Size(), int64(10); got != want {
+		t.Errorf("bad size: got %d, want %d", got, want)
+	}
+	p := make([]byte, 3)
+	if _, err := hr.ReadAt(p, 4); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(p), "456"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	n, err := hr.ReadAt(p, 8)
+	if got, want := string(p[:n]), "89"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if err != io.EOF {
+		t.Errorf("bad error: got %v, want %v", err, io.EOF)
+	}
+	if _, err := hr.ReadAt(p, 10); err != io.EOF {
+		t.Errorf("bad error: got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestHTTPReaderReadAtEmpty(t *testing.T) {
+	srv, requests := countingServer(t, "0123456789")
+	hr, err := NewHTTPReader(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hr.Close()
+	before := atomic.LoadInt64(requests)
+	for _, off := range []int64{0, 5, 10} {
+		if n, err := hr.ReadAt(nil, off); n != 0 || err != nil {
+			t.Errorf("offset %d: got %d, %v, want 0, <nil>", off, n, err)
+		}
+	}
+	if got := atomic.LoadInt64(requests); got != before {
+		t.Errorf("bad number of requests: got %d, want none", got-before)
+	}
+}
+
+func TestHTTPReaderReadSeek(t *testing.T) {
+	srv, _ := countingServer(t, "0123456789")
+	hr, err := NewHTTPReader(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hr.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(hr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "6789"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if err := hr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hr.ReadAt(make([]byte, 1), 0); err == nil {
+		t.Error("read after close")
+	}
+}
+
+func TestHTTPReaderReadAhead(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	srv, requests := countingServer(t, content)
+	hr, err := NewHTTPReader(srv.Client(), srv.URL, WithReadAhead(500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 10)
+	for off := int64(0); off < 1000; off += 10 {
+		if _, err := hr.ReadAt(p, off); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(p), content[off:off+10]; got != want {
+			t.Fatalf("offset %d: bad content: got %q, want %q", off, got, want)
+		}
+	}
+	// One request to learn the size, and one for each half.
+	if got, want := atomic.LoadInt64(requests), int64(3); got != want {
+		t.Errorf("bad number of requests: got %d, want %d", got, want)
+	}
+}
+
+func TestHTTPReaderZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "hello, world")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := countingServer(t, buf.String())
+	hr, err := NewHTTPReader(srv.Client(), srv.URL, WithReadAhead(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(hr, hr.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := zr.Open("hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "hello, world"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
+
+func TestHTTPReaderEmpty(t *testing.T) {
+	srv, _ := countingServer(t, "")
+	hr, err := NewHTTPReader(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hr.Size(), int64(0); got != want {
+		t.Errorf("bad size: got %d, want %d", got, want)
+	}
+}
+
+func TestHTTPReaderNotSupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "0123456789")
+	}))
+	defer srv.Close()
+	if _, err := NewHTTPReader(srv.Client(), srv.URL); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("bad error: got %v, want %v", err, ErrNotSupported)
+	}
+}
+
+// changingServer serves a resource with ServeRanges, which changes to a new
+// version whenever change is called.
+type changingServer struct {
+	version  int64
+	useETag  bool
+	ignoreIf bool
+}
+
+func (c *changingServer) change() {
+	atomic.AddInt64(&c.version, 1)
+}
+
+func (c *changingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v := atomic.LoadInt64(&c.version)
+	content := strings.Repeat(fmt.Sprint(v), 10)
+	modtime := time.Unix(1e9+v, 0)
+	if c.useETag {
+		w.Header().Set("Etag", fmt.Sprintf(`"v%d"`, v))
+		modtime = time.Time{}
+	}
+	if c.ignoreIf {
+		r.Header.Del("If-Range")
+	}
+	ServeRanges(w, r, "", modtime, strings.NewReader(content))
+}
+
+func TestHTTPReaderResourceChanged(t *testing.T) {
+	tests := map[string]*changingServer{
+		"etag":          {useETag: true},
+		"last-modified": {},
+		"ignored":       {useETag: true, ignoreIf: true},
+	}
+	for name, cs := range tests {
+		srv := httptest.NewServer(cs)
+		hr, err := NewHTTPReader(srv.Client(), srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := make([]byte, 5)
+		if _, err := hr.ReadAt(p, 0); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		cs.change()
+		if _, err := hr.ReadAt(p, 5); err != ErrResourceChanged {
+			t.Errorf("%s: bad error: got %v, want %v", name, err, ErrResourceChanged)
+		}
+		srv.Close()
+	}
+}
+
+func TestHTTPReaderContext(t *testing.T) {
+	srv, requests := countingServer(t, "0123456789")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewHTTPReaderContext(ctx, srv.Client(), srv.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("bad error: got %v, want %v", err, context.Canceled)
+	}
+	hr, err := NewHTTPReaderContext(context.Background(), srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := atomic.LoadInt64(requests)
+	p := make([]byte, 5)
+	if _, err := hr.ReadAtContext(ctx, p, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("bad error: got %v, want %v", err, context.Canceled)
+	}
+	if got, want := atomic.LoadInt64(requests), before; got != want {
+		t.Errorf("bad number of requests: got %d, want %d", got, want)
+	}
+	if _, err := hr.ReadAtContext(context.Background(), p, 5); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(p), "56789"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
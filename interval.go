@@ -0,0 +1,167 @@
+package ranger
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Integer is a constraint for the integer types an Interval can range over.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Interval is a contiguous interval of integers of any type, such as item
+// indexes, frame numbers or uint64 object offsets, for units other than the
+// bytes a Range counts. Like a Range, both ends are inclusive. An
+// Interval[int64] converts to and from a Range directly:
+//
+//	r := ranger.Range(iv)
+//	iv := ranger.Interval[int64](r)
+type Interval[T Integer] struct {
+	Start T
+	Stop  T
+}
+
+// Len returns the number of values in iv, or 0 if iv is reversed. The length
+// of an interval covering every value of T doesn't fit in a T, and wraps to 0.
+func (iv Interval[T]) Len() T {
+	if iv.Start > iv.Stop {
+		return 0
+	}
+	return iv.Stop - iv.Start + 1
+}
+
+// Contains reports whether v falls within iv.
+func (iv Interval[T]) Contains(v T) bool {
+	return iv.Start <= v && v <= iv.Stop
+}
+
+// Overlaps reports whether iv and o have any values in common.
+func (iv Interval[T]) Overlaps(o Interval[T]) bool {
+	return iv.Start <= o.Stop && o.Start <= iv.Stop
+}
+
+// Intersect returns the values that iv and o have in common. If they have
+// none, ok is false.
+func (iv Interval[T]) Intersect(o Interval[T]) (Interval[T], bool) {
+	if !iv.Overlaps(o) {
+		return Interval[T]{}, false
+	}
+	return Interval[T]{Start: max(iv.Start, o.Start), Stop: min(iv.Stop, o.Stop)}, true
+}
+
+// MergeIntervals is Merge for intervals of any integer type: it returns them
+// sorted in ascending order of Start, with any that overlap or touch coalesced
+// into one. Reversed intervals are dropped. It works on a copy of intervals,
+// which is left untouched.
+func MergeIntervals[T Integer](intervals []Interval[T]) []Interval[T] {
+	return CoalesceIntervals(intervals, 0)
+}
+
+// CoalesceIntervals is Coalesce for intervals of any integer type: it's like
+// MergeIntervals, but also merges intervals separated by gaps of up to maxGap
+// values.
+func CoalesceIntervals[T Integer](intervals []Interval[T], maxGap T) []Interval[T] {
+	if result := coalesceSpans(slices.Clone(intervals), maxGap); len(result) > 0 {
+		return result
+	}
+	return nil
+}
+
+// SubtractIntervals is Subtract for intervals of any integer type: it returns
+// the parts of a that aren't covered by any interval in b, merged and sorted
+// as by MergeIntervals.
+func SubtractIntervals[T Integer](a, b []Interval[T]) []Interval[T] {
+	return subtractSpans[T](a, b)
+}
+
+// span is an interval of values of T, inclusive at both ends, such as an
+// Interval or a Range, so that they can share one implementation of merging
+// and subtracting.
+type span[T Integer, S any] interface {
+	bounds() (start, stop T)
+	withBounds(start, stop T) S
+}
+
+func (iv Interval[T]) bounds() (T, T) {
+	return iv.Start, iv.Stop
+}
+
+func (iv Interval[T]) withBounds(start, stop T) Interval[T] {
+	return Interval[T]{Start: start, Stop: stop}
+}
+
+// coalesceSpans sorts spans in place, in ascending order of start and then of
+// stop, and merges those that overlap, touch, or are separated by gaps of up
+// to maxGap values, allocating nothing. Reversed spans are dropped.
+func coalesceSpans[T Integer, S span[T, S]](spans []S, maxGap T) []S {
+	maxGap = max(maxGap, 0)
+	spans = slices.DeleteFunc(spans, func(s S) bool {
+		start, stop := s.bounds()
+		return start > stop
+	})
+	slices.SortFunc(spans, func(a, b S) int {
+		aStart, aStop := a.bounds()
+		bStart, bStop := b.bounds()
+		return cmp.Or(cmp.Compare(aStart, bStart), cmp.Compare(aStop, bStop))
+	})
+	result := spans[:0]
+	for _, s := range spans {
+		if len(result) == 0 {
+			result = append(result, s)
+			continue
+		}
+		start, stop := s.bounds()
+		curStart, curStop := result[len(result)-1].bounds()
+		if start <= curStop || gap(curStop, start) <= uint64(maxGap) {
+			result[len(result)-1] = s.withBounds(curStart, max(curStop, stop))
+		} else {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// gap returns the number of values between a and b, where a < b. It's counted
+// in a uint64, which holds the difference between any two values of T, so as
+// not to overflow at either end of T.
+func gap[T Integer](a, b T) uint64 {
+	return uint64(b) - uint64(a) - 1
+}
+
+// subtractSpans returns the parts of a that aren't covered by any span in b,
+// merged and sorted as by coalesceSpans.
+func subtractSpans[T Integer, S span[T, S]](a, b []S) []S {
+	a, b = coalesceSpans(slices.Clone(a), 0), coalesceSpans(slices.Clone(b), 0)
+	var result []S
+	j := 0
+	for _, s := range a {
+		start, stop := s.bounds()
+		for j < len(b) {
+			if _, bStop := b[j].bounds(); bStop >= start {
+				break
+			}
+			j++
+		}
+		covered := false
+		for k := j; k < len(b); k++ {
+			bStart, bStop := b[k].bounds()
+			if bStart > stop {
+				break
+			}
+			if bStart > start {
+				result = append(result, s.withBounds(start, bStart-1))
+			}
+			if bStop >= stop {
+				covered = true
+				break
+			}
+			start = bStop + 1
+		}
+		if !covered {
+			result = append(result, s.withBounds(start, stop))
+		}
+	}
+	return result
+}
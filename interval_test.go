@@ -0,0 +1,93 @@
+package ranger
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+type mergeIntervalsTest struct {
+	Intervals []Interval[uint64]
+	MaxGap    uint64
+	Expected  []Interval[uint64]
+}
+
+func TestCoalesceIntervals(t *testing.T) {
+	tests := []mergeIntervalsTest{
+		{ // overlapping and touching
+			Intervals: []Interval[uint64]{{Start: 10, Stop: 19}, {Start: 0, Stop: 5}, {Start: 6, Stop: 9}, {Start: 30, Stop: 39}},
+			Expected:  []Interval[uint64]{{Start: 0, Stop: 19}, {Start: 30, Stop: 39}},
+		},
+		{ // within the gap
+			Intervals: []Interval[uint64]{{Start: 0, Stop: 9}, {Start: 15, Stop: 19}},
+			MaxGap:    5,
+			Expected:  []Interval[uint64]{{Start: 0, Stop: 19}},
+		},
+		{ // at the largest value
+			Intervals: []Interval[uint64]{{Start: math.MaxUint64 - 1, Stop: math.MaxUint64}, {Start: 0, Stop: 0}, {Start: math.MaxUint64, Stop: math.MaxUint64}},
+			Expected:  []Interval[uint64]{{Start: 0, Stop: 0}, {Start: math.MaxUint64 - 1, Stop: math.MaxUint64}},
+		},
+		{ // reversed intervals are dropped
+			Intervals: []Interval[uint64]{{Start: 5, Stop: 4}},
+		},
+	}
+	for i, test := range tests {
+		if got, want := CoalesceIntervals(test.Intervals, test.MaxGap), test.Expected; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad intervals: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestCoalesceIntervalsSigned(t *testing.T) {
+	ends := []Interval[int8]{{Start: -128, Stop: -128}, {Start: 127, Stop: 127}}
+	if got := CoalesceIntervals(ends, 0); !reflect.DeepEqual(got, ends) {
+		t.Errorf("bad intervals: got %v, want %v", got, ends)
+	}
+	if got := CoalesceIntervals(ends, math.MaxInt8); !reflect.DeepEqual(got, ends) {
+		t.Errorf("bad intervals: got %v, want %v", got, ends)
+	}
+	halves := []Interval[int8]{{Start: -128, Stop: -1}, {Start: 1, Stop: 127}}
+	if got := CoalesceIntervals(halves, 0); !reflect.DeepEqual(got, halves) {
+		t.Errorf("bad intervals: got %v, want %v", got, halves)
+	}
+	if got, want := CoalesceIntervals(halves, 1), []Interval[int8]{{Start: -128, Stop: 127}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad intervals: got %v, want %v", got, want)
+	}
+	wide := []Interval[int64]{{Start: math.MinInt64, Stop: math.MinInt64}, {Start: math.MaxInt64, Stop: math.MaxInt64}}
+	if got := CoalesceIntervals(wide, math.MaxInt64); !reflect.DeepEqual(got, wide) {
+		t.Errorf("bad intervals: got %v, want %v", got, wide)
+	}
+}
+
+func TestSubtractIntervals(t *testing.T) {
+	a := []Interval[int]{{Start: 0, Stop: 99}}
+	b := []Interval[int]{{Start: 10, Stop: 19}, {Start: 90, Stop: 200}}
+	want := []Interval[int]{{Start: 0, Stop: 9}, {Start: 20, Stop: 89}}
+	if got := SubtractIntervals(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("bad intervals: got %v, want %v", got, want)
+	}
+	u := []Interval[uint8]{{Start: 0, Stop: 255}}
+	cut := []Interval[uint8]{{Start: 0, Stop: 0}, {Start: 255, Stop: 255}}
+	if got, want := SubtractIntervals(u, cut), []Interval[uint8]{{Start: 1, Stop: 254}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad intervals: got %v, want %v", got, want)
+	}
+	if got := SubtractIntervals(u, u); len(got) != 0 {
+		t.Errorf("bad intervals: got %v, want none", got)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	iv := Interval[int32]{Start: 5, Stop: 9}
+	if got, want := iv.Len(), int32(5); got != want {
+		t.Errorf("bad length: got %d, want %d", got, want)
+	}
+	if !iv.Contains(9) || iv.Contains(10) {
+		t.Error("bad Contains")
+	}
+	if got, ok := iv.Intersect(Interval[int32]{Start: 8, Stop: 20}); !ok || got != (Interval[int32]{Start: 8, Stop: 9}) {
+		t.Errorf("bad intersection: got %v, %v", got, ok)
+	}
+	if got, want := Range(Interval[int64]{Start: 1, Stop: 2}), (Range{Start: 1, Stop: 2}); got != want {
+		t.Errorf("bad conversion: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package ranger
+
+import (
+	"iter"
+)
+
+// Iter yields each offset in r, in ascending order. It can be used directly in
+// a range-over-func loop:
+//
+//	for off := range r.Iter {
+//		...
+//	}
+func (r Range) Iter(yield func(int64) bool) {
+	for off := r.Start; off <= r.Stop; off++ {
+		// Stopping at r.Stop, rather than past it, keeps off from
+		// overflowing at the largest offset.
+		if !yield(off) || off == r.Stop {
+			return
+		}
+	}
+}
+
+// Iter returns a sequence of every offset covered by ranges, in ascending
+// order. The ranges are merged first, so an offset covered by more than one of
+// them is only yielded once.
+func Iter(ranges []Range) iter.Seq[int64] {
+	merged := Merge(ranges)
+	return func(yield func(int64) bool) {
+		for _, r := range merged {
+			for off := range r.Iter {
+				if !yield(off) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// All returns a sequence of the ranges in s, in ascending order, without
+// copying them as Ranges does.
+func (s RangeSet) All() iter.Seq[Range] {
+	return func(yield func(Range) bool) {
+		for _, r := range s.ranges {
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
+
+// ChunkSeq returns a sequence of the ranges in seq, each split into
+// contiguous ranges of chunkSize bytes as Range.Chunks splits them. Chunks are
+// produced as they're needed, so very large sets of ranges can be split up
+// without building a slice of every chunk:
+//
+//	for chunk := range ChunkSeq(set.All(), 1<<20) {
+//		...
+//	}
+//
+// If chunkSize is not positive, the sequence is empty.
+func ChunkSeq(seq iter.Seq[Range], chunkSize int64) iter.Seq[Range] {
+	return func(yield func(Range) bool) {
+		if chunkSize <= 0 {
+			return
+		}
+		for r := range seq {
+			for start := r.Start; start <= r.Stop; {
+				// Written so as not to overflow at the largest offsets.
+				stop := min(start, r.Stop-chunkSize+1) + chunkSize - 1
+				if !yield(Range{Start: start, Stop: stop}) {
+					return
+				}
+				if stop == r.Stop {
+					break
+				}
+				start = stop + 1
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package ranger
+
+import (
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestRangeIter(t *testing.T) {
+	var got []int64
+	for off := range (Range{Start: 3, Stop: 6}).Iter {
+		got = append(got, off)
+	}
+	if want := []int64{3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad offsets: got %v, want %v", got, want)
+	}
+}
+
+func TestRangeIterMaxInt64(t *testing.T) {
+	r := Range{Start: math.MaxInt64 - 1, Stop: math.MaxInt64}
+	want := []int64{math.MaxInt64 - 1, math.MaxInt64}
+	var got []int64
+	for off := range r.Iter {
+		got = append(got, off)
+		if len(got) > len(want) {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bad offsets: got %v, want %v", got, want)
+	}
+	got = nil
+	for off := range Iter([]Range{r}) {
+		got = append(got, off)
+		if len(got) > len(want) {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bad offsets: got %v, want %v", got, want)
+	}
+}
+
+type iterTest struct {
+	Ranges          []Range
+	Limit           int
+	ExpectedOffsets []int64
+}
+
+func TestIter(t *testing.T) {
+	tests := []iterTest{
+		{ // disjoint ranges in ascending order
+			Ranges:          []Range{{Start: 8, Stop: 9}, {Start: 0, Stop: 1}},
+			ExpectedOffsets: []int64{0, 1, 8, 9},
+		},
+		{ // overlapping ranges don't repeat offsets
+			Ranges:          []Range{{Start: 0, Stop: 3}, {Start: 2, Stop: 5}},
+			ExpectedOffsets: []int64{0, 1, 2, 3, 4, 5},
+		},
+		{ // early stop
+			Ranges:          []Range{{Start: 0, Stop: 3}, {Start: 10, Stop: 13}},
+			Limit:           5,
+			ExpectedOffsets: []int64{0, 1, 2, 3, 10},
+		},
+		{ // empty
+		},
+	}
+	for i, test := range tests {
+		var got []int64
+		for off := range Iter(test.Ranges) {
+			got = append(got, off)
+			if len(got) == test.Limit {
+				break
+			}
+		}
+		if want := test.ExpectedOffsets; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad offsets: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestRangeSetAll(t *testing.T) {
+	set := NewRangeSet(Range{Start: 8, Stop: 9}, Range{Start: 0, Stop: 1})
+	var got []Range
+	for r := range set.All() {
+		got = append(got, r)
+	}
+	if want := set.Ranges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+}
+
+type chunkSeqTest struct {
+	Ranges         []Range
+	ChunkSize      int64
+	Limit          int
+	ExpectedChunks []Range
+}
+
+func TestChunkSeq(t *testing.T) {
+	tests := []chunkSeqTest{
+		{ // each range is split on its own
+			Ranges:    []Range{{Start: 0, Stop: 4}, {Start: 10, Stop: 12}},
+			ChunkSize: 2,
+			ExpectedChunks: []Range{
+				{Start: 0, Stop: 1}, {Start: 2, Stop: 3}, {Start: 4, Stop: 4},
+				{Start: 10, Stop: 11}, {Start: 12, Stop: 12},
+			},
+		},
+		{ // early stop
+			Ranges:         []Range{{Start: 0, Stop: 99}},
+			ChunkSize:      10,
+			Limit:          2,
+			ExpectedChunks: []Range{{Start: 0, Stop: 9}, {Start: 10, Stop: 19}},
+		},
+		{ // near the largest offset
+			Ranges:         []Range{{Start: math.MaxInt64 - 2, Stop: math.MaxInt64}},
+			ChunkSize:      2,
+			ExpectedChunks: []Range{{Start: math.MaxInt64 - 2, Stop: math.MaxInt64 - 1}, {Start: math.MaxInt64, Stop: math.MaxInt64}},
+		},
+		{ // zero chunk size
+			Ranges:    []Range{{Start: 0, Stop: 99}},
+			ChunkSize: 0,
+		},
+	}
+	for i, test := range tests {
+		var got []Range
+		for c := range ChunkSeq(slices.Values(test.Ranges), test.ChunkSize) {
+			got = append(got, c)
+			if len(got) == test.Limit {
+				break
+			}
+		}
+		if want := test.ExpectedChunks; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad chunks: got %v, want %v", i, got, want)
+		}
+	}
+}
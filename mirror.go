@@ -0,0 +1,228 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrMirrorMismatch is the error a mirror is dropped from a download with when
+// it doesn't serve the same representation of the resource as the first URL:
+// its length differs, or its ETag or Last-Modified date does.
+var ErrMirrorMismatch = errors.New("ranger: mirror serves a different representation")
+
+const (
+	// maxMirrorFailures is how many chunks in a row may fail from a mirror
+	// before it's dropped.
+	maxMirrorFailures = 3
+
+	// slowMirrorFactor is how many times longer than the fastest source would
+	// take a chunk may take from a mirror before it's fetched from another.
+	slowMirrorFactor = 4
+
+	// minMirrorTimeout is the least time a chunk is given before it's fetched
+	// from another source for being slow.
+	minMirrorTimeout = 250 * time.Millisecond
+)
+
+// mirror is a source of a download, and what's known of its health.
+type mirror struct {
+	index int
+	url   string
+	hr    *HTTPReader
+
+	// The rest are guarded by the mirrorSet's mu.
+	rate     float64 // bytes per second, as a moving average; 0 until measured
+	failures int     // chunks failed in a row
+	active   int     // chunks being fetched
+	dropped  bool
+}
+
+// mirrorSet is the sources of a download: the first URL, and the mirrors of
+// the Downloader that serve the same representation of it.
+type mirrorSet struct {
+	mu      sync.Mutex
+	mirrors []*mirror
+	onDrop  func(url string, err error)
+}
+
+// openMirrors returns the sources of a download from hr, and d.Mirrors. Each
+// mirror is opened and cross-checked against hr concurrently, and those that
+// fail are dropped.
+func (d *Downloader) openMirrors(ctx context.Context, hr *HTTPReader) *mirrorSet {
+	ms := &mirrorSet{mirrors: []*mirror{{url: hr.url, hr: hr}}, onDrop: d.OnMirrorDropped}
+	opened := make([]*HTTPReader, len(d.Mirrors))
+	errs := make([]error, len(d.Mirrors))
+	var wg sync.WaitGroup
+	for i, url := range d.Mirrors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opened[i], errs[i] = NewHTTPReaderContext(ctx, d.Client, url)
+			if errs[i] == nil && !sameRepresentation(hr, opened[i]) {
+				errs[i] = ErrMirrorMismatch
+			}
+		}()
+	}
+	wg.Wait()
+	for i, url := range d.Mirrors {
+		if errs[i] != nil {
+			if d.OnMirrorDropped != nil {
+				d.OnMirrorDropped(url, errs[i])
+			}
+			continue
+		}
+		ms.mirrors = append(ms.mirrors, &mirror{index: len(ms.mirrors), url: url, hr: opened[i]})
+	}
+	return ms
+}
+
+// sameRepresentation reports whether a and b serve the same representation of
+// a resource, as far as their lengths and validators tell.
+func sameRepresentation(a, b *HTTPReader) bool {
+	va, vb := a.validators, b.validators
+	switch {
+	case a.Size() != b.Size():
+		return false
+	case va.etag != "" && vb.etag != "" && va.etag != vb.etag:
+		return false
+	case va.lastModified != "" && vb.lastModified != "" && va.lastModified != vb.lastModified:
+		return false
+	}
+	return true
+}
+
+// pick chooses the source to fetch a chunk of n bytes from, out of those that
+// haven't been tried for it, and returns how long the fetch may take before
+// it's tried from another source, or 0 if there's no other source to try.
+// Sources that haven't been measured yet are tried first, so that each is
+// measured; after that, the fastest is chosen, for the share of its speed that
+// its chunks in flight leave. pick returns nil if there are none left.
+func (ms *mirrorSet) pick(tried []bool, n int64) (*mirror, time.Duration) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var best *mirror
+	var bestRate float64
+	untried := 0
+	for _, m := range ms.mirrors {
+		if m.dropped {
+			continue
+		}
+		bestRate = max(bestRate, m.rate)
+		if tried[m.index] {
+			continue
+		}
+		untried++
+		if best == nil || betterMirror(m, best) {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, 0
+	}
+	best.active++
+	if untried == 1 || bestRate == 0 {
+		return best, 0
+	}
+	expected := time.Duration(float64(n) / bestRate * float64(time.Second))
+	return best, max(slowMirrorFactor*expected, minMirrorTimeout)
+}
+
+// betterMirror reports whether a should be chosen over b.
+func betterMirror(a, b *mirror) bool {
+	if a.unmeasured() != b.unmeasured() {
+		return a.unmeasured()
+	}
+	if a.unmeasured() {
+		return a.active < b.active
+	}
+	return a.rate/float64(a.active+1) > b.rate/float64(b.active+1)
+}
+
+// unmeasured reports whether no chunk has been fetched from m yet, nor failed.
+func (m *mirror) unmeasured() bool {
+	return m.rate == 0 && m.failures == 0
+}
+
+// done records that a fetch of n bytes from m took d, and failed with err if
+// it isn't nil. A mirror is dropped once it has failed maxMirrorFailures times
+// in a row, or if the resource changed on it, unless it's the last source.
+func (ms *mirrorSet) done(m *mirror, n int64, d time.Duration, err error) {
+	ms.mu.Lock()
+	m.active--
+	if err == nil {
+		m.failures = 0
+		rate := float64(n) / max(d.Seconds(), 1e-9)
+		if m.rate == 0 {
+			m.rate = rate
+		} else {
+			m.rate = 0.7*m.rate + 0.3*rate
+		}
+		ms.mu.Unlock()
+		return
+	}
+	m.failures++
+	m.rate /= 2
+	drop := !m.dropped && (m.failures >= maxMirrorFailures || errors.Is(err, ErrResourceChanged)) && ms.live() > 1
+	if drop {
+		m.dropped = true
+	}
+	ms.mu.Unlock()
+	if drop && ms.onDrop != nil {
+		ms.onDrop(m.url, err)
+	}
+}
+
+// release records that a fetch from m was abandoned, without counting it
+// towards m's health.
+func (ms *mirrorSet) release(m *mirror) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	m.active--
+}
+
+// live returns the number of sources that haven't been dropped.
+func (ms *mirrorSet) live() int {
+	n := 0
+	for _, m := range ms.mirrors {
+		if !m.dropped {
+			n++
+		}
+	}
+	return n
+}
+
+// fetchMirrors fetches a single chunk from the best of ms's sources, and
+// writes it to dst. If it fails or is too slow there, it's fetched from the
+// next best, until every source has been tried.
+func (d *Downloader) fetchMirrors(ctx context.Context, ms *mirrorSet, dst io.WriterAt, r Range, connLimiter Limiter) error {
+	if len(ms.mirrors) == 1 {
+		return d.tracedFetch(ctx, ms.mirrors[0].hr, dst, r, connLimiter)
+	}
+	tried := make([]bool, len(ms.mirrors))
+	var err error
+	for {
+		m, timeout := ms.pick(tried, r.Len())
+		if m == nil {
+			return err
+		}
+		tried[m.index] = true
+		fetchCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			fetchCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		start := time.Now()
+		err = d.tracedFetch(fetchCtx, m.hr, dst, r, connLimiter)
+		cancel()
+		if ctx.Err() != nil {
+			ms.release(m)
+			return err
+		}
+		ms.done(m, r.Len(), time.Since(start), err)
+		if err == nil {
+			return nil
+		}
+	}
+}
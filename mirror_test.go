@@ -0,0 +1,158 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// brokenMirror answers the request for the first byte, with which a mirror is
+// opened, from h, and every other request with fail.
+type brokenMirror struct {
+	h        http.Handler
+	fail     func(w http.ResponseWriter, r *http.Request)
+	requests int64
+}
+
+func (b *brokenMirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Range") == "bytes=0-0" {
+		b.h.ServeHTTP(w, r)
+		return
+	}
+	atomic.AddInt64(&b.requests, 1)
+	b.fail(w, r)
+}
+
+// droppedMirrors records the mirrors dropped from a download.
+type droppedMirrors struct {
+	mu   sync.Mutex
+	errs map[string]error
+}
+
+func (d *droppedMirrors) drop(url string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.errs == nil {
+		d.errs = map[string]error{}
+	}
+	d.errs[url] = err
+}
+
+func TestDownloaderMirrors(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, requests := countingServer(t, content)
+	mirror, mirrorRequests := countingServer(t, content)
+	d := &Downloader{ChunkSize: 10, Workers: 2, Mirrors: []string{mirror.URL}}
+	dst := memFile(make([]byte, len(content)))
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	// One request to each to open it, and one for each of the 10 chunks.
+	n, m := atomic.LoadInt64(requests), atomic.LoadInt64(mirrorRequests)
+	if got, want := n+m, int64(12); got != want {
+		t.Errorf("bad number of requests: got %d, want %d", got, want)
+	}
+	if m < 2 {
+		t.Errorf("bad number of mirror requests: got %d, want at least 2", m)
+	}
+}
+
+func TestDownloaderMirrorFails(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, _ := countingServer(t, content)
+	broken := &brokenMirror{
+		h: Handler(strings.NewReader(content), 100),
+		fail: func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+		},
+	}
+	mirror := httptest.NewServer(broken)
+	defer mirror.Close()
+	d := &Downloader{ChunkSize: 10, Workers: 1, Mirrors: []string{mirror.URL}}
+	dst := memFile(make([]byte, len(content)))
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	// Once it has failed, the mirror is only used if the other fails too.
+	if got, want := atomic.LoadInt64(&broken.requests), int64(1); got != want {
+		t.Errorf("bad number of mirror requests: got %d, want %d", got, want)
+	}
+}
+
+func TestDownloaderMirrorChanged(t *testing.T) {
+	cs := &changingServer{useETag: true}
+	srv := httptest.NewServer(cs)
+	defer srv.Close()
+	changed := &brokenMirror{h: cs, fail: (&changingServer{version: 1, useETag: true}).ServeHTTP}
+	mirror := httptest.NewServer(changed)
+	defer mirror.Close()
+	var dropped droppedMirrors
+	d := &Downloader{ChunkSize: 2, Workers: 1, Mirrors: []string{mirror.URL}, OnMirrorDropped: dropped.drop}
+	dst := memFile(make([]byte, 10))
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), "0000000000"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if err := dropped.errs[mirror.URL]; !errors.Is(err, ErrResourceChanged) {
+		t.Errorf("bad error: got %v, want %v", err, ErrResourceChanged)
+	}
+}
+
+func TestDownloaderSlowMirror(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, _ := countingServer(t, content)
+	slow := &brokenMirror{
+		h: Handler(strings.NewReader(content), 100),
+		fail: func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	}
+	mirror := httptest.NewServer(slow)
+	defer mirror.Close()
+	d := &Downloader{ChunkSize: 10, Workers: 1, Mirrors: []string{mirror.URL}}
+	dst := memFile(make([]byte, len(content)))
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	// Once it has been too slow, the mirror is only used if the other fails.
+	if got, want := atomic.LoadInt64(&slow.requests), int64(1); got != want {
+		t.Errorf("bad number of mirror requests: got %d, want %d", got, want)
+	}
+}
+
+func TestDownloaderMirrorMismatch(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, _ := countingServer(t, content)
+	mirror, mirrorRequests := countingServer(t, content[:99])
+	var dropped droppedMirrors
+	d := &Downloader{ChunkSize: 10, Mirrors: []string{mirror.URL}, OnMirrorDropped: dropped.drop}
+	dst := memFile(make([]byte, len(content)))
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if err := dropped.errs[mirror.URL]; !errors.Is(err, ErrMirrorMismatch) {
+		t.Errorf("bad error: got %v, want %v", err, ErrMirrorMismatch)
+	}
+	if got, want := atomic.LoadInt64(mirrorRequests), int64(1); got != want {
+		t.Errorf("bad number of mirror requests: got %d, want %d", got, want)
+	}
+}
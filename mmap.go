@@ -0,0 +1,104 @@
+package ranger
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// MappedFile is a file mapped into memory, read-only, as an io.ReaderAt. Serving
+// it with Handler copies each range straight out of the mapping, rather than
+// making a read system call for every chunk, which suits large static files.
+// On platforms without mmap, it reads the file as usual.
+//
+// The file must not be truncated while it's mapped; reading past the end of
+// what's left of it crashes the program.
+type MappedFile struct {
+	size    int64
+	modTime time.Time
+
+	mu   sync.RWMutex
+	data []byte   // the mapping, if the file is mapped
+	file *os.File // the file, if it isn't
+	open bool
+}
+
+// OpenMapped opens the named file, and maps it into memory.
+func OpenMapped(name string) (*MappedFile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, &os.PathError{Op: "mmap", Path: name, Err: errors.New("not a regular file")}
+	}
+	m := &MappedFile{size: fi.Size(), modTime: fi.ModTime(), open: true}
+	if err := m.mapFile(f); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "mmap", Path: name, Err: err}
+	}
+	if m.file == nil {
+		// The mapping outlives the file descriptor.
+		f.Close()
+	}
+	return m, nil
+}
+
+// Size returns the size of the file when it was opened.
+func (m *MappedFile) Size() int64 {
+	return m.size
+}
+
+// ModTime returns the time the file was last modified when it was opened.
+func (m *MappedFile) ModTime() time.Time {
+	return m.modTime
+}
+
+// ReadAt copies len(p) bytes of the file, from offset off, into p. It's safe
+// for concurrent use.
+func (m *MappedFile) ReadAt(p []byte, off int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.open {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, errors.New("ranger: negative offset")
+	}
+	if m.file != nil {
+		return m.file.ReadAt(p, off)
+	}
+	if off >= m.size {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close unmaps the file. Reads that are under way finish first, and later
+// reads fail with os.ErrClosed.
+func (m *MappedFile) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.open {
+		return os.ErrClosed
+	}
+	m.open = false
+	if m.file != nil {
+		return m.file.Close()
+	}
+	data := m.data
+	m.data = nil
+	return unmap(data)
+}
@@ -0,0 +1,15 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package ranger
+
+import "os"
+
+// mapFile keeps f open to read it as usual, since mmap isn't supported here.
+func (m *MappedFile) mapFile(f *os.File) error {
+	m.file = f
+	return nil
+}
+
+func unmap(data []byte) error {
+	return nil
+}
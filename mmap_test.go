@@ -0,0 +1,111 @@
+package ranger
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type mappedReadTest struct {
+	Off           int64
+	Len           int
+	ExpectedData  string
+	ExpectedError string
+}
+
+func TestMappedFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(name, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := OpenMapped(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Size(), int64(10); got != want {
+		t.Errorf("bad size: got %d, want %d", got, want)
+	}
+	tests := []mappedReadTest{
+		{ // start
+			Off:           0,
+			Len:           4,
+			ExpectedData:  "0123",
+			ExpectedError: "<nil>",
+		},
+		{ // end
+			Off:           6,
+			Len:           4,
+			ExpectedData:  "6789",
+			ExpectedError: "<nil>",
+		},
+		{ // past the end
+			Off:           8,
+			Len:           4,
+			ExpectedData:  "89",
+			ExpectedError: "EOF",
+		},
+		{ // beyond the end
+			Off:           10,
+			Len:           4,
+			ExpectedData:  "",
+			ExpectedError: "EOF",
+		},
+		{ // negative
+			Off:           -1,
+			Len:           4,
+			ExpectedData:  "",
+			ExpectedError: "ranger: negative offset",
+		},
+	}
+	for i, test := range tests {
+		p := make([]byte, test.Len)
+		n, err := m.ReadAt(p, test.Off)
+		if got := string(p[:n]); got != test.ExpectedData {
+			t.Errorf("test %d: bad data: got %q, want %q", i, got, test.ExpectedData)
+		}
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %s, want %s", i, got, want)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	w := httptest.NewRecorder()
+	Handler(m, m.Size(), WithModTime(m.ModTime())).ServeHTTP(w, req)
+	if got, want := w.Code, http.StatusPartialContent; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := w.Body.String(), "2345"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ReadAt(make([]byte, 1), 0); err != os.ErrClosed {
+		t.Errorf("bad error after closing: got %v, want %v", err, os.ErrClosed)
+	}
+}
+
+func TestMappedFileEmpty(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "empty")
+	if err := os.WriteFile(name, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := OpenMapped(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.ReadAt(make([]byte, 1), 0); err != io.EOF {
+		t.Errorf("bad error: got %v, want %v", err, io.EOF)
+	}
+	if _, err := OpenMapped(dir); err == nil {
+		t.Error("directory mapped")
+	}
+}
@@ -0,0 +1,31 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package ranger
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps all of f into memory.
+func (m *MappedFile) mapFile(f *os.File) error {
+	if m.size == 0 {
+		return nil
+	}
+	if int64(int(m.size)) != m.size {
+		return syscall.EFBIG
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(m.size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	m.data = data
+	return nil
+}
+
+func unmap(data []byte) error {
+	if data == nil {
+		return nil
+	}
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,247 @@
+package ranger
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sync"
+)
+
+// MultipartWriter writes a multipart/byteranges body, as sent in a 206
+// response to a request for several ranges. Each part carries one range of
+// the content, along with its Content-Range and Content-Type.
+type MultipartWriter struct {
+	mw          *multipart.Writer
+	size        int64
+	contentType string
+	partType    func(Range) string
+}
+
+// NewMultipartWriter returns a MultipartWriter that writes to w, for content
+// of size bytes with the given Content-Type. If contentType is empty, the
+// parts are written without one. The boundary is chosen at random.
+func NewMultipartWriter(w io.Writer, size int64, contentType string) *MultipartWriter {
+	return &MultipartWriter{
+		mw:          multipart.NewWriter(w),
+		size:        size,
+		contentType: contentType,
+	}
+}
+
+// Boundary returns the boundary between parts.
+func (m *MultipartWriter) Boundary() string {
+	return m.mw.Boundary()
+}
+
+// SetBoundary overrides the random boundary between parts. It must be called
+// before any parts are written.
+func (m *MultipartWriter) SetBoundary(boundary string) error {
+	return m.mw.SetBoundary(boundary)
+}
+
+// SetPartContentType makes the Content-Type of the part for each range f(r),
+// rather than the writer's own, for content made up of parts of different
+// types, such as the images of a sprite sheet, or the segments of a media
+// container. Where f returns "", the writer's own is used. It must be called
+// before any parts are written.
+func (m *MultipartWriter) SetPartContentType(f func(r Range) string) {
+	m.partType = f
+}
+
+// Len returns the length of the whole body, if a part is written for each of
+// ranges, in order, and then it's closed. Servers can send it as the
+// Content-Length before writing any of the body. The boundary must be set
+// before Len is called.
+func (m *MultipartWriter) Len(ranges []Range) int64 {
+	var c countingWriter
+	mw := multipart.NewWriter(&c)
+	if err := mw.SetBoundary(m.Boundary()); err != nil {
+		return -1
+	}
+	cm := &MultipartWriter{mw: mw, size: m.size, contentType: m.contentType, partType: m.partType}
+	total := int64(0)
+	for _, r := range ranges {
+		cm.CreatePart(r)
+		total += r.Len()
+	}
+	mw.Close()
+	return total + c.n
+}
+
+// countingWriter counts the bytes written to it, and discards them.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// SeededBoundaries returns a function that makes a new boundary with each
+// call, for WithBoundaryFunc. The boundaries look random, but they're the same
+// every time for the same seed, so responses can be compared with golden
+// files while each still has a boundary of its own.
+func SeededBoundaries(seed uint64) func() string {
+	var mu sync.Mutex
+	rng := rand.New(rand.NewPCG(seed, seed))
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		var b [15]byte
+		for i := range b {
+			b[i] = byte(rng.Uint32())
+		}
+		return hex.EncodeToString(b[:])
+	}
+}
+
+// ContentType returns the Content-Type of the whole body, including the
+// boundary, such as 'multipart/byteranges; boundary=...'.
+func (m *MultipartWriter) ContentType() string {
+	return mime.FormatMediaType("multipart/byteranges", map[string]string{"boundary": m.Boundary()})
+}
+
+// CreatePart starts a new part for r, and returns a writer for its content.
+// Exactly r.Stop-r.Start+1 bytes should be written to it before the next part
+// is created.
+func (m *MultipartWriter) CreatePart(r Range) (io.Writer, error) {
+	h := textproto.MIMEHeader{
+		"Content-Range": {r.ContentRange(m.size)},
+	}
+	contentType := m.contentType
+	if m.partType != nil {
+		if t := m.partType(r); t != "" {
+			contentType = t
+		}
+	}
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	return m.mw.CreatePart(h)
+}
+
+// WritePart writes a part for r, copying its content from src. If src holds
+// fewer bytes than r, an error is returned.
+func (m *MultipartWriter) WritePart(r Range, src io.Reader) error {
+	part, err := m.CreatePart(r)
+	if err != nil {
+		return err
+	}
+	n, err := io.CopyN(part, src, r.Stop-r.Start+1)
+	if err == io.EOF {
+		return fmt.Errorf("ranger: short part for range %v: got %d bytes", r, n)
+	}
+	return err
+}
+
+// Close finishes the body, writing the final boundary.
+func (m *MultipartWriter) Close() error {
+	return m.mw.Close()
+}
+
+// MultipartReader reads the parts of a multipart/byteranges body, as received
+// in a 206 response to a request for several ranges.
+type MultipartReader struct {
+	mr     *multipart.Reader
+	length int64
+}
+
+// NewMultipartReader returns a MultipartReader that reads parts from r, which
+// are separated by boundary.
+func NewMultipartReader(r io.Reader, boundary string) *MultipartReader {
+	return &MultipartReader{mr: multipart.NewReader(r, boundary), length: -1}
+}
+
+// NewMultipartResponseReader returns a MultipartReader for the body of resp,
+// taking the boundary from its Content-Type. If resp isn't a 206 with a
+// multipart/byteranges body, an error is returned.
+func NewMultipartResponseReader(resp *http.Response) (*MultipartReader, error) {
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("ranger: unexpected status %q", resp.Status)
+	}
+	ct := resp.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil || mediaType != "multipart/byteranges" || params["boundary"] == "" {
+		return nil, fmt.Errorf("ranger: not a multipart/byteranges response: %q", ct)
+	}
+	return NewMultipartReader(resp.Body, params["boundary"]), nil
+}
+
+// Length returns the complete length of the content, as given by the parts
+// read so far, or -1 if it isn't known.
+func (m *MultipartReader) Length() int64 {
+	return m.length
+}
+
+// NextPart returns the range carried by the next part, and a reader for its
+// content. The reader returns an error if the part holds more or fewer bytes
+// than its range, and is only valid until the next call to NextPart.
+//
+// If a part has a missing or malformed Content-Range, or one that disagrees
+// with earlier parts about the length of the content, an error wrapping
+// ErrContentRange is returned. Once there are no more parts, NextPart returns
+// io.EOF.
+func (m *MultipartReader) NextPart() (Range, io.Reader, error) {
+	_, r, pr, err := m.nextPart()
+	return r, pr, err
+}
+
+// nextPart is NextPart, also returning the part itself, for its header.
+func (m *MultipartReader) nextPart() (*multipart.Part, Range, io.Reader, error) {
+	part, err := m.mr.NextPart()
+	if err != nil {
+		return nil, Range{}, nil, err
+	}
+	cr, err := ParseContentRangeValue(part.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, Range{}, nil, err
+	}
+	if cr.Unsatisfied {
+		return nil, Range{}, nil, fmt.Errorf("%w: unsatisfied range in part", ErrContentRange)
+	}
+	if cr.Length >= 0 {
+		if m.length >= 0 && m.length != cr.Length {
+			return nil, Range{}, nil, fmt.Errorf("%w: parts disagree on length: %d and %d", ErrContentRange, m.length, cr.Length)
+		}
+		m.length = cr.Length
+	}
+	return part, cr.Range, &partReader{r: part, remaining: cr.Range.Stop - cr.Range.Start + 1}, nil
+}
+
+// errLongPart is returned when a part holds more bytes than its range.
+var errLongPart = errors.New("ranger: part is longer than its range")
+
+// partReader reads the content of a part, checking that it's as long as the
+// part's range says.
+type partReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (p *partReader) Read(b []byte) (int, error) {
+	if p.remaining <= 0 {
+		var extra [1]byte
+		if n, _ := io.ReadFull(p.r, extra[:]); n > 0 {
+			return 0, errLongPart
+		}
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.remaining {
+		b = b[:p.remaining]
+	}
+	n, err := p.r.Read(b)
+	p.remaining -= int64(n)
+	if err == io.EOF && p.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	} else if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
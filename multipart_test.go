@@ -0,0 +1,235 @@
+package ranger
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMultipartWriter(t *testing.T) {
+	var buf bytes.Buffer
+	mw := NewMultipartWriter(&buf, 10, "text/plain")
+	if err := mw.SetBoundary("BOUNDARY"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mw.ContentType(), "multipart/byteranges; boundary=BOUNDARY"; got != want {
+		t.Errorf("bad content type: got %q, want %q", got, want)
+	}
+	if err := mw.WritePart(Range{Start: 0, Stop: 1}, strings.NewReader("01")); err != nil {
+		t.Fatal(err)
+	}
+	part, err := mw.CreatePart(Range{Start: 8, Stop: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write([]byte("89")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "--BOUNDARY\r\n" +
+		"Content-Range: bytes 0-1/10\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"01\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Range: bytes 8-9/10\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"89\r\n" +
+		"--BOUNDARY--\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+}
+
+func TestMultipartWriterShortPart(t *testing.T) {
+	mw := NewMultipartWriter(&bytes.Buffer{}, 10, "")
+	if err := mw.WritePart(Range{Start: 0, Stop: 4}, strings.NewReader("01")); err == nil {
+		t.Error("expected an error for a short part")
+	}
+}
+
+func TestMultipartWriterPartContentType(t *testing.T) {
+	var buf bytes.Buffer
+	mw := NewMultipartWriter(&buf, 10, "application/octet-stream")
+	if err := mw.SetBoundary("B"); err != nil {
+		t.Fatal(err)
+	}
+	mw.SetPartContentType(func(r Range) string {
+		if r.Start < 5 {
+			return "image/png"
+		}
+		return ""
+	})
+	ranges := []Range{{Start: 0, Stop: 1}, {Start: 8, Stop: 9}}
+	length := mw.Len(ranges)
+	for i, content := range []string{"01", "89"} {
+		if err := mw.WritePart(ranges[i], strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "--B\r\n" +
+		"Content-Range: bytes 0-1/10\r\n" +
+		"Content-Type: image/png\r\n" +
+		"\r\n" +
+		"01\r\n" +
+		"--B\r\n" +
+		"Content-Range: bytes 8-9/10\r\n" +
+		"Content-Type: application/octet-stream\r\n" +
+		"\r\n" +
+		"89\r\n" +
+		"--B--\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if got, want := length, int64(buf.Len()); got != want {
+		t.Errorf("bad length: got %d, want %d", got, want)
+	}
+}
+
+func TestMultipartWriterLen(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	cases := [][]Range{
+		nil,
+		{{Start: 0, Stop: 0}},
+		{{Start: 0, Stop: 9}, {Start: 500, Stop: 999}},
+		{{Start: 1, Stop: 2}, {Start: 10, Stop: 99}, {Start: 998, Stop: 999}},
+	}
+	for _, contentType := range []string{"", "text/plain"} {
+		for i, ranges := range cases {
+			var b bytes.Buffer
+			mw := NewMultipartWriter(&b, int64(len(content)), contentType)
+			want := mw.Len(ranges)
+			for _, r := range ranges {
+				if err := mw.WritePart(r, strings.NewReader(content[r.Start:r.Stop+1])); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := mw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := int64(b.Len()); got != want {
+				t.Errorf("test %d, %q: bad length: got %d, want %d", i, contentType, got, want)
+			}
+		}
+	}
+}
+
+func TestSeededBoundaries(t *testing.T) {
+	a, b := SeededBoundaries(1), SeededBoundaries(1)
+	first, second := a(), a()
+	if first == second {
+		t.Errorf("boundary repeated: %q", first)
+	}
+	if got, want := b()+" "+b(), first+" "+second; got != want {
+		t.Errorf("bad boundaries: got %q, want %q", got, want)
+	}
+	if got := SeededBoundaries(2)(); got == first {
+		t.Errorf("boundary repeated for another seed: %q", got)
+	}
+	if err := NewMultipartWriter(io.Discard, 0, "").SetBoundary(first); err != nil {
+		t.Errorf("bad boundary %q: %v", first, err)
+	}
+}
+
+func TestMultipartReader(t *testing.T) {
+	path := writeTestFile(t, "0123456789")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-1,5-6,8-")
+	rec := httptest.NewRecorder()
+	if err := ServeFile(rec, req, path); err != nil {
+		t.Fatal(err)
+	}
+	mr, err := NewMultipartResponseReader(rec.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ranges []Range
+	var content []string
+	for {
+		r, body, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ranges = append(ranges, r)
+		content = append(content, string(b))
+	}
+	if got, want := ranges, []Range{{Start: 0, Stop: 1}, {Start: 5, Stop: 6}, {Start: 8, Stop: 9}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+	if got, want := content, []string{"01", "56", "89"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := mr.Length(), int64(10); got != want {
+		t.Errorf("bad length: got %d, want %d", got, want)
+	}
+}
+
+type multipartReaderTest struct {
+	Body          string
+	ExpectedError error
+}
+
+func TestMultipartReaderInvalid(t *testing.T) {
+	tests := []multipartReaderTest{
+		{ // part shorter than its range
+			Body:          "--B\r\nContent-Range: bytes 0-4/10\r\n\r\n01\r\n--B--\r\n",
+			ExpectedError: io.ErrUnexpectedEOF,
+		},
+		{ // part longer than its range
+			Body:          "--B\r\nContent-Range: bytes 0-1/10\r\n\r\n0123\r\n--B--\r\n",
+			ExpectedError: errLongPart,
+		},
+		{ // missing Content-Range
+			Body:          "--B\r\n\r\n01\r\n--B--\r\n",
+			ExpectedError: ErrContentRange,
+		},
+		{ // parts disagree on length
+			Body: "--B\r\nContent-Range: bytes 0-1/10\r\n\r\n01\r\n" +
+				"--B\r\nContent-Range: bytes 2-3/20\r\n\r\n23\r\n--B--\r\n",
+			ExpectedError: ErrContentRange,
+		},
+	}
+	for i, test := range tests {
+		mr := NewMultipartReader(strings.NewReader(test.Body), "B")
+		var err error
+		for err == nil {
+			var body io.Reader
+			_, body, err = mr.NextPart()
+			if err == nil {
+				_, err = io.ReadAll(body)
+			}
+		}
+		if !errors.Is(err, test.ExpectedError) {
+			t.Errorf("test %d: bad error: got %v, want %v", i, err, test.ExpectedError)
+		}
+	}
+}
+
+func TestNewMultipartResponseReaderNotMultipart(t *testing.T) {
+	resp := &http.Response{
+		Status:     "206 Partial Content",
+		StatusCode: http.StatusPartialContent,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+	}
+	if _, err := NewMultipartResponseReader(resp); err == nil {
+		t.Error("expected an error for a single-part response")
+	}
+}
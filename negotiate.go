@@ -0,0 +1,133 @@
+package ranger
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Decision is how to answer a request for content, as decided by Negotiate.
+type Decision struct {
+	// Status is the status to reply with: 200 to serve all of the content,
+	// 206 to serve Ranges, 304 or 412 if a precondition says so, or 416 if
+	// the ranges can't be satisfied.
+	Status int
+
+	// Ranges are the ranges to serve, if Status is 206. If there are several,
+	// they're to be served as a multipart/byteranges body.
+	Ranges []Range
+
+	// Header holds the fields to set on the response: Accept-Ranges, the
+	// validators, Content-Range for a single range or a 416, and
+	// Content-Length when the body is all of the content or a single range.
+	// The Content-Type is left to the caller, since a multipart body needs a
+	// boundary.
+	Header http.Header
+
+	// Err is why the Range header was ignored, or couldn't be satisfied, if
+	// it was parsed and failed.
+	Err error
+
+	parsed bool // whether the Range header was parsed
+}
+
+// Negotiate decides how to answer r, for content of size bytes with the given
+// validators, by the rules of RFC 7232 and RFC 7233, just as Handler does: a
+// request without a Range header, with a malformed one, or with an If-Range
+// that doesn't match gets all of the content; preconditions are evaluated
+// first; ranges that run past the end of the content are clamped to it; and
+// ranges that all start past the end get a 416. Handlers that do their own
+// I/O can rely on it for the protocol, and serve what it decides.
+//
+// If etag is empty, or modtime is the zero time, the content has no such
+// validator.
+func Negotiate(r *http.Request, size int64, etag string, modtime time.Time) Decision {
+	return negotiate(r, size, &serveConfig{etag: etag, modtime: modtime})
+}
+
+func negotiate(r *http.Request, size int64, cfg *serveConfig) Decision {
+	d := Decision{Header: make(http.Header)}
+	if cfg.noRanges {
+		d.Header.Set("Accept-Ranges", "none")
+	} else {
+		SetAcceptRanges(d.Header)
+	}
+	if cfg.etag != "" {
+		d.Header.Set("Etag", cfg.etag)
+	}
+	if !cfg.modtime.IsZero() {
+		d.Header.Set("Last-Modified", cfg.modtime.UTC().Format(http.TimeFormat))
+	}
+	switch status := CheckPreconditions(r, cfg.etag, cfg.modtime); status {
+	case http.StatusNotModified, http.StatusPreconditionFailed:
+		d.Status = status
+		return d
+	}
+	if cfg.noRanges || len(r.Header["Range"]) == 0 || !EvaluateIfRange(r.Header.Get("If-Range"), cfg.etag, cfg.modtime) {
+		return d.all(size)
+	}
+	if cfg.complete == CompleteOK {
+		if have, ok := resumeOffset(r.Header["Range"], size); ok && have == size {
+			return d.all(size)
+		}
+	}
+	ranges, err := cfg.parseRanges(r.Header["Range"], size)
+	d.parsed, d.Err = true, err
+	status := Status(ranges, err)
+	if errors.Is(err, ErrLimit) && cfg.limitStatus == http.StatusRequestedRangeNotSatisfiable {
+		status = cfg.limitStatus
+	}
+	switch status {
+	case http.StatusOK:
+		return d.all(size)
+	case http.StatusRequestedRangeNotSatisfiable:
+		d.Status = status
+		d.Header.Set("Content-Range", UnsatisfiedContentRange(size))
+		return d
+	}
+	d.Status = http.StatusPartialContent
+	d.Ranges = ranges
+	if len(ranges) == 1 {
+		d.Header.Set("Content-Range", ranges[0].ContentRange(size))
+		d.Header.Set("Content-Length", strconv.FormatInt(ranges[0].Len(), 10))
+	}
+	return d
+}
+
+// all decides to serve all size bytes of the content.
+func (d Decision) all(size int64) Decision {
+	d.Status = http.StatusOK
+	d.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+	return d
+}
+
+// parseRanges parses the values of a Range header, for content of size bytes,
+// and puts the ranges in the order c says to serve them in. Ranges that run
+// past the end of the content are clamped to it, as RFC 7233 requires and
+// http.ServeContent does, so only ranges that start past the end are
+// unsatisfiable.
+func (c *serveConfig) parseRanges(values []string, size int64) ([]Range, error) {
+	opts := c.parse
+	opts.Clamp = true
+	if len(values) == 1 && opts == (ParseOptions{Clamp: true}) {
+		if rng, ok, err := parseSingleClamp(values[0], size); ok {
+			if err != nil {
+				return nil, err
+			}
+			return []Range{rng}, nil
+		}
+	}
+	if c.order != ClientOrder || opts.NoMerge {
+		ranges, err := opts.Parse(values, "bytes=", size)
+		if err != nil || c.order == DefaultOrder {
+			return ranges, err
+		}
+		return c.order.Apply(ranges), nil
+	}
+	d, err := opts.ParseDetailed(values, "bytes=", size)
+	if err != nil {
+		return nil, err
+	}
+	return d.clientOrder(), nil
+}
@@ -0,0 +1,111 @@
+package ranger
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type negotiateTest struct {
+	Header               http.Header
+	ExpectedStatus       int
+	ExpectedRanges       []Range
+	ExpectedContentRange string
+	ExpectedLength       string
+	ExpectedError        string
+}
+
+func TestNegotiate(t *testing.T) {
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []negotiateTest{
+		{ // no range
+			ExpectedStatus: http.StatusOK,
+			ExpectedLength: "100",
+			ExpectedError:  "<nil>",
+		},
+		{ // single range
+			Header:               http.Header{"Range": {"bytes=10-19"}},
+			ExpectedStatus:       http.StatusPartialContent,
+			ExpectedRanges:       []Range{{Start: 10, Stop: 19}},
+			ExpectedContentRange: "bytes 10-19/100",
+			ExpectedLength:       "10",
+			ExpectedError:        "<nil>",
+		},
+		{ // several ranges
+			Header:         http.Header{"Range": {"bytes=0-0,-1"}},
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedRanges: []Range{{Start: 0, Stop: 0}, {Start: 99, Stop: 99}},
+			ExpectedError:  "<nil>",
+		},
+		{ // malformed, so ignored
+			Header:         http.Header{"Range": {"bytes=x"}},
+			ExpectedStatus: http.StatusOK,
+			ExpectedLength: "100",
+			ExpectedError:  `invalid range: malformed: range 0 "x": missing '-'`,
+		},
+		{ // unsatisfiable
+			Header:               http.Header{"Range": {"bytes=100-"}},
+			ExpectedStatus:       http.StatusRequestedRangeNotSatisfiable,
+			ExpectedContentRange: "bytes */100",
+			ExpectedError:        `invalid range: unsatisfiable: range 0 "100-": outside the content`,
+		},
+		{ // If-Range matches
+			Header:               http.Header{"Range": {"bytes=10-19"}, "If-Range": {`"v1"`}},
+			ExpectedStatus:       http.StatusPartialContent,
+			ExpectedRanges:       []Range{{Start: 10, Stop: 19}},
+			ExpectedContentRange: "bytes 10-19/100",
+			ExpectedLength:       "10",
+			ExpectedError:        "<nil>",
+		},
+		{ // If-Range doesn't match
+			Header:         http.Header{"Range": {"bytes=10-19"}, "If-Range": {`"v0"`}},
+			ExpectedStatus: http.StatusOK,
+			ExpectedLength: "100",
+			ExpectedError:  "<nil>",
+		},
+		{ // not modified
+			Header:         http.Header{"Range": {"bytes=10-19"}, "If-None-Match": {`"v1"`}},
+			ExpectedStatus: http.StatusNotModified,
+			ExpectedError:  "<nil>",
+		},
+		{ // precondition failed
+			Header:         http.Header{"If-Match": {`"v0"`}},
+			ExpectedStatus: http.StatusPreconditionFailed,
+			ExpectedError:  "<nil>",
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		for k, vs := range test.Header {
+			req.Header[k] = vs
+		}
+		d := Negotiate(req, 100, `"v1"`, modtime)
+		if got, want := d.Status, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := d.Ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %v, want %v", i, got, want)
+		}
+		if got, want := d.Header.Get("Content-Range"), test.ExpectedContentRange; got != want {
+			t.Errorf("test %d: bad content range: got %q, want %q", i, got, want)
+		}
+		if got, want := d.Header.Get("Content-Length"), test.ExpectedLength; got != want {
+			t.Errorf("test %d: bad content length: got %q, want %q", i, got, want)
+		}
+		if got, want := fmt.Sprintf("%v", d.Err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %s, want %s", i, got, want)
+		}
+		if got, want := d.Header.Get("Accept-Ranges"), "bytes"; got != want {
+			t.Errorf("test %d: bad accept ranges: got %q, want %q", i, got, want)
+		}
+		if got, want := d.Header.Get("Etag"), `"v1"`; got != want {
+			t.Errorf("test %d: bad etag: got %q, want %q", i, got, want)
+		}
+		if got, want := d.Header.Get("Last-Modified"), "Wed, 01 Jan 2020 00:00:00 GMT"; got != want {
+			t.Errorf("test %d: bad last modified: got %q, want %q", i, got, want)
+		}
+	}
+}
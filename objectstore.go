@@ -0,0 +1,123 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ObjectStore is an object store that supports ranged reads, such as Amazon
+// S3 or Google Cloud Storage, for ObjectHandler to serve objects from. It's
+// small enough to implement over any store's client, without this package
+// depending on it. Over the AWS SDK, for instance:
+//
+//	func (s s3Store) GetRange(ctx context.Context, key string, start, end int64) (io.ReadCloser, error) {
+//		out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+//			Bucket: &s.bucket,
+//			Key:    &key,
+//			Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+//		})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return out.Body, nil
+//	}
+//
+// and over the Cloud Storage client:
+//
+//	func (s gcsStore) GetRange(ctx context.Context, key string, start, end int64) (io.ReadCloser, error) {
+//		return s.bucket.Object(key).NewRangeReader(ctx, start, end-start+1)
+//	}
+//
+// Stat is implemented likewise, with HeadObject or Attrs.
+type ObjectStore interface {
+	// Stat returns what's known of the object with the given key. If there's
+	// no such object, the error should wrap fs.ErrNotExist.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+
+	// GetRange returns a reader for the bytes of the object with the given
+	// key from start to end, inclusive, as a Range is.
+	GetRange(ctx context.Context, key string, start, end int64) (io.ReadCloser, error)
+}
+
+// ObjectInfo is what's known of an object in an ObjectStore.
+type ObjectInfo struct {
+	// Size is the length of the object, in bytes.
+	Size int64
+
+	// ETag and ModTime are the object's validators, if it has them.
+	ETag    string
+	ModTime time.Time
+
+	// ContentType is the object's media type, if it has one.
+	ContentType string
+}
+
+// ObjectContent is the Content of a single object in an ObjectStore, for
+// ContentHandler to serve. Info is as Stat gave it, so that the object needn't
+// be looked up again to serve it.
+type ObjectContent struct {
+	Store ObjectStore
+	Key   string
+	Info  ObjectInfo
+}
+
+// Size returns the size of the object.
+func (o *ObjectContent) Size() int64 {
+	return o.Info.Size
+}
+
+// ReadRange reads the range r of the object from the store.
+func (o *ObjectContent) ReadRange(ctx context.Context, r Range) (io.ReadCloser, error) {
+	return o.Store.GetRange(ctx, o.Key, r.Start, r.Stop)
+}
+
+// Validators returns the validators of the object.
+func (o *ObjectContent) Validators() (string, time.Time) {
+	return o.Info.ETag, o.Info.ModTime
+}
+
+// ObjectHandler returns an http.Handler that serves the objects in store, as
+// ContentHandler does, with their keys taken from the request paths without
+// the leading '/'. Each request looks up its object with Stat, and reads each
+// range it needs with GetRange, so partial content is served straight from
+// the store, without the rest of the object being read.
+//
+// If there's no such object, the handler replies with a 404, and if the store
+// denies access to it, with a 403. Other failures get a 502. Requests with
+// methods other than GET and HEAD get a 405.
+func ObjectHandler(store ObjectStore, opts ...ServeOption) http.Handler {
+	base := newServeConfig(opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		info, err := store.Stat(r.Context(), key)
+		if err != nil {
+			status := http.StatusBadGateway
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				status = http.StatusNotFound
+			case errors.Is(err, fs.ErrPermission):
+				status = http.StatusForbidden
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		cfg := base.withValidators(info.ETag, info.ModTime)
+		if cfg.contentType == "" {
+			cfg.contentType = info.ContentType
+		}
+		if cfg.contentType == "" {
+			cfg.contentType = "application/octet-stream"
+		}
+		_ = serve(w, r, &ObjectContent{Store: store, Key: key, Info: info}, cfg)
+	})
+}
@@ -0,0 +1,126 @@
+package ranger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// memStore is an ObjectStore of strings, which records the ranges it's asked
+// for.
+type memStore struct {
+	objects map[string]string
+	gets    []string
+}
+
+func (s *memStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	switch key {
+	case "secret":
+		return ObjectInfo{}, fmt.Errorf("stat %s: %w", key, fs.ErrPermission)
+	case "broken":
+		return ObjectInfo{}, fmt.Errorf("stat %s: connection reset", key)
+	}
+	obj, ok := s.objects[key]
+	if !ok {
+		return ObjectInfo{}, fmt.Errorf("stat %s: %w", key, fs.ErrNotExist)
+	}
+	return ObjectInfo{Size: int64(len(obj)), ETag: `"` + key + `"`, ContentType: "text/plain"}, nil
+}
+
+func (s *memStore) GetRange(ctx context.Context, key string, start, end int64) (io.ReadCloser, error) {
+	s.gets = append(s.gets, fmt.Sprintf("%s %d-%d", key, start, end))
+	return io.NopCloser(strings.NewReader(s.objects[key][start : end+1])), nil
+}
+
+type objectHandlerTest struct {
+	Method         string
+	Path           string
+	Range          string
+	ExpectedStatus int
+	ExpectedBody   string
+	ExpectedGets   []string
+}
+
+func TestObjectHandler(t *testing.T) {
+	store := &memStore{objects: map[string]string{"a/b.txt": "0123456789"}}
+	h := ObjectHandler(store)
+	tests := []objectHandlerTest{
+		{ // whole object
+			Path:           "/a/b.txt",
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "0123456789",
+			ExpectedGets:   []string{"a/b.txt 0-9"},
+		},
+		{ // a range
+			Path:           "/a/b.txt",
+			Range:          "bytes=-3",
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   "789",
+			ExpectedGets:   []string{"a/b.txt 7-9"},
+		},
+		{ // head reads nothing
+			Method:         "HEAD",
+			Path:           "/a/b.txt",
+			ExpectedStatus: http.StatusOK,
+		},
+		{ // missing
+			Path:           "/nope",
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedBody:   "Not Found\n",
+		},
+		{ // forbidden
+			Path:           "/secret",
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedBody:   "Forbidden\n",
+		},
+		{ // store failure
+			Path:           "/broken",
+			ExpectedStatus: http.StatusBadGateway,
+			ExpectedBody:   "Bad Gateway\n",
+		},
+		{ // bad method
+			Method:         "PUT",
+			Path:           "/a/b.txt",
+			ExpectedStatus: http.StatusMethodNotAllowed,
+			ExpectedBody:   "Method Not Allowed\n",
+		},
+	}
+	for i, test := range tests {
+		store.gets = nil
+		method := test.Method
+		if method == "" {
+			method = "GET"
+		}
+		req := httptest.NewRequest(method, test.Path, nil)
+		if test.Range != "" {
+			req.Header.Set("Range", test.Range)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if got, want := w.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := w.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+		if got, want := store.gets, test.ExpectedGets; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad reads: got %v, want %v", i, got, want)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/a/b.txt", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got, want := w.Header().Get("Etag"), `"a/b.txt"`; got != want {
+		t.Errorf("bad etag: got %q, want %q", got, want)
+	}
+	if got, want := w.Header().Get("Content-Type"), "text/plain"; got != want {
+		t.Errorf("bad content type: got %q, want %q", got, want)
+	}
+}
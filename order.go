@@ -0,0 +1,124 @@
+package ranger
+
+import (
+	"slices"
+	"strconv"
+)
+
+// Order is the order ranges are read in, by a Handler or CopyRanges. Different
+// sources want different orders: a client may expect its parts back in the
+// order it asked for them, a disk is quickest read from start to end, and a
+// tape or cold store is quickest if it seeks as little as possible.
+type Order int
+
+const (
+	// DefaultOrder leaves the ranges as they are: for a handler, in the order
+	// they were parsed in, which is by offset unless ParseOptions.NoMerge is
+	// set, and for CopyRanges, in the order they were given.
+	DefaultOrder Order = iota
+
+	// ClientOrder reads the ranges in the order they were asked for. Ranges
+	// that a handler merged are read in the order of the first range that
+	// went into each.
+	ClientOrder
+
+	// AscendingOrder reads the ranges by offset, as Sort orders them.
+	AscendingOrder
+
+	// SeekOrder reads the first range first, then each time the range that
+	// starts nearest to where the last one stopped, so as to seek as little
+	// as possible in between.
+	SeekOrder
+)
+
+func (o Order) String() string {
+	switch o {
+	case DefaultOrder:
+		return "default"
+	case ClientOrder:
+		return "client"
+	case AscendingOrder:
+		return "ascending"
+	case SeekOrder:
+		return "seek"
+	}
+	return "Order(" + strconv.Itoa(int(o)) + ")"
+}
+
+// Apply returns a copy of ranges, in the order o says to read them in. The
+// ranges are taken to be in the order they were asked for.
+func (o Order) Apply(ranges []Range) []Range {
+	result := make([]Range, len(ranges))
+	for i, j := range o.indices(ranges) {
+		result[i] = ranges[j]
+	}
+	return result
+}
+
+// indices returns the indices of ranges, in the order o says to read them in.
+func (o Order) indices(ranges []Range) []int {
+	idx := make([]int, len(ranges))
+	for i := range idx {
+		idx[i] = i
+	}
+	switch o {
+	case AscendingOrder:
+		slices.SortStableFunc(idx, func(a, b int) int {
+			return compareRanges(ranges[a], ranges[b])
+		})
+	case SeekOrder:
+		for i := 1; i < len(idx); i++ {
+			pos := ranges[idx[i-1]].Stop + 1
+			best := i
+			for j := i + 1; j < len(idx); j++ {
+				if seekDistance(pos, ranges[idx[j]]) < seekDistance(pos, ranges[idx[best]]) {
+					best = j
+				}
+			}
+			idx[i], idx[best] = idx[best], idx[i]
+			// Keep the ranges that weren't chosen in the order they were
+			// asked for, so that ties go to the earliest.
+			slices.Sort(idx[i+1:])
+		}
+	}
+	return idx
+}
+
+// seekDistance returns how far it is from pos to the start of r.
+func seekDistance(pos int64, r Range) int64 {
+	if r.Start < pos {
+		return pos - r.Start
+	}
+	return r.Start - pos
+}
+
+// WithOrder sets the order the ranges of a multipart/byteranges response are
+// served in. Without it, they're served in the order they were parsed in.
+func WithOrder(o Order) ServeOption {
+	return func(c *serveConfig) {
+		c.order = o
+	}
+}
+
+// clientOrder returns d.Ranges in the order of the first of d.Specs that went
+// into each.
+func (d ParseDetail) clientOrder() []Range {
+	first := make([]int, len(d.Ranges))
+	for i := range d.Ranges {
+		if sources := d.Sources(i); len(sources) > 0 {
+			first[i] = sources[0].Index
+		}
+	}
+	idx := make([]int, len(d.Ranges))
+	for i := range idx {
+		idx[i] = i
+	}
+	slices.SortStableFunc(idx, func(a, b int) int {
+		return first[a] - first[b]
+	})
+	result := make([]Range, len(idx))
+	for i, j := range idx {
+		result[i] = d.Ranges[j]
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package ranger
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type orderTest struct {
+	Order          Order
+	Ranges         []Range
+	ExpectedRanges []Range
+}
+
+func TestOrder(t *testing.T) {
+	ranges := []Range{{Start: 50, Stop: 59}, {Start: 0, Stop: 9}, {Start: 90, Stop: 99}, {Start: 40, Stop: 44}}
+	tests := []orderTest{
+		{ // default
+			Order:          DefaultOrder,
+			Ranges:         ranges,
+			ExpectedRanges: ranges,
+		},
+		{ // client
+			Order:          ClientOrder,
+			Ranges:         ranges,
+			ExpectedRanges: ranges,
+		},
+		{ // ascending
+			Order:          AscendingOrder,
+			Ranges:         ranges,
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 40, Stop: 44}, {Start: 50, Stop: 59}, {Start: 90, Stop: 99}},
+		},
+		{ // seek
+			Order:          SeekOrder,
+			Ranges:         ranges,
+			ExpectedRanges: []Range{{Start: 50, Stop: 59}, {Start: 40, Stop: 44}, {Start: 0, Stop: 9}, {Start: 90, Stop: 99}},
+		},
+		{ // seek ties
+			Order:          SeekOrder,
+			Ranges:         []Range{{Start: 10, Stop: 19}, {Start: 30, Stop: 39}, {Start: 0, Stop: 9}},
+			ExpectedRanges: []Range{{Start: 10, Stop: 19}, {Start: 30, Stop: 39}, {Start: 0, Stop: 9}},
+		},
+		{ // empty
+			Order:          SeekOrder,
+			ExpectedRanges: []Range{},
+		},
+	}
+	for i, test := range tests {
+		if got, want := test.Order.Apply(test.Ranges), test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestOrderString(t *testing.T) {
+	if got, want := SeekOrder.String(), "seek"; got != want {
+		t.Errorf("bad string: got %q, want %q", got, want)
+	}
+	if got, want := Order(9).String(), "Order(9)"; got != want {
+		t.Errorf("bad string: got %q, want %q", got, want)
+	}
+}
+
+type handlerOrderTest struct {
+	Order        Order
+	Range        string
+	ExpectedBody string
+}
+
+func TestHandlerOrder(t *testing.T) {
+	tests := []handlerOrderTest{
+		{ // default
+			Order:        DefaultOrder,
+			Range:        "bytes=8-9,0-0,1-2",
+			ExpectedBody: "--B\r\nContent-Range: bytes 0-2/10\r\nContent-Type: text/plain\r\n\r\n012\r\n--B\r\nContent-Range: bytes 8-9/10\r\nContent-Type: text/plain\r\n\r\n89\r\n--B--\r\n",
+		},
+		{ // client, merged
+			Order:        ClientOrder,
+			Range:        "bytes=8-9,1-2,0-0",
+			ExpectedBody: "--B\r\nContent-Range: bytes 8-9/10\r\nContent-Type: text/plain\r\n\r\n89\r\n--B\r\nContent-Range: bytes 0-2/10\r\nContent-Type: text/plain\r\n\r\n012\r\n--B--\r\n",
+		},
+		{ // seek
+			Order:        SeekOrder,
+			Range:        "bytes=8-9,0-0",
+			ExpectedBody: "--B\r\nContent-Range: bytes 0-0/10\r\nContent-Type: text/plain\r\n\r\n0\r\n--B\r\nContent-Range: bytes 8-9/10\r\nContent-Type: text/plain\r\n\r\n89\r\n--B--\r\n",
+		},
+	}
+	for i, test := range tests {
+		h := Handler(strings.NewReader("0123456789"), 10, WithContentType("text/plain"), WithBoundary("B"), WithOrder(test.Order))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Range", test.Range)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+// offsetRecorder is an io.ReaderAt that records the offset of each read.
+type offsetRecorder struct {
+	r       *strings.Reader
+	offsets []int64
+}
+
+func (o *offsetRecorder) ReadAt(p []byte, off int64) (int, error) {
+	o.offsets = append(o.offsets, off)
+	return o.r.ReadAt(p, off)
+}
+
+func TestCopyRangesOrder(t *testing.T) {
+	src := &offsetRecorder{r: strings.NewReader("0123456789")}
+	dst := memFile(strings.Repeat(".", 10))
+	ranges := []Range{{Start: 8, Stop: 8}, {Start: 0, Stop: 0}, {Start: 4, Stop: 4}}
+	if err := CopyRanges(context.Background(), dst, src, ranges, CopyOptions{Order: AscendingOrder}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := src.offsets, []int64{0, 4, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad offsets: got %v, want %v", got, want)
+	}
+	if got, want := string(dst), "0...4...8."; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package ranger
+
+import "sync"
+
+// Parser parses Range headers like ParseOptions.Parse, but reuses its scratch
+// space from one call to the next, for proxies that parse so many headers
+// that the garbage matters. A Parser must not be used concurrently; give each
+// goroutine its own, or take them from a ParserPool. The zero value parses
+// exactly like Parse.
+type Parser struct {
+	// Options are the limits and policies to parse with.
+	Options ParseOptions
+
+	ranges []Range
+}
+
+// Parse parses ranges like ParseOptions.Parse, with p.Options. The result is
+// only valid until the next call to Parse or Reset, which reuse its space;
+// copy it to keep it for longer.
+func (p *Parser) Parse(ranges []string, prefix string, contentLen int64) ([]Range, error) {
+	result, err := p.Options.parse(p.ranges, ranges, prefix, contentLen, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.ranges = result
+	return result, nil
+}
+
+// Reset returns p to its zero value, so that it can be handed to another
+// user, but keeps its scratch space.
+func (p *Parser) Reset() {
+	p.Options = ParseOptions{}
+	p.ranges = p.ranges[:0]
+}
+
+// ParserPool is a pool of Parsers, which all parse with the same Options. The
+// zero value is ready to use, and a ParserPool may be used concurrently.
+type ParserPool struct {
+	// Options are the limits and policies the Parsers parse with. They must
+	// not be changed once the pool is in use.
+	Options ParseOptions
+
+	pool sync.Pool
+}
+
+// Get takes a Parser from the pool, or makes a new one if the pool is empty.
+func (pp *ParserPool) Get() *Parser {
+	p, _ := pp.pool.Get().(*Parser)
+	if p == nil {
+		p = new(Parser)
+	}
+	p.Options = pp.Options
+	return p
+}
+
+// Put resets p, and returns it to the pool. Neither p nor anything it parsed
+// may be used after.
+func (pp *ParserPool) Put(p *Parser) {
+	p.Reset()
+	pp.pool.Put(p)
+}
@@ -0,0 +1,95 @@
+package ranger
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type parserTest struct {
+	Options        ParseOptions
+	Ranges         []string
+	ExpectedRanges []Range
+	ExpectedError  string
+}
+
+func TestParser(t *testing.T) {
+	tests := []parserTest{
+		{ // merged
+			Ranges:         []string{"bytes=50-99,0-59"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+			ExpectedError:  "<nil>",
+		},
+		{ // with options
+			Options:        ParseOptions{NoMerge: true},
+			Ranges:         []string{"bytes=50-99,0-59"},
+			ExpectedRanges: []Range{{Start: 50, Stop: 99}, {Start: 0, Stop: 59}},
+			ExpectedError:  "<nil>",
+		},
+		{ // malformed
+			Ranges:        []string{"bytes=0-9,x"},
+			ExpectedError: `invalid range: malformed: range 1 "x": missing '-'`,
+		},
+		{ // after an error
+			Ranges:         []string{"bytes=-10"},
+			ExpectedRanges: []Range{{Start: 90, Stop: 99}},
+			ExpectedError:  "<nil>",
+		},
+	}
+	var p Parser
+	for i, test := range tests {
+		p.Options = test.Options
+		ranges, err := p.Parse(test.Ranges, "bytes=", 100)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestParserAllocs(t *testing.T) {
+	ranges := []string{"bytes=200-299,0-99,50-149"}
+	var p Parser
+	if _, err := p.Parse(ranges, "bytes=", 1000); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Reset()
+		if _, err := p.Parse(ranges, "bytes=", 1000); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("bad allocations: got %v, want 0", allocs)
+	}
+}
+
+func TestParserPool(t *testing.T) {
+	pool := &ParserPool{Options: ParseOptions{MaxRanges: 1}}
+	p := pool.Get()
+	if _, err := p.Parse([]string{"bytes=0-9,20-29"}, "bytes=", 100); err == nil {
+		t.Error("expected an error")
+	}
+	p.Options.MaxRanges = 5
+	pool.Put(p)
+	p = pool.Get()
+	if got, want := p.Options, pool.Options; got != want {
+		t.Errorf("bad options: got %+v, want %+v", got, want)
+	}
+	pool.Put(p)
+}
+
+func BenchmarkParser(b *testing.B) {
+	ranges := []string{"bytes=200-299,0-99,50-149"}
+	pool := new(ParserPool)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := pool.Get()
+		if _, err := p.Parse(ranges, "bytes=", 1000); err != nil {
+			b.Fatal(err)
+		}
+		pool.Put(p)
+	}
+}
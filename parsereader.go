@@ -0,0 +1,59 @@
+package ranger
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// ParseReader is like Parse, but reads a single list of ranges from r,
+// delimited with ',' and beginning with prefix. The ranges are read one at a
+// time, so a very long list never has to be held in memory all at once. As
+// with Parse, reading more than MaxRanges ranges returns ErrLimit.
+//
+// Empty input is treated like an absent header, and returns no ranges.
+func ParseReader(r io.Reader, prefix string, contentLen int64) ([]Range, error) {
+	sc := bufio.NewScanner(r)
+	sc.Split(scanRanges())
+	result := make([]Range, 0, 1)
+	first := true
+	for sc.Scan() {
+		tok := sc.Text()
+		if first {
+			tok = strings.TrimPrefix(tok, prefix)
+			first = false
+		}
+		if len(result) == MaxRanges {
+			return nil, ErrLimit
+		}
+		rng, err := parseRange(trimOWS(tok), len(result), contentLen)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rng)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return mergeRanges(result), nil
+}
+
+// scanRanges returns a bufio.SplitFunc that splits its input on commas. Like
+// strings.Split, a trailing comma yields a final, empty token.
+func scanRanges() bufio.SplitFunc {
+	comma := false
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, ','); i >= 0 {
+			comma = true
+			return i + 1, data[:i], nil
+		}
+		if !atEOF {
+			return 0, nil, nil
+		}
+		if len(data) > 0 || comma {
+			return len(data), append([]byte{}, data...), bufio.ErrFinalToken
+		}
+		return 0, nil, nil
+	}
+}
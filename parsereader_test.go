@@ -0,0 +1,53 @@
+package ranger
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReader(t *testing.T) {
+	inputs := []string{
+		"bytes=0-99",
+		"bytes=0-99,50-149,200-",
+		"bytes=-50,0-9",
+		"bytes=0-9,",
+		"bytes=,0-9",
+		"bytes=0-9,,20-29",
+		"bytes=5-3",
+		"bytes=0-999",
+		"foo=0-9",
+		"0-9,20-29",
+	}
+	for _, input := range inputs {
+		want, wantErr := Parse([]string{input}, "bytes=", 300)
+		got, gotErr := ParseReader(strings.NewReader(input), "bytes=", 300)
+		if got, want := fmt.Sprintf("%v", gotErr), fmt.Sprintf("%v", wantErr); got != want {
+			t.Errorf("%q: bad error: got %q, want %q", input, got, want)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: bad ranges: got %+v, want %+v", input, got, want)
+		}
+	}
+}
+
+func TestParseReaderEmpty(t *testing.T) {
+	ranges, err := ParseReader(strings.NewReader(""), "bytes=", 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ranges, []Range{}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseReaderMaxRanges(t *testing.T) {
+	input := "bytes=0-0" + strings.Repeat(",0-0", MaxRanges)
+	if _, err := ParseReader(strings.NewReader(input), "bytes=", 300); err != ErrLimit {
+		t.Errorf("bad error: got %v, want %v", err, ErrLimit)
+	}
+	if _, err := Parse([]string{input}, "bytes=", 300); err != ErrLimit {
+		t.Errorf("bad error from Parse: got %v, want %v", err, ErrLimit)
+	}
+}
@@ -0,0 +1,146 @@
+package ranger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PartialCache keeps byte ranges of a single object, such as those fetched
+// from a remote resource, in memory, within a budget. It answers ReadAt for the
+// parts of the object it holds, and reports the parts it doesn't, so that the
+// caller can fetch them and Put them. Once it holds more than its budget, the
+// ranges that were read least recently are evicted.
+//
+// A PartialCache may be used concurrently. WithCache plugs one into an
+// HTTPReader, and CachingProxy.NewCache into a CachingProxy.
+type PartialCache struct {
+	maxBytes int64
+
+	mu     sync.Mutex
+	pieces []cachePiece // sorted, and not overlapping
+	size   int64
+	clock  uint64
+}
+
+// cachePiece is a range kept by a PartialCache.
+type cachePiece struct {
+	r        Range
+	data     []byte
+	lastUsed uint64
+}
+
+// NewPartialCache returns an empty PartialCache that holds at most maxBytes. If
+// maxBytes isn't positive, the cache holds everything it's given.
+func NewPartialCache(maxBytes int64) *PartialCache {
+	return &PartialCache{maxBytes: maxBytes}
+}
+
+// CacheMiss is the error ReadAt returns for a read that the cache can't answer
+// in full. Missing are the parts of the read that the cache doesn't hold.
+type CacheMiss struct {
+	Missing RangeSet
+}
+
+func (e *CacheMiss) Error() string {
+	return fmt.Sprintf("ranger: cache miss: %d bytes missing", e.Missing.Len())
+}
+
+// Put keeps data, the bytes of the range r, which must have the same length.
+// The parts of r that the cache already holds are left as they are. The cache
+// keeps slices of data, so the caller must not modify it afterwards.
+func (c *PartialCache) Put(r Range, data []byte) {
+	if r.Len() != int64(len(data)) || len(data) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock++
+	for _, gap := range NewRangeSet(r).Subtract(c.have()).Ranges() {
+		piece := cachePiece{r: gap, data: data[gap.Start-r.Start : gap.Stop-r.Start+1], lastUsed: c.clock}
+		i := sort.Search(len(c.pieces), func(i int) bool {
+			return c.pieces[i].r.Start > gap.Start
+		})
+		c.pieces = append(c.pieces, cachePiece{})
+		copy(c.pieces[i+1:], c.pieces[i:])
+		c.pieces[i] = piece
+		c.size += gap.Len()
+	}
+	c.evict()
+}
+
+// ReadAt reads len(p) bytes from the cache, at offset off in the object. If
+// the cache doesn't hold all of them, it reads as many as it holds from off
+// onwards, and the error is a *CacheMiss.
+func (c *PartialCache) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	want := Range{Start: off, Stop: off + int64(len(p)) - 1}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock++
+	n := 0
+	i := sort.Search(len(c.pieces), func(i int) bool {
+		return c.pieces[i].r.Stop >= off
+	})
+	for ; i < len(c.pieces) && n < len(p); i++ {
+		piece := &c.pieces[i]
+		if piece.r.Start > off+int64(n) {
+			break
+		}
+		piece.lastUsed = c.clock
+		n += copy(p[n:], piece.data[off+int64(n)-piece.r.Start:])
+	}
+	if n < len(p) {
+		return n, &CacheMiss{Missing: NewRangeSet(want).Subtract(c.have())}
+	}
+	return n, nil
+}
+
+// Missing returns the parts of r that the cache doesn't hold.
+func (c *PartialCache) Missing(r Range) RangeSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return NewRangeSet(r).Subtract(c.have())
+}
+
+// Have returns the ranges that the cache holds.
+func (c *PartialCache) Have() RangeSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.have()
+}
+
+// Len returns the number of bytes that the cache holds.
+func (c *PartialCache) Len() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *PartialCache) have() RangeSet {
+	ranges := make([]Range, len(c.pieces))
+	for i, piece := range c.pieces {
+		ranges[i] = piece.r
+	}
+	return NewRangeSet(ranges...)
+}
+
+// evict evicts the pieces used least recently, until the cache is within its
+// budget.
+func (c *PartialCache) evict() {
+	if c.maxBytes <= 0 {
+		return
+	}
+	for c.size > c.maxBytes {
+		oldest := 0
+		for i, piece := range c.pieces {
+			if piece.lastUsed < c.pieces[oldest].lastUsed {
+				oldest = i
+			}
+		}
+		c.size -= c.pieces[oldest].r.Len()
+		c.pieces = append(c.pieces[:oldest], c.pieces[oldest+1:]...)
+	}
+}
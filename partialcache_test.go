@@ -0,0 +1,168 @@
+package ranger
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type partialCacheTest struct {
+	Off             int64
+	Len             int
+	ExpectedN       int
+	ExpectedData    string
+	ExpectedMissing []Range
+}
+
+func TestPartialCache(t *testing.T) {
+	content := "0123456789abcdefghij"
+	c := NewPartialCache(0)
+	c.Put(Range{Start: 2, Stop: 5}, []byte(content[2:6]))
+	c.Put(Range{Start: 4, Stop: 9}, []byte(content[4:10]))
+	c.Put(Range{Start: 15, Stop: 17}, []byte(content[15:18]))
+	c.Put(Range{Start: 0, Stop: 0}, []byte("too long"))
+	if got, want := c.Len(), int64(11); got != want {
+		t.Errorf("bad length: got %d, want %d", got, want)
+	}
+	if got, want := c.Have().Ranges(), []Range{{Start: 2, Stop: 9}, {Start: 15, Stop: 17}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+	tests := []partialCacheTest{
+		{ // across pieces
+			Off:          3,
+			Len:          6,
+			ExpectedN:    6,
+			ExpectedData: content[3:9],
+		},
+		{ // prefix held
+			Off:             8,
+			Len:             10,
+			ExpectedN:       2,
+			ExpectedData:    content[8:10],
+			ExpectedMissing: []Range{{Start: 10, Stop: 14}},
+		},
+		{ // none held
+			Off:             0,
+			Len:             4,
+			ExpectedMissing: []Range{{Start: 0, Stop: 1}},
+		},
+	}
+	for i, test := range tests {
+		p := make([]byte, test.Len)
+		n, err := c.ReadAt(p, test.Off)
+		if got, want := n, test.ExpectedN; got != want {
+			t.Errorf("test %d: bad n: got %d, want %d", i, got, want)
+		}
+		if got, want := string(p[:n]), test.ExpectedData; got != want {
+			t.Errorf("test %d: bad data: got %q, want %q", i, got, want)
+		}
+		var miss *CacheMiss
+		if errors.As(err, &miss) {
+			if got, want := miss.Missing.Ranges(), test.ExpectedMissing; !reflect.DeepEqual(got, want) {
+				t.Errorf("test %d: bad missing ranges: got %v, want %v", i, got, want)
+			}
+		} else if test.ExpectedMissing != nil || err != nil {
+			t.Errorf("test %d: bad error: got %v, want a cache miss of %v", i, err, test.ExpectedMissing)
+		}
+	}
+	if got, want := c.Missing(Range{Start: 0, Stop: 19}).Ranges(), []Range{{Start: 0, Stop: 1}, {Start: 10, Stop: 14}, {Start: 18, Stop: 19}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad missing ranges: got %v, want %v", got, want)
+	}
+}
+
+func TestPartialCacheEviction(t *testing.T) {
+	c := NewPartialCache(10)
+	c.Put(Range{Start: 0, Stop: 3}, []byte("0123"))
+	c.Put(Range{Start: 10, Stop: 13}, []byte("abcd"))
+	// Reading the first piece makes the second the least recently used.
+	if _, err := c.ReadAt(make([]byte, 2), 0); err != nil {
+		t.Fatal(err)
+	}
+	c.Put(Range{Start: 20, Stop: 23}, []byte("wxyz"))
+	if got, want := c.Have().Ranges(), []Range{{Start: 0, Stop: 3}, {Start: 20, Stop: 23}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+	if got, want := c.Len(), int64(8); got != want {
+		t.Errorf("bad length: got %d, want %d", got, want)
+	}
+}
+
+func TestCacheMissError(t *testing.T) {
+	err := &CacheMiss{Missing: NewRangeSet(Range{Start: 0, Stop: 9})}
+	if got, want := fmt.Sprintf("%v", err), "ranger: cache miss: 10 bytes missing"; got != want {
+		t.Errorf("bad error: got %q, want %q", got, want)
+	}
+}
+
+func TestHTTPReaderCache(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, requests := countingServer(t, content)
+	c := NewPartialCache(0)
+	hr, err := NewHTTPReader(srv.Client(), srv.URL, WithCache(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 20)
+	for i := 0; i < 3; i++ {
+		if _, err := hr.ReadAt(p, 30); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(p), content[30:50]; got != want {
+			t.Fatalf("bad content: got %q, want %q", got, want)
+		}
+	}
+	// One request to learn the size, and one for the range.
+	if got, want := atomic.LoadInt64(requests), int64(2); got != want {
+		t.Errorf("bad number of requests: got %d, want %d", got, want)
+	}
+	if got, want := c.Have().Ranges(), []Range{{Start: 30, Stop: 49}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad cached ranges: got %v, want %v", got, want)
+	}
+}
+
+func TestCachingProxyPartialCache(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	rr := &rangeRecorder{h: Handler(strings.NewReader(content), int64(len(content)))}
+	srv := httptest.NewServer(rr)
+	defer srv.Close()
+	proxy := &CachingProxy{
+		Client:        srv.Client(),
+		Upstream:      srv.URL,
+		MaxObjectSize: 1,
+		NewCache:      func() *PartialCache { return NewPartialCache(20) },
+	}
+	tests := []cachingProxyTest{
+		{ // kept, although larger than MaxObjectSize
+			Range:            "bytes=10-19",
+			ExpectedBody:     content[10:20],
+			ExpectedUpstream: []string{"bytes=0-0", "bytes=10-19"},
+		},
+		{ // only the missing part is fetched
+			Range:            "bytes=15-24",
+			ExpectedBody:     content[15:25],
+			ExpectedUpstream: []string{"bytes=20-24"},
+		},
+		{ // more than the budget is fetched, and evicted
+			Range:            "bytes=50-79",
+			ExpectedBody:     content[50:80],
+			ExpectedUpstream: []string{"bytes=50-79", "bytes=50-79"},
+		},
+	}
+	for i, test := range tests {
+		rr.ranges = nil
+		req := httptest.NewRequest("GET", "/file", nil)
+		req.Header.Set("Range", test.Range)
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		if got, want := w.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+		if got, want := rr.ranges, test.ExpectedUpstream; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad upstream requests: got %q, want %q", i, got, want)
+		}
+	}
+}
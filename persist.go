@@ -0,0 +1,287 @@
+package ranger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// encodingVersion is the version of the binary and JSON encodings of RangeSet
+// and Tracker. It's written first, so that the encodings can change without
+// breaking checkpoints written by older versions.
+const encodingVersion = 1
+
+var errCorrupt = errors.New("ranger: corrupt encoding")
+
+// MarshalBinary encodes s compactly, as a version byte followed by varints
+// for the gap before each range and its length.
+func (s RangeSet) MarshalBinary() ([]byte, error) {
+	return s.appendBinary([]byte{encodingVersion}), nil
+}
+
+func (s RangeSet) appendBinary(b []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s.ranges)))
+	next := int64(0)
+	for _, r := range s.ranges {
+		b = binary.AppendUvarint(b, uint64(r.Start-next))
+		b = binary.AppendUvarint(b, uint64(r.Stop-r.Start))
+		next = r.Stop + 1
+	}
+	return b
+}
+
+// UnmarshalBinary decodes a RangeSet encoded by MarshalBinary.
+func (s *RangeSet) UnmarshalBinary(data []byte) error {
+	data, err := checkVersion(data)
+	if err != nil {
+		return err
+	}
+	set, rest, err := decodeRangeSet(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errCorrupt
+	}
+	*s = set
+	return nil
+}
+
+func checkVersion(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errCorrupt
+	}
+	if data[0] != encodingVersion {
+		return nil, fmt.Errorf("ranger: unsupported encoding version %d", data[0])
+	}
+	return data[1:], nil
+}
+
+func decodeRangeSet(data []byte) (RangeSet, []byte, error) {
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return RangeSet{}, nil, err
+	}
+	// Every range takes at least two bytes, which bounds the allocation.
+	if n > uint64(len(data)/2) {
+		return RangeSet{}, nil, errCorrupt
+	}
+	ranges := make([]Range, 0, n)
+	next := int64(0)
+	for i := uint64(0); i < n; i++ {
+		var gap, length uint64
+		if gap, data, err = readUvarint(data); err != nil {
+			return RangeSet{}, nil, err
+		}
+		if length, data, err = readUvarint(data); err != nil {
+			return RangeSet{}, nil, err
+		}
+		start := next + int64(gap)
+		stop := start + int64(length)
+		if gap > 1<<62 || length > 1<<62 || start < next || stop < start {
+			return RangeSet{}, nil, errCorrupt
+		}
+		ranges = append(ranges, Range{Start: start, Stop: stop})
+		next = stop + 1
+	}
+	return NewRangeSet(ranges...), data, nil
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errCorrupt
+	}
+	return v, data[n:], nil
+}
+
+type rangeSetJSON struct {
+	Version int        `json:"version"`
+	Length  *int64     `json:"length,omitempty"`
+	Ranges  [][2]int64 `json:"ranges"`
+}
+
+func (s RangeSet) toJSON() rangeSetJSON {
+	v := rangeSetJSON{Version: encodingVersion, Ranges: make([][2]int64, 0, len(s.ranges))}
+	for _, r := range s.ranges {
+		v.Ranges = append(v.Ranges, [2]int64{r.Start, r.Stop})
+	}
+	return v
+}
+
+func (v rangeSetJSON) rangeSet() (RangeSet, error) {
+	if v.Version != encodingVersion {
+		return RangeSet{}, fmt.Errorf("ranger: unsupported encoding version %d", v.Version)
+	}
+	ranges := make([]Range, 0, len(v.Ranges))
+	for _, r := range v.Ranges {
+		if r[0] < 0 || r[0] > r[1] {
+			return RangeSet{}, errCorrupt
+		}
+		ranges = append(ranges, Range{Start: r[0], Stop: r[1]})
+	}
+	return NewRangeSet(ranges...), nil
+}
+
+// MarshalJSON encodes s as an object holding the encoding version and a list
+// of [start, stop] pairs.
+func (s RangeSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.toJSON())
+}
+
+// UnmarshalJSON decodes a RangeSet encoded by MarshalJSON.
+func (s *RangeSet) UnmarshalJSON(data []byte) error {
+	var v rangeSetJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	set, err := v.rangeSet()
+	if err != nil {
+		return err
+	}
+	*s = set
+	return nil
+}
+
+// MarshalBinary encodes the length of the content t is tracking, and the
+// ranges completed so far, so that an interrupted operation can be resumed.
+func (t *Tracker) MarshalBinary() ([]byte, error) {
+	b := binary.AppendUvarint([]byte{encodingVersion}, uint64(t.length))
+	return t.Completed().appendBinary(b), nil
+}
+
+// UnmarshalBinary decodes a Tracker encoded by MarshalBinary. It replaces
+// everything in t, so it should be called on a new Tracker, before it's
+// shared with other goroutines.
+func (t *Tracker) UnmarshalBinary(data []byte) error {
+	data, err := checkVersion(data)
+	if err != nil {
+		return err
+	}
+	length, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+	set, rest, err := decodeRangeSet(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 || length > 1<<62 {
+		return errCorrupt
+	}
+	t.restore(int64(length), set)
+	return nil
+}
+
+// MarshalJSON encodes t like RangeSet.MarshalJSON, along with the length of
+// the content.
+func (t *Tracker) MarshalJSON() ([]byte, error) {
+	v := t.Completed().toJSON()
+	v.Length = &t.length
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a Tracker encoded by MarshalJSON. As with
+// UnmarshalBinary, it should be called before t is shared.
+func (t *Tracker) UnmarshalJSON(data []byte) error {
+	var v rangeSetJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Length == nil || *v.Length < 0 {
+		return errCorrupt
+	}
+	set, err := v.rangeSet()
+	if err != nil {
+		return err
+	}
+	t.restore(*v.Length, set)
+	return nil
+}
+
+// restore resets t to track content of length bytes, with set completed.
+func (t *Tracker) restore(length int64, set RangeSet) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.length = length
+	t.done = make(chan struct{})
+	t.set = set.Intersect(NewRangeSet(Range{Start: 0, Stop: length - 1}))
+	if t.set.Len() == t.length {
+		close(t.done)
+	}
+}
+
+// MarshalText encodes r as in a Range header, such as '100-200', which is how
+// it's also encoded as JSON.
+func (r Range) MarshalText() ([]byte, error) {
+	if r.Start < 0 || r.Start > r.Stop {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, r)
+	}
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decodes a range encoded by MarshalText. Suffix and open-ended
+// ranges can't be decoded into a Range, since they can't be resolved without
+// the length of the content; use a RangeSpec for those.
+func (r *Range) UnmarshalText(text []byte) error {
+	spec, reason := parseSpec(text)
+	if reason != "" || spec.IsSuffix() || spec.IsOpenEnded() {
+		return fmt.Errorf("%w: %q", ErrMalformed, text)
+	}
+	*r = Range{Start: spec.First, Stop: spec.Last}
+	return nil
+}
+
+// MarshalText encodes s as in a Range header, such as '0-99', '-500' or
+// '100-'.
+func (s RangeSpec) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a range encoded by MarshalText, as ParseSpec does.
+func (s *RangeSpec) UnmarshalText(text []byte) error {
+	spec, reason := parseSpec(text)
+	if reason != "" {
+		return fmt.Errorf("%w: %q", ErrMalformed, text)
+	}
+	*s = spec
+	return nil
+}
+
+// MarshalText encodes s as a comma-separated list of ranges, such as
+// '0-99,200-299'. The empty set is encoded as the empty string. As JSON, s has
+// the form MarshalJSON gives it instead.
+func (s RangeSet) MarshalText() ([]byte, error) {
+	var b []byte
+	for i, r := range s.ranges {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendInt(b, r.Start, 10)
+		b = append(b, '-')
+		b = strconv.AppendInt(b, r.Stop, 10)
+	}
+	return b, nil
+}
+
+// UnmarshalText decodes a RangeSet encoded by MarshalText. The ranges needn't
+// be in order, and may overlap.
+func (s *RangeSet) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*s = RangeSet{}
+		return nil
+	}
+	var ranges []Range
+	for _, field := range bytes.Split(text, []byte(",")) {
+		var r Range
+		if err := r.UnmarshalText(field); err != nil {
+			return err
+		}
+		ranges = append(ranges, r)
+	}
+	*s = NewRangeSet(ranges...)
+	return nil
+}
@@ -0,0 +1,227 @@
+package ranger
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRangeSetBinary(t *testing.T) {
+	sets := []RangeSet{
+		{},
+		NewRangeSet(Range{Start: 0, Stop: 0}),
+		NewRangeSet(Range{Start: 0, Stop: 99}, Range{Start: 1 << 40, Stop: 1<<40 + 99}),
+	}
+	for i, s := range sets {
+		data, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got RangeSet
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("test %d: %s", i, err)
+		}
+		if !got.Equal(s) {
+			t.Errorf("test %d: bad round trip: got %+v, want %+v", i, got.Ranges(), s.Ranges())
+		}
+	}
+}
+
+type unmarshalTest struct {
+	Data          []byte
+	ExpectedError string
+}
+
+func TestRangeSetUnmarshalBinaryErrors(t *testing.T) {
+	tests := []unmarshalTest{
+		{ // empty
+			Data:          nil,
+			ExpectedError: "ranger: corrupt encoding",
+		},
+		{ // unknown version
+			Data:          []byte{2, 0},
+			ExpectedError: "ranger: unsupported encoding version 2",
+		},
+		{ // truncated
+			Data:          []byte{1, 1, 0},
+			ExpectedError: "ranger: corrupt encoding",
+		},
+		{ // trailing bytes
+			Data:          []byte{1, 0, 0},
+			ExpectedError: "ranger: corrupt encoding",
+		},
+		{ // count larger than the data
+			Data:          []byte{1, 100, 0, 0},
+			ExpectedError: "ranger: corrupt encoding",
+		},
+	}
+	for i, test := range tests {
+		var s RangeSet
+		err := s.UnmarshalBinary(test.Data)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestRangeSetJSON(t *testing.T) {
+	s := NewRangeSet(Range{Start: 0, Stop: 99}, Range{Start: 200, Stop: 299})
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"version":1,"ranges":[[0,99],[200,299]]}`; got != want {
+		t.Errorf("bad JSON: got %s, want %s", got, want)
+	}
+	var got RangeSet
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(s) {
+		t.Errorf("bad round trip: got %+v, want %+v", got.Ranges(), s.Ranges())
+	}
+	if err := json.Unmarshal([]byte(`{"version":1,"ranges":[[9,0]]}`), &got); err == nil {
+		t.Error("reversed range decoded")
+	}
+	if err := json.Unmarshal([]byte(`{"version":2,"ranges":[]}`), &got); err == nil {
+		t.Error("unknown version decoded")
+	}
+}
+
+func TestTrackerPersistence(t *testing.T) {
+	tr := NewTracker(100)
+	tr.Mark(Range{Start: 0, Stop: 9})
+	tr.Mark(Range{Start: 50, Stop: 59})
+	want := []Range{{Start: 10, Stop: 49}, {Start: 60, Stop: 99}}
+
+	data, err := tr.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBinary := new(Tracker)
+	if err := fromBinary.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	data, err = json.Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := new(Tracker)
+	if err := json.Unmarshal(data, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	for _, resumed := range []*Tracker{fromBinary, fromJSON} {
+		if got, want := resumed.Length(), int64(100); got != want {
+			t.Errorf("bad length: got %d, want %d", got, want)
+		}
+		if got := resumed.Missing(); !reflect.DeepEqual(got, want) {
+			t.Errorf("bad missing ranges: got %+v, want %+v", got, want)
+		}
+		resumed.Mark(Range{Start: 0, Stop: 99})
+		select {
+		case <-resumed.Done():
+		default:
+			t.Error("resumed tracker not done when complete")
+		}
+	}
+}
+
+type rangeTextTest struct {
+	Text          string
+	Expected      Range
+	ExpectedError string
+}
+
+func TestRangeText(t *testing.T) {
+	tests := []rangeTextTest{
+		{ // a range
+			Text:          "100-200",
+			Expected:      Range{Start: 100, Stop: 200},
+			ExpectedError: "<nil>",
+		},
+		{ // suffix
+			Text:          "-500",
+			ExpectedError: `invalid range: malformed: "-500"`,
+		},
+		{ // open-ended
+			Text:          "100-",
+			ExpectedError: `invalid range: malformed: "100-"`,
+		},
+		{ // reversed
+			Text:          "200-100",
+			ExpectedError: `invalid range: malformed: "200-100"`,
+		},
+	}
+	for i, test := range tests {
+		var r Range
+		err := r.UnmarshalText([]byte(test.Text))
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %v, want %v", i, got, want)
+		}
+		if got, want := r, test.Expected; got != want {
+			t.Errorf("test %d: bad range: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+// rangeDocument is a document holding the range types.
+type rangeDocument struct {
+	Ranges []Range
+	Specs  []RangeSpec
+	Set    RangeSet
+}
+
+func TestRangeJSON(t *testing.T) {
+	v := rangeDocument{
+		Ranges: []Range{{Start: 0, Stop: 99}, {Start: 200, Stop: 299}},
+		Specs:  []RangeSpec{{First: -1, Last: 500}, {First: 100, Last: -1}, {First: 0, Last: 9}},
+		Set:    NewRangeSet(Range{Start: 0, Stop: 9}),
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Ranges":["0-99","200-299"],"Specs":["-500","100-","0-9"],"Set":{"version":1,"ranges":[[0,9]]}}`
+	if got := string(data); got != want {
+		t.Errorf("bad encoding: got %s, want %s", got, want)
+	}
+	var got rangeDocument
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("bad round trip: got %+v, want %+v", got, v)
+	}
+}
+
+func TestRangeSetText(t *testing.T) {
+	sets := []RangeSet{
+		{},
+		NewRangeSet(Range{Start: 0, Stop: 0}),
+		NewRangeSet(Range{Start: 0, Stop: 99}, Range{Start: 200, Stop: 299}),
+	}
+	for i, s := range sets {
+		text, err := s.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got RangeSet
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(s) {
+			t.Errorf("test %d: bad round trip of %q: got %v, want %v", i, text, got, s)
+		}
+	}
+	var s RangeSet
+	if err := s.UnmarshalText([]byte("200-299,0-150,100-199")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Ranges(), []Range{{Start: 0, Stop: 299}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+	if err := s.UnmarshalText([]byte("0-99,")); err == nil {
+		t.Error("expected an error for a trailing comma")
+	}
+}
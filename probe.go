@@ -0,0 +1,139 @@
+package ranger
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Capabilities are what a server supports for a resource, as found by Probe.
+type Capabilities struct {
+	// AcceptRanges is whether the server advertised support for byte ranges
+	// with Accept-Ranges, which many servers that do support them don't.
+	AcceptRanges bool
+
+	// Ranges is whether the server answered a request for a byte range with
+	// just that range.
+	Ranges bool
+
+	// Size is the length of the resource, or -1 if it's unknown.
+	Size int64
+
+	// ETag and LastModified are the resource's validators, if it has them.
+	ETag         string
+	LastModified string
+
+	// IfRange is whether the server honours If-Range: serving the range when
+	// the validator matches, and all of the resource when it doesn't.
+	IfRange bool
+}
+
+// Parallel reports whether the resource can be fetched with several range
+// requests at once: the server serves ranges, and the size is known. Unless
+// IfRange is true too, a change to the resource in between the requests can't
+// be detected reliably.
+func (c Capabilities) Parallel() bool {
+	return c.Ranges && c.Size > 0
+}
+
+// Probe finds out what the server at url supports for the resource there, so
+// that a client can choose between fetching it with parallel range requests
+// and fetching it in a single stream. It makes a HEAD request, then a GET for
+// the first byte, and, if the resource has a validator, two more GETs for the
+// first byte with If-Range, one matching and one not. No more than a byte of
+// any body is read. If client is nil, http.DefaultClient is used.
+//
+// A HEAD request that fails is ignored, since some servers don't allow them.
+// If the GET gets a status other than 200, 206 or 416, the error is a
+// *StatusError.
+func Probe(ctx context.Context, client *http.Client, url string) (Capabilities, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := Capabilities{Size: -1}
+	if resp, err := probeRequest(ctx, client, http.MethodHead, url, nil); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			c.AcceptRanges = AcceptsRanges(resp.Header)
+			c.Size = resp.ContentLength
+			c.ETag = resp.Header.Get("Etag")
+			c.LastModified = resp.Header.Get("Last-Modified")
+		}
+	}
+
+	resp, err := probeRequest(ctx, client, http.MethodGet, url, http.Header{"Range": {"bytes=0-0"}})
+	if err != nil {
+		return c, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		_, total, err := ParseContentRange(resp.Header)
+		if err != nil {
+			return c, err
+		}
+		c.Ranges = true
+		c.Size = total
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Only an empty resource can't satisfy a request for its first byte.
+		cr, err := ParseContentRangeValue(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return c, err
+		}
+		c.Ranges = true
+		c.Size = cr.Length
+	case http.StatusOK:
+		if resp.ContentLength >= 0 {
+			c.Size = resp.ContentLength
+		}
+	default:
+		return c, &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	c.AcceptRanges = c.AcceptRanges || AcceptsRanges(resp.Header)
+	if c.ETag == "" && c.LastModified == "" {
+		c.ETag = resp.Header.Get("Etag")
+		c.LastModified = resp.Header.Get("Last-Modified")
+	}
+
+	v := validators{etag: c.ETag, lastModified: c.LastModified}
+	if !c.Ranges || c.Size <= 0 || v.ifRange() == "" {
+		return c, nil
+	}
+	stale := `"ranger-probe"`
+	if !strings.HasPrefix(v.ifRange(), `"`) {
+		stale = time.Unix(0, 0).UTC().Format(http.TimeFormat)
+	}
+	matched, err := probeStatus(ctx, client, url, v.ifRange())
+	if err != nil {
+		return c, err
+	}
+	unmatched, err := probeStatus(ctx, client, url, stale)
+	if err != nil {
+		return c, err
+	}
+	c.IfRange = matched == http.StatusPartialContent && unmatched == http.StatusOK
+	return c, nil
+}
+
+// probeStatus returns the status of a GET for the first byte of the resource
+// at url, with the given If-Range.
+func probeStatus(ctx context.Context, client *http.Client, url, ifRange string) (int, error) {
+	resp, err := probeRequest(ctx, client, http.MethodGet, url, http.Header{"Range": {"bytes=0-0"}, "If-Range": {ifRange}})
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func probeRequest(ctx context.Context, client *http.Client, method, url string, h http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range h {
+		req.Header[k] = vs
+	}
+	return client.Do(req)
+}
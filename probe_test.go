@@ -0,0 +1,83 @@
+package ranger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type probeTest struct {
+	Handler       http.Handler
+	Expected      Capabilities
+	ExpectedError string
+}
+
+func TestProbe(t *testing.T) {
+	content := "0123456789"
+	tests := []probeTest{
+		{ // everything supported
+			Handler:       Handler(strings.NewReader(content), 10, WithETag(`"v1"`)),
+			Expected:      Capabilities{AcceptRanges: true, Ranges: true, Size: 10, ETag: `"v1"`, IfRange: true},
+			ExpectedError: "<nil>",
+		},
+		{ // no validators
+			Handler:       Handler(strings.NewReader(content), 10),
+			Expected:      Capabilities{AcceptRanges: true, Ranges: true, Size: 10},
+			ExpectedError: "<nil>",
+		},
+		{ // no ranges
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, content)
+			}),
+			Expected:      Capabilities{Size: 10},
+			ExpectedError: "<nil>",
+		},
+		{ // ranges, but If-Range is ignored
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.Header.Del("If-Range")
+				w.Header().Set("Etag", `"v1"`)
+				ServeRanges(w, r, "", time.Time{}, strings.NewReader(content))
+			}),
+			Expected:      Capabilities{AcceptRanges: true, Ranges: true, Size: 10, ETag: `"v1"`},
+			ExpectedError: "<nil>",
+		},
+		{ // empty
+			Handler:       Handler(strings.NewReader(""), 0, WithETag(`"v1"`)),
+			Expected:      Capabilities{AcceptRanges: true, Ranges: true, Size: 0, ETag: `"v1"`},
+			ExpectedError: "<nil>",
+		},
+		{ // missing
+			Handler:       http.NotFoundHandler(),
+			Expected:      Capabilities{Size: -1},
+			ExpectedError: "ranger: GET URL: 404 Not Found",
+		},
+	}
+	for i, test := range tests {
+		srv := httptest.NewServer(test.Handler)
+		got, err := Probe(context.Background(), srv.Client(), srv.URL)
+		srv.Close()
+		if got != test.Expected {
+			t.Errorf("test %d: bad capabilities: got %+v, want %+v", i, got, test.Expected)
+		}
+		if got, want := strings.ReplaceAll(fmt.Sprintf("%v", err), srv.URL, "URL"), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestCapabilitiesParallel(t *testing.T) {
+	if !(Capabilities{Ranges: true, Size: 10}).Parallel() {
+		t.Error("ranges of known size not parallel")
+	}
+	if (Capabilities{Ranges: true, Size: -1}).Parallel() {
+		t.Error("ranges of unknown size parallel")
+	}
+	if (Capabilities{Size: 10}).Parallel() {
+		t.Error("no ranges parallel")
+	}
+}
@@ -0,0 +1,119 @@
+package ranger
+
+import (
+	"sync"
+	"time"
+)
+
+// rateWindow is how far back a ProgressMeter looks to measure the current
+// rate of a download.
+const rateWindow = 5 * time.Second
+
+// Progress is a snapshot of the progress of a download.
+type Progress struct {
+	// Completed is the number of bytes downloaded so far, out of Total.
+	Completed int64
+	Total     int64
+
+	// Remaining is the number of ranges still to be downloaded.
+	Remaining int
+
+	// Rate is the rate of the download over the last few seconds, and
+	// AverageRate is its rate since it started, both in bytes per second.
+	// Bytes already downloaded when it started, such as by an earlier
+	// attempt that's being resumed, don't count.
+	Rate        float64
+	AverageRate float64
+
+	// Elapsed is the time since the download started.
+	Elapsed time.Duration
+
+	// ETA is the time the rest of the download is estimated to take, at the
+	// current rate, or -1 if it can't be estimated yet.
+	ETA time.Duration
+}
+
+// ProgressMeter measures the progress of a download, for rendering progress
+// bars and the like. Set it as a Downloader's Progress, and call Snapshot
+// from any goroutine while the download is running.
+type ProgressMeter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	start     time.Time
+	initial   int64
+	completed int64
+	total     int64
+	remaining int
+	samples   []progressSample
+}
+
+type progressSample struct {
+	at        time.Time
+	completed int64
+}
+
+// NewProgressMeter returns a ProgressMeter for a download that hasn't started.
+func NewProgressMeter() *ProgressMeter {
+	return &ProgressMeter{now: time.Now}
+}
+
+// begin starts measuring a download tracked by tr.
+func (m *ProgressMeter) begin(tr *Tracker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.start = m.now()
+	m.initial = tr.Covered()
+	m.total = tr.Length()
+	m.completed = m.initial
+	m.remaining = len(tr.Missing())
+	m.samples = []progressSample{{at: m.start, completed: m.completed}}
+}
+
+// update records the progress of the download tracked by tr.
+func (m *ProgressMeter) update(tr *Tracker) {
+	now := m.now()
+	completed, remaining := tr.Covered(), len(tr.Missing())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.completed, m.remaining = completed, remaining
+	// Keep one sample from before the window, to measure across all of it.
+	i := 0
+	for i+1 < len(m.samples) && now.Sub(m.samples[i+1].at) >= rateWindow {
+		i++
+	}
+	m.samples = append(m.samples[i:], progressSample{at: now, completed: completed})
+}
+
+// Snapshot returns the progress of the download so far.
+func (m *ProgressMeter) Snapshot() Progress {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := Progress{
+		Completed: m.completed,
+		Total:     m.total,
+		Remaining: m.remaining,
+		ETA:       -1,
+	}
+	if m.start.IsZero() {
+		return p
+	}
+	p.Elapsed = now.Sub(m.start)
+	if secs := p.Elapsed.Seconds(); secs > 0 {
+		p.AverageRate = float64(m.completed-m.initial) / secs
+	}
+	p.Rate = p.AverageRate
+	if first, last := m.samples[0], m.samples[len(m.samples)-1]; len(m.samples) > 1 {
+		if secs := now.Sub(first.at).Seconds(); secs > 0 {
+			p.Rate = float64(last.completed-first.completed) / secs
+		}
+	}
+	switch {
+	case m.completed == m.total:
+		p.ETA = 0
+	case p.Rate > 0:
+		p.ETA = time.Duration(float64(m.total-m.completed) / p.Rate * float64(time.Second))
+	}
+	return p
+}
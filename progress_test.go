@@ -0,0 +1,83 @@
+package ranger
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgressMeter(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	m := NewProgressMeter()
+	m.now = func() time.Time { return now }
+	if got := m.Snapshot(); got.ETA != -1 || got.Total != 0 {
+		t.Errorf("bad progress before starting: %+v", got)
+	}
+
+	tr := NewTracker(1000)
+	tr.Mark(Range{Start: 0, Stop: 99})
+	m.begin(tr)
+	for i := int64(1); i <= 4; i++ {
+		now = now.Add(2 * time.Second)
+		tr.Mark(Range{Start: i * 100, Stop: i*100 + 99})
+		m.update(tr)
+	}
+	got := m.Snapshot()
+	want := Progress{
+		Completed:   500,
+		Total:       1000,
+		Remaining:   1,
+		Rate:        50,
+		AverageRate: 50,
+		Elapsed:     8 * time.Second,
+		ETA:         10 * time.Second,
+	}
+	if got != want {
+		t.Errorf("bad progress: got %+v, want %+v", got, want)
+	}
+
+	// Speed up, so the current rate moves away from the average.
+	for i := int64(5); i <= 9; i++ {
+		now = now.Add(time.Second / 4)
+		tr.Mark(Range{Start: i * 100, Stop: i*100 + 99})
+		m.update(tr)
+	}
+	got = m.Snapshot()
+	if got.Rate <= got.AverageRate {
+		t.Errorf("bad rate: got %v, want more than the average of %v", got.Rate, got.AverageRate)
+	}
+	if got.ETA != 0 || got.Completed != 1000 || got.Remaining != 0 {
+		t.Errorf("bad progress once complete: %+v", got)
+	}
+}
+
+func TestDownloaderProgress(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, _ := countingServer(t, content)
+	var mu sync.Mutex
+	var last Progress
+	calls := 0
+	meter := NewProgressMeter()
+	d := &Downloader{Client: srv.Client(), ChunkSize: 10, Progress: meter, OnProgress: func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if p.Completed > last.Completed {
+			last = p
+		}
+	}}
+	if _, err := d.Download(context.Background(), srv.URL, memFile(make([]byte, 100)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := calls, 10; got != want {
+		t.Errorf("bad number of calls: got %d, want %d", got, want)
+	}
+	if last.Completed != 100 || last.Total != 100 || last.Remaining != 0 || last.ETA != 0 {
+		t.Errorf("bad final progress: %+v", last)
+	}
+	if got := meter.Snapshot(); got.Completed != 100 {
+		t.Errorf("bad snapshot: %+v", got)
+	}
+}
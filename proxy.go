@@ -0,0 +1,141 @@
+package ranger
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+// ForwardRange copies the fields of a client's request that make it a range
+// request, Range and If-Range, to the header of a request to forward upstream.
+func ForwardRange(dst, src http.Header) {
+	for _, key := range []string{"Range", "If-Range"} {
+		if v, ok := src[key]; ok {
+			dst[key] = append([]string(nil), v...)
+		}
+	}
+}
+
+// VerifyingTransport is an http.RoundTripper for reverse proxies, such as
+// httputil.ReverseProxy, that checks each 206 response from upstream against
+// the Range header of the request it answers, so that broken origins that
+// ignore or mangle ranges can't corrupt proxied downloads.
+//
+// A response whose Content-Range is malformed, or describes bytes that
+// weren't asked for, is rejected with an error wrapping ErrContentRange, which
+// a ReverseProxy reports to the client as a 502. The body of a single-part
+// response must hold exactly the bytes its Content-Range describes, and each
+// part of a multipart/byteranges body must hold exactly its range, with all
+// parts agreeing on the length of the content. A multipart body is checked as
+// it's streamed, and reframed, with the same boundary and part header fields;
+// a mismatch in it fails the read.
+//
+// Ranges can only be checked against the length of the content, so if
+// upstream reports it as unknown, only the lengths of the parts are checked.
+// Responses to requests whose Range header ranger can't parse are passed on
+// unchecked, as are responses with any other status.
+type VerifyingTransport struct {
+	// Base makes the upstream requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+func (t *VerifyingTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *VerifyingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base().RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusPartialContent {
+		return resp, err
+	}
+	specs, err := ParseSpecs(req.Header["Range"], "bytes=")
+	if err != nil {
+		return resp, nil
+	}
+	mediaType, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "multipart/byteranges" {
+		mr, err := NewMultipartResponseReader(resp)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		go reframe(pw, mr, params["boundary"], resp.Body, specs)
+		resp.Body = pr
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	}
+	cr, err := ParseContentRangeValue(resp.Header.Get("Content-Range"))
+	if err == nil && cr.Unsatisfied {
+		err = fmt.Errorf("%w: unsatisfied range in 206 response", ErrContentRange)
+	}
+	if err == nil {
+		err = checkPart(cr.Range, cr.Length, specs)
+	}
+	if err == nil && resp.ContentLength >= 0 && resp.ContentLength != cr.Range.Len() {
+		err = fmt.Errorf("%w: %d bytes for range %v", ErrContentRange, resp.ContentLength, cr.Range)
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{&partReader{r: resp.Body, remaining: cr.Range.Len()}, resp.Body}
+	return resp, nil
+}
+
+// checkPart checks that r, of content of the given length, was asked for by
+// specs. Specs that run past the end of the content ask for as much of it as
+// there is, as RFC 7233 has a server clamp them, and specs that start past
+// the end ask for none of it.
+func checkPart(r Range, length int64, specs []RangeSpec) error {
+	if length < 0 {
+		return nil
+	}
+	requested, _ := PartitionSpecs(specs, length)
+	if len(requested) == 0 {
+		return fmt.Errorf("%w: range %v sent for unsatisfiable request", ErrContentRange, r)
+	}
+	if NewRangeSet(r).Subtract(NewRangeSet(requested...)).Len() > 0 {
+		return fmt.Errorf("%w: range %v wasn't requested", ErrContentRange, r)
+	}
+	return nil
+}
+
+// reframe checks each part read by mr against specs, and writes it to pw,
+// separated by the same boundary. body is closed once it's done.
+func reframe(pw *io.PipeWriter, mr *MultipartReader, boundary string, body io.Closer, specs []RangeSpec) {
+	defer body.Close()
+	mw := multipart.NewWriter(pw)
+	err := mw.SetBoundary(boundary)
+	for err == nil {
+		var part *multipart.Part
+		var r Range
+		var content io.Reader
+		part, r, content, err = mr.nextPart()
+		if err == io.EOF {
+			err = mw.Close()
+			break
+		}
+		if err != nil {
+			break
+		}
+		if err = checkPart(r, mr.Length(), specs); err != nil {
+			break
+		}
+		var w io.Writer
+		if w, err = mw.CreatePart(part.Header); err != nil {
+			break
+		}
+		_, err = io.Copy(w, content)
+	}
+	pw.CloseWithError(err)
+}
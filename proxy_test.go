@@ -0,0 +1,211 @@
+package ranger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// partialHandler replies to every request with a 206 carrying the given
+// Content-Range and body, whatever was asked for.
+type partialHandler struct {
+	contentRange string
+	body         string
+}
+
+func (h partialHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Range", h.contentRange)
+	w.WriteHeader(http.StatusPartialContent)
+	io.WriteString(w, h.body)
+}
+
+type verifyingTransportTest struct {
+	Range         string
+	Upstream      http.Handler
+	ExpectedBody  string
+	ExpectedError string
+}
+
+func TestVerifyingTransport(t *testing.T) {
+	content := "0123456789"
+	good := Handler(strings.NewReader(content), int64(len(content)))
+	compliant := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	})
+	tests := []verifyingTransportTest{
+		{ // single range
+			Range:         "bytes=2-4",
+			Upstream:      good,
+			ExpectedBody:  "234",
+			ExpectedError: "<nil>",
+		},
+		{ // several ranges, reframed
+			Range:         "bytes=0-1,5-6",
+			Upstream:      good,
+			ExpectedBody:  "01|56|",
+			ExpectedError: "<nil>",
+		},
+		{ // past the end, clamped by a compliant server
+			Range:         "bytes=2-999",
+			Upstream:      compliant,
+			ExpectedBody:  "23456789",
+			ExpectedError: "<nil>",
+		},
+		{ // one satisfiable, one not, by a compliant server
+			Range:         "bytes=0-9,500-600",
+			Upstream:      compliant,
+			ExpectedBody:  "0123456789",
+			ExpectedError: "<nil>",
+		},
+		{ // several ranges, one clamped
+			Range:         "bytes=0-1,5-999",
+			Upstream:      good,
+			ExpectedBody:  "01|56789|",
+			ExpectedError: "<nil>",
+		},
+		{ // none satisfiable
+			Range:         "bytes=20-30",
+			Upstream:      partialHandler{contentRange: "bytes 0-2/10", body: "012"},
+			ExpectedError: `ranger: invalid content-range: range 0-2 sent for unsatisfiable request`,
+		},
+		{ // not what was asked for
+			Range:         "bytes=2-4",
+			Upstream:      partialHandler{contentRange: "bytes 0-2/10", body: "012"},
+			ExpectedError: `ranger: invalid content-range: range 0-2 wasn't requested`,
+		},
+		{ // malformed Content-Range
+			Range:         "bytes=2-4",
+			Upstream:      partialHandler{contentRange: "bytes 2-4", body: "234"},
+			ExpectedError: `ranger: invalid content-range: "bytes 2-4"`,
+		},
+		{ // body shorter than its range
+			Range:         "bytes=2-4",
+			Upstream:      partialHandler{contentRange: "bytes 2-4/10", body: "23"},
+			ExpectedError: `ranger: invalid content-range: 2 bytes for range 2-4`,
+		},
+		{ // unknown length, so only the body is checked
+			Range:         "bytes=2-4",
+			Upstream:      partialHandler{contentRange: "bytes 2-4/*", body: "234"},
+			ExpectedBody:  "234",
+			ExpectedError: "<nil>",
+		},
+		{ // unparseable request, passed on
+			Range:         "lines=2-4",
+			Upstream:      partialHandler{contentRange: "lines 2-4/10", body: "234"},
+			ExpectedBody:  "234",
+			ExpectedError: "<nil>",
+		},
+	}
+	for i, test := range tests {
+		srv := httptest.NewServer(test.Upstream)
+		client := &http.Client{Transport: &VerifyingTransport{Base: srv.Client().Transport}}
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		req.Header.Set("Range", test.Range)
+		body, err := verifiedBody(client, req)
+		srv.Close()
+		if got, want := fmt.Sprintf("%v", errorsTail(err)), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %v, want %v", i, got, want)
+		}
+		if got, want := body, test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+// verifiedBody gets the body of a response to req, with the parts of a
+// multipart body each followed by '|'.
+func verifiedBody(client *http.Client, req *http.Request) (string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "multipart/") {
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+	mr, err := NewMultipartResponseReader(resp)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for {
+		_, part, err := mr.NextPart()
+		if err == io.EOF {
+			return sb.String(), nil
+		} else if err != nil {
+			return "", err
+		}
+		b, err := io.ReadAll(part)
+		if err != nil {
+			return "", err
+		}
+		sb.Write(b)
+		sb.WriteString("|")
+	}
+}
+
+// errorsTail strips the *url.Error wrapping of a client error.
+func errorsTail(err error) error {
+	if ue, ok := err.(*url.Error); ok {
+		return ue.Err
+	}
+	return err
+}
+
+func TestVerifyingTransportMultipartMismatch(t *testing.T) {
+	var body strings.Builder
+	mw := NewMultipartWriter(&body, 10, "")
+	mw.WritePart(Range{Start: 0, Stop: 1}, strings.NewReader("01"))
+	mw.WritePart(Range{Start: 7, Stop: 8}, strings.NewReader("78"))
+	mw.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mw.ContentType())
+		w.WriteHeader(http.StatusPartialContent)
+		io.WriteString(w, body.String())
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: &VerifyingTransport{Base: srv.Client().Transport}}
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Range", "bytes=0-1,5-6")
+	_, err := verifiedBody(client, req)
+	if !errors.Is(err, ErrContentRange) {
+		t.Errorf("bad error: got %v, want %v", err, ErrContentRange)
+	}
+}
+
+func TestVerifyingTransportReverseProxy(t *testing.T) {
+	upstream := httptest.NewServer(partialHandler{contentRange: "bytes 0-2/10", body: "012"})
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	rp := httputil.NewSingleHostReverseProxy(u)
+	rp.Transport = &VerifyingTransport{Base: upstream.Client().Transport}
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=5-7")
+	w := httptest.NewRecorder()
+	rp.ServeHTTP(w, req)
+	if got, want := w.Code, http.StatusBadGateway; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+}
+
+func TestForwardRange(t *testing.T) {
+	src := http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"v1"`}, "Accept": {"*/*"}}
+	dst := http.Header{}
+	ForwardRange(dst, src)
+	want := http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"v1"`}}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("bad header: got %v, want %v", dst, want)
+	}
+}
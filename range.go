@@ -0,0 +1,63 @@
+package ranger
+
+// Len returns the number of bytes in r, or 0 if r is reversed.
+func (r Range) Len() int64 {
+	return max(r.Stop-r.Start+1, 0)
+}
+
+// Contains reports whether offset falls within r.
+func (r Range) Contains(offset int64) bool {
+	return r.Start <= offset && offset <= r.Stop
+}
+
+// Overlaps reports whether r and c have any bytes in common.
+func (r Range) Overlaps(c Range) bool {
+	return r.Start <= c.Stop && c.Start <= r.Stop
+}
+
+// Intersect returns the bytes that r and c have in common. If they have none,
+// ok is false.
+func (r Range) Intersect(c Range) (Range, bool) {
+	if !r.Overlaps(c) {
+		return Range{}, false
+	}
+	return Range{Start: max(r.Start, c.Start), Stop: min(r.Stop, c.Stop)}, true
+}
+
+// Split splits r in two at offset at, which becomes the Start of the second
+// range. If at isn't after r.Start, or is past r.Stop, r can't be split,
+// and ok is false.
+func (r Range) Split(at int64) (before, after Range, ok bool) {
+	if at <= r.Start || at > r.Stop {
+		return Range{}, Range{}, false
+	}
+	return Range{Start: r.Start, Stop: at - 1}, Range{Start: at, Stop: r.Stop}, true
+}
+
+// Shift returns r moved by n bytes, which may be negative. It's useful for
+// translating ranges of a whole object into ranges of one of its parts, and
+// back.
+func (r Range) Shift(n int64) Range {
+	return Range{Start: r.Start + n, Stop: r.Stop + n}
+}
+
+// Clamp returns the part of r that falls within the bytes from lo up to, but
+// not including, hi; Clamp(0, size) clamps r to content of size bytes. If none
+// of r falls within them, ok is false.
+func (r Range) Clamp(lo, hi int64) (Range, bool) {
+	return r.Intersect(Range{Start: lo, Stop: hi - 1})
+}
+
+// ToHalfOpen returns r as a half-open interval, from start up to, but not
+// including, end, as Go's slice expressions take it: buf[start:end] holds the
+// bytes of r.
+func (r Range) ToHalfOpen() (start, end int64) {
+	return r.Start, r.Stop + 1
+}
+
+// FromHalfOpen returns the Range of the bytes from start up to, but not
+// including, end. If end isn't after start, the Range is reversed, and has a
+// Len of 0.
+func FromHalfOpen(start, end int64) Range {
+	return Range{Start: start, Stop: end - 1}
+}
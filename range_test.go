@@ -0,0 +1,129 @@
+package ranger
+
+import "testing"
+
+func TestRangeLen(t *testing.T) {
+	tests := map[Range]int64{
+		{Start: 0, Stop: 0}:  1,
+		{Start: 0, Stop: 99}: 100,
+		{Start: 9, Stop: 0}:  0,
+	}
+	for r, want := range tests {
+		if got := r.Len(); got != want {
+			t.Errorf("%+v: bad length: got %d, want %d", r, got, want)
+		}
+	}
+}
+
+func TestRangeContains(t *testing.T) {
+	r := Range{Start: 10, Stop: 19}
+	for off, want := range map[int64]bool{9: false, 10: true, 15: true, 19: true, 20: false} {
+		if got := r.Contains(off); got != want {
+			t.Errorf("offset %d: got %v, want %v", off, got, want)
+		}
+	}
+}
+
+type intersectTest struct {
+	A, B          Range
+	ExpectedRange Range
+	ExpectedOK    bool
+}
+
+func TestRangeIntersect(t *testing.T) {
+	tests := []intersectTest{
+		{ // partial overlap
+			A:             Range{Start: 0, Stop: 99},
+			B:             Range{Start: 50, Stop: 149},
+			ExpectedRange: Range{Start: 50, Stop: 99},
+			ExpectedOK:    true,
+		},
+		{ // nested
+			A:             Range{Start: 0, Stop: 99},
+			B:             Range{Start: 10, Stop: 19},
+			ExpectedRange: Range{Start: 10, Stop: 19},
+			ExpectedOK:    true,
+		},
+		{ // single byte in common
+			A:             Range{Start: 0, Stop: 10},
+			B:             Range{Start: 10, Stop: 19},
+			ExpectedRange: Range{Start: 10, Stop: 10},
+			ExpectedOK:    true,
+		},
+		{ // adjacent
+			A: Range{Start: 0, Stop: 9},
+			B: Range{Start: 10, Stop: 19},
+		},
+	}
+	for i, test := range tests {
+		for _, pair := range [][2]Range{{test.A, test.B}, {test.B, test.A}} {
+			r, ok := pair[0].Intersect(pair[1])
+			if got, want := r, test.ExpectedRange; got != want {
+				t.Errorf("test %d: bad range: got %+v, want %+v", i, got, want)
+			}
+			if got, want := ok, test.ExpectedOK; got != want {
+				t.Errorf("test %d: bad ok: got %v, want %v", i, got, want)
+			}
+			if got, want := pair[0].Overlaps(pair[1]), test.ExpectedOK; got != want {
+				t.Errorf("test %d: bad overlap: got %v, want %v", i, got, want)
+			}
+		}
+	}
+}
+
+func TestRangeSplit(t *testing.T) {
+	r := Range{Start: 10, Stop: 19}
+	before, after, ok := r.Split(15)
+	if !ok {
+		t.Fatal("can't split")
+	}
+	if got, want := before, (Range{Start: 10, Stop: 14}); got != want {
+		t.Errorf("bad first range: got %+v, want %+v", got, want)
+	}
+	if got, want := after, (Range{Start: 15, Stop: 19}); got != want {
+		t.Errorf("bad second range: got %+v, want %+v", got, want)
+	}
+	if _, _, ok := r.Split(19); !ok {
+		t.Error("can't split before the last byte")
+	}
+	for _, at := range []int64{9, 10, 20} {
+		if _, _, ok := r.Split(at); ok {
+			t.Errorf("split at %d", at)
+		}
+	}
+}
+
+func TestRangeShift(t *testing.T) {
+	r := Range{Start: 10, Stop: 19}
+	if got, want := r.Shift(-10), (Range{Start: 0, Stop: 9}); got != want {
+		t.Errorf("bad range: got %+v, want %+v", got, want)
+	}
+	if got, want := r.Shift(-10).Shift(10), r; got != want {
+		t.Errorf("bad range: got %+v, want %+v", got, want)
+	}
+}
+
+func TestRangeClamp(t *testing.T) {
+	r, ok := Range{Start: 90, Stop: 199}.Clamp(0, 100)
+	if got, want := r, (Range{Start: 90, Stop: 99}); !ok || got != want {
+		t.Errorf("bad range: got %+v, %v, want %+v, true", got, ok, want)
+	}
+	if _, ok := (Range{Start: 100, Stop: 199}).Clamp(0, 100); ok {
+		t.Error("range past the end clamped")
+	}
+}
+
+func TestRangeHalfOpen(t *testing.T) {
+	buf := []byte("0123456789")
+	r := Range{Start: 2, Stop: 4}
+	start, end := r.ToHalfOpen()
+	if got, want := string(buf[start:end]), "234"; got != want {
+		t.Errorf("bad slice: got %q, want %q", got, want)
+	}
+	if got := FromHalfOpen(start, end); got != r {
+		t.Errorf("bad round trip: got %+v, want %+v", got, r)
+	}
+	if got := FromHalfOpen(5, 5).Len(); got != 0 {
+		t.Errorf("bad length of empty interval: got %d, want 0", got)
+	}
+}
@@ -22,131 +22,596 @@ package ranger
 
 import (
 	"errors"
+	"fmt"
+	"mime"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 )
 
+// Error is returned for any range that can't be parsed or served. More
+// specific errors wrap it, so errors.Is(err, Error) reports any of them.
 var Error = errors.New("invalid range")
 
-// Range is simply a contiguous range.
+var (
+	// ErrMalformed is returned for ranges that aren't syntactically valid.
+	// Servers should ignore a malformed Range header, and serve all of the
+	// content.
+	ErrMalformed = fmt.Errorf("%w: malformed", Error)
+
+	// ErrUnsatisfiable is returned for ranges that are syntactically valid,
+	// but fall outside of the content. Servers should reply with a 416.
+	ErrUnsatisfiable = fmt.Errorf("%w: unsatisfiable", Error)
+
+	// ErrLimit is returned for ranges that exceed the limits set by
+	// ParseOptions. Servers may ignore the Range header, or reply with a 416.
+	ErrLimit = fmt.Errorf("%w: limit exceeded", Error)
+)
+
+// MaxRanges is the most ranges that will be parsed from a single request,
+// unless ParseOptions says otherwise. Asking for more than that is treated as
+// an attack, and ErrLimit is returned.
+const MaxRanges = 1000
+
+// Range is simply a contiguous range. Both ends are inclusive, as they are in
+// a Range header, so '0-99' is Range{Start: 0, Stop: 99}, 100 bytes; ranges
+// resolved from suffix and open-ended forms are no different. To slice a Go
+// buffer, which takes a half-open interval, use buf[r.Start:r.Stop+1], or
+// convert with ToHalfOpen.
 type Range struct {
-	Start int
-	Stop  int
+	Start int64
+	Stop  int64
 }
 
-func (b Range) overlaps(c Range) bool {
-	return b.Start <= c.Stop && c.Start <= b.Stop
+// validate checks the invariant that r.Start <= r.Stop, for a range already
+// resolved against the content. It's only a backstop: reversed specs are
+// reported as ErrMalformed when they're parsed, so a range reversed here is
+// one that falls outside of the content, such as 'bytes=100-' of 100 bytes,
+// and ErrUnsatisfiable is returned. A negative Start, which can only come
+// from a suffix range longer than the content, is normalized to 0.
+func (r Range) validate() (Range, error) {
+	if r.Start < 0 {
+		r.Start = 0
+	}
+	if r.Start > r.Stop {
+		return Range{}, ErrUnsatisfiable
+	}
+	return r, nil
 }
 
 // valid iff b <= c
 func (b Range) merge(c Range) Range {
-	return Range{Start: b.Start, Stop: c.Stop}
+	return Range{Start: b.Start, Stop: max(b.Stop, c.Stop)}
 }
 
-type rangeSlice []Range
-
-func (b rangeSlice) Len() int {
-	return len(b)
+// ParseHeader parses an http.Header. It assumes that the range starts with
+// 'bytes='. For other types of ranges, use Parse.
+//
+// The header must contain a valid Range field. Otherwise, ErrMalformed or
+// ErrUnsatisfiable will be returned; see Parse.
+//
+// Deprecated: contentLength is the size of the whole representation, which is
+// only the Content-Length of a response that carries all of it. Use
+// ParseHeaderSize, which says so, or ParseResponse.
+func ParseHeader(h http.Header, contentLength int64) ([]Range, error) {
+	return ParseHeaderSize(h, contentLength)
 }
 
-func (b rangeSlice) Swap(i, j int) {
-	b[i], b[j] = b[j], b[i]
+// ParseHeaderSize parses the Range field of an http.Header, which must start
+// with 'bytes=', against the size of the whole representation being ranged
+// over. Several Range fields are combined, as if they were one; for another
+// policy, use ParseOptions.ParseHeader with Fields. Errors are as for Parse.
+func ParseHeaderSize(h http.Header, size int64) ([]Range, error) {
+	return Parse(h["Range"], "bytes=", size)
 }
 
-func (b rangeSlice) Less(i, j int) bool {
-	if b[i].Start < b[j].Start {
-		return true
+// ParseResponse parses the Range field of the request that resp answers,
+// against the size of the whole representation, as resp gives it: from its
+// Content-Range field if it has one, as a 206 or 416 does, or else from its
+// Content-Length. A client can compare the result with what it was sent.
+//
+// If resp has no request, or doesn't give the size, Error is returned.
+// Otherwise, errors are as for Parse.
+func ParseResponse(resp *http.Response) ([]Range, error) {
+	if resp.Request == nil {
+		return nil, fmt.Errorf("%w: response has no request", Error)
 	}
-	if b[i].Start == b[j].Start {
-		return b[i].Stop < b[j].Stop
+	size := resp.ContentLength
+	if v := resp.Header.Get("Content-Range"); v != "" {
+		cr, err := ParseContentRangeValue(v)
+		if err != nil {
+			return nil, err
+		}
+		size = cr.Length
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("%w: response doesn't give the size", Error)
 	}
-	return false
+	return ParseHeaderSize(resp.Request.Header, size)
 }
 
-// ParseHeader parses an http.Header. It assumes that the range starts with
-// 'bytes='. For other types of ranges, use Parse.
+// ParseResponseRanges returns the ranges that resp serves, and the complete
+// length of the representation, so that a client needn't piece them together
+// from the header fields itself. A 206 serves the range in its Content-Range,
+// which gives the length, unless it's the 'N-M/*' form. A 200 serves all of
+// the representation, whose length is its Content-Length, and a 416 serves
+// none of it, giving the length in the 'bytes */N' form of Content-Range. If
+// the length isn't known, it's -1; for a 200, so are the ranges.
 //
-// The header must contain a valid Range field. Otherwise, Error will be
+// A 206 with a multipart/byteranges body only gives its ranges in the body;
+// use NewMultipartResponseReader to read them, and the parts along with them.
+// Any other status gets a *StatusError.
+func ParseResponseRanges(resp *http.Response) (ranges []Range, size int64, err error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if resp.ContentLength <= 0 {
+			return nil, resp.ContentLength, nil
+		}
+		return []Range{{Start: 0, Stop: resp.ContentLength - 1}}, resp.ContentLength, nil
+	case http.StatusPartialContent:
+		v := resp.Header.Get("Content-Range")
+		if v == "" {
+			if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "multipart/byteranges" {
+				return nil, -1, fmt.Errorf("%w: multipart response gives its ranges in the body", Error)
+			}
+			return nil, -1, fmt.Errorf("%w: partial response has no Content-Range", ErrContentRange)
+		}
+		cr, err := ParseContentRangeValue(v)
+		if err != nil {
+			return nil, -1, err
+		}
+		if cr.Unsatisfied {
+			return nil, -1, fmt.Errorf("%w: partial response is unsatisfied: %q", ErrContentRange, v)
+		}
+		return []Range{cr.Range}, cr.Length, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		cr, err := ParseContentRangeValue(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, -1, err
+		}
+		return nil, cr.Length, nil
+	}
+	var url string
+	if resp.Request != nil {
+		url = resp.Request.URL.String()
+	}
+	return nil, -1, &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+}
+
+// ParseRequest parses the Range header of an inbound request, against the size
+// of the representation being requested. This is what a server handler needs:
+// note that the request's own Content-Length field is the size of its body, not
+// of the representation, so it must not be used here. Errors are as for Parse.
+func ParseRequest(r *http.Request, size int64) ([]Range, error) {
+	return ParseHeaderSize(r.Header, size)
+}
+
+// ParseHeaderUnit parses an http.Header like ParseHeader, but accepts any range
+// unit, such as 'items=' or 'blob-sha256=', and returns it to the caller along
+// with the ranges. The unit must be a valid RFC 7230 token, and every Range
+// field in the header must use the same unit. Otherwise, ErrMalformed is
 // returned.
-func ParseHeader(h http.Header, contentLength int) ([]Range, error) {
-	return Parse(h["Range"], "bytes=", contentLength)
+func ParseHeaderUnit(h http.Header, contentLength int64) (string, []Range, error) {
+	return ParseUnit(h["Range"], contentLength)
+}
+
+// ParseUnit is like Parse, but rather than stripping a prefix given by the
+// caller, it accepts any range unit, such as 'items=' for an API that pages
+// with Range headers, and returns the unit along with the ranges. The unit must
+// be a valid RFC 7230 token, and every value must use the same unit.
+// Otherwise, ErrMalformed is returned.
+func ParseUnit(values []string, contentLength int64) (string, []Range, error) {
+	unit := ""
+	for i, v := range values {
+		j := strings.IndexByte(v, '=')
+		if j < 0 || !isToken(v[:j]) {
+			return "", nil, ErrMalformed
+		}
+		if i > 0 && v[:j] != unit {
+			return "", nil, ErrMalformed
+		}
+		unit = v[:j]
+	}
+	ranges, err := Parse(values, unit+"=", contentLength)
+	if err != nil {
+		return "", nil, err
+	}
+	return unit, ranges, nil
+}
+
+// isToken reports whether s is a token, as defined by RFC 7230.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 // Parse parses an RFC2616 HTTP range. It accepts a slice of strings, each
 // beginning with prefix and delimited with ','. contentLen is the size of the
 // content being ranged over.
 //
-// Parse merges overlapping ranges together. The returned []Range will be
-// sorted such that a.Start =< b.Start.
+// Parse merges overlapping and adjacent ranges together, as Merge does, so
+// 'bytes=0-49,50-99' parses as the single range 0-99. The returned []Range
+// will be sorted such that a.Start =< b.Start.
+//
+// Spaces and tabs around each range are ignored, as RFC 7233 allows, so that
+// 'bytes=0-99, 200-299' parses. To reject them, use ParseOptions with Strict.
 //
-// If contentLen is < 0, then Error is returned. If any of the the ranges fall
-// outside of 0 or contentLen, Error is returned.
-func Parse(ranges []string, prefix string, contentLen int) ([]Range, error) {
-	result := make([]Range, 0, len(ranges))
+// If any of the ranges are malformed or reversed, ErrMalformed is returned. If
+// there are more than MaxRanges of them, ErrLimit is returned. If any of them
+// fall outside of 0 or contentLen, or contentLen is < 0, ErrUnsatisfiable is
+// returned. A suffix range longer than the content covers all of it. Errors
+// for a particular range are wrapped in a *SpecError, which says which one it
+// was.
+func Parse(ranges []string, prefix string, contentLen int64) ([]Range, error) {
+	if len(ranges) == 1 && strings.IndexByte(ranges[0], ',') < 0 {
+		// Fast path: most requests only ask for a single range, which needs
+		// no merging.
+		r, err := parseRange(trimOWS(strings.TrimPrefix(ranges[0], prefix)), 0, contentLen)
+		if err != nil {
+			return nil, err
+		}
+		return []Range{r}, nil
+	}
+	return ParseOptions{}.Parse(ranges, prefix, contentLen)
+}
+
+// ParseOptions sets limits and policies for parsing ranges, so that servers
+// and proxies can defend against requests for many small or overlapping
+// ranges. The zero value parses exactly like Parse.
+type ParseOptions struct {
+	// MaxRanges is the most ranges to accept. If it's zero, the MaxRanges
+	// constant is used.
+	MaxRanges int
+
+	// MaxBytes is the most bytes the ranges may add up to, before they're
+	// merged, so overlapping bytes count once for each range that asks for
+	// them. If it's zero, there's no limit.
+	MaxBytes int64
+
+	// RejectOverlap refuses any ranges that overlap each other.
+	RejectOverlap bool
+
+	// NoMerge preserves the ranges as they were requested, in the order they
+	// were requested, rather than sorting and merging them.
+	NoMerge bool
+
+	// Clamp clamps ranges that extend past the end of the content, as RFC
+	// 7233 requires, and drops ranges that start past the end, as ParseClamp
+	// does. ErrUnsatisfiable is only returned if every range was dropped.
+	Clamp bool
+
+	// MaxRangeBytes, if it's set, truncates each range to at most that many
+	// bytes from its start, as many media servers do, so that a request such
+	// as 'bytes=0-' can't tie up a connection with all of a large object.
+	// The Content-Range of the response tells the client how much more there
+	// is, so that it can ask for the rest. The ranges are truncated before
+	// they're counted against MaxBytes.
+	MaxRangeBytes int64
+
+	// Fields says what to do with a request that has more than one Range
+	// field. By default, they're combined, as if they were one.
+	Fields FieldPolicy
+
+	// Strict refuses whitespace around the ranges with ErrMalformed. RFC
+	// 7233 allows it, but clients that send it are rare, and some servers
+	// would rather not accept it.
+	Strict bool
+}
+
+// Parse parses ranges like the package-level Parse function, subject to the
+// limits and policies in o. If the ranges exceed the limits, or are refused
+// by policy, ErrLimit is returned.
+func (o ParseOptions) Parse(ranges []string, prefix string, contentLen int64) ([]Range, error) {
+	return o.parse(nil, ranges, prefix, contentLen, nil)
+}
+
+// ParseHeader parses the Range field of an http.Header like ParseHeaderSize,
+// subject to the limits and policies in o.
+func (o ParseOptions) ParseHeader(h http.Header, size int64) ([]Range, error) {
+	return o.Parse(h["Range"], "bytes=", size)
+}
+
+// ParseRequest parses the Range header of an inbound request like the
+// package-level ParseRequest function, subject to the limits and policies in
+// o.
+func (o ParseOptions) ParseRequest(r *http.Request, size int64) ([]Range, error) {
+	return o.ParseHeader(r.Header, size)
+}
+
+// FieldPolicy says what ParseOptions does with a request that has more than
+// one Range field. RFC 7230 allows a field to be repeated only if its value is
+// a list, which a Range isn't, so many servers refuse such requests.
+type FieldPolicy int
+
+const (
+	// CombineFields parses the fields as if they were one, joined by commas.
+	CombineFields FieldPolicy = iota
+
+	// FirstField parses the first field, and ignores the rest.
+	FirstField
+
+	// RejectFields refuses the request with ErrMalformed.
+	RejectFields
+)
+
+func (p FieldPolicy) String() string {
+	switch p {
+	case CombineFields:
+		return "combine"
+	case FirstField:
+		return "first"
+	case RejectFields:
+		return "reject"
+	}
+	return "FieldPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// parse is Parse, but puts the ranges in dst's space, if it has any. If specs
+// isn't nil, each range that's kept is appended to it, as it was written.
+func (o ParseOptions) parse(dst []Range, ranges []string, prefix string, contentLen int64, specs *[]ParsedSpec) ([]Range, error) {
+	if len(ranges) > 1 {
+		switch o.Fields {
+		case FirstField:
+			ranges = ranges[:1]
+		case RejectFields:
+			return nil, fmt.Errorf("%w: %d Range fields", ErrMalformed, len(ranges))
+		}
+	}
+	maxRanges := o.MaxRanges
+	if maxRanges <= 0 {
+		maxRanges = MaxRanges
+	}
+	result := dst[:0]
+	if result == nil {
+		result = make([]Range, 0, len(ranges))
+	}
+	requested := 0
+	total := int64(0)
+	for _, value := range ranges {
+		// Cut rather than Split, so that a Parser allocates nothing.
+		for rest, more := strings.TrimPrefix(value, prefix), true; more; {
+			var r string
+			r, rest, more = strings.Cut(rest, ",")
+			if requested == maxRanges {
+				return nil, ErrLimit
+			}
+			if !o.Strict {
+				r = trimOWS(r)
+			}
+			rng, ok, err := o.parseRange(r, requested, contentLen)
+			requested++
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			if o.MaxRangeBytes > 0 && rng.Len() > o.MaxRangeBytes {
+				rng.Stop = rng.Start + o.MaxRangeBytes - 1
+			}
+			if specs != nil {
+				spec, _ := parseSpec(r)
+				*specs = append(*specs, ParsedSpec{Raw: r, Index: requested - 1, Spec: spec, Range: rng})
+			}
+			total += rng.Stop - rng.Start + 1
+			if o.MaxBytes > 0 && total > o.MaxBytes {
+				return nil, ErrLimit
+			}
+			result = append(result, rng)
+		}
+	}
+	if requested > 0 && len(result) == 0 {
+		return nil, ErrUnsatisfiable
+	}
+	if o.RejectOverlap && HasOverlap(result) {
+		return nil, ErrLimit
+	}
+	if o.NoMerge {
+		return result, nil
+	}
+	return mergeRanges(result), nil
+}
+
+// parseRange parses the index'th range of a header, with no prefix. If o.Clamp
+// is set, and the range was dropped, ok is false.
+func (o ParseOptions) parseRange(r string, index int, contentLen int64) (rng Range, ok bool, err error) {
+	if !o.Clamp {
+		rng, err := parseRange(r, index, contentLen)
+		return rng, err == nil, err
+	}
+	spec, err := specAt(r, index)
+	if err != nil {
+		return Range{}, false, err
+	}
+	rng, _, ok = spec.clamp(contentLen)
+	return rng, ok, nil
+}
+
+// ParseOrdered parses ranges like Parse, but preserves them as they were
+// requested, in the order they were requested, for clients that expect their
+// parts back in that order. Rather than being merged, overlapping ranges are
+// reported as pairs, as by FindOverlaps, so that the caller can decide what to
+// do with them.
+func ParseOrdered(ranges []string, prefix string, contentLen int64) ([]Range, [][2]Range, error) {
+	result, err := ParseOptions{NoMerge: true}.Parse(ranges, prefix, contentLen)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, FindOverlaps(result), nil
+}
+
+// ParseOne parses a single range, such as '0-99', '-100' or '100-', with an
+// optional 'bytes=' prefix. contentLen is the size of the content being ranged
+// over.
+//
+// If s contains more than one range, ErrMalformed is returned. Otherwise,
+// errors are as for Parse.
+func ParseOne(s string, contentLen int64) (Range, error) {
+	s = strings.TrimPrefix(s, "bytes=")
+	if strings.IndexByte(s, ',') >= 0 {
+		return Range{}, ErrMalformed
+	}
+	return parseRange(trimOWS(s), 0, contentLen)
+}
+
+// ParseSingle parses the value of a Range header that asks for a single byte
+// range, such as the 'bytes=N-' that media players send, without allocating.
+// size is the size of the content being ranged over.
+//
+// ok is false if header isn't a single byte range, such as if it has several
+// ranges, or another unit; the caller should fall back to Parse. Otherwise,
+// errors are as for Parse.
+func ParseSingle(header string, size int64) (r Range, ok bool, err error) {
+	s, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.IndexByte(s, ',') >= 0 {
+		return Range{}, false, nil
+	}
+	r, err = parseRange(trimOWS(s), 0, size)
+	return r, true, err
+}
+
+// parseSingleClamp is ParseSingle, but clamps a range that runs past the end
+// of the content, as ParseClamp does.
+func parseSingleClamp(header string, size int64) (r Range, ok bool, err error) {
+	s, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.IndexByte(s, ',') >= 0 {
+		return Range{}, false, nil
+	}
+	s = trimOWS(s)
+	spec, err := specAt(s, 0)
+	if err != nil {
+		return Range{}, true, err
+	}
+	if r, _, ok = spec.clamp(size); !ok {
+		return Range{}, true, &SpecError{Spec: s, Index: 0, Reason: reasonOutOfRange, Err: ErrUnsatisfiable}
+	}
+	return r, true, nil
+}
+
+// ParseLenient parses ranges like Parse, but never fails. Ranges that are
+// malformed, or fall entirely outside of the content, are dropped, and ranges
+// that extend past the end of the content are clamped to fit, as RFC 7233
+// allows. Ranges beyond the first MaxRanges are dropped too. Empty list
+// elements and the whitespace around them are skipped.
+//
+// dropped reports whether any ranges were dropped. Servers that would rather
+// ignore such a Range header, and reply with all of the content, can check it.
+func ParseLenient(ranges []string, prefix string, contentLen int64) (result []Range, dropped bool) {
+	requested := 0
+	for _, value := range ranges {
+		for rest, more := strings.TrimPrefix(value, prefix), true; more; {
+			var r string
+			r, rest, more = strings.Cut(rest, ",")
+			if r = trimOWS(r); r == "" {
+				continue
+			}
+			if requested == MaxRanges {
+				return mergeRanges(result), true
+			}
+			requested++
+			spec, err := ParseSpec(r)
+			if err != nil {
+				dropped = true
+				continue
+			}
+			rng, _, ok := spec.clamp(contentLen)
+			if !ok {
+				dropped = true
+				continue
+			}
+			result = append(result, rng)
+		}
+	}
+	return mergeRanges(result), dropped
+}
+
+// ParseResult is the result of parsing ranges with ParseClampResult.
+type ParseResult struct {
+	// Ranges are the ranges to serve, merged and sorted as by Parse.
+	Ranges []Range
+
+	// Clamped are the ranges that extended past the end of the content, as
+	// they were requested, in the order they were requested. A suffix range is
+	// expressed relative to the end of the content, so its Start may be
+	// negative.
+	Clamped []Range
+
+	// Dropped are the ranges that could not be satisfied at all, as they were
+	// requested, in the order they were requested.
+	Dropped []Range
+}
+
+// ParseClamp is like Parse, but rather than returning ErrUnsatisfiable for
+// ranges that extend past the end of the content, it clamps them to fit, as
+// RFC 7233 requires. Ranges that start past the end of the content are
+// dropped. ErrUnsatisfiable is only returned if every one of them was dropped.
+func ParseClamp(ranges []string, prefix string, contentLen int64) ([]Range, error) {
+	result, err := ParseClampResult(ranges, prefix, contentLen)
+	if err != nil {
+		return nil, err
+	}
+	return result.Ranges, nil
+}
+
+// ParseClampResult is like ParseClamp, but also reports which of the ranges
+// were clamped or dropped. If every range was dropped, the result is returned
+// along with ErrUnsatisfiable.
+func ParseClampResult(ranges []string, prefix string, contentLen int64) (ParseResult, error) {
+	var result ParseResult
+	requested := 0
 	for _, r := range ranges {
 		r = strings.TrimPrefix(r, prefix)
 		ranges := strings.Split(r, ",")
 		for _, r := range ranges {
-			parts := strings.Split(r, "-")
-			if len(parts) != 2 {
-				return nil, Error
+			if requested == MaxRanges {
+				return ParseResult{}, ErrLimit
+			}
+			spec, err := specAt(trimOWS(r), requested)
+			if err != nil {
+				return ParseResult{}, err
 			}
-			if parts[0] == "" {
-				y, err := strconv.Atoi(parts[1])
-				if err != nil {
-					return nil, err
-				}
-				if y < 0 || y > contentLen {
-					return nil, Error
-				}
-				result = append(result, Range{Start: contentLen - y, Stop: contentLen - 1})
-			} else if parts[1] == "" {
-				x, err := strconv.Atoi(parts[0])
-				if err != nil {
-					return nil, err
-				}
-				if x < 0 || x >= contentLen {
-					return nil, Error
-				}
-				result = append(result, Range{Start: x, Stop: contentLen - 1})
-			} else {
-				x, err := strconv.Atoi(parts[0])
-				if err != nil {
-					return nil, err
-				}
-				y, err := strconv.Atoi(parts[1])
-				if err != nil {
-					return nil, err
-				}
-				if x < 0 || y < 0 || x >= contentLen || y >= contentLen || x > y {
-					return nil, Error
-				}
-				result = append(result, Range{Start: x, Stop: y})
+			requested++
+			rng, orig, ok := spec.clamp(contentLen)
+			switch {
+			case !ok:
+				result.Dropped = append(result.Dropped, orig)
+			case rng != orig:
+				result.Clamped = append(result.Clamped, orig)
+				fallthrough
+			default:
+				result.Ranges = append(result.Ranges, rng)
 			}
 		}
 	}
-	result = mergeRanges(result)
+	result.Ranges = mergeRanges(result.Ranges)
+	if requested > 0 && len(result.Ranges) == 0 {
+		return result, ErrUnsatisfiable
+	}
 	return result, nil
 }
 
-func mergeRanges(br []Range) []Range {
-	if len(br) < 2 {
-		return br
-	}
-	sort.Sort(rangeSlice(br))
-	result := make([]Range, 0, len(br))
-	cur := br[0]
-	for i := 1; i < len(br); i++ {
-		b := br[i]
-		if cur.overlaps(b) {
-			cur = cur.merge(b)
-		} else {
-			result = append(result, cur)
-			cur = b
-		}
+// parseRange parses the index'th range of a header, with no prefix.
+func parseRange(r string, index int, contentLen int64) (Range, error) {
+	spec, err := specAt(r, index)
+	if err != nil {
+		return Range{}, err
 	}
-	result = append(result, cur)
-	return result
+	return resolveAt(spec, r, index, contentLen)
+}
+
+// mergeRanges merges ranges like Merge, but in place, for callers that own
+// the slice and have no further use for it as it was.
+func mergeRanges(br []Range) []Range {
+	return coalesceSpans(br, 0)
 }
@@ -1,16 +1,19 @@
 package ranger
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 )
 
 type parseTest struct {
 	Ranges         []string
 	Prefix         string
-	ContentLength  int
+	ContentLength  int64
 	ExpectedRanges []Range
 	ExpectedError  string
 }
@@ -31,6 +34,28 @@ func TestParse(t *testing.T) {
 			},
 			ExpectedError: "<nil>",
 		},
+		{ // nested ranges don't shrink the range they're nested in
+			Ranges: []string{
+				"bytes=0-99,10-20",
+			},
+			Prefix:        "bytes=",
+			ContentLength: 350,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 99},
+			},
+			ExpectedError: "<nil>",
+		},
+		{ // adjacent ranges are merged
+			Ranges: []string{
+				"bytes=50-99,0-49",
+			},
+			Prefix:        "bytes=",
+			ContentLength: 350,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 99},
+			},
+			ExpectedError: "<nil>",
+		},
 		{ // ranges falling outside of maxLen return an error
 			Ranges: []string{
 				"bytes=0-99",
@@ -41,7 +66,47 @@ func TestParse(t *testing.T) {
 			Prefix:         "bytes=",
 			ContentLength:  200,
 			ExpectedRanges: nil,
-			ExpectedError:  "invalid range",
+			ExpectedError:  `invalid range: unsatisfiable: range 2 "200-300": outside the content`,
+		},
+		{ // the whole content from the start
+			Ranges: []string{
+				"bytes=0-",
+			},
+			Prefix:        "bytes=",
+			ContentLength: 200,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 199},
+			},
+			ExpectedError: "<nil>",
+		},
+		{ // reversed ranges return an error
+			Ranges: []string{
+				"bytes=5-3",
+			},
+			Prefix:         "bytes=",
+			ContentLength:  200,
+			ExpectedRanges: nil,
+			ExpectedError:  `invalid range: malformed: range 0 "5-3": first byte after last`,
+		},
+		{ // suffix ranges longer than the content cover all of it
+			Ranges: []string{
+				"bytes=-500",
+			},
+			Prefix:        "bytes=",
+			ContentLength: 200,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 199},
+			},
+			ExpectedError: "<nil>",
+		},
+		{ // empty suffix ranges return an error
+			Ranges: []string{
+				"bytes=0-9,-0",
+			},
+			Prefix:         "bytes=",
+			ContentLength:  200,
+			ExpectedRanges: nil,
+			ExpectedError:  `invalid range: unsatisfiable: range 1 "-0": outside the content`,
 		},
 		{ // Wrong prefix
 			Ranges: []string{
@@ -50,12 +115,81 @@ func TestParse(t *testing.T) {
 			Prefix:         "bytes=",
 			ContentLength:  200,
 			ExpectedRanges: nil,
-			ExpectedError:  `strconv.Atoi: parsing "foo=0": invalid syntax`,
+			ExpectedError:  `invalid range: malformed: range 0 "foo=0-100": bad number`,
+		},
+		{ // signed positions
+			Ranges: []string{
+				"bytes=+5-10",
+			},
+			Prefix:         "bytes=",
+			ContentLength:  200,
+			ExpectedRanges: nil,
+			ExpectedError:  `invalid range: malformed: range 0 "+5-10": signed number`,
+		},
+		{ // leading zeros
+			Ranges: []string{
+				"bytes=05-10",
+			},
+			Prefix:         "bytes=",
+			ContentLength:  200,
+			ExpectedRanges: nil,
+			ExpectedError:  `invalid range: malformed: range 0 "05-10": bad number`,
+		},
+		{ // hexadecimal positions
+			Ranges: []string{
+				"bytes=5-0x10",
+			},
+			Prefix:         "bytes=",
+			ContentLength:  200,
+			ExpectedRanges: nil,
+			ExpectedError:  `invalid range: malformed: range 0 "5-0x10": bad number`,
+		},
+		{ // past the largest int64
+			Ranges: []string{
+				"bytes=0-9223372036854775808",
+			},
+			Prefix:         "bytes=",
+			ContentLength:  200,
+			ExpectedRanges: nil,
+			ExpectedError:  `invalid range: malformed: range 0 "0-9223372036854775808": number too large`,
+		},
+		{ // absurdly long positions
+			Ranges: []string{
+				"bytes=-" + strings.Repeat("9", 1000),
+			},
+			Prefix:         "bytes=",
+			ContentLength:  200,
+			ExpectedRanges: nil,
+			ExpectedError:  `invalid range: malformed: range 0 "-` + strings.Repeat("9", 1000) + `": number too large`,
+		},
+		{ // a bare zero is fine
+			Ranges: []string{
+				"bytes=0-0",
+			},
+			Prefix:        "bytes=",
+			ContentLength: 200,
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 0},
+			},
+			ExpectedError: "<nil>",
 		},
 		{ // Empty
 			ExpectedError:  "<nil>",
 			ExpectedRanges: []Range{},
 		},
+		{ // content larger than 4GB
+			Ranges: []string{
+				"bytes=4294967296-4294967395,-100",
+				"bytes=6442450944-",
+			},
+			Prefix:        "bytes=",
+			ContentLength: 10 << 30,
+			ExpectedRanges: []Range{
+				{Start: 4294967296, Stop: 4294967395},
+				{Start: 6442450944, Stop: 10737418239},
+			},
+			ExpectedError: "<nil>",
+		},
 	}
 
 	for i, test := range tests {
@@ -71,7 +205,7 @@ func TestParse(t *testing.T) {
 
 type headerTest struct {
 	Header         http.Header
-	Length         int
+	Length         int64
 	ExpectedRanges []Range
 	ExpectedError  string
 }
@@ -99,3 +233,790 @@ func TestParseHeader(t *testing.T) {
 		}
 	}
 }
+
+type headerUnitTest struct {
+	Header         http.Header
+	Length         int64
+	ExpectedUnit   string
+	ExpectedRanges []Range
+	ExpectedError  string
+}
+
+func TestParseRequest(t *testing.T) {
+	r, err := http.NewRequest("PUT", "/", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Range", "bytes=10-19")
+	ranges, err := ParseRequest(r, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ranges, []Range{{Start: 10, Stop: 19}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+	if _, err := ParseRequest(r, 15); !errors.Is(err, ErrUnsatisfiable) {
+		t.Errorf("bad error: got %v, want %v", err, ErrUnsatisfiable)
+	}
+}
+
+func TestParseHeaderUnit(t *testing.T) {
+	tests := []headerUnitTest{
+		{ // bytes
+			Header: http.Header{
+				"Range": {"bytes=0-99"},
+			},
+			Length:       300,
+			ExpectedUnit: "bytes",
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 99},
+			},
+			ExpectedError: "<nil>",
+		},
+		{ // complex unit token
+			Header: http.Header{
+				"Range": {"blob-sha256=0-99", "blob-sha256=200-"},
+			},
+			Length:       300,
+			ExpectedUnit: "blob-sha256",
+			ExpectedRanges: []Range{
+				{Start: 0, Stop: 99},
+				{Start: 200, Stop: 299},
+			},
+			ExpectedError: "<nil>",
+		},
+		{ // mismatched units
+			Header: http.Header{
+				"Range": {"bytes=0-99", "items=0-9"},
+			},
+			Length:        300,
+			ExpectedError: "invalid range: malformed",
+		},
+		{ // missing unit
+			Header: http.Header{
+				"Range": {"0-99"},
+			},
+			Length:        300,
+			ExpectedError: "invalid range: malformed",
+		},
+		{ // unit is not a token
+			Header: http.Header{
+				"Range": {"by tes=0-99"},
+			},
+			Length:        300,
+			ExpectedError: "invalid range: malformed",
+		},
+	}
+	for i, test := range tests {
+		unit, ranges, err := ParseHeaderUnit(test.Header, test.Length)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := unit, test.ExpectedUnit; got != want {
+			t.Errorf("test %d: bad unit: got %q, want %q", i, got, want)
+		}
+		if got, want := ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type parseSingleTest struct {
+	Header        string
+	ExpectedRange Range
+	ExpectedOK    bool
+	ExpectedError string
+}
+
+func TestParseSingle(t *testing.T) {
+	tests := []parseSingleTest{
+		{ // open-ended range
+			Header:        "bytes=100-",
+			ExpectedRange: Range{Start: 100, Stop: 199},
+			ExpectedOK:    true,
+			ExpectedError: "<nil>",
+		},
+		{ // suffix range with whitespace
+			Header:        "bytes= -50",
+			ExpectedRange: Range{Start: 150, Stop: 199},
+			ExpectedOK:    true,
+			ExpectedError: "<nil>",
+		},
+		{ // several ranges
+			Header:        "bytes=0-9,20-29",
+			ExpectedError: "<nil>",
+		},
+		{ // another unit
+			Header:        "items=0-9",
+			ExpectedError: "<nil>",
+		},
+		{ // malformed
+			Header:        "bytes=9-0",
+			ExpectedOK:    true,
+			ExpectedError: `invalid range: malformed: range 0 "9-0": first byte after last`,
+		},
+		{ // out of bounds
+			Header:        "bytes=200-",
+			ExpectedOK:    true,
+			ExpectedError: `invalid range: unsatisfiable: range 0 "200-": outside the content`,
+		},
+	}
+	for i, test := range tests {
+		r, ok, err := ParseSingle(test.Header, 200)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := ok, test.ExpectedOK; got != want {
+			t.Errorf("test %d: bad ok: got %v, want %v", i, got, want)
+		}
+		if got, want := r, test.ExpectedRange; got != want {
+			t.Errorf("test %d: bad range: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func BenchmarkParseSingleOpenEnded(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ParseSingle("bytes=100-", 1000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type parseOneTest struct {
+	Range         string
+	ContentLength int64
+	ExpectedRange Range
+	ExpectedError string
+}
+
+func TestParseUnit(t *testing.T) {
+	unit, ranges, err := ParseUnit([]string{"items=0-49", "items=100-149"}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := unit, "items"; got != want {
+		t.Errorf("bad unit: got %q, want %q", got, want)
+	}
+	if got, want := ranges, []Range{{Start: 0, Stop: 49}, {Start: 100, Stop: 149}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+	for _, values := range [][]string{{"it ems=0-49"}, {"=0-49"}, {"0-49"}, {"items=0-49", "seconds=0-9"}} {
+		if _, _, err := ParseUnit(values, 1000); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%q: bad error: got %v, want %v", values, err, ErrMalformed)
+		}
+	}
+}
+
+func TestParseOne(t *testing.T) {
+	tests := []parseOneTest{
+		{ // closed range
+			Range:         "0-99",
+			ContentLength: 200,
+			ExpectedRange: Range{Start: 0, Stop: 99},
+			ExpectedError: "<nil>",
+		},
+		{ // suffix range with prefix
+			Range:         "bytes=-50",
+			ContentLength: 200,
+			ExpectedRange: Range{Start: 150, Stop: 199},
+			ExpectedError: "<nil>",
+		},
+		{ // open-ended range
+			Range:         "100-",
+			ContentLength: 200,
+			ExpectedRange: Range{Start: 100, Stop: 199},
+			ExpectedError: "<nil>",
+		},
+		{ // more than one range
+			Range:         "bytes=0-9,20-29",
+			ContentLength: 200,
+			ExpectedError: "invalid range: malformed",
+		},
+		{ // out of bounds
+			Range:         "bytes=200-",
+			ContentLength: 200,
+			ExpectedError: `invalid range: unsatisfiable: range 0 "200-": outside the content`,
+		},
+	}
+	for i, test := range tests {
+		r, err := ParseOne(test.Range, test.ContentLength)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := r, test.ExpectedRange; got != want {
+			t.Errorf("test %d: bad range: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type parseClampTest struct {
+	Ranges         []string
+	ContentLength  int64
+	ExpectedResult ParseResult
+	ExpectedError  string
+}
+
+func TestParseClampResult(t *testing.T) {
+	tests := []parseClampTest{
+		{ // in-bounds, clamped and out-of-bounds ranges
+			Ranges:        []string{"bytes=0-9,90-199", "bytes=150-160,-500"},
+			ContentLength: 100,
+			ExpectedResult: ParseResult{
+				Ranges:  []Range{{Start: 0, Stop: 99}},
+				Clamped: []Range{{Start: 90, Stop: 199}, {Start: -400, Stop: 99}},
+				Dropped: []Range{{Start: 150, Stop: 160}},
+			},
+			ExpectedError: "<nil>",
+		},
+		{ // in-bounds only
+			Ranges:        []string{"bytes=0-9,20-"},
+			ContentLength: 100,
+			ExpectedResult: ParseResult{
+				Ranges: []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 99}},
+			},
+			ExpectedError: "<nil>",
+		},
+		{ // everything dropped
+			Ranges:        []string{"bytes=100-,200-299,-0"},
+			ContentLength: 100,
+			ExpectedResult: ParseResult{
+				Dropped: []Range{{Start: 100, Stop: 99}, {Start: 200, Stop: 299}, {Start: 100, Stop: 99}},
+			},
+			ExpectedError: "invalid range: unsatisfiable",
+		},
+		{ // malformed
+			Ranges:         []string{"bytes=0-9,5-3"},
+			ContentLength:  100,
+			ExpectedResult: ParseResult{},
+			ExpectedError:  `invalid range: malformed: range 1 "5-3": first byte after last`,
+		},
+	}
+	for i, test := range tests {
+		result, err := ParseClampResult(test.Ranges, "bytes=", test.ContentLength)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := result, test.ExpectedResult; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad result: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestParseClamp(t *testing.T) {
+	ranges, err := ParseClamp([]string{"bytes=0-99999"}, "bytes=", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ranges, []Range{{Start: 0, Stop: 99}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseOrdered(t *testing.T) {
+	ranges, overlaps, err := ParseOrdered([]string{"bytes=50-99,0-9", "bytes=0-59"}, "bytes=", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ranges, []Range{{Start: 50, Stop: 99}, {Start: 0, Stop: 9}, {Start: 0, Stop: 59}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+	wantOverlaps := [][2]Range{
+		{{Start: 0, Stop: 9}, {Start: 0, Stop: 59}},
+		{{Start: 0, Stop: 59}, {Start: 50, Stop: 99}},
+	}
+	if got, want := overlaps, wantOverlaps; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad overlaps: got %+v, want %+v", got, want)
+	}
+	if _, _, err := ParseOrdered([]string{"bytes=0-9,x"}, "bytes=", 100); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+type parseLenientTest struct {
+	Ranges          []string
+	ExpectedRanges  []Range
+	ExpectedDropped bool
+}
+
+func TestParseLenient(t *testing.T) {
+	tests := []parseLenientTest{
+		{ // all valid
+			Ranges:         []string{"bytes=0-9,20-29"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}},
+		},
+		{ // malformed spec dropped
+			Ranges:          []string{"bytes=0-9,x-y,20-29"},
+			ExpectedRanges:  []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}},
+			ExpectedDropped: true,
+		},
+		{ // reversed spec dropped
+			Ranges:          []string{"bytes=9-0,20-29"},
+			ExpectedRanges:  []Range{{Start: 20, Stop: 29}},
+			ExpectedDropped: true,
+		},
+		{ // past the end clamped, not dropped
+			Ranges:         []string{"bytes=90-199"},
+			ExpectedRanges: []Range{{Start: 90, Stop: 99}},
+		},
+		{ // start past the end dropped
+			Ranges:          []string{"bytes=0-9,100-199"},
+			ExpectedRanges:  []Range{{Start: 0, Stop: 9}},
+			ExpectedDropped: true,
+		},
+		{ // everything dropped
+			Ranges:          []string{"bytes=foo"},
+			ExpectedRanges:  nil,
+			ExpectedDropped: true,
+		},
+		{ // empty elements and whitespace
+			Ranges:         []string{"bytes=0-9, ,, 20-29 "},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}},
+		},
+	}
+	for i, test := range tests {
+		ranges, dropped := ParseLenient(test.Ranges, "bytes=", 100)
+		if got, want := ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+		if got, want := dropped, test.ExpectedDropped; got != want {
+			t.Errorf("test %d: bad dropped: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestParseLenientMaxRanges(t *testing.T) {
+	ranges, dropped := ParseLenient([]string{"bytes=" + strings.Repeat("0-0,", MaxRanges) + "0-0"}, "bytes=", 100)
+	if got, want := ranges, []Range{{Start: 0, Stop: 0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+	if !dropped {
+		t.Error("ranges past MaxRanges not reported as dropped")
+	}
+}
+
+func TestRangeValidate(t *testing.T) {
+	if _, err := (Range{Start: 5, Stop: 3}).validate(); err != ErrUnsatisfiable {
+		t.Errorf("bad error: got %v, want %v", err, ErrUnsatisfiable)
+	}
+	r, err := Range{Start: -5, Stop: 3}.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r, (Range{Start: 0, Stop: 3}); got != want {
+		t.Errorf("bad range: got %+v, want %+v", got, want)
+	}
+}
+
+func TestErrorsWrapError(t *testing.T) {
+	for _, err := range []error{ErrMalformed, ErrUnsatisfiable, ErrLimit} {
+		if !errors.Is(err, Error) {
+			t.Errorf("%v doesn't wrap %v", err, Error)
+		}
+	}
+}
+
+func TestParseFastPath(t *testing.T) {
+	inputs := []string{
+		"bytes=0-99",
+		"bytes=0-",
+		"bytes=-50",
+		"bytes=50-99",
+		"bytes=99-99",
+		"bytes=100-",
+		"bytes=0-100",
+		"bytes=-101",
+		"bytes=5-3",
+		"bytes=",
+		"bytes=-",
+		"bytes=1-2-3",
+		"bytes=a-b",
+		"0-99",
+		"foo=0-99",
+	}
+	for _, input := range inputs {
+		fast, fastErr := Parse([]string{input}, "bytes=", 100)
+		slow, slowErr := ParseOptions{}.Parse([]string{input}, "bytes=", 100)
+		if got, want := fmt.Sprintf("%v", fastErr), fmt.Sprintf("%v", slowErr); got != want {
+			t.Errorf("%q: bad error: got %q, want %q", input, got, want)
+		}
+		if got, want := fast, slow; !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: bad ranges: got %+v, want %+v", input, got, want)
+		}
+	}
+}
+
+func BenchmarkParseSingle(b *testing.B) {
+	ranges := []string{"bytes=100-199"}
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(ranges, "bytes=", 1000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseSingleGeneral(b *testing.B) {
+	ranges := []string{"bytes=100-199"}
+	for i := 0; i < b.N; i++ {
+		if _, err := (ParseOptions{}).Parse(ranges, "bytes=", 1000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type parseOptionsTest struct {
+	Options        ParseOptions
+	Ranges         []string
+	ExpectedRanges []Range
+	ExpectedError  string
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []parseOptionsTest{
+		{ // zero value parses like Parse
+			Ranges:         []string{"bytes=50-99,0-59"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+			ExpectedError:  "<nil>",
+		},
+		{ // whitespace around ranges
+			Ranges:         []string{"bytes=0-9, 20-29 ,\t40-49"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}, {Start: 40, Stop: 49}},
+			ExpectedError:  "<nil>",
+		},
+		{ // whitespace refused when strict
+			Options:       ParseOptions{Strict: true},
+			Ranges:        []string{"bytes=0-9, 20-29"},
+			ExpectedError: `invalid range: malformed: range 1 " 20-29": bad number`,
+		},
+		{ // whitespace within a range is never allowed
+			Ranges:        []string{"bytes=0 -9"},
+			ExpectedError: `invalid range: malformed: range 0 "0 -9": bad number`,
+		},
+		{ // within MaxRanges
+			Options:        ParseOptions{MaxRanges: 2},
+			Ranges:         []string{"bytes=0-9", "bytes=20-29"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}},
+			ExpectedError:  "<nil>",
+		},
+		{ // too many ranges
+			Options:       ParseOptions{MaxRanges: 2},
+			Ranges:        []string{"bytes=0-9,20-29", "bytes=40-49"},
+			ExpectedError: "invalid range: limit exceeded",
+		},
+		{ // within MaxBytes
+			Options:        ParseOptions{MaxBytes: 20},
+			Ranges:         []string{"bytes=0-9,20-29"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}},
+			ExpectedError:  "<nil>",
+		},
+		{ // overlapping bytes count against MaxBytes
+			Options:       ParseOptions{MaxBytes: 20},
+			Ranges:        []string{"bytes=0-9,0-9,0-9"},
+			ExpectedError: "invalid range: limit exceeded",
+		},
+		{ // overlap refused
+			Options:       ParseOptions{RejectOverlap: true},
+			Ranges:        []string{"bytes=0-9,5-14"},
+			ExpectedError: "invalid range: limit exceeded",
+		},
+		{ // adjacent ranges don't overlap
+			Options:        ParseOptions{RejectOverlap: true},
+			Ranges:         []string{"bytes=0-9,10-19"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 19}},
+			ExpectedError:  "<nil>",
+		},
+		{ // past the end is unsatisfiable by default
+			Ranges:        []string{"bytes=0-99999"},
+			ExpectedError: `invalid range: unsatisfiable: range 0 "0-99999": outside the content`,
+		},
+		{ // past the end clamped
+			Options:        ParseOptions{Clamp: true},
+			Ranges:         []string{"bytes=0-99999"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+			ExpectedError:  "<nil>",
+		},
+		{ // start past the end dropped
+			Options:        ParseOptions{Clamp: true},
+			Ranges:         []string{"bytes=0-9,100-199"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}},
+			ExpectedError:  "<nil>",
+		},
+		{ // everything dropped
+			Options:       ParseOptions{Clamp: true},
+			Ranges:        []string{"bytes=100-199,200-"},
+			ExpectedError: "invalid range: unsatisfiable",
+		},
+		{ // malformed is still malformed
+			Options:       ParseOptions{Clamp: true},
+			Ranges:        []string{"bytes=0-9,x-"},
+			ExpectedError: `invalid range: malformed: range 1 "x-": bad number`,
+		},
+		{ // clamped bytes count against MaxBytes
+			Options:        ParseOptions{Clamp: true, MaxBytes: 10},
+			Ranges:         []string{"bytes=90-99999"},
+			ExpectedRanges: []Range{{Start: 90, Stop: 99}},
+			ExpectedError:  "<nil>",
+		},
+		{ // open-ended range truncated
+			Options:        ParseOptions{MaxRangeBytes: 10},
+			Ranges:         []string{"bytes=50-"},
+			ExpectedRanges: []Range{{Start: 50, Stop: 59}},
+			ExpectedError:  "<nil>",
+		},
+		{ // each range truncated before merging
+			Options:        ParseOptions{MaxRangeBytes: 10},
+			Ranges:         []string{"bytes=0-29,5-,-5"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 14}, {Start: 95, Stop: 99}},
+			ExpectedError:  "<nil>",
+		},
+		{ // truncated bytes count against MaxBytes
+			Options:        ParseOptions{MaxRangeBytes: 10, MaxBytes: 10},
+			Ranges:         []string{"bytes=0-"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}},
+			ExpectedError:  "<nil>",
+		},
+		{ // several fields combined
+			Ranges:         []string{"bytes=0-9", "bytes=20-29"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}},
+			ExpectedError:  "<nil>",
+		},
+		{ // first field only
+			Options:        ParseOptions{Fields: FirstField},
+			Ranges:         []string{"bytes=0-9", "bytes=20-29"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}},
+			ExpectedError:  "<nil>",
+		},
+		{ // several fields refused
+			Options:       ParseOptions{Fields: RejectFields},
+			Ranges:        []string{"bytes=0-9", "bytes=20-29"},
+			ExpectedError: "invalid range: malformed: 2 Range fields",
+		},
+		{ // a single field is fine
+			Options:        ParseOptions{Fields: RejectFields},
+			Ranges:         []string{"bytes=0-9,20-29"},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}},
+			ExpectedError:  "<nil>",
+		},
+		{ // preserved in order
+			Options:        ParseOptions{NoMerge: true},
+			Ranges:         []string{"bytes=50-99,0-59"},
+			ExpectedRanges: []Range{{Start: 50, Stop: 99}, {Start: 0, Stop: 59}},
+			ExpectedError:  "<nil>",
+		},
+	}
+	for i, test := range tests {
+		ranges, err := test.Options.Parse(test.Ranges, "bytes=", 100)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestParseOptionsRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Add("Range", "bytes=0-9")
+	req.Header.Add("Range", "bytes=20-29")
+	ranges, err := ParseOptions{Fields: FirstField}.ParseRequest(req, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ranges, []Range{{Start: 0, Stop: 9}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+	if _, err := (ParseOptions{Fields: RejectFields}).ParseHeader(req.Header, 100); !errors.Is(err, ErrMalformed) {
+		t.Errorf("bad error: got %v, want %v", err, ErrMalformed)
+	}
+}
+
+func BenchmarkParseMulti(b *testing.B) {
+	ranges := []string{"bytes=200-299,0-99,50-149"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(ranges, "bytes=", 1000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseWhitespace(t *testing.T) {
+	want := []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}}
+	value := "bytes=0-9, 20-29"
+	if got, err := Parse([]string{value}, "bytes=", 100); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("bad Parse: got %v, %v, want %v", got, err, want)
+	}
+	if got, err := AppendParse(nil, []byte(value), "bytes=", 100); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("bad AppendParse: got %v, %v, want %v", got, err, want)
+	}
+	if got, err := ParseReader(strings.NewReader(value), "bytes=", 100); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ParseReader: got %v, %v, want %v", got, err, want)
+	}
+	if got, err := ParseClamp([]string{value}, "bytes=", 100); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ParseClamp: got %v, %v, want %v", got, err, want)
+	}
+	if _, err := ParseSpecs([]string{value}, "bytes="); err != nil {
+		t.Errorf("bad ParseSpecs: %v", err)
+	}
+	if got, err := ParseOne("bytes= 0-9", 100); err != nil || got != want[0] {
+		t.Errorf("bad ParseOne: got %v, %v, want %v", got, err, want[0])
+	}
+}
+
+type parseResponseTest struct {
+	Response       *http.Response
+	ExpectedRanges []Range
+	ExpectedError  string
+}
+
+func TestParseResponse(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-9,-10")
+	tests := []parseResponseTest{
+		{ // size from Content-Range
+			Response: &http.Response{
+				Request:       req,
+				Header:        http.Header{"Content-Range": {"bytes 0-9/100"}},
+				ContentLength: 10,
+			},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 90, Stop: 99}},
+			ExpectedError:  "<nil>",
+		},
+		{ // size from an unsatisfied Content-Range
+			Response: &http.Response{
+				Request:       req,
+				Header:        http.Header{"Content-Range": {"bytes */5"}},
+				ContentLength: 0,
+			},
+			ExpectedError: `invalid range: unsatisfiable: range 0 "0-9": outside the content`,
+		},
+		{ // size from Content-Length
+			Response: &http.Response{
+				Request:       req,
+				Header:        http.Header{},
+				ContentLength: 50,
+			},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 40, Stop: 49}},
+			ExpectedError:  "<nil>",
+		},
+		{ // unknown size
+			Response: &http.Response{
+				Request:       req,
+				Header:        http.Header{"Content-Range": {"bytes 0-9/*"}},
+				ContentLength: 10,
+			},
+			ExpectedError: "invalid range: response doesn't give the size",
+		},
+		{ // no request
+			Response:      &http.Response{Header: http.Header{}, ContentLength: 10},
+			ExpectedError: "invalid range: response has no request",
+		},
+	}
+	for i, test := range tests {
+		ranges, err := ParseResponse(test.Response)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %v, want %v", i, got, want)
+		}
+		if got, want := ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+type parseResponseRangesTest struct {
+	Response       *http.Response
+	ExpectedRanges []Range
+	ExpectedSize   int64
+	ExpectedError  string
+}
+
+func TestParseResponseRanges(t *testing.T) {
+	tests := []parseResponseRangesTest{
+		{ // partial content
+			Response: &http.Response{
+				StatusCode:    http.StatusPartialContent,
+				Header:        http.Header{"Content-Range": {"bytes 10-19/100"}},
+				ContentLength: 10,
+			},
+			ExpectedRanges: []Range{{Start: 10, Stop: 19}},
+			ExpectedSize:   100,
+			ExpectedError:  "<nil>",
+		},
+		{ // partial content of unknown length
+			Response: &http.Response{
+				StatusCode:    http.StatusPartialContent,
+				Header:        http.Header{"Content-Range": {"bytes 10-19/*"}},
+				ContentLength: 10,
+			},
+			ExpectedRanges: []Range{{Start: 10, Stop: 19}},
+			ExpectedSize:   -1,
+			ExpectedError:  "<nil>",
+		},
+		{ // all of the content
+			Response: &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Range": {"bytes 10-19/100"}},
+				ContentLength: 50,
+			},
+			ExpectedRanges: []Range{{Start: 0, Stop: 49}},
+			ExpectedSize:   50,
+			ExpectedError:  "<nil>",
+		},
+		{ // all of the content, of unknown length
+			Response: &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{},
+				ContentLength: -1,
+			},
+			ExpectedSize:  -1,
+			ExpectedError: "<nil>",
+		},
+		{ // unsatisfiable
+			Response: &http.Response{
+				StatusCode: http.StatusRequestedRangeNotSatisfiable,
+				Header:     http.Header{"Content-Range": {"bytes */5"}},
+			},
+			ExpectedSize:  5,
+			ExpectedError: "<nil>",
+		},
+		{ // multipart
+			Response: &http.Response{
+				StatusCode: http.StatusPartialContent,
+				Header:     http.Header{"Content-Type": {"multipart/byteranges; boundary=B"}},
+			},
+			ExpectedSize:  -1,
+			ExpectedError: "invalid range: multipart response gives its ranges in the body",
+		},
+		{ // missing Content-Range
+			Response: &http.Response{
+				StatusCode: http.StatusPartialContent,
+				Header:     http.Header{},
+			},
+			ExpectedSize:  -1,
+			ExpectedError: "ranger: invalid content-range: partial response has no Content-Range",
+		},
+		{ // another status
+			Response: &http.Response{
+				StatusCode: http.StatusNotFound,
+				Status:     "404 Not Found",
+				Request:    httptest.NewRequest("GET", "http://example.com/x", nil),
+			},
+			ExpectedSize:  -1,
+			ExpectedError: "ranger: GET http://example.com/x: 404 Not Found",
+		},
+	}
+	for i, test := range tests {
+		ranges, size, err := ParseResponseRanges(test.Response)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %v, want %v", i, got, want)
+		}
+		if got, want := ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %v, want %v", i, got, want)
+		}
+		if got, want := size, test.ExpectedSize; got != want {
+			t.Errorf("test %d: bad size: got %d, want %d", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package rangertest provides utilities for testing how HTTP handlers serve
+// byte ranges.
+//
+// NewServer serves a byte slice the way RFC 7233 says to, as ranger.Handler
+// does, so that clients can be tested against it, and the assertions check
+// that a handler under test answers a Range header the same way.
+package rangertest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/echlebek/ranger"
+)
+
+// NewHandler returns a handler that serves content, with correct 200, 206,
+// 416 and multipart/byteranges responses, as a reference to test against.
+// Like net/http, it clamps ranges that run past the end of the content, so
+// 'bytes=0-999' of 10 bytes gets a 206 for 'bytes 0-9/10', and refuses only
+// ranges that start past the end.
+func NewHandler(content []byte, opts ...ranger.ServeOption) http.Handler {
+	return ranger.Handler(bytes.NewReader(content), int64(len(content)), opts...)
+}
+
+// NewServer starts and returns a new server that serves content as NewHandler
+// does. The caller should call Close when finished, to shut it down.
+func NewServer(content []byte, opts ...ranger.ServeOption) *httptest.Server {
+	return httptest.NewServer(NewHandler(content, opts...))
+}
+
+// AssertServesRange checks that h answers a GET with the given Range header
+// with a 206, and a single part holding want.
+func AssertServesRange(t testing.TB, h http.Handler, rangeHeader string, want []byte) {
+	t.Helper()
+	resp := get(h, rangeHeader)
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Errorf("%s: bad status: got %d, want %d", rangeHeader, resp.StatusCode, http.StatusPartialContent)
+		return
+	}
+	r, _, err := ranger.ParseContentRange(resp.Header)
+	if err != nil {
+		t.Errorf("%s: %v", rangeHeader, err)
+		return
+	}
+	got, _ := io.ReadAll(resp.Body)
+	if r.Len() != int64(len(got)) {
+		t.Errorf("%s: Content-Range %s doesn't match the %d bytes sent", rangeHeader, resp.Header.Get("Content-Range"), len(got))
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: bad body: got %q, want %q", rangeHeader, got, want)
+	}
+}
+
+// AssertServesRanges checks that h, serving content, answers a GET with the
+// given Range header with a 206 holding the ranges ParseClamp finds in it:
+// just the range, if there's one, or a multipart/byteranges body of them all,
+// in order, if there are several.
+func AssertServesRanges(t testing.TB, h http.Handler, rangeHeader string, content []byte) {
+	t.Helper()
+	ranges, err := ranger.ParseClamp([]string{rangeHeader}, "bytes=", int64(len(content)))
+	if err != nil {
+		t.Fatalf("%s: %v", rangeHeader, err)
+	}
+	if len(ranges) == 1 {
+		AssertServesRange(t, h, rangeHeader, content[ranges[0].Start:ranges[0].Stop+1])
+		return
+	}
+	resp := get(h, rangeHeader)
+	mr, err := ranger.NewMultipartResponseReader(resp)
+	if err != nil {
+		t.Errorf("%s: %v", rangeHeader, err)
+		return
+	}
+	for i := 0; ; i++ {
+		r, part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			if i != len(ranges) {
+				t.Errorf("%s: bad number of parts: got %d, want %d", rangeHeader, i, len(ranges))
+			}
+			return
+		}
+		if err != nil {
+			t.Errorf("%s: part %d: %v", rangeHeader, i, err)
+			return
+		}
+		if i >= len(ranges) || r != ranges[i] {
+			t.Errorf("%s: part %d: unexpected range %v", rangeHeader, i, r)
+			return
+		}
+		got, err := io.ReadAll(part)
+		if err != nil {
+			t.Errorf("%s: part %d: %v", rangeHeader, i, err)
+			return
+		}
+		if want := content[r.Start : r.Stop+1]; !bytes.Equal(got, want) {
+			t.Errorf("%s: part %d: bad body: got %q, want %q", rangeHeader, i, got, want)
+		}
+	}
+}
+
+// AssertServesAll checks that h answers a GET with the given Range header with
+// a 200 holding all of content, as it should when the header is malformed.
+func AssertServesAll(t testing.TB, h http.Handler, rangeHeader string, content []byte) {
+	t.Helper()
+	resp := get(h, rangeHeader)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("%s: bad status: got %d, want %d", rangeHeader, resp.StatusCode, http.StatusOK)
+		return
+	}
+	if got, _ := io.ReadAll(resp.Body); !bytes.Equal(got, content) {
+		t.Errorf("%s: bad body: got %q, want %q", rangeHeader, got, content)
+	}
+}
+
+// AssertUnsatisfiable checks that h, serving size bytes, answers a GET with
+// the given Range header with a 416, and a Content-Range of 'bytes */size'.
+func AssertUnsatisfiable(t testing.TB, h http.Handler, rangeHeader string, size int64) {
+	t.Helper()
+	resp := get(h, rangeHeader)
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("%s: bad status: got %d, want %d", rangeHeader, resp.StatusCode, http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if got, want := resp.Header.Get("Content-Range"), ranger.UnsatisfiedContentRange(size); got != want {
+		t.Errorf("%s: bad content range: got %q, want %q", rangeHeader, got, want)
+	}
+}
+
+// get sends h a GET with the given Range header, and returns the response.
+func get(h http.Handler, rangeHeader string) *http.Response {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Result()
+}
@@ -0,0 +1,123 @@
+package rangertest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// recorder is a testing.TB that records failures, rather than failing.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+var content = []byte("0123456789")
+
+func TestAssertions(t *testing.T) {
+	h := NewHandler(content)
+	AssertServesRange(t, h, "bytes=5-9", []byte("56789"))
+	AssertServesRanges(t, h, "bytes=0-0,-1", content)
+	AssertServesRanges(t, h, "bytes=2-", content)
+	AssertServesRange(t, h, "bytes=0-999", content)
+	AssertServesRanges(t, h, "bytes=0-0,5-999,500-600", content)
+	AssertServesAll(t, h, "", content)
+	AssertServesAll(t, h, "bytes=9-5", content)
+	AssertUnsatisfiable(t, h, "bytes=10-", 10)
+}
+
+func TestNewServer(t *testing.T) {
+	srv := NewServer(content)
+	defer srv.Close()
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Range", "bytes=-3")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "789"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+}
+
+func TestNewHandlerClamps(t *testing.T) {
+	resp := get(NewHandler(content), "bytes=0-999")
+	if got, want := resp.StatusCode, http.StatusPartialContent; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := resp.Header.Get("Content-Range"), "bytes 0-9/10"; got != want {
+		t.Errorf("bad content range: got %q, want %q", got, want)
+	}
+}
+
+// ignoresRanges serves all of the content, whatever the request.
+var ignoresRanges = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write(content)
+})
+
+type failureTest struct {
+	Assert        func(testing.TB)
+	ExpectedError string
+}
+
+func TestAssertionFailures(t *testing.T) {
+	tests := []failureTest{
+		{ // single range
+			Assert: func(t testing.TB) {
+				AssertServesRange(t, ignoresRanges, "bytes=5-9", []byte("56789"))
+			},
+			ExpectedError: "bytes=5-9: bad status: got 200, want 206",
+		},
+		{ // several ranges
+			Assert: func(t testing.TB) {
+				AssertServesRanges(t, ignoresRanges, "bytes=0-0,-1", content)
+			},
+			ExpectedError: `bytes=0-0,-1: ranger: unexpected status "200 OK"`,
+		},
+		{ // wrong body
+			Assert: func(t testing.TB) {
+				AssertServesRange(t, NewHandler(content), "bytes=5-9", []byte("5678x"))
+			},
+			ExpectedError: `bytes=5-9: bad body: got "56789", want "5678x"`,
+		},
+		{ // unsatisfiable
+			Assert: func(t testing.TB) {
+				AssertUnsatisfiable(t, ignoresRanges, "bytes=10-", 10)
+			},
+			ExpectedError: "bytes=10-: bad status: got 200, want 416",
+		},
+		{ // all of the content
+			Assert: func(t testing.TB) {
+				AssertServesAll(t, NewHandler(content), "bytes=0-", content)
+			},
+			ExpectedError: "bytes=0-: bad status: got 206, want 200",
+		},
+	}
+	for i, test := range tests {
+		r := &recorder{TB: t}
+		test.Assert(r)
+		if got, want := strings.Join(r.errors, "\n"), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package ranger
+
+import (
+	"slices"
+	"sort"
+)
+
+// RangeSet is a set of offsets, held as ranges in the normalized form that
+// Merge returns. The zero value is the empty set.
+//
+// A RangeSet is immutable. Its methods return new sets, and never modify the
+// ranges of the sets they're given, so a RangeSet may be shared freely.
+type RangeSet struct {
+	ranges []Range
+}
+
+// NewRangeSet returns the set of offsets covered by ranges. The ranges don't
+// need to be sorted or merged, and are left untouched. Reversed ranges cover
+// nothing, and are ignored.
+func NewRangeSet(ranges ...Range) RangeSet {
+	valid := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Start <= r.Stop {
+			valid = append(valid, r)
+		}
+	}
+	return RangeSet{ranges: mergeRanges(valid)}
+}
+
+// Ranges returns the ranges in s, sorted and merged as by Merge. The caller
+// may modify the result.
+func (s RangeSet) Ranges() []Range {
+	return append([]Range(nil), s.ranges...)
+}
+
+// Len returns the number of offsets in s.
+func (s RangeSet) Len() int64 {
+	n := int64(0)
+	for _, r := range s.ranges {
+		n += r.Stop - r.Start + 1
+	}
+	return n
+}
+
+// Union returns the offsets that are in either s or o.
+func (s RangeSet) Union(o RangeSet) RangeSet {
+	return NewRangeSet(append(s.Ranges(), o.ranges...)...)
+}
+
+// Intersect returns the offsets that are in both s and o.
+func (s RangeSet) Intersect(o RangeSet) RangeSet {
+	var result []Range
+	a, b := s.ranges, o.ranges
+	for len(a) > 0 && len(b) > 0 {
+		if r, ok := a[0].Intersect(b[0]); ok {
+			result = append(result, r)
+		}
+		if a[0].Stop < b[0].Stop {
+			a = a[1:]
+		} else {
+			b = b[1:]
+		}
+	}
+	return RangeSet{ranges: result}
+}
+
+// Subtract returns the offsets that are in s, but not in o.
+func (s RangeSet) Subtract(o RangeSet) RangeSet {
+	return RangeSet{ranges: Subtract(s.ranges, o.ranges)}
+}
+
+// Complement returns the offsets in content of length bytes that aren't in s.
+func (s RangeSet) Complement(length int64) RangeSet {
+	return RangeSet{ranges: Gaps(s.ranges, length)}
+}
+
+// Contains reports whether offset is in s.
+func (s RangeSet) Contains(offset int64) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].Stop >= offset
+	})
+	return i < len(s.ranges) && s.ranges[i].Start <= offset
+}
+
+// Equal reports whether s and o contain exactly the same offsets.
+func (s RangeSet) Equal(o RangeSet) bool {
+	return slices.Equal(s.ranges, o.ranges)
+}
+
+// ToBitmap returns a bitfield of the pieces of pieceSize bytes, in content of
+// length bytes, that s covers entirely, as torrent-like systems track which
+// pieces are available. Piece i is bit 7-i%8 of byte i/8, so that the first
+// piece is the high bit of the first byte, as in BitTorrent. The final piece
+// holds whatever remains, and the spare bits after it are zero.
+//
+// If pieceSize or length are not positive, ToBitmap returns nil.
+func (s RangeSet) ToBitmap(pieceSize, length int64) []byte {
+	if pieceSize <= 0 || length <= 0 {
+		return nil
+	}
+	pieces := (length + pieceSize - 1) / pieceSize
+	bitmap := make([]byte, (pieces+7)/8)
+	for _, r := range s.ranges {
+		if r.Start >= length {
+			break
+		}
+		first := (max(r.Start, 0) + pieceSize - 1) / pieceSize
+		last := pieces - 1
+		if end := r.Stop + 1; end < length {
+			last = end/pieceSize - 1
+		}
+		for i := first; i <= last; i++ {
+			bitmap[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return bitmap
+}
+
+// FromBitmap returns the set of offsets in the pieces of pieceSize bytes, in
+// content of length bytes, that are set in bitmap, as ToBitmap makes it. Bits
+// past the final piece are ignored.
+//
+// If pieceSize or length are not positive, FromBitmap returns the empty set.
+func FromBitmap(bitmap []byte, pieceSize, length int64) RangeSet {
+	if pieceSize <= 0 || length <= 0 {
+		return RangeSet{}
+	}
+	pieces := min((length+pieceSize-1)/pieceSize, int64(len(bitmap))*8)
+	var ranges []Range
+	for i := int64(0); i < pieces; i++ {
+		if bitmap[i/8]&(0x80>>(i%8)) == 0 {
+			continue
+		}
+		r := Range{Start: i * pieceSize, Stop: min((i+1)*pieceSize, length) - 1}
+		if n := len(ranges); n > 0 && ranges[n-1].Stop+1 == r.Start {
+			ranges[n-1].Stop = r.Stop
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	return RangeSet{ranges: ranges}
+}
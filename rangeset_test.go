@@ -0,0 +1,198 @@
+package ranger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewRangeSet(t *testing.T) {
+	ranges := []Range{{Start: 50, Stop: 99}, {Start: 0, Stop: 59}, {Start: 9, Stop: 0}, {Start: 200, Stop: 299}}
+	orig := append([]Range(nil), ranges...)
+	s := NewRangeSet(ranges...)
+	if got, want := s.Ranges(), []Range{{Start: 0, Stop: 99}, {Start: 200, Stop: 299}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+	if got, want := s.Len(), int64(200); got != want {
+		t.Errorf("bad length: got %d, want %d", got, want)
+	}
+	if got, want := ranges, orig; !reflect.DeepEqual(got, want) {
+		t.Errorf("input modified: got %+v, want %+v", got, want)
+	}
+	s.Ranges()[0] = Range{}
+	if got, want := s.Ranges()[0], (Range{Start: 0, Stop: 99}); got != want {
+		t.Errorf("set modified through Ranges: got %+v, want %+v", got, want)
+	}
+}
+
+type rangeSetTest struct {
+	A, B              []Range
+	ExpectedUnion     []Range
+	ExpectedIntersect []Range
+	ExpectedSubtract  []Range
+}
+
+func TestRangeSetAlgebra(t *testing.T) {
+	tests := []rangeSetTest{
+		{ // overlapping
+			A:                 []Range{{Start: 0, Stop: 99}},
+			B:                 []Range{{Start: 50, Stop: 149}},
+			ExpectedUnion:     []Range{{Start: 0, Stop: 149}},
+			ExpectedIntersect: []Range{{Start: 50, Stop: 99}},
+			ExpectedSubtract:  []Range{{Start: 0, Stop: 49}},
+		},
+		{ // adjacent
+			A:                []Range{{Start: 0, Stop: 49}},
+			B:                []Range{{Start: 50, Stop: 99}},
+			ExpectedUnion:    []Range{{Start: 0, Stop: 99}},
+			ExpectedSubtract: []Range{{Start: 0, Stop: 49}},
+		},
+		{ // one range spanning several
+			A:                 []Range{{Start: 0, Stop: 99}},
+			B:                 []Range{{Start: 10, Stop: 19}, {Start: 90, Stop: 109}},
+			ExpectedUnion:     []Range{{Start: 0, Stop: 109}},
+			ExpectedIntersect: []Range{{Start: 10, Stop: 19}, {Start: 90, Stop: 99}},
+			ExpectedSubtract:  []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 89}},
+		},
+		{ // interleaved
+			A:                 []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}},
+			B:                 []Range{{Start: 5, Stop: 24}},
+			ExpectedUnion:     []Range{{Start: 0, Stop: 29}},
+			ExpectedIntersect: []Range{{Start: 5, Stop: 9}, {Start: 20, Stop: 24}},
+			ExpectedSubtract:  []Range{{Start: 0, Stop: 4}, {Start: 25, Stop: 29}},
+		},
+		{ // b empty
+			A:                []Range{{Start: 0, Stop: 9}},
+			ExpectedUnion:    []Range{{Start: 0, Stop: 9}},
+			ExpectedSubtract: []Range{{Start: 0, Stop: 9}},
+		},
+		{ // both empty
+		},
+	}
+	for i, test := range tests {
+		a, b := NewRangeSet(test.A...), NewRangeSet(test.B...)
+		if got, want := a.Union(b).Ranges(), test.ExpectedUnion; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad union: got %+v, want %+v", i, got, want)
+		}
+		if got, want := a.Intersect(b).Ranges(), test.ExpectedIntersect; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad intersection: got %+v, want %+v", i, got, want)
+		}
+		if got, want := b.Intersect(a).Ranges(), test.ExpectedIntersect; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: intersection not commutative: got %+v, want %+v", i, got, want)
+		}
+		if got, want := a.Subtract(b).Ranges(), test.ExpectedSubtract; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad difference: got %+v, want %+v", i, got, want)
+		}
+		if got, want := a.Union(b).Equal(b.Union(a)), true; got != want {
+			t.Errorf("test %d: union not commutative", i)
+		}
+	}
+}
+
+func TestRangeSetComplement(t *testing.T) {
+	s := NewRangeSet(Range{Start: 10, Stop: 19}, Range{Start: 90, Stop: 99})
+	if got, want := s.Complement(100).Ranges(), []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 89}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad complement: got %+v, want %+v", got, want)
+	}
+	if got, want := s.Complement(100).Complement(100), NewRangeSet(s.Ranges()...); !got.Equal(want) {
+		t.Errorf("bad double complement: got %+v, want %+v", got.Ranges(), want.Ranges())
+	}
+	if got := s.Complement(0).Ranges(); got != nil {
+		t.Errorf("bad complement of empty content: got %+v, want nil", got)
+	}
+	if got, want := (RangeSet{}).Complement(10).Ranges(), []Range{{Start: 0, Stop: 9}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad complement of empty set: got %+v, want %+v", got, want)
+	}
+}
+
+func TestRangeSetContains(t *testing.T) {
+	s := NewRangeSet(Range{Start: 10, Stop: 19}, Range{Start: 30, Stop: 30})
+	for _, off := range []int64{10, 15, 19, 30} {
+		if !s.Contains(off) {
+			t.Errorf("offset %d not contained", off)
+		}
+	}
+	for _, off := range []int64{-1, 0, 9, 20, 29, 31} {
+		if s.Contains(off) {
+			t.Errorf("offset %d contained", off)
+		}
+	}
+	if (RangeSet{}).Contains(0) {
+		t.Error("empty set contains 0")
+	}
+}
+
+func TestRangeSetEqual(t *testing.T) {
+	a := NewRangeSet(Range{Start: 0, Stop: 50}, Range{Start: 51, Stop: 99})
+	b := NewRangeSet(Range{Start: 0, Stop: 99})
+	if !a.Equal(b) {
+		t.Errorf("%+v != %+v", a.Ranges(), b.Ranges())
+	}
+	c := NewRangeSet(Range{Start: 0, Stop: 98})
+	if a.Equal(c) {
+		t.Errorf("%+v == %+v", a.Ranges(), c.Ranges())
+	}
+	if !(RangeSet{}).Equal(NewRangeSet()) {
+		t.Error("empty sets not equal")
+	}
+}
+
+type bitmapTest struct {
+	Ranges         []Range
+	PieceSize      int64
+	Length         int64
+	ExpectedBitmap []byte
+	ExpectedRanges []Range
+}
+
+func TestRangeSetBitmap(t *testing.T) {
+	tests := []bitmapTest{
+		{ // whole pieces
+			Ranges:         []Range{{Start: 0, Stop: 19}, {Start: 40, Stop: 49}},
+			PieceSize:      10,
+			Length:         100,
+			ExpectedBitmap: []byte{0xc8, 0x00},
+			ExpectedRanges: []Range{{Start: 0, Stop: 19}, {Start: 40, Stop: 49}},
+		},
+		{ // partial pieces don't count
+			Ranges:         []Range{{Start: 5, Stop: 24}},
+			PieceSize:      10,
+			Length:         100,
+			ExpectedBitmap: []byte{0x40, 0x00},
+			ExpectedRanges: []Range{{Start: 10, Stop: 19}},
+		},
+		{ // short final piece
+			Ranges:         []Range{{Start: 80, Stop: 84}},
+			PieceSize:      10,
+			Length:         85,
+			ExpectedBitmap: []byte{0x00, 0x80},
+			ExpectedRanges: []Range{{Start: 80, Stop: 84}},
+		},
+		{ // past the end
+			Ranges:         []Range{{Start: 70, Stop: 199}},
+			PieceSize:      10,
+			Length:         80,
+			ExpectedBitmap: []byte{0x01},
+			ExpectedRanges: []Range{{Start: 70, Stop: 79}},
+		},
+		{ // empty content
+			Ranges:    []Range{{Start: 0, Stop: 9}},
+			PieceSize: 10,
+		},
+	}
+	for i, test := range tests {
+		bitmap := NewRangeSet(test.Ranges...).ToBitmap(test.PieceSize, test.Length)
+		if got, want := bitmap, test.ExpectedBitmap; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad bitmap: got %x, want %x", i, got, want)
+		}
+		if got, want := FromBitmap(bitmap, test.PieceSize, test.Length).Ranges(), test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestFromBitmapIgnoresSpareBits(t *testing.T) {
+	s := FromBitmap([]byte{0xff, 0xff}, 10, 25)
+	if got, want := s.Ranges(), []Range{{Start: 0, Stop: 24}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+}
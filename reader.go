@@ -0,0 +1,75 @@
+package ranger
+
+import (
+	"context"
+	"io"
+)
+
+// rangeReader reads the bytes covered by a list of ranges, in order.
+type rangeReader struct {
+	ctx    context.Context
+	src    io.ReaderAt
+	ranges []Range
+	off    int64 // offset into ranges[0]
+}
+
+// NewReader returns an io.Reader that reads the bytes covered by ranges from
+// src, one range after another, in the order given.
+//
+// The reader only ever calls src.ReadAt, and keeps its position to itself.
+// Any number of readers may therefore share a single src, such as an *os.File,
+// without interfering with each other.
+func NewReader(src io.ReaderAt, ranges []Range) io.Reader {
+	return NewReaderContext(context.Background(), src, ranges)
+}
+
+// NewReaderContext is like NewReader, but the reader stops once ctx is done.
+// The context is checked before every read from src, and once it's done, Read
+// returns ctx.Err().
+func NewReaderContext(ctx context.Context, src io.ReaderAt, ranges []Range) io.Reader {
+	return &rangeReader{ctx: ctx, src: src, ranges: ranges}
+}
+
+// SectionReaders returns an io.SectionReader for each of the ranges, in the
+// order given, for callers that need to handle the ranges one at a time, such
+// as when writing the parts of a multipart response. To read them one after
+// another as a single stream, use NewReader.
+func SectionReaders(src io.ReaderAt, ranges []Range) []*io.SectionReader {
+	result := make([]*io.SectionReader, 0, len(ranges))
+	for _, r := range ranges {
+		result = append(result, io.NewSectionReader(src, r.Start, r.Len()))
+	}
+	return result
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	for len(r.ranges) > 0 {
+		cur := r.ranges[0]
+		remaining := cur.Stop - cur.Start + 1 - r.off
+		if remaining <= 0 {
+			r.ranges = r.ranges[1:]
+			r.off = 0
+			continue
+		}
+		if len(p) == 0 {
+			return 0, nil
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		n, err := r.src.ReadAt(p, cur.Start+r.off)
+		r.off += int64(n)
+		if err == io.EOF {
+			if n == len(p) {
+				err = nil
+			} else {
+				err = io.ErrUnexpectedEOF
+			}
+		}
+		return n, err
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,114 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type readerTest struct {
+	Content  string
+	Ranges   []Range
+	Expected string
+}
+
+func TestNewReader(t *testing.T) {
+	tests := []readerTest{
+		{ // single range
+			Content:  "0123456789",
+			Ranges:   []Range{{Start: 2, Stop: 4}},
+			Expected: "234",
+		},
+		{ // several ranges are read in order
+			Content:  "0123456789",
+			Ranges:   []Range{{Start: 7, Stop: 9}, {Start: 0, Stop: 1}},
+			Expected: "78901",
+		},
+		{ // range ending on the last byte
+			Content:  "0123456789",
+			Ranges:   []Range{{Start: 9, Stop: 9}},
+			Expected: "9",
+		},
+		{ // no ranges
+			Content:  "0123456789",
+			Expected: "",
+		},
+	}
+	for i, test := range tests {
+		b, err := io.ReadAll(NewReader(strings.NewReader(test.Content), test.Ranges))
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if got, want := string(b), test.Expected; got != want {
+			t.Errorf("test %d: bad content: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestSectionReaders(t *testing.T) {
+	src := strings.NewReader("0123456789")
+	readers := SectionReaders(src, []Range{{Start: 7, Stop: 9}, {Start: 0, Stop: 1}, {Start: 5, Stop: 5}})
+	want := []string{"789", "01", "5"}
+	if got, want := len(readers), len(want); got != want {
+		t.Fatalf("bad number of readers: got %d, want %d", got, want)
+	}
+	for i, r := range readers {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(b), want[i]; got != want {
+			t.Errorf("reader %d: bad content: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestNewReaderSharedSource(t *testing.T) {
+	src := strings.NewReader("abcdefghij")
+	r1 := NewReader(src, []Range{{Start: 0, Stop: 4}})
+	r2 := NewReader(src, []Range{{Start: 5, Stop: 9}})
+	var got1, got2 []byte
+	buf := make([]byte, 1)
+	for i := 0; i < 5; i++ {
+		if _, err := r1.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		got1 = append(got1, buf[0])
+		if _, err := r2.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		got2 = append(got2, buf[0])
+	}
+	if got, want := string(got1), "abcde"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := string(got2), "fghij"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
+
+func TestNewReaderShortSource(t *testing.T) {
+	_, err := io.ReadAll(NewReader(strings.NewReader("abc"), []Range{{Start: 0, Stop: 9}}))
+	if got, want := err, io.ErrUnexpectedEOF; got != want {
+		t.Errorf("bad error: got %v, want %v", got, want)
+	}
+}
+
+func TestNewReaderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewReaderContext(ctx, strings.NewReader("0123456789"), []Range{{Start: 0, Stop: 9}})
+	buf := make([]byte, 4)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	n, err := r.Read(buf)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("bad error: got %v, want %v", err, context.Canceled)
+	}
+	if n != 0 {
+		t.Errorf("read %d bytes after cancellation", n)
+	}
+}
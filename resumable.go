@@ -0,0 +1,105 @@
+package ranger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+)
+
+// StatusResumeIncomplete is the status of a reply to a part of a resumable
+// upload that leaves it incomplete, as in Google's resumable upload protocol.
+// It shares its code with 308 Permanent Redirect.
+const StatusResumeIncomplete = 308
+
+// UploadHandler is an http.Handler for resumable uploads, which accepts the
+// parts of an object in PUT or PATCH requests with Content-Range fields, as
+// ParseUploadRange parses them, and writes each at its offset.
+//
+// While the upload is incomplete, each part is answered with a 308, with a
+// Range field such as 'bytes=0-N' giving the bytes received contiguously from
+// the start, from which the client should continue; it's left out if there
+// are none. A request with 'Content-Range: bytes */*' and no body asks for the
+// same reply without sending anything. Once the upload is complete, the reply
+// is left to Complete.
+//
+// A malformed part gets a 400, and one that conflicts with what's been
+// received already, as UploadTracker.Add reports, gets a 409. So does a part
+// that overlaps one that another request is storing; each part's range is
+// reserved with UploadTracker.Reserve while its bytes are written, and
+// released, to be sent again, if they can't all be.
+type UploadHandler struct {
+	// Lookup returns where to write the upload a request is for, such as by
+	// its path, and the tracker for it. If it returns an error wrapping
+	// fs.ErrNotExist, the reply is a 404; for any other error, it's a 500.
+	Lookup func(r *http.Request) (io.WriterAt, *UploadTracker, error)
+
+	// Complete replies to the request that completed an upload, or asked for
+	// the status of a complete one. If nil, the reply is a 200.
+	Complete func(w http.ResponseWriter, r *http.Request)
+}
+
+func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		w.Header().Set("Allow", "PUT, PATCH")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	dst, tracker, err := h.Lookup(r)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if r.Header.Get("Content-Range") == "bytes */*" && r.ContentLength <= 0 {
+		h.reply(w, r, tracker)
+		return
+	}
+	cr, err := ParseUploadRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := tracker.Reserve(cr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer res.Release()
+	if !cr.Unsatisfied {
+		n, err := io.CopyN(io.NewOffsetWriter(dst, cr.Range.Start), r.Body, cr.Range.Len())
+		if err == io.EOF {
+			http.Error(w, fmt.Sprintf("ranger: short part for range %v: got %d bytes", cr.Range, n), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err := res.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	h.reply(w, r, tracker)
+}
+
+// reply replies with the status of the upload tracked by tracker.
+func (h *UploadHandler) reply(w http.ResponseWriter, r *http.Request, tracker *UploadTracker) {
+	if tracker.Complete() {
+		if h.Complete != nil {
+			h.Complete(w, r)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		return
+	}
+	if n := tracker.Committed(); n > 0 {
+		w.Header().Set("Range", "bytes=0-"+strconv.FormatInt(n-1, 10))
+	}
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(StatusResumeIncomplete)
+}
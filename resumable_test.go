@@ -0,0 +1,141 @@
+package ranger
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type uploadHandlerTest struct {
+	Method         string
+	ContentRange   string
+	Body           string
+	ExpectedStatus int
+	ExpectedRange  string
+}
+
+func TestUploadHandler(t *testing.T) {
+	dst := memFile(make([]byte, 10))
+	tracker := NewUploadTracker(-1)
+	h := &UploadHandler{
+		Lookup: func(r *http.Request) (io.WriterAt, *UploadTracker, error) {
+			if r.URL.Path != "/upload" {
+				return nil, nil, fs.ErrNotExist
+			}
+			return dst, tracker, nil
+		},
+		Complete: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		},
+	}
+	tests := []uploadHandlerTest{
+		{ // status before anything is sent
+			Method:         "PUT",
+			ContentRange:   "bytes */*",
+			ExpectedStatus: StatusResumeIncomplete,
+		},
+		{ // first part
+			Method:         "PUT",
+			ContentRange:   "bytes 0-3/*",
+			Body:           "0123",
+			ExpectedStatus: StatusResumeIncomplete,
+			ExpectedRange:  "bytes=0-3",
+		},
+		{ // a later part, leaving a gap
+			Method:         "PATCH",
+			ContentRange:   "bytes 7-9/10",
+			Body:           "789",
+			ExpectedStatus: StatusResumeIncomplete,
+			ExpectedRange:  "bytes=0-3",
+		},
+		{ // overlap
+			Method:         "PUT",
+			ContentRange:   "bytes 2-4/10",
+			Body:           "XXX",
+			ExpectedStatus: http.StatusConflict,
+		},
+		{ // short body
+			Method:         "PUT",
+			ContentRange:   "bytes 4-6/10",
+			Body:           "45",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{ // the gap is filled
+			Method:         "PUT",
+			ContentRange:   "bytes 4-6/10",
+			Body:           "456",
+			ExpectedStatus: http.StatusCreated,
+		},
+		{ // status once complete
+			Method:         "PUT",
+			ContentRange:   "bytes */*",
+			ExpectedStatus: http.StatusCreated,
+		},
+		{ // wrong method
+			Method:         "GET",
+			ExpectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest(test.Method, "/upload", strings.NewReader(test.Body))
+		if test.ContentRange != "" {
+			req.Header.Set("Content-Range", test.ContentRange)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if got, want := w.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := w.Header().Get("Range"), test.ExpectedRange; got != want {
+			t.Errorf("test %d: bad range: got %q, want %q", i, got, want)
+		}
+	}
+	if got, want := string(dst), "0123456789"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/other", nil))
+	if got, want := w.Code, http.StatusNotFound; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+}
+
+func TestUploadHandlerConcurrentOverlap(t *testing.T) {
+	dst := memFile(make([]byte, 10))
+	tracker := NewUploadTracker(10)
+	h := &UploadHandler{
+		Lookup: func(r *http.Request) (io.WriterAt, *UploadTracker, error) {
+			return dst, tracker, nil
+		},
+	}
+	pr, pw := io.Pipe()
+	first := httptest.NewRequest("PUT", "/", pr)
+	first.Header.Set("Content-Range", "bytes 0-4/10")
+	w1 := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(w1, first)
+	}()
+	// Once the first part's body is being read, its range is reserved.
+	io.WriteString(pw, "0")
+	second := httptest.NewRequest("PUT", "/", strings.NewReader("XXXXX"))
+	second.Header.Set("Content-Range", "bytes 2-6/10")
+	w2 := httptest.NewRecorder()
+	h.ServeHTTP(w2, second)
+	if got, want := w2.Code, http.StatusConflict; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	io.WriteString(pw, "1234")
+	pw.Close()
+	<-done
+	if got, want := w1.Code, StatusResumeIncomplete; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := string(dst), "01234\x00\x00\x00\x00\x00"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+package ranger
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CompletePolicy says how to answer a client resuming a download that it has
+// already completed: one asking for the bytes from the end of the content on,
+// as 'bytes=N-' does for content of N bytes, or for none of them, as
+// 'bytes=-0' does.
+type CompletePolicy int
+
+const (
+	// CompleteUnsatisfiable answers with a 416, whose Content-Range gives the
+	// length of the content, as RFC 7233 has a server do for a range that
+	// starts at the end. Clients such as curl take it to mean that the
+	// download is complete.
+	CompleteUnsatisfiable CompletePolicy = iota
+
+	// CompleteOK answers with a 200 and all of the content, for clients that
+	// take a 416 for a failure. The client must then start over, as it must
+	// with any 200 to a request with a Range header.
+	CompleteOK
+)
+
+func (p CompletePolicy) String() string {
+	switch p {
+	case CompleteUnsatisfiable:
+		return "unsatisfiable"
+	case CompleteOK:
+		return "ok"
+	}
+	return "CompletePolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// WithCompletePolicy sets how to answer a client resuming a download it has
+// already completed. By default, it gets a 416.
+func WithCompletePolicy(p CompletePolicy) ServeOption {
+	return func(c *serveConfig) {
+		c.complete = p
+	}
+}
+
+// Resume decides how to answer r, a request to resume a download of content
+// of size bytes with the given validators, as Negotiate does, with policy for
+// a client that has all of the content already. A client resumes with a
+// Range header for the bytes from what it has on, as 'bytes=N-', or for the
+// last bytes, as 'bytes=-M', and an If-Range with the validator it got with
+// what it has, so that:
+//
+//   - a client with fewer bytes than the content gets the rest, in a 206
+//     with a Content-Range for them;
+//   - a client with all of them is answered as policy says;
+//   - a client with more bytes than the content, which must have changed,
+//     gets a 416, with a Content-Range for the length of the content;
+//   - and a client whose If-Range doesn't match, because the content has
+//     changed since, gets all of it, in a 200, so that it starts over.
+//
+// Requests that aren't to resume, such as those without a Range header, or
+// for other ranges, are answered just as Negotiate answers them.
+func Resume(r *http.Request, size int64, etag string, modtime time.Time, policy CompletePolicy) Decision {
+	return negotiate(r, size, &serveConfig{etag: etag, modtime: modtime, complete: policy})
+}
+
+// resumeOffset returns the number of bytes of content of size bytes that a
+// client with the given Range header is taken to have, if it asks for a single
+// range that runs to the end of the content.
+func resumeOffset(values []string, size int64) (int64, bool) {
+	if len(values) != 1 {
+		return 0, false
+	}
+	v, ok := strings.CutPrefix(values[0], "bytes=")
+	if !ok || strings.IndexByte(v, ',') >= 0 {
+		return 0, false
+	}
+	spec, reason := parseSpec(trimOWS(v))
+	switch {
+	case reason != "":
+		return 0, false
+	case spec.First < 0:
+		return max(size-spec.Last, 0), true
+	case spec.Last < 0:
+		return spec.First, true
+	}
+	return 0, false
+}
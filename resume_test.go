@@ -0,0 +1,140 @@
+package ranger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type resumeTest struct {
+	negotiateTest
+	Policy CompletePolicy
+}
+
+func TestResume(t *testing.T) {
+	tests := []resumeTest{
+		{ // the rest
+			negotiateTest: negotiateTest{
+				Header:               http.Header{"Range": {"bytes=40-"}, "If-Range": {`"v1"`}},
+				ExpectedStatus:       http.StatusPartialContent,
+				ExpectedRanges:       []Range{{Start: 40, Stop: 99}},
+				ExpectedContentRange: "bytes 40-99/100",
+				ExpectedLength:       "60",
+			},
+		},
+		{ // the rest, by suffix
+			negotiateTest: negotiateTest{
+				Header:               http.Header{"Range": {"bytes=-60"}},
+				ExpectedStatus:       http.StatusPartialContent,
+				ExpectedRanges:       []Range{{Start: 40, Stop: 99}},
+				ExpectedContentRange: "bytes 40-99/100",
+				ExpectedLength:       "60",
+			},
+		},
+		{ // changed since, so start over
+			negotiateTest: negotiateTest{
+				Header:         http.Header{"Range": {"bytes=40-"}, "If-Range": {`"v0"`}},
+				ExpectedStatus: http.StatusOK,
+				ExpectedLength: "100",
+			},
+		},
+		{ // complete, unsatisfiable
+			negotiateTest: negotiateTest{
+				Header:               http.Header{"Range": {"bytes=100-"}, "If-Range": {`"v1"`}},
+				ExpectedStatus:       http.StatusRequestedRangeNotSatisfiable,
+				ExpectedContentRange: "bytes */100",
+			},
+			Policy: CompleteUnsatisfiable,
+		},
+		{ // complete, ok
+			negotiateTest: negotiateTest{
+				Header:         http.Header{"Range": {"bytes=100-"}, "If-Range": {`"v1"`}},
+				ExpectedStatus: http.StatusOK,
+				ExpectedLength: "100",
+			},
+			Policy: CompleteOK,
+		},
+		{ // complete by suffix, ok
+			negotiateTest: negotiateTest{
+				Header:         http.Header{"Range": {"bytes=-0"}},
+				ExpectedStatus: http.StatusOK,
+				ExpectedLength: "100",
+			},
+			Policy: CompleteOK,
+		},
+		{ // more than the content
+			negotiateTest: negotiateTest{
+				Header:               http.Header{"Range": {"bytes=150-"}},
+				ExpectedStatus:       http.StatusRequestedRangeNotSatisfiable,
+				ExpectedContentRange: "bytes */100",
+			},
+			Policy: CompleteOK,
+		},
+		{ // not a resume
+			negotiateTest: negotiateTest{
+				Header:               http.Header{"Range": {"bytes=90-99"}},
+				ExpectedStatus:       http.StatusPartialContent,
+				ExpectedRanges:       []Range{{Start: 90, Stop: 99}},
+				ExpectedContentRange: "bytes 90-99/100",
+				ExpectedLength:       "10",
+			},
+			Policy: CompleteOK,
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		for k, vs := range test.Header {
+			req.Header[k] = vs
+		}
+		d := Resume(req, 100, `"v1"`, time.Time{}, test.Policy)
+		if got, want := d.Status, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := d.Ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %v, want %v", i, got, want)
+		}
+		if got, want := d.Header.Get("Content-Range"), test.ExpectedContentRange; got != want {
+			t.Errorf("test %d: bad content range: got %q, want %q", i, got, want)
+		}
+		if got, want := d.Header.Get("Content-Length"), test.ExpectedLength; got != want {
+			t.Errorf("test %d: bad content length: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestResumeEmpty(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-")
+	if got, want := Resume(req, 0, "", time.Time{}, CompleteOK).Status, http.StatusOK; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := Resume(req, 0, "", time.Time{}, CompleteUnsatisfiable).Status, http.StatusRequestedRangeNotSatisfiable; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+}
+
+func TestHandlerCompletePolicy(t *testing.T) {
+	h := Handler(strings.NewReader("0123456789"), 10, WithCompletePolicy(CompleteOK))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=10-")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := rec.Body.String(), "0123456789"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+}
+
+func TestCompletePolicyString(t *testing.T) {
+	if got, want := CompleteOK.String(), "ok"; got != want {
+		t.Errorf("bad string: got %q, want %q", got, want)
+	}
+	if got, want := CompletePolicy(7).String(), "CompletePolicy(7)"; got != want {
+		t.Errorf("bad string: got %q, want %q", got, want)
+	}
+}
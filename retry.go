@@ -0,0 +1,135 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy says how many times to try fetching a range, and how long to
+// wait between tries. The waits grow exponentially, from Backoff up to
+// MaxBackoff, with random jitter, so that many clients failing at once don't
+// all retry at once. The zero value tries once.
+type RetryPolicy struct {
+	// Attempts is the most times to try. If it's zero, 1 is used.
+	Attempts int
+
+	// Backoff is the longest wait before the first retry, which doubles for
+	// each one after it. Each wait is chosen at random from the upper half
+	// of its limit. If it's zero, there's no wait.
+	Backoff time.Duration
+
+	// MaxBackoff is the longest wait before any retry. If it's zero, 30
+	// seconds is used.
+	MaxBackoff time.Duration
+
+	// Retryable reports whether a failed try is worth another. If it's nil,
+	// IsRetryable is used.
+	Retryable func(error) bool
+}
+
+// IsRetryable reports whether err might not happen again, such as a timeout, a
+// broken connection, or a response with a 5xx, 408 or 429 status. Errors that
+// will, such as other 4xx statuses, a changed resource, a server that doesn't
+// support byte ranges, or a cancelled context, aren't retryable.
+func IsRetryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrResourceChanged), errors.Is(err, ErrNotSupported), errors.Is(err, errClosed):
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return se.StatusCode >= 500
+	}
+	return true
+}
+
+// FailedRanges returns the ranges of every *RangeError in err, such as the
+// error from Download or CopyRanges, in order, so that they can be fetched
+// again.
+func FailedRanges(err error) []Range {
+	var result []Range
+	var walk func(error)
+	walk = func(err error) {
+		if rerr, ok := err.(*RangeError); ok {
+			result = append(result, rerr.Range)
+			return
+		}
+		switch err := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, err := range err.Unwrap() {
+				walk(err)
+			}
+		case interface{ Unwrap() error }:
+			walk(err.Unwrap())
+		}
+	}
+	walk(err)
+	return result
+}
+
+// do calls f until it succeeds, fails with an error that isn't retryable, or
+// has been tried as many times as p allows, and returns its last error. It
+// gives up early once ctx is done.
+func (p RetryPolicy) do(ctx context.Context, f func() error) error {
+	attempts := max(p.Attempts, 1)
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := p.wait(ctx, attempt); err != nil {
+				return err
+			}
+		}
+		if err = f(); err == nil || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// wait waits before the given retry, counting from 1, or until ctx is done.
+func (p RetryPolicy) wait(ctx context.Context, retry int) error {
+	d := p.delay(retry)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay returns how long to wait before the given retry, counting from 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+	limit := p.MaxBackoff
+	if limit <= 0 {
+		limit = 30 * time.Second
+	}
+	d := p.Backoff
+	for i := 1; i < retry && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	return d/2 + rand.N(d/2+1)
+}
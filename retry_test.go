@@ -0,0 +1,162 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type retryableTest struct {
+	Err      error
+	Expected bool
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []retryableTest{
+		{ // no error
+			Err:      nil,
+			Expected: false,
+		},
+		{ // server error
+			Err:      &StatusError{StatusCode: http.StatusServiceUnavailable},
+			Expected: true,
+		},
+		{ // too many requests
+			Err:      &StatusError{StatusCode: http.StatusTooManyRequests},
+			Expected: true,
+		},
+		{ // not found
+			Err:      &StatusError{StatusCode: http.StatusNotFound},
+			Expected: false,
+		},
+		{ // broken connection
+			Err:      io.ErrUnexpectedEOF,
+			Expected: true,
+		},
+		{ // corrupted
+			Err:      ErrIntegrity,
+			Expected: true,
+		},
+		{ // changed
+			Err:      fmt.Errorf("oops: %w", ErrResourceChanged),
+			Expected: false,
+		},
+		{ // cancelled
+			Err:      context.Canceled,
+			Expected: false,
+		},
+		{ // timed out
+			Err:      context.DeadlineExceeded,
+			Expected: false,
+		},
+	}
+	for i, test := range tests {
+		if got := IsRetryable(test.Err); got != test.Expected {
+			t.Errorf("test %d: bad result for %v: got %v, want %v", i, test.Err, got, test.Expected)
+		}
+	}
+}
+
+func TestFailedRanges(t *testing.T) {
+	err := errors.Join(
+		&RangeError{Range: Range{Start: 0, Stop: 9}, Err: io.ErrUnexpectedEOF},
+		errors.New("something else"),
+		fmt.Errorf("wrapped: %w", &RangeError{Range: Range{Start: 20, Stop: 29}, Err: io.ErrUnexpectedEOF}),
+	)
+	want := []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 29}}
+	if got := FailedRanges(err); !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+	if got := FailedRanges(nil); got != nil {
+		t.Errorf("bad ranges: got %v, want none", got)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	limits := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, limit := range limits {
+		limit *= time.Millisecond
+		for range 100 {
+			if got := p.delay(i + 1); got < limit/2 || got > limit {
+				t.Errorf("test %d: bad delay: got %v, want between %v and %v", i, got, limit/2, limit)
+				break
+			}
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	retryable := &StatusError{StatusCode: http.StatusBadGateway}
+	calls := 0
+	p := RetryPolicy{Attempts: 3}
+	err := p.do(context.Background(), func() error {
+		calls++
+		return retryable
+	})
+	if err != retryable || calls != 3 {
+		t.Errorf("bad result: got %v after %d calls, want %v after 3", err, calls, retryable)
+	}
+
+	calls = 0
+	err = p.do(context.Background(), func() error {
+		calls++
+		return ErrResourceChanged
+	})
+	if err != ErrResourceChanged || calls != 1 {
+		t.Errorf("bad result: got %v after %d calls, want %v after 1", err, calls, ErrResourceChanged)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	p.Backoff = time.Hour
+	err = p.do(ctx, func() error {
+		calls++
+		cancel()
+		return retryable
+	})
+	if err != context.Canceled || calls != 1 {
+		t.Errorf("bad result: got %v after %d calls, want %v after 1", err, calls, context.Canceled)
+	}
+}
+
+func TestDownloaderRetryPolicy(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	flaky := &flakyHandler{seen: map[string]bool{"bytes=0-0": true}, h: Handler(strings.NewReader(content), 100)}
+	srv := httptest.NewServer(flaky)
+	defer srv.Close()
+
+	dst := memFile(make([]byte, 100))
+	d := &Downloader{Client: srv.Client(), ChunkSize: 30, Retry: RetryPolicy{Attempts: 2, Backoff: time.Millisecond}}
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
+
+func TestHTTPReaderRetry(t *testing.T) {
+	flaky := &flakyHandler{seen: map[string]bool{"bytes=0-0": true}, h: Handler(strings.NewReader("0123456789"), 10)}
+	srv := httptest.NewServer(flaky)
+	defer srv.Close()
+
+	hr, err := NewHTTPReader(srv.Client(), srv.URL, WithRetry(RetryPolicy{Attempts: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 5)
+	if _, err := hr.ReadAt(p, 5); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(p), "56789"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,452 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServeOption configures how ranges are served.
+type ServeOption func(*serveConfig)
+
+type serveConfig struct {
+	boundary     string
+	boundaryFunc func() string
+	contentType  string
+	partType     func(Range) string
+	etag         string
+	etagFunc     ETagFunc
+	modtime      time.Time
+	parse        ParseOptions
+	limitStatus  int
+	limiter      func(*http.Request) Limiter
+	hooks        Hooks
+	order        Order
+	complete     CompletePolicy
+
+	encoding        string
+	noEncodedRanges bool
+	noRanges        bool
+	precompressed   []precompressed
+}
+
+func newServeConfig(opts []ServeOption) *serveConfig {
+	cfg := new(serveConfig)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithBoundary sets the boundary used between the parts of a
+// multipart/byteranges response, rather than a random one. A fixed boundary is
+// mostly useful in tests; since the boundary must not appear in the content,
+// production servers should stick with the random default.
+func WithBoundary(boundary string) ServeOption {
+	return func(c *serveConfig) {
+		c.boundary = boundary
+	}
+}
+
+// WithBoundaryFunc makes a new boundary for each multipart/byteranges response
+// by calling f, which must be safe for concurrent use, rather than choosing one
+// at random. SeededBoundaries makes boundaries that are the same from one run
+// to the next, for golden-file tests.
+func WithBoundaryFunc(f func() string) ServeOption {
+	return func(c *serveConfig) {
+		c.boundaryFunc = f
+	}
+}
+
+// WithContentType sets the Content-Type of the content being served. Without
+// it, Handler serves 'application/octet-stream', and ServeFile guesses from
+// the file's extension.
+func WithContentType(contentType string) ServeOption {
+	return func(c *serveConfig) {
+		c.contentType = contentType
+	}
+}
+
+// WithPartContentType sets the Content-Type of each part of a
+// multipart/byteranges response to f(r), for the range r it carries, as
+// MultipartWriter.SetPartContentType does. Where f returns "", and in the
+// responses that aren't multipart, the Content-Type of the content is used.
+func WithPartContentType(f func(r Range) string) ServeOption {
+	return func(c *serveConfig) {
+		c.partType = f
+	}
+}
+
+// WithETag sets the entity tag of the content, which is sent in the ETag field,
+// and used to evaluate conditional requests. It must be a quoted string, such
+// as '"v1"', with a 'W/' prefix if it's weak.
+func WithETag(etag string) ServeOption {
+	return func(c *serveConfig) {
+		c.etag = etag
+	}
+}
+
+// WithModTime sets the time the content was last modified, which is sent in
+// the Last-Modified field, and used to evaluate conditional requests.
+// ServeFile uses the file's modification time by default.
+func WithModTime(modtime time.Time) ServeOption {
+	return func(c *serveConfig) {
+		c.modtime = modtime
+	}
+}
+
+// WithParseOptions sets the limits and policies for parsing the Range header,
+// such as the most ranges to serve in a single response, the most bytes they
+// may add up to, and whether they may overlap. Without limits, a client can
+// ask for the same bytes over and over, in a single request, which amplifies
+// the traffic a server sends. By default, ranges are parsed as by ParseClamp;
+// they're clamped to the content whether or not opts sets Clamp.
+//
+// A request that exceeds the limits is answered with all of the content, in a
+// 200, as RFC 7233 allows for a Range header the server would rather ignore.
+// WithLimitStatus can change that. Ranges truncated by MaxRangeBytes are
+// served as truncated, in a 206.
+func WithParseOptions(opts ParseOptions) ServeOption {
+	return func(c *serveConfig) {
+		c.parse = opts
+	}
+}
+
+// WithLimitStatus sets the status of the reply to a request that exceeds the
+// limits set by WithParseOptions: either http.StatusOK, to serve all of the
+// content as if there were no Range header, or
+// http.StatusRequestedRangeNotSatisfiable, to refuse it. The default is
+// http.StatusOK.
+func WithLimitStatus(status int) ServeOption {
+	return func(c *serveConfig) {
+		c.limitStatus = status
+	}
+}
+
+// Handler returns an http.Handler that serves size bytes of content, honouring
+// any byte ranges in the request's Range header.
+//
+// A request without a Range header gets a 200 with all of the content. A
+// single range is served as a 206 with a Content-Range header, and several
+// ranges are served as a 206 multipart/byteranges body. A range that runs past
+// the end of the content is served as far as the end, as RFC 7233 requires,
+// and one that starts past the end is dropped; if every range is dropped, the
+// handler replies with a 416. If the Range header is malformed, it's ignored.
+// Every response advertises support for byte ranges with Accept-Ranges. A
+// HEAD request gets the same status and header fields as a GET would, without
+// the content being read.
+//
+// With WithETag, WithETagFunc or WithModTime, conditional requests are
+// evaluated as CheckPreconditions does, and answered with a 304 or 412 where
+// RFC 7232 requires, before any Range header is considered.
+//
+// The content is read with ReadAt, so it may be shared between any number of
+// concurrent requests. Content that can't be read with ReadAt can be served
+// with ContentHandler, or, if it can only be read from start to finish, with
+// StreamHandler.
+func Handler(content io.ReaderAt, size int64, opts ...ServeOption) http.Handler {
+	cfg := newServeConfig(opts)
+	if cfg.contentType == "" {
+		cfg.contentType = "application/octet-stream"
+	}
+	if cfg.etagFunc != nil {
+		cfg.etagFunc = onceETag(cfg.etagFunc)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = serve(w, r, readerAtContent{content, size}, cfg)
+	})
+}
+
+// ServeFile replies to r with the contents of the named file, honouring any
+// byte ranges in the request's Range header, just as Handler does.
+//
+// The file is read with ReadAt, except for a single range or all of it, which
+// is copied straight to w, so that it can be sent with sendfile where the
+// platform has it. The file is closed before ServeFile returns. If
+// the file can't be opened, no response is written and the error is returned,
+// so that the caller can map it to a status; errors.Is(err, fs.ErrNotExist)
+// and errors.Is(err, fs.ErrPermission) report the common cases.
+func ServeFile(w http.ResponseWriter, r *http.Request, path string, opts ...ServeOption) error {
+	cfg := newServeConfig(opts)
+	name := path
+	if len(cfg.precompressed) > 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if alt, altCfg, ok := cfg.alternate(r, path); ok {
+			name, cfg = alt, altCfg
+		}
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return &os.PathError{Op: "serve", Path: path, Err: errors.New("is a directory")}
+	}
+	if cfg.contentType == "" {
+		cfg.contentType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	if cfg.contentType == "" {
+		cfg.contentType = "application/octet-stream"
+	}
+	if cfg.modtime.IsZero() {
+		cfg.modtime = fi.ModTime()
+	}
+	return serve(w, r, fileContent{readerAtContent{f, fi.Size()}, f}, cfg)
+}
+
+// ServeRanges replies to r with the content of a io.ReadSeeker, honouring any
+// byte ranges in the request's Range header. It's a drop-in replacement for
+// http.ServeContent, which serves only the first of several ranges; see Handler
+// for how ranges are served. Like http.ServeContent, it clamps ranges that run
+// past the end of the content, and refuses only those that start past it.
+//
+// As with http.ServeContent, the Content-Type is taken from w's header if it's
+// already set, or else guessed from the extension of name, or else sniffed from
+// the content. If modtime is not the zero time, it's sent as Last-Modified. An
+// If-Range precondition is checked against modtime and any ETag already set in
+// w's header; if it doesn't match, the Range header is ignored and all of the
+// content is served.
+//
+// If content is also an io.ReaderAt, it's read with ReadAt. Otherwise, reads
+// are serialized through Seek and Read.
+func ServeRanges(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker) {
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		http.Error(w, "seeker can't seek", http.StatusInternalServerError)
+		return
+	}
+	src, ok := content.(io.ReaderAt)
+	if !ok {
+		src = &seekReaderAt{rs: content}
+	}
+	cfg := &serveConfig{
+		contentType: w.Header().Get("Content-Type"),
+		etag:        w.Header().Get("Etag"),
+		modtime:     modtime,
+	}
+	if cfg.contentType == "" {
+		cfg.contentType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if cfg.contentType == "" {
+		buf := make([]byte, 512)
+		n, _ := io.ReadFull(NewReader(src, []Range{{Start: 0, Stop: min(size, 512) - 1}}), buf)
+		cfg.contentType = http.DetectContentType(buf[:n])
+	}
+	if modtime.Equal(time.Unix(0, 0)) {
+		cfg.modtime = time.Time{}
+	}
+	_ = serve(w, r, readerAtContent{src, size}, cfg)
+}
+
+// seekReaderAt adapts an io.ReadSeeker to an io.ReaderAt, by serializing
+// calls to Seek and Read.
+type seekReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(s.rs, p)
+}
+
+// serve replies to r with the content of src.
+func serve(w http.ResponseWriter, r *http.Request, src Content, cfg *serveConfig) (err error) {
+	size := src.Size()
+	tracer := cfg.hooks.Tracer
+	if cfg.hooks.OnServed != nil || tracer != nil {
+		start := time.Now()
+		ow := &observedResponseWriter{ResponseWriter: w}
+		w = ow
+		var span Span
+		if tracer != nil {
+			var ctx context.Context
+			ctx, span = tracer.Start(r.Context(), SpanServe, Attr{Key: AttrMethod, Value: r.Method})
+			r = r.WithContext(ctx)
+		}
+		defer func() {
+			if cfg.hooks.OnServed != nil {
+				cfg.hooks.OnServed(r, ow.status, ow.bytes, time.Since(start))
+			}
+			if span != nil {
+				span.SetAttributes(Attr{Key: AttrStatus, Value: ow.status}, Attr{Key: AttrBytes, Value: ow.bytes})
+				span.End(err)
+			}
+		}()
+	}
+	if cfg.limiter != nil {
+		if l := cfg.limiter(r); l != nil {
+			w = &limitedResponseWriter{ResponseWriter: w, body: NewLimitedWriter(r.Context(), w, l)}
+		}
+	}
+	if cfg, err = cfg.withETag(r.Context(), src); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	if cfg.encoding != "" {
+		w.Header().Set("Content-Encoding", cfg.encoding)
+	}
+	if cfg.refusesRanges(w.Header().Get("Content-Encoding")) {
+		cp := *cfg
+		cp.noRanges = true
+		cfg = &cp
+	}
+	var parseSpan Span
+	if tracer != nil && len(r.Header["Range"]) > 0 {
+		_, parseSpan = tracer.Start(r.Context(), SpanParse, Attr{Key: AttrRange, Value: r.Header.Get("Range")})
+	}
+	d := negotiate(r, size, cfg)
+	if parseSpan != nil {
+		parseSpan.SetAttributes(Attr{Key: AttrRangeCount, Value: len(d.Ranges)})
+		parseSpan.End(d.Err)
+	}
+	if d.parsed && d.Err != nil && cfg.hooks.OnRejected != nil {
+		cfg.hooks.OnRejected(r, d.Err)
+	} else if d.parsed && d.Err == nil && cfg.hooks.OnParsed != nil {
+		cfg.hooks.OnParsed(r, d.Ranges)
+	}
+	for k, vs := range d.Header {
+		w.Header()[k] = vs
+	}
+	switch d.Status {
+	case http.StatusNotModified:
+		w.WriteHeader(d.Status)
+		return nil
+	case http.StatusPreconditionFailed:
+		http.Error(w, http.StatusText(d.Status), d.Status)
+		return nil
+	case http.StatusOK:
+		return serveAll(w, r, src, size, cfg.contentType)
+	case http.StatusRequestedRangeNotSatisfiable:
+		w.WriteHeader(d.Status)
+		return nil
+	}
+	ranges := d.Ranges
+	if len(ranges) == 1 {
+		w.Header().Set("Content-Type", cfg.contentType)
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method == http.MethodHead {
+			return nil
+		}
+		return servePart(w, r, src, ranges[0])
+	}
+	mw := NewMultipartWriter(w, size, cfg.contentType)
+	if cfg.partType != nil {
+		mw.SetPartContentType(cfg.partType)
+	}
+	boundary := cfg.boundary
+	if boundary == "" && cfg.boundaryFunc != nil {
+		boundary = cfg.boundaryFunc()
+	}
+	if boundary != "" {
+		if err := mw.SetBoundary(boundary); err != nil {
+			return err
+		}
+	}
+	w.Header().Set("Content-Type", mw.ContentType())
+	w.Header().Set("Content-Length", strconv.FormatInt(mw.Len(ranges), 10))
+	w.WriteHeader(http.StatusPartialContent)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	for _, rng := range ranges {
+		body, err := src.ReadRange(r.Context(), rng)
+		if err != nil {
+			return err
+		}
+		err = mw.WritePart(rng, body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// servePart copies the range rng of src to w, as the body of the response to
+// r. A file is copied straight to w, so that it can be sent with sendfile.
+func servePart(w io.Writer, r *http.Request, src Content, rng Range) error {
+	if fc, ok := src.(fileContent); ok {
+		return fc.copyRange(w, rng)
+	}
+	body, err := src.ReadRange(r.Context(), rng)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// Status returns the status a server should reply with, given the result of
+// parsing a request's Range header: 206 if there are ranges to serve, or 416
+// if they are unsatisfiable. If there are no ranges, or the Range header is
+// malformed and should be ignored, the status is 200.
+func Status(ranges []Range, err error) int {
+	switch {
+	case errors.Is(err, ErrUnsatisfiable):
+		return http.StatusRequestedRangeNotSatisfiable
+	case err != nil, len(ranges) == 0:
+		return http.StatusOK
+	}
+	return http.StatusPartialContent
+}
+
+// WriteUnsatisfiable replies with a 416 Range Not Satisfiable, for content of
+// total bytes. As RFC 7233 requires, the response carries a Content-Range
+// field of the form 'bytes */total', and no content. It also advertises
+// support for byte ranges with Accept-Ranges.
+func WriteUnsatisfiable(w http.ResponseWriter, total int64) {
+	SetAcceptRanges(w.Header())
+	w.Header().Set("Content-Range", UnsatisfiedContentRange(total))
+	w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+}
+
+func serveAll(w http.ResponseWriter, r *http.Request, src Content, size int64, ctype string) error {
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+	if size == 0 || r.Method == http.MethodHead {
+		return nil
+	}
+	return servePart(w, r, src, Range{Start: 0, Stop: size - 1})
+}
+
+// EvaluateIfRange evaluates the value of an If-Range field against the
+// current validators of the content, its ETag and modification time. It
+// reports whether the Range header should be honoured; if not, the server
+// should reply with all of the content, in a 200.
+//
+// As RFC 7233 requires, an entity tag must match etag exactly, and weak tags
+// never match. A date must match modtime exactly, to the second. If header is
+// empty, there's no precondition, and EvaluateIfRange returns true.
+func EvaluateIfRange(header string, etag string, modtime time.Time) bool {
+	if header == "" {
+		return true
+	}
+	if strings.HasPrefix(header, `"`) || strings.HasPrefix(header, "W/") {
+		return etag != "" && !strings.HasPrefix(etag, "W/") && header == etag
+	}
+	t, err := http.ParseTime(header)
+	if err != nil || modtime.IsZero() {
+		return false
+	}
+	return t.Equal(modtime.Truncate(time.Second))
+}
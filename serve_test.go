@@ -0,0 +1,631 @@
+package ranger
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type serveFileTest struct {
+	Range                string
+	ExpectedStatus       int
+	ExpectedContentRange string
+	ExpectedBody         string
+}
+
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestServeFile(t *testing.T) {
+	path := writeTestFile(t, "0123456789")
+	tests := []serveFileTest{
+		{ // no range
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "0123456789",
+		},
+		{ // single range
+			Range:                "bytes=2-4",
+			ExpectedStatus:       http.StatusPartialContent,
+			ExpectedContentRange: "bytes 2-4/10",
+			ExpectedBody:         "234",
+		},
+		{ // suffix range
+			Range:                "bytes=-3",
+			ExpectedStatus:       http.StatusPartialContent,
+			ExpectedContentRange: "bytes 7-9/10",
+			ExpectedBody:         "789",
+		},
+		{ // the whole file is still a partial response
+			Range:                "bytes=0-",
+			ExpectedStatus:       http.StatusPartialContent,
+			ExpectedContentRange: "bytes 0-9/10",
+			ExpectedBody:         "0123456789",
+		},
+		{ // unsatisfiable
+			Range:                "bytes=20-30",
+			ExpectedStatus:       http.StatusRequestedRangeNotSatisfiable,
+			ExpectedContentRange: "bytes */10",
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.Range != "" {
+			req.Header.Set("Range", test.Range)
+		}
+		rec := httptest.NewRecorder()
+		if err := ServeFile(rec, req, path); err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if got, want := rec.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := rec.Header().Get("Content-Range"), test.ExpectedContentRange; got != want {
+			t.Errorf("test %d: bad content range: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Header().Get("Accept-Ranges"), "bytes"; got != want {
+			t.Errorf("test %d: bad accept ranges: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestHandlerBoundaryFunc(t *testing.T) {
+	boundaries := SeededBoundaries(1)
+	want := []string{boundaries(), boundaries()}
+	h := Handler(strings.NewReader("0123456789"), 10, WithBoundaryFunc(SeededBoundaries(1)))
+	for i := range want {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Range", "bytes=0-0,-1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		_, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := params["boundary"]; got != want[i] {
+			t.Errorf("response %d: bad boundary: got %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+type handlerTest struct {
+	Range                string
+	ExpectedStatus       int
+	ExpectedContentRange string
+	ExpectedLength       string
+	ExpectedBody         string
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler(strings.NewReader("0123456789"), 10, WithContentType("text/plain"), WithBoundary("B"))
+	tests := []handlerTest{
+		{ // no range
+			ExpectedStatus: http.StatusOK,
+			ExpectedLength: "10",
+			ExpectedBody:   "0123456789",
+		},
+		{ // single range
+			Range:                "bytes=3-5",
+			ExpectedStatus:       http.StatusPartialContent,
+			ExpectedContentRange: "bytes 3-5/10",
+			ExpectedLength:       "3",
+			ExpectedBody:         "345",
+		},
+		{ // several ranges
+			Range:          "bytes=0-0,-1",
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedLength: "137",
+			ExpectedBody: "--B\r\nContent-Range: bytes 0-0/10\r\nContent-Type: text/plain\r\n\r\n0\r\n" +
+				"--B\r\nContent-Range: bytes 9-9/10\r\nContent-Type: text/plain\r\n\r\n9\r\n--B--\r\n",
+		},
+		{ // unsatisfiable
+			Range:                "bytes=10-",
+			ExpectedStatus:       http.StatusRequestedRangeNotSatisfiable,
+			ExpectedContentRange: "bytes */10",
+		},
+		{ // past the end, so clamped
+			Range:                "bytes=0-999",
+			ExpectedStatus:       http.StatusPartialContent,
+			ExpectedContentRange: "bytes 0-9/10",
+			ExpectedLength:       "10",
+			ExpectedBody:         "0123456789",
+		},
+		{ // one satisfiable, one not
+			Range:                "bytes=0-9,500-600",
+			ExpectedStatus:       http.StatusPartialContent,
+			ExpectedContentRange: "bytes 0-9/10",
+			ExpectedLength:       "10",
+			ExpectedBody:         "0123456789",
+		},
+		{ // malformed
+			Range:          "bytes=5-3",
+			ExpectedStatus: http.StatusOK,
+			ExpectedLength: "10",
+			ExpectedBody:   "0123456789",
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.Range != "" {
+			req.Header.Set("Range", test.Range)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := rec.Header().Get("Content-Range"), test.ExpectedContentRange; got != want {
+			t.Errorf("test %d: bad content range: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Header().Get("Content-Length"), test.ExpectedLength; got != want {
+			t.Errorf("test %d: bad content length: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Header().Get("Accept-Ranges"), "bytes"; got != want {
+			t.Errorf("test %d: bad accept ranges: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestHandlerMaxRangeBytes(t *testing.T) {
+	h := Handler(strings.NewReader("0123456789"), 10, WithParseOptions(ParseOptions{MaxRangeBytes: 4}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=2-")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusPartialContent; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Content-Range"), "bytes 2-5/10"; got != want {
+		t.Errorf("bad content range: got %q, want %q", got, want)
+	}
+	if got, want := rec.Body.String(), "2345"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+}
+
+func TestHandlerPartContentType(t *testing.T) {
+	partType := func(r Range) string {
+		if r.Start >= 5 {
+			return "image/png"
+		}
+		return ""
+	}
+	h := Handler(strings.NewReader("0123456789"), 10, WithContentType("text/plain"), WithBoundary("B"), WithPartContentType(partType))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-1,5-6")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	want := "--B\r\nContent-Range: bytes 0-1/10\r\nContent-Type: text/plain\r\n\r\n01\r\n" +
+		"--B\r\nContent-Range: bytes 5-6/10\r\nContent-Type: image/png\r\n\r\n56\r\n--B--\r\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if got, want := rec.Header().Get("Content-Length"), strconv.Itoa(len(want)); got != want {
+		t.Errorf("bad content length: got %s, want %s", got, want)
+	}
+
+	// A single range is served with the type of the content.
+	req.Header.Set("Range", "bytes=5-6")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Header().Get("Content-Type"), "text/plain"; got != want {
+		t.Errorf("bad content type: got %q, want %q", got, want)
+	}
+}
+
+// failingReaderAt fails every read.
+type failingReaderAt struct{}
+
+func (failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errors.New("content read")
+}
+
+func TestHandlerHead(t *testing.T) {
+	content := "0123456789"
+	get := Handler(strings.NewReader(content), 10, WithBoundary("B"))
+	head := Handler(failingReaderAt{}, 10, WithBoundary("B"))
+	for _, rng := range []string{"", "bytes=3-5", "bytes=0-0,-1", "bytes=10-"} {
+		getReq := httptest.NewRequest("GET", "/", nil)
+		headReq := httptest.NewRequest("HEAD", "/", nil)
+		if rng != "" {
+			getReq.Header.Set("Range", rng)
+			headReq.Header.Set("Range", rng)
+		}
+		getRec, headRec := httptest.NewRecorder(), httptest.NewRecorder()
+		get.ServeHTTP(getRec, getReq)
+		head.ServeHTTP(headRec, headReq)
+		if got, want := headRec.Code, getRec.Code; got != want {
+			t.Errorf("%q: bad status: got %d, want %d", rng, got, want)
+		}
+		if got, want := headRec.Header(), getRec.Header(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: bad header: got %v, want %v", rng, got, want)
+		}
+		if got := headRec.Body.String(); got != "" {
+			t.Errorf("%q: unexpected body: %q", rng, got)
+		}
+	}
+}
+
+type limitTest struct {
+	Options        []ServeOption
+	Range          string
+	ExpectedStatus int
+}
+
+func TestHandlerLimits(t *testing.T) {
+	tests := []limitTest{
+		{ // within the limits
+			Options:        []ServeOption{WithParseOptions(ParseOptions{MaxRanges: 2, MaxBytes: 4})},
+			Range:          "bytes=0-1,5-6",
+			ExpectedStatus: http.StatusPartialContent,
+		},
+		{ // too many ranges
+			Options:        []ServeOption{WithParseOptions(ParseOptions{MaxRanges: 2})},
+			Range:          "bytes=0-0,2-2,4-4",
+			ExpectedStatus: http.StatusOK,
+		},
+		{ // too many bytes
+			Options:        []ServeOption{WithParseOptions(ParseOptions{MaxBytes: 5})},
+			Range:          "bytes=0-9",
+			ExpectedStatus: http.StatusOK,
+		},
+		{ // overlapping ranges refused
+			Options:        []ServeOption{WithParseOptions(ParseOptions{RejectOverlap: true})},
+			Range:          "bytes=0-5,3-9",
+			ExpectedStatus: http.StatusOK,
+		},
+		{ // refused with a 416
+			Options: []ServeOption{
+				WithParseOptions(ParseOptions{RejectOverlap: true}),
+				WithLimitStatus(http.StatusRequestedRangeNotSatisfiable),
+			},
+			Range:          "bytes=0-5,3-9",
+			ExpectedStatus: http.StatusRequestedRangeNotSatisfiable,
+		},
+	}
+	for i, test := range tests {
+		h := Handler(strings.NewReader("0123456789"), 10, test.Options...)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Range", test.Range)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestServeFileStream(t *testing.T) {
+	path := writeTestFile(t, "0123456789")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := ServeFile(w, r, path); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+	for _, rng := range []string{"", "bytes=3-", "bytes=0-0"} {
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "0123456789"
+		if rng != "" {
+			r, _ := ParseOne(rng, 10)
+			want = want[r.Start : r.Stop+1]
+		}
+		if got := string(b); got != want {
+			t.Errorf("%q: bad body: got %q, want %q", rng, got, want)
+		}
+	}
+}
+
+func TestServeFileMultipart(t *testing.T) {
+	path := writeTestFile(t, "0123456789")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-1,8-9")
+	rec := httptest.NewRecorder()
+	if err := ServeFile(rec, req, path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rec.Code, http.StatusPartialContent; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "multipart/byteranges; boundary=") {
+		t.Errorf("bad content type: %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"Content-Range: bytes 0-1/10\r\n", "Content-Range: bytes 8-9/10\r\n", "\r\n\r\n01\r\n", "\r\n\r\n89\r\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestServeFileBoundary(t *testing.T) {
+	path := writeTestFile(t, "0123456789")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-1,8-9")
+	rec := httptest.NewRecorder()
+	if err := ServeFile(rec, req, path, WithBoundary("BOUNDARY")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rec.Header().Get("Content-Type"), "multipart/byteranges; boundary=BOUNDARY"; got != want {
+		t.Errorf("bad content type: got %q, want %q", got, want)
+	}
+	want := "--BOUNDARY\r\n" +
+		"Content-Range: bytes 0-1/10\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"01\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Range: bytes 8-9/10\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"89\r\n" +
+		"--BOUNDARY--\r\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+}
+
+func TestServeFileNotFound(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	err := ServeFile(rec, req, filepath.Join(t.TempDir(), "missing"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("bad error: got %v, want %v", err, fs.ErrNotExist)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("unexpected body: %q", rec.Body.String())
+	}
+}
+
+func TestWriteUnsatisfiable(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteUnsatisfiable(rec, 1234)
+	if got, want := rec.Code, http.StatusRequestedRangeNotSatisfiable; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Content-Range"), "bytes */1234"; got != want {
+		t.Errorf("bad content range: got %q, want %q", got, want)
+	}
+	if got, want := rec.Header().Get("Accept-Ranges"), "bytes"; got != want {
+		t.Errorf("bad accept ranges: got %q, want %q", got, want)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("unexpected body: %q", rec.Body.String())
+	}
+}
+
+type serveRangesTest struct {
+	Range          string
+	IfRange        string
+	ExpectedStatus int
+	ExpectedBody   string
+}
+
+func TestServeRanges(t *testing.T) {
+	modtime := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []serveRangesTest{
+		{ // no range
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "0123456789",
+		},
+		{ // single range
+			Range:          "bytes=3-5",
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   "345",
+		},
+		{ // matching entity tag
+			Range:          "bytes=3-5",
+			IfRange:        `"v1"`,
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   "345",
+		},
+		{ // mismatched entity tag
+			Range:          "bytes=3-5",
+			IfRange:        `"v2"`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "0123456789",
+		},
+		{ // matching date
+			Range:          "bytes=3-5",
+			IfRange:        modtime.Format(http.TimeFormat),
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   "345",
+		},
+		{ // mismatched date
+			Range:          "bytes=3-5",
+			IfRange:        modtime.Add(time.Hour).Format(http.TimeFormat),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "0123456789",
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.Range != "" {
+			req.Header.Set("Range", test.Range)
+		}
+		if test.IfRange != "" {
+			req.Header.Set("If-Range", test.IfRange)
+		}
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Etag", `"v1"`)
+		ServeRanges(rec, req, "test.txt", modtime, onlyReadSeeker{strings.NewReader("0123456789")})
+		if got, want := rec.Code, test.ExpectedStatus; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := rec.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Header().Get("Content-Type"), "text/plain; charset=utf-8"; got != want {
+			t.Errorf("test %d: bad content type: got %q, want %q", i, got, want)
+		}
+		if got, want := rec.Header().Get("Last-Modified"), "Mon, 02 Jan 2017 03:04:05 GMT"; got != want {
+			t.Errorf("test %d: bad last modified: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestServeRangesLikeServeContent(t *testing.T) {
+	for i, value := range []string{"bytes=0-999", "bytes=3-500", "bytes=0-3,500-600", "bytes=500-600", "bytes=-999"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Range", value)
+		got, want := httptest.NewRecorder(), httptest.NewRecorder()
+		ServeRanges(got, req, "test.txt", time.Time{}, strings.NewReader("0123456789"))
+		http.ServeContent(want, req, "test.txt", time.Time{}, strings.NewReader("0123456789"))
+		if got, want := got.Code, want.Code; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+		if got, want := got.Header().Get("Content-Range"), want.Header().Get("Content-Range"); got != want {
+			t.Errorf("test %d: bad content range: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestServeRangesSniff(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-0,-1")
+	rec := httptest.NewRecorder()
+	ServeRanges(rec, req, "data", time.Time{}, strings.NewReader("<html></html>"))
+	if got, want := rec.Code, http.StatusPartialContent; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "Content-Type: text/html; charset=utf-8") {
+		t.Errorf("content type not sniffed:\n%s", body)
+	}
+	if got := rec.Header().Get("Last-Modified"); got != "" {
+		t.Errorf("unexpected last modified: %q", got)
+	}
+}
+
+// onlyReadSeeker hides any methods besides Read and Seek.
+type onlyReadSeeker struct {
+	io.ReadSeeker
+}
+
+type statusTest struct {
+	Ranges   []Range
+	Err      error
+	Expected int
+}
+
+func TestStatus(t *testing.T) {
+	tests := []statusTest{
+		{ // no ranges
+			Ranges:   []Range{},
+			Expected: http.StatusOK,
+		},
+		{ // ranges
+			Ranges:   []Range{{Start: 0, Stop: 9}},
+			Expected: http.StatusPartialContent,
+		},
+		{ // unsatisfiable
+			Err:      ErrUnsatisfiable,
+			Expected: http.StatusRequestedRangeNotSatisfiable,
+		},
+		{ // malformed ranges are ignored
+			Err:      ErrMalformed,
+			Expected: http.StatusOK,
+		},
+	}
+	for i, test := range tests {
+		if got, want := Status(test.Ranges, test.Err), test.Expected; got != want {
+			t.Errorf("test %d: bad status: got %d, want %d", i, got, want)
+		}
+	}
+}
+
+type ifRangeTest struct {
+	Header   string
+	ETag     string
+	ModTime  time.Time
+	Expected bool
+}
+
+func TestEvaluateIfRange(t *testing.T) {
+	modtime := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []ifRangeTest{
+		{ // no precondition
+			ETag:     `"v1"`,
+			Expected: true,
+		},
+		{ // matching entity tag
+			Header:   `"v1"`,
+			ETag:     `"v1"`,
+			Expected: true,
+		},
+		{ // mismatched entity tag
+			Header:   `"v2"`,
+			ETag:     `"v1"`,
+			Expected: false,
+		},
+		{ // weak entity tags never match
+			Header:   `W/"v1"`,
+			ETag:     `W/"v1"`,
+			Expected: false,
+		},
+		{ // no entity tag
+			Header:   `"v1"`,
+			Expected: false,
+		},
+		{ // matching date
+			Header:   "Mon, 02 Jan 2017 03:04:05 GMT",
+			ModTime:  modtime.Add(500 * time.Millisecond),
+			Expected: true,
+		},
+		{ // older date
+			Header:   "Mon, 02 Jan 2017 03:04:04 GMT",
+			ModTime:  modtime,
+			Expected: false,
+		},
+		{ // no modification time
+			Header:   "Mon, 02 Jan 2017 03:04:05 GMT",
+			Expected: false,
+		},
+		{ // garbage
+			Header:   "yesterday",
+			ModTime:  modtime,
+			Expected: false,
+		},
+	}
+	for i, test := range tests {
+		if got, want := EvaluateIfRange(test.Header, test.ETag, test.ModTime), test.Expected; got != want {
+			t.Errorf("test %d: bad result: got %v, want %v", i, got, want)
+		}
+	}
+}
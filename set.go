@@ -0,0 +1,160 @@
+package ranger
+
+import (
+	"cmp"
+	"slices"
+)
+
+// BoundingRange returns the smallest single range that covers every byte in
+// rs, along with the number of bytes inside it that rs doesn't cover. It's
+// useful for answering a multi-range request with a single part.
+//
+// If rs is empty, BoundingRange returns the zero Range and 0.
+func BoundingRange(rs []Range) (Range, int64) {
+	if len(rs) == 0 {
+		return Range{}, 0
+	}
+	bound := rs[0]
+	for _, r := range rs[1:] {
+		bound.Start = min(bound.Start, r.Start)
+		bound.Stop = max(bound.Stop, r.Stop)
+	}
+	covered := int64(0)
+	for _, r := range Merge(rs) {
+		covered += r.Stop - r.Start + 1
+	}
+	return bound, bound.Stop - bound.Start + 1 - covered
+}
+
+// Merge normalizes ranges, returning them sorted in ascending order of Start,
+// with any overlapping or adjacent ranges coalesced into one. The result never
+// contains two ranges that could be merged, so Merge is idempotent. Reversed
+// ranges are dropped, as MergeIntervals drops them.
+//
+// Merge works on a copy of ranges, which is left untouched.
+func Merge(ranges []Range) []Range {
+	return Coalesce(ranges, 0)
+}
+
+// Sort sorts ranges in place, in ascending order of Start, and then of Stop,
+// which is the order Merge returns them in.
+func Sort(ranges []Range) {
+	slices.SortFunc(ranges, compareRanges)
+}
+
+// Normalize checks ranges, and merges them as Merge does, for ranges that were
+// built rather than parsed. A range that's reversed, or starts before 0, is
+// reported as a *RangeError wrapping ErrMalformed. If length isn't negative,
+// it's the length of the content, and a range that extends past the end of it
+// is reported as a *RangeError wrapping ErrUnsatisfiable.
+//
+// Normalize works on a copy of ranges, which is left untouched.
+func Normalize(ranges []Range, length int64) ([]Range, error) {
+	for _, r := range ranges {
+		switch {
+		case r.Start < 0 || r.Start > r.Stop:
+			return nil, &RangeError{Range: r, Err: ErrMalformed}
+		case length >= 0 && r.Stop >= length:
+			return nil, &RangeError{Range: r, Err: ErrUnsatisfiable}
+		}
+	}
+	return Merge(ranges), nil
+}
+
+// Coalesce is like Merge, but also merges ranges separated by gaps of up to
+// maxGap bytes, so that the result covers those gaps too. Reading a few extra
+// bytes is often cheaper than the extra seeks, parts or backend requests that
+// separate ranges would cost. With a maxGap of 0, Coalesce is the same as
+// Merge.
+func Coalesce(ranges []Range, maxGap int64) []Range {
+	return coalesceSpans(slices.Clone(ranges), maxGap)
+}
+
+// Equal reports whether a and b cover exactly the same bytes, regardless of
+// how the ranges are ordered or split up.
+func Equal(a, b []Range) bool {
+	return slices.Equal(Merge(a), Merge(b))
+}
+
+// IsCanonical reports whether ranges is already normalized, as by Merge: that
+// is, sorted in ascending order, with no ranges that overlap or touch.
+func IsCanonical(ranges []Range) bool {
+	for i, r := range ranges {
+		if r.Start > r.Stop {
+			return false
+		}
+		if i > 0 && ranges[i-1].Stop+1 >= r.Start {
+			return false
+		}
+	}
+	return true
+}
+
+// Subtract returns the parts of a that aren't covered by any range in b,
+// merged and sorted as by Merge. A range in a is split in two if a range in b
+// falls in its middle, and dropped if b covers it entirely.
+func Subtract(a, b []Range) []Range {
+	return subtractSpans[int64](a, b)
+}
+
+// Gaps returns the parts of content of length bytes that aren't covered by any
+// of the given ranges, such as the ranges still to be fetched when resuming a
+// download. The result is sorted and merged as by Merge. Ranges, or parts of
+// them, that fall outside of the content are ignored.
+func Gaps(ranges []Range, length int64) []Range {
+	if length <= 0 {
+		return nil
+	}
+	return Subtract([]Range{{Start: 0, Stop: length - 1}}, ranges)
+}
+
+// HasOverlap reports whether any two of the given ranges overlap. The ranges
+// don't need to be sorted, and are left untouched.
+func HasOverlap(ranges []Range) bool {
+	sorted := sortedCopy(ranges)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1].Overlaps(sorted[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindOverlaps returns every pair of the given ranges that overlap, without
+// merging them. Each pair is ordered such that pair[0] sorts before pair[1],
+// and the pairs are sorted the same way. The ranges don't need to be sorted,
+// and are left untouched.
+func FindOverlaps(ranges []Range) [][2]Range {
+	sorted := sortedCopy(ranges)
+	var result [][2]Range
+	for i, r := range sorted {
+		for _, c := range sorted[i+1:] {
+			if c.Start > r.Stop {
+				break
+			}
+			result = append(result, [2]Range{r, c})
+		}
+	}
+	return result
+}
+
+func sortedCopy(ranges []Range) []Range {
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, compareRanges)
+	return sorted
+}
+
+func (r Range) bounds() (int64, int64) {
+	return r.Start, r.Stop
+}
+
+func (r Range) withBounds(start, stop int64) Range {
+	return Range{Start: start, Stop: stop}
+}
+
+func compareRanges(a, b Range) int {
+	if c := cmp.Compare(a.Start, b.Start); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Stop, b.Stop)
+}
@@ -0,0 +1,470 @@
+package ranger
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type boundingRangeTest struct {
+	Ranges         []Range
+	ExpectedRange  Range
+	ExpectedWasted int64
+}
+
+func TestBoundingRange(t *testing.T) {
+	tests := []boundingRangeTest{
+		{ // two disjoint ranges
+			Ranges:         []Range{{Start: 200, Stop: 299}, {Start: 0, Stop: 99}},
+			ExpectedRange:  Range{Start: 0, Stop: 299},
+			ExpectedWasted: 100,
+		},
+		{ // overlapping ranges waste nothing
+			Ranges:         []Range{{Start: 0, Stop: 99}, {Start: 50, Stop: 149}},
+			ExpectedRange:  Range{Start: 0, Stop: 149},
+			ExpectedWasted: 0,
+		},
+		{ // nested ranges waste nothing
+			Ranges:         []Range{{Start: 0, Stop: 99}, {Start: 10, Stop: 20}, {Start: 150, Stop: 199}},
+			ExpectedRange:  Range{Start: 0, Stop: 199},
+			ExpectedWasted: 50,
+		},
+		{ // single range
+			Ranges:         []Range{{Start: 10, Stop: 20}},
+			ExpectedRange:  Range{Start: 10, Stop: 20},
+			ExpectedWasted: 0,
+		},
+		{ // empty
+			ExpectedRange:  Range{},
+			ExpectedWasted: 0,
+		},
+	}
+	for i, test := range tests {
+		r, wasted := BoundingRange(test.Ranges)
+		if got, want := r, test.ExpectedRange; got != want {
+			t.Errorf("test %d: bad range: got %+v, want %+v", i, got, want)
+		}
+		if got, want := wasted, test.ExpectedWasted; got != want {
+			t.Errorf("test %d: bad wasted bytes: got %d, want %d", i, got, want)
+		}
+	}
+}
+
+type overlapTest struct {
+	Ranges           []Range
+	ExpectedOverlaps [][2]Range
+}
+
+func TestFindOverlaps(t *testing.T) {
+	tests := []overlapTest{
+		{ // nested
+			Ranges: []Range{{Start: 10, Stop: 20}, {Start: 0, Stop: 99}},
+			ExpectedOverlaps: [][2]Range{
+				{{Start: 0, Stop: 99}, {Start: 10, Stop: 20}},
+			},
+		},
+		{ // partially overlapping
+			Ranges: []Range{{Start: 50, Stop: 149}, {Start: 0, Stop: 99}, {Start: 140, Stop: 199}},
+			ExpectedOverlaps: [][2]Range{
+				{{Start: 0, Stop: 99}, {Start: 50, Stop: 149}},
+				{{Start: 50, Stop: 149}, {Start: 140, Stop: 199}},
+			},
+		},
+		{ // one range overlapping several others
+			Ranges: []Range{{Start: 0, Stop: 99}, {Start: 10, Stop: 19}, {Start: 30, Stop: 39}},
+			ExpectedOverlaps: [][2]Range{
+				{{Start: 0, Stop: 99}, {Start: 10, Stop: 19}},
+				{{Start: 0, Stop: 99}, {Start: 30, Stop: 39}},
+			},
+		},
+		{ // disjoint
+			Ranges: []Range{{Start: 100, Stop: 199}, {Start: 0, Stop: 99}},
+		},
+		{ // empty
+		},
+	}
+	for i, test := range tests {
+		orig := append([]Range(nil), test.Ranges...)
+		if got, want := FindOverlaps(test.Ranges), test.ExpectedOverlaps; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad overlaps: got %+v, want %+v", i, got, want)
+		}
+		if got, want := HasOverlap(test.Ranges), len(test.ExpectedOverlaps) > 0; got != want {
+			t.Errorf("test %d: bad HasOverlap: got %v, want %v", i, got, want)
+		}
+		if got, want := test.Ranges, orig; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: input modified: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type mergeTest struct {
+	Ranges         []Range
+	ExpectedRanges []Range
+}
+
+func TestMerge(t *testing.T) {
+	tests := []mergeTest{
+		{ // overlapping
+			Ranges:         []Range{{Start: 50, Stop: 149}, {Start: 0, Stop: 99}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 149}},
+		},
+		{ // adjacent
+			Ranges:         []Range{{Start: 51, Stop: 99}, {Start: 0, Stop: 50}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+		},
+		{ // nested
+			Ranges:         []Range{{Start: 0, Stop: 99}, {Start: 10, Stop: 20}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+		},
+		{ // disjoint
+			Ranges:         []Range{{Start: 200, Stop: 299}, {Start: 0, Stop: 98}, {Start: 100, Stop: 149}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 98}, {Start: 100, Stop: 149}, {Start: 200, Stop: 299}},
+		},
+		{ // single
+			Ranges:         []Range{{Start: 0, Stop: 99}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+		},
+		{ // nested at the largest offset
+			Ranges:         []Range{{Start: 0, Stop: math.MaxInt64}, {Start: 5, Stop: 6}},
+			ExpectedRanges: []Range{{Start: 0, Stop: math.MaxInt64}},
+		},
+		{ // adjacent at the largest offset
+			Ranges:         []Range{{Start: math.MaxInt64, Stop: math.MaxInt64}, {Start: 0, Stop: math.MaxInt64 - 1}},
+			ExpectedRanges: []Range{{Start: 0, Stop: math.MaxInt64}},
+		},
+		{ // empty
+		},
+	}
+	for i, test := range tests {
+		merged := Merge(test.Ranges)
+		if got, want := merged, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+		if got, want := Merge(merged), merged; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: merge not idempotent: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	ranges := []Range{{Start: 5, Stop: 9}, {Start: 0, Stop: 9}, {Start: 0, Stop: 4}}
+	Sort(ranges)
+	want := []Range{{Start: 0, Stop: 4}, {Start: 0, Stop: 9}, {Start: 5, Stop: 9}}
+	if got := ranges; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+}
+
+type normalizeTest struct {
+	Ranges         []Range
+	Length         int64
+	ExpectedRanges []Range
+	ExpectedError  string
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []normalizeTest{
+		{ // merged
+			Ranges:         []Range{{Start: 50, Stop: 99}, {Start: 0, Stop: 49}},
+			Length:         100,
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+			ExpectedError:  "<nil>",
+		},
+		{ // unknown length
+			Ranges:         []Range{{Start: 1000, Stop: 1999}},
+			Length:         -1,
+			ExpectedRanges: []Range{{Start: 1000, Stop: 1999}},
+			ExpectedError:  "<nil>",
+		},
+		{ // past the end
+			Ranges:        []Range{{Start: 0, Stop: 9}, {Start: 90, Stop: 100}},
+			Length:        100,
+			ExpectedError: "range 90-100: invalid range: unsatisfiable",
+		},
+		{ // reversed
+			Ranges:        []Range{{Start: 9, Stop: 0}},
+			Length:        -1,
+			ExpectedError: "range 9-0: invalid range: malformed",
+		},
+		{ // negative
+			Ranges:        []Range{{Start: -1, Stop: 0}},
+			Length:        100,
+			ExpectedError: "range -1-0: invalid range: malformed",
+		},
+	}
+	for i, test := range tests {
+		ranges, err := Normalize(test.Ranges, test.Length)
+		if got, want := ranges, test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestMergeDoesNotModifyInput(t *testing.T) {
+	ranges := []Range{{Start: 200, Stop: 299}, {Start: 0, Stop: 99}, {Start: 50, Stop: 149}}
+	orig := append([]Range(nil), ranges...)
+	merged := Merge(ranges)
+	if got, want := merged, []Range{{Start: 0, Stop: 149}, {Start: 200, Stop: 299}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %+v, want %+v", got, want)
+	}
+	if got, want := ranges, orig; !reflect.DeepEqual(got, want) {
+		t.Errorf("input modified: got %+v, want %+v", got, want)
+	}
+	BoundingRange(ranges)
+	if got, want := ranges, orig; !reflect.DeepEqual(got, want) {
+		t.Errorf("input modified by BoundingRange: got %+v, want %+v", got, want)
+	}
+	single := []Range{{Start: 0, Stop: 99}}
+	Merge(single)[0].Stop = 9
+	if got, want := single[0], (Range{Start: 0, Stop: 99}); got != want {
+		t.Errorf("input shared by the result: got %+v, want %+v", got, want)
+	}
+}
+
+type coalesceTest struct {
+	Ranges         []Range
+	MaxGap         int64
+	ExpectedRanges []Range
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := []coalesceTest{
+		{ // gap within tolerance
+			Ranges:         []Range{{Start: 101, Stop: 200}, {Start: 0, Stop: 99}},
+			MaxGap:         16 << 10,
+			ExpectedRanges: []Range{{Start: 0, Stop: 200}},
+		},
+		{ // gap exactly at tolerance
+			Ranges:         []Range{{Start: 0, Stop: 99}, {Start: 110, Stop: 199}},
+			MaxGap:         10,
+			ExpectedRanges: []Range{{Start: 0, Stop: 199}},
+		},
+		{ // gap just past tolerance
+			Ranges:         []Range{{Start: 0, Stop: 99}, {Start: 111, Stop: 199}},
+			MaxGap:         10,
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}, {Start: 111, Stop: 199}},
+		},
+		{ // no tolerance is Merge
+			Ranges:         []Range{{Start: 0, Stop: 99}, {Start: 100, Stop: 199}, {Start: 201, Stop: 299}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 199}, {Start: 201, Stop: 299}},
+		},
+		{ // chained
+			Ranges:         []Range{{Start: 0, Stop: 9}, {Start: 15, Stop: 19}, {Start: 25, Stop: 29}},
+			MaxGap:         5,
+			ExpectedRanges: []Range{{Start: 0, Stop: 29}},
+		},
+		{ // the largest gap
+			Ranges:         []Range{{Start: math.MaxInt64, Stop: math.MaxInt64}, {Start: 0, Stop: 0}},
+			MaxGap:         math.MaxInt64,
+			ExpectedRanges: []Range{{Start: 0, Stop: math.MaxInt64}},
+		},
+	}
+	for i, test := range tests {
+		if got, want := Coalesce(test.Ranges, test.MaxGap), test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type subtractTest struct {
+	A, B           []Range
+	ExpectedRanges []Range
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []subtractTest{
+		{ // hole in the middle
+			A:              []Range{{Start: 0, Stop: 99}},
+			B:              []Range{{Start: 40, Stop: 59}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 39}, {Start: 60, Stop: 99}},
+		},
+		{ // several holes
+			A:              []Range{{Start: 0, Stop: 99}},
+			B:              []Range{{Start: 60, Stop: 69}, {Start: 10, Stop: 19}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}, {Start: 20, Stop: 59}, {Start: 70, Stop: 99}},
+		},
+		{ // partial overlap at the edges
+			A:              []Range{{Start: 0, Stop: 99}, {Start: 200, Stop: 299}},
+			B:              []Range{{Start: 50, Stop: 249}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 49}, {Start: 250, Stop: 299}},
+		},
+		{ // fully covered
+			A:              []Range{{Start: 10, Stop: 19}, {Start: 30, Stop: 39}},
+			B:              []Range{{Start: 0, Stop: 99}},
+			ExpectedRanges: nil,
+		},
+		{ // exactly covered
+			A:              []Range{{Start: 10, Stop: 19}},
+			B:              []Range{{Start: 10, Stop: 19}},
+			ExpectedRanges: nil,
+		},
+		{ // b empty
+			A:              []Range{{Start: 50, Stop: 99}, {Start: 0, Stop: 59}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+		},
+		{ // disjoint
+			A:              []Range{{Start: 0, Stop: 9}},
+			B:              []Range{{Start: 10, Stop: 19}},
+			ExpectedRanges: []Range{{Start: 0, Stop: 9}},
+		},
+		{ // a empty
+			B:              []Range{{Start: 10, Stop: 19}},
+			ExpectedRanges: nil,
+		},
+	}
+	for i, test := range tests {
+		if got, want := Subtract(test.A, test.B), test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad ranges: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type gapsTest struct {
+	Ranges         []Range
+	Length         int64
+	ExpectedRanges []Range
+}
+
+func TestGaps(t *testing.T) {
+	tests := []gapsTest{
+		{ // nothing fetched
+			Length:         100,
+			ExpectedRanges: []Range{{Start: 0, Stop: 99}},
+		},
+		{ // everything fetched
+			Ranges: []Range{{Start: 0, Stop: 99}},
+			Length: 100,
+		},
+		{ // first and last bytes missing
+			Ranges:         []Range{{Start: 1, Stop: 98}},
+			Length:         100,
+			ExpectedRanges: []Range{{Start: 0, Stop: 0}, {Start: 99, Stop: 99}},
+		},
+		{ // single byte gap between adjacent chunks
+			Ranges:         []Range{{Start: 50, Stop: 99}, {Start: 0, Stop: 48}},
+			Length:         100,
+			ExpectedRanges: []Range{{Start: 49, Stop: 49}},
+		},
+		{ // fetched ranges past the end are ignored
+			Ranges:         []Range{{Start: 90, Stop: 199}},
+			Length:         100,
+			ExpectedRanges: []Range{{Start: 0, Stop: 89}},
+		},
+		{ // empty content
+			Ranges: []Range{{Start: 0, Stop: 9}},
+			Length: 0,
+		},
+	}
+	for i, test := range tests {
+		if got, want := Gaps(test.Ranges, test.Length), test.ExpectedRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad gaps: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type equalTest struct {
+	A, B     []Range
+	Expected bool
+}
+
+func TestEqual(t *testing.T) {
+	tests := []equalTest{
+		{ // differently expressed but equivalent
+			A:        []Range{{Start: 0, Stop: 50}, {Start: 51, Stop: 99}},
+			B:        []Range{{Start: 0, Stop: 99}},
+			Expected: true,
+		},
+		{ // different order
+			A:        []Range{{Start: 200, Stop: 299}, {Start: 0, Stop: 99}},
+			B:        []Range{{Start: 0, Stop: 99}, {Start: 200, Stop: 299}},
+			Expected: true,
+		},
+		{ // overlapping
+			A:        []Range{{Start: 0, Stop: 60}, {Start: 40, Stop: 99}},
+			B:        []Range{{Start: 0, Stop: 99}},
+			Expected: true,
+		},
+		{ // different
+			A:        []Range{{Start: 0, Stop: 50}, {Start: 52, Stop: 99}},
+			B:        []Range{{Start: 0, Stop: 99}},
+			Expected: false,
+		},
+		{ // empty
+			Expected: true,
+		},
+		{ // empty and not
+			A:        []Range{{Start: 0, Stop: 99}},
+			Expected: false,
+		},
+	}
+	for i, test := range tests {
+		if got, want := Equal(test.A, test.B), test.Expected; got != want {
+			t.Errorf("test %d: bad result: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+type canonicalTest struct {
+	Ranges   []Range
+	Expected bool
+}
+
+func TestIsCanonical(t *testing.T) {
+	tests := []canonicalTest{
+		{ // sorted and merged
+			Ranges:   []Range{{Start: 0, Stop: 49}, {Start: 51, Stop: 99}},
+			Expected: true,
+		},
+		{ // adjacent
+			Ranges:   []Range{{Start: 0, Stop: 50}, {Start: 51, Stop: 99}},
+			Expected: false,
+		},
+		{ // overlapping
+			Ranges:   []Range{{Start: 0, Stop: 60}, {Start: 40, Stop: 99}},
+			Expected: false,
+		},
+		{ // unsorted
+			Ranges:   []Range{{Start: 200, Stop: 299}, {Start: 0, Stop: 99}},
+			Expected: false,
+		},
+		{ // reversed
+			Ranges:   []Range{{Start: 99, Stop: 0}},
+			Expected: false,
+		},
+		{ // empty
+			Expected: true,
+		},
+	}
+	for i, test := range tests {
+		if got, want := IsCanonical(test.Ranges), test.Expected; got != want {
+			t.Errorf("test %d: bad result: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func benchmarkMerge(b *testing.B, ranges []Range) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Merge(ranges)
+	}
+}
+
+func BenchmarkMerge1(b *testing.B) {
+	benchmarkMerge(b, []Range{{Start: 0, Stop: 99}})
+}
+
+func BenchmarkMerge3(b *testing.B) {
+	benchmarkMerge(b, []Range{{Start: 200, Stop: 299}, {Start: 0, Stop: 99}, {Start: 50, Stop: 149}})
+}
+
+func BenchmarkMerge100(b *testing.B) {
+	ranges := make([]Range, 0, 100)
+	for i := int64(99); i >= 0; i-- {
+		ranges = append(ranges, Range{Start: i * 10, Stop: i*10 + 4})
+	}
+	benchmarkMerge(b, ranges)
+}
@@ -0,0 +1,333 @@
+package ranger
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RangeSpec is a single range, as it was written, before it has been resolved
+// against the length of the content. Proxies and loggers can inspect a Range
+// header with ParseSpecs before the length is known, and resolve it later.
+type RangeSpec struct {
+	// First is the first byte position, or -1 for a suffix range such as
+	// '-500'.
+	First int64
+
+	// Last is the last byte position, or -1 for an open-ended range such as
+	// '100-'. For a suffix range, it's the length of the suffix.
+	Last int64
+}
+
+// SpecError describes a range that couldn't be parsed or resolved: which one
+// it was, as written, and why. Proxies and firewalls can log exactly what a
+// client got wrong. Err is ErrMalformed or ErrUnsatisfiable, so errors.Is
+// reports those as usual.
+type SpecError struct {
+	// Spec is the range as it was written, such as '0-x'.
+	Spec string
+
+	// Index is the position of the range among all of those in the header,
+	// counting from 0.
+	Index int
+
+	// Reason says what's wrong with the range, such as "bad number".
+	Reason string
+
+	// Err is ErrMalformed or ErrUnsatisfiable.
+	Err error
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("%v: range %d %q: %s", e.Err, e.Index, e.Spec, e.Reason)
+}
+
+func (e *SpecError) Unwrap() error {
+	return e.Err
+}
+
+// Reasons given by a SpecError.
+const (
+	reasonNoDash     = "missing '-'"
+	reasonExtraDash  = "more than one '-'"
+	reasonBadNumber  = "bad number"
+	reasonSign       = "signed number"
+	reasonTooLarge   = "number too large"
+	reasonReversed   = "first byte after last"
+	reasonOutOfRange = "outside the content"
+)
+
+// SpecKind says how a range was written.
+type SpecKind int
+
+const (
+	// ClosedSpec is a range with both a first and a last byte, such as '0-99'.
+	ClosedSpec SpecKind = iota
+
+	// OpenSpec is an open-ended range, such as '100-'.
+	OpenSpec
+
+	// SuffixSpec is a suffix range, such as '-500'.
+	SuffixSpec
+)
+
+func (k SpecKind) String() string {
+	switch k {
+	case ClosedSpec:
+		return "closed"
+	case OpenSpec:
+		return "open"
+	case SuffixSpec:
+		return "suffix"
+	}
+	return "SpecKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Kind returns how s was written.
+func (s RangeSpec) Kind() SpecKind {
+	switch {
+	case s.IsSuffix():
+		return SuffixSpec
+	case s.IsOpenEnded():
+		return OpenSpec
+	}
+	return ClosedSpec
+}
+
+// IsSuffix reports whether s is a suffix range, such as '-500', which asks for
+// the last s.Last bytes of the content.
+func (s RangeSpec) IsSuffix() bool {
+	return s.First < 0
+}
+
+// IsOpenEnded reports whether s is an open-ended range, such as '100-', which
+// asks for everything from s.First to the end of the content.
+func (s RangeSpec) IsOpenEnded() bool {
+	return s.First >= 0 && s.Last < 0
+}
+
+// String formats s as it was written, such as '0-99', '-500' or '100-'.
+func (s RangeSpec) String() string {
+	switch {
+	case s.IsSuffix():
+		return "-" + strconv.FormatInt(s.Last, 10)
+	case s.IsOpenEnded():
+		return strconv.FormatInt(s.First, 10) + "-"
+	}
+	return strconv.FormatInt(s.First, 10) + "-" + strconv.FormatInt(s.Last, 10)
+}
+
+// ParseSpecs parses ranges like Parse, but without knowing the length of the
+// content, so the ranges are neither resolved nor merged. They're returned as
+// they were written, in the order they were written. Errors are as for Parse,
+// except that ErrUnsatisfiable can't be known until the specs are resolved.
+func ParseSpecs(ranges []string, prefix string) ([]RangeSpec, error) {
+	result := make([]RangeSpec, 0, len(ranges))
+	for _, r := range ranges {
+		r = strings.TrimPrefix(r, prefix)
+		ranges := strings.Split(r, ",")
+		for _, r := range ranges {
+			if len(result) == MaxRanges {
+				return nil, ErrLimit
+			}
+			spec, err := specAt(trimOWS(r), len(result))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, spec)
+		}
+	}
+	return result, nil
+}
+
+// ResolveSpecs resolves specs against content of contentLen bytes, and merges
+// them, giving the same result as parsing them with Parse.
+func ResolveSpecs(specs []RangeSpec, contentLen int64) ([]Range, error) {
+	result := make([]Range, 0, len(specs))
+	for i, s := range specs {
+		r, err := resolveAt(s, s.String(), i, contentLen)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return mergeRanges(result), nil
+}
+
+// PartitionSpecs resolves the specs that can be satisfied by content of
+// contentLen bytes, and returns the rest, so that a server can serve a 206 for
+// the ranges it can, as RFC 7233 allows, rather than a 416 for them all. Specs
+// that extend past the end of the content are satisfiable, and are clamped to
+// fit; only those that fall wholly outside it aren't. The satisfiable ranges
+// are sorted and merged as by Parse, and the unsatisfiable specs are returned
+// as they were, in order. If satisfiable is empty, the server should reply
+// with a 416.
+func PartitionSpecs(specs []RangeSpec, contentLen int64) (satisfiable []Range, unsatisfiable []RangeSpec) {
+	for _, s := range specs {
+		if r, _, ok := s.clamp(contentLen); ok {
+			satisfiable = append(satisfiable, r)
+		} else {
+			unsatisfiable = append(unsatisfiable, s)
+		}
+	}
+	return mergeRanges(satisfiable), unsatisfiable
+}
+
+// ParseSpec parses a single range, such as '0-99', '-500' or '100-', with no
+// prefix. If it's malformed or reversed, a *SpecError wrapping ErrMalformed is
+// returned.
+func ParseSpec(r string) (RangeSpec, error) {
+	return specAt(r, 0)
+}
+
+// specAt parses the index'th range of a header, r.
+func specAt[T string | []byte](r T, index int) (RangeSpec, error) {
+	spec, reason := parseSpec(r)
+	if reason != "" {
+		return RangeSpec{}, &SpecError{Spec: string(r), Index: index, Reason: reason, Err: ErrMalformed}
+	}
+	return spec, nil
+}
+
+// resolveAt resolves spec, the index'th range of a header, written as r.
+func resolveAt[T string | []byte](spec RangeSpec, r T, index int, contentLen int64) (Range, error) {
+	rng, err := spec.Resolve(contentLen)
+	if err != nil {
+		return Range{}, &SpecError{Spec: string(r), Index: index, Reason: reasonOutOfRange, Err: err}
+	}
+	return rng, nil
+}
+
+// parseSpec parses a single range from either a string or a byte slice, so
+// that AppendParse needn't convert one to the other. If the range is
+// malformed, the reason why is returned.
+func parseSpec[T string | []byte](r T) (RangeSpec, string) {
+	i := indexByte(r, '-')
+	if i < 0 {
+		return RangeSpec{}, reasonNoDash
+	}
+	first, last := r[:i], r[i+1:]
+	if indexByte(last, '-') >= 0 {
+		return RangeSpec{}, reasonExtraDash
+	}
+	if len(first) == 0 {
+		y, reason := parsePos(last)
+		if reason != "" {
+			return RangeSpec{}, reason
+		}
+		return RangeSpec{First: -1, Last: y}, ""
+	} else if len(last) == 0 {
+		x, reason := parsePos(first)
+		if reason != "" {
+			return RangeSpec{}, reason
+		}
+		return RangeSpec{First: x, Last: -1}, ""
+	}
+	x, reason := parsePos(first)
+	if reason != "" {
+		return RangeSpec{}, reason
+	}
+	y, reason := parsePos(last)
+	if reason != "" {
+		return RangeSpec{}, reason
+	}
+	if x > y {
+		return RangeSpec{}, reasonReversed
+	}
+	return RangeSpec{First: x, Last: y}, ""
+}
+
+// parsePos parses a byte position. It must be made up of digits only, with no
+// sign and no leading zeros, and fit in an int64, whatever the size of an int
+// on the platform. If it doesn't, the reason why is returned. Numbers longer
+// than any int64 are refused before they're scanned.
+func parsePos[T string | []byte](s T) (int64, string) {
+	switch {
+	case len(s) == 0:
+		return 0, reasonBadNumber
+	case s[0] == '+' || s[0] == '-':
+		return 0, reasonSign
+	case len(s) > maxPosDigits:
+		for i := 0; i < len(s); i++ {
+			if s[i] < '0' || s[i] > '9' {
+				return 0, reasonBadNumber
+			}
+		}
+		return 0, reasonTooLarge
+	case len(s) > 1 && s[0] == '0':
+		return 0, reasonBadNumber
+	}
+	n := int64(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return 0, reasonBadNumber
+		}
+		if n > (math.MaxInt64-int64(c-'0'))/10 {
+			return 0, reasonTooLarge
+		}
+		n = n*10 + int64(c-'0')
+	}
+	return n, ""
+}
+
+// maxPosDigits is the most digits a byte position can have: as many as
+// math.MaxInt64 has.
+const maxPosDigits = 19
+
+// trimOWS trims the optional whitespace, spaces and tabs, that RFC 7230 allows
+// around the elements of a list, such as the ranges in 'bytes=0-99, 200-299'.
+func trimOWS[T string | []byte](s T) T {
+	for len(s) > 0 && (s[0] == ' ' || s[0] == '\t') {
+		s = s[1:]
+	}
+	for len(s) > 0 && (s[len(s)-1] == ' ' || s[len(s)-1] == '\t') {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func indexByte[T string | []byte](s T, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// Resolve returns the range s refers to in content of contentLen bytes. If any
+// of it falls outside of the content, ErrUnsatisfiable is returned. A suffix
+// range longer than the content refers to all of it.
+func (s RangeSpec) Resolve(contentLen int64) (Range, error) {
+	switch {
+	case s.First < 0:
+		return Range{Start: contentLen - s.Last, Stop: contentLen - 1}.validate()
+	case s.Last < 0:
+		return Range{Start: s.First, Stop: contentLen - 1}.validate()
+	}
+	if s.Last >= contentLen {
+		return Range{}, ErrUnsatisfiable
+	}
+	return Range{Start: s.First, Stop: s.Last}.validate()
+}
+
+// clamp returns the range s refers to in content of contentLen bytes, clamped
+// to fit, along with the range as it was requested. If none of s falls within
+// the content, ok is false.
+func (s RangeSpec) clamp(contentLen int64) (r, orig Range, ok bool) {
+	switch {
+	case s.First < 0:
+		orig = Range{Start: contentLen - s.Last, Stop: contentLen - 1}
+	case s.Last < 0:
+		orig = Range{Start: s.First, Stop: contentLen - 1}
+	default:
+		orig = Range{Start: s.First, Stop: s.Last}
+	}
+	r, err := Range{Start: orig.Start, Stop: min(orig.Stop, contentLen-1)}.validate()
+	if err != nil {
+		return Range{}, orig, false
+	}
+	return r, orig, true
+}
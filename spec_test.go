@@ -0,0 +1,145 @@
+package ranger
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type specTest struct {
+	Ranges        []string
+	ExpectedSpecs []RangeSpec
+	ExpectedError string
+}
+
+func TestParseSpecs(t *testing.T) {
+	tests := []specTest{
+		{ // each kind of spec, in order
+			Ranges:        []string{"bytes=100-,0-99", "bytes=-500"},
+			ExpectedSpecs: []RangeSpec{{First: 100, Last: -1}, {First: 0, Last: 99}, {First: -1, Last: 500}},
+			ExpectedError: "<nil>",
+		},
+		{ // not merged
+			Ranges:        []string{"bytes=0-99,50-149"},
+			ExpectedSpecs: []RangeSpec{{First: 0, Last: 99}, {First: 50, Last: 149}},
+			ExpectedError: "<nil>",
+		},
+		{ // malformed
+			Ranges:        []string{"bytes=0-99,abc"},
+			ExpectedError: `invalid range: malformed: range 1 "abc": missing '-'`,
+		},
+		{ // reversed
+			Ranges:        []string{"bytes=99-0"},
+			ExpectedError: `invalid range: malformed: range 0 "99-0": first byte after last`,
+		},
+	}
+	for i, test := range tests {
+		specs, err := ParseSpecs(test.Ranges, "bytes=")
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+		if got, want := specs, test.ExpectedSpecs; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad specs: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestRangeSpecKinds(t *testing.T) {
+	specs, err := ParseSpecs([]string{"bytes=0-99,100-,-500"}, "bytes=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, open, suffix := specs[0], specs[1], specs[2]
+	if closed.IsSuffix() || closed.IsOpenEnded() {
+		t.Errorf("%v: wrong kind", closed)
+	}
+	if open.IsSuffix() || !open.IsOpenEnded() {
+		t.Errorf("%v: wrong kind", open)
+	}
+	if !suffix.IsSuffix() || suffix.IsOpenEnded() {
+		t.Errorf("%v: wrong kind", suffix)
+	}
+	if got, want := fmt.Sprintf("%v,%v,%v", closed, open, suffix), "0-99,100-,-500"; got != want {
+		t.Errorf("bad string: got %q, want %q", got, want)
+	}
+}
+
+func TestResolveSpecs(t *testing.T) {
+	for _, header := range []string{"bytes=100-,0-99", "bytes=-500", "bytes=0-1000", "bytes=900-,0-9"} {
+		specs, err := ParseSpecs([]string{header}, "bytes=")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ranges, err := ResolveSpecs(specs, 1000)
+		wantRanges, wantErr := Parse([]string{header}, "bytes=", 1000)
+		if got, want := fmt.Sprintf("%v", err), fmt.Sprintf("%v", wantErr); got != want {
+			t.Errorf("%s: bad error: got %q, want %q", header, got, want)
+		}
+		if got, want := ranges, wantRanges; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: bad ranges: got %+v, want %+v", header, got, want)
+		}
+	}
+}
+
+type partitionTest struct {
+	Header                string
+	ExpectedSatisfiable   []Range
+	ExpectedUnsatisfiable []RangeSpec
+}
+
+func TestPartitionSpecs(t *testing.T) {
+	tests := []partitionTest{
+		{ // all satisfiable
+			Header:              "bytes=0-9,-10",
+			ExpectedSatisfiable: []Range{{Start: 0, Stop: 9}, {Start: 990, Stop: 999}},
+		},
+		{ // some past the end
+			Header:                "bytes=1000-,0-9,2000-2999",
+			ExpectedSatisfiable:   []Range{{Start: 0, Stop: 9}},
+			ExpectedUnsatisfiable: []RangeSpec{{First: 1000, Last: -1}, {First: 2000, Last: 2999}},
+		},
+		{ // clamped and merged
+			Header:              "bytes=990-1999,-5000",
+			ExpectedSatisfiable: []Range{{Start: 0, Stop: 999}},
+		},
+		{ // none satisfiable
+			Header:                "bytes=1000-1001",
+			ExpectedUnsatisfiable: []RangeSpec{{First: 1000, Last: 1001}},
+		},
+	}
+	for i, test := range tests {
+		specs, err := ParseSpecs([]string{test.Header}, "bytes=")
+		if err != nil {
+			t.Fatal(err)
+		}
+		satisfiable, unsatisfiable := PartitionSpecs(specs, 1000)
+		if got, want := satisfiable, test.ExpectedSatisfiable; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad satisfiable ranges: got %v, want %v", i, got, want)
+		}
+		if got, want := unsatisfiable, test.ExpectedUnsatisfiable; !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: bad unsatisfiable specs: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSpecError(t *testing.T) {
+	_, err := Parse([]string{"bytes=0-9,20-x"}, "bytes=", 100)
+	var se *SpecError
+	if !errors.As(err, &se) {
+		t.Fatalf("bad error: got %v, want a *SpecError", err)
+	}
+	if got, want := *se, (SpecError{Spec: "20-x", Index: 1, Reason: "bad number", Err: ErrMalformed}); got != want {
+		t.Errorf("bad error: got %+v, want %+v", got, want)
+	}
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("error doesn't wrap ErrMalformed: %v", err)
+	}
+	_, err = AppendParse(nil, []byte("bytes=0-9, 200-"), "bytes=", 100)
+	if !errors.As(err, &se) {
+		t.Fatalf("bad error: got %v, want a *SpecError", err)
+	}
+	if got, want := *se, (SpecError{Spec: "200-", Index: 1, Reason: "outside the content", Err: ErrUnsatisfiable}); got != want {
+		t.Errorf("bad error: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// errRangeOrder is returned by a range filter given ranges that aren't sorted,
+// or that overlap.
+var errRangeOrder = errors.New("ranger: ranges must be sorted, and must not overlap")
+
+// rangeFilter reads the bytes covered by a list of ranges from a reader that
+// can't seek, discarding the bytes in between.
+type rangeFilter struct {
+	r       io.Reader
+	ranges  []Range
+	pos     int64 // offset of the next byte of r
+	inRange bool  // whether pos is within ranges[0]
+}
+
+// NewRangeFilter returns an io.Reader that reads the bytes covered by ranges
+// from r, one range after another, by reading r from start to finish and
+// discarding the bytes in between. It suits sources that can't seek, such as
+// pipes, decompressing readers, and tape-like backends; a source that can
+// should be read with NewReader, which skips the bytes in between instead.
+//
+// The ranges must be sorted, and must not overlap, as Merge returns them.
+// Otherwise, Read fails when it comes to a range that starts before the end
+// of the one before it. If r ends before the last range does, Read fails with
+// io.ErrUnexpectedEOF.
+func NewRangeFilter(r io.Reader, ranges []Range) io.Reader {
+	return &rangeFilter{r: r, ranges: ranges}
+}
+
+func (f *rangeFilter) Read(p []byte) (int, error) {
+	for len(f.ranges) > 0 {
+		cur := f.ranges[0]
+		if cur.Start > cur.Stop {
+			f.ranges = f.ranges[1:]
+			continue
+		}
+		if !f.inRange {
+			if cur.Start < f.pos {
+				return 0, errRangeOrder
+			}
+			n, err := io.CopyN(io.Discard, f.r, cur.Start-f.pos)
+			f.pos += n
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return 0, err
+			}
+			f.inRange = true
+		}
+		if len(p) == 0 {
+			return 0, nil
+		}
+		if remaining := cur.Stop + 1 - f.pos; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		n, err := f.r.Read(p)
+		f.pos += int64(n)
+		if f.pos > cur.Stop {
+			f.ranges = f.ranges[1:]
+			f.inRange = false
+			if err == io.EOF {
+				err = nil
+			}
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+	return 0, io.EOF
+}
+
+// StreamHandler returns an http.Handler that serves size bytes of content
+// from a source that can't seek, just as Handler serves an io.ReaderAt. For
+// each request that needs the content, open is called to start reading it
+// from the beginning, and the ranges are filtered out of it as by
+// NewRangeFilter. The reader is closed once the response is written.
+//
+// Serving a range costs reading all of the content up to its end, so a
+// request for the last byte reads the lot. The ranges of a multipart response
+// are read from a single stream, if they're served in ascending order, as
+// they are by default; otherwise, open is called again for each range that
+// starts before the last one stopped.
+func StreamHandler(open func(ctx context.Context) (io.ReadCloser, error), size int64, opts ...ServeOption) http.Handler {
+	cfg := newServeConfig(opts)
+	if cfg.contentType == "" {
+		cfg.contentType = "application/octet-stream"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := &streamContent{open: open, size: size}
+		defer c.close()
+		_ = serve(w, r, c, cfg)
+	})
+}
+
+// streamContent is the Content of a stream, for a single request.
+type streamContent struct {
+	open   func(ctx context.Context) (io.ReadCloser, error)
+	size   int64
+	body   io.ReadCloser
+	filter rangeFilter
+}
+
+func (c *streamContent) Size() int64 {
+	return c.size
+}
+
+func (c *streamContent) ReadRange(ctx context.Context, r Range) (io.ReadCloser, error) {
+	if c.body == nil || r.Start < c.filter.pos {
+		c.close()
+		body, err := c.open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.body = body
+		c.filter = rangeFilter{r: body}
+	}
+	c.filter.ranges, c.filter.inRange = []Range{r}, false
+	return io.NopCloser(&c.filter), nil
+}
+
+func (c *streamContent) close() {
+	if c.body != nil {
+		c.body.Close()
+		c.body = nil
+	}
+}
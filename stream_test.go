@@ -0,0 +1,140 @@
+package ranger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+type rangeFilterTest struct {
+	Ranges         []Range
+	ExpectedOutput string
+	ExpectedError  string
+}
+
+func TestRangeFilter(t *testing.T) {
+	tests := []rangeFilterTest{
+		{ // several ranges
+			Ranges:         []Range{{Start: 0, Stop: 1}, {Start: 5, Stop: 6}, {Start: 9, Stop: 9}},
+			ExpectedOutput: "01569",
+			ExpectedError:  "<nil>",
+		},
+		{ // adjacent ranges
+			Ranges:         []Range{{Start: 2, Stop: 3}, {Start: 4, Stop: 5}},
+			ExpectedOutput: "2345",
+			ExpectedError:  "<nil>",
+		},
+		{ // no ranges
+			ExpectedError: "<nil>",
+		},
+		{ // out of order
+			Ranges:         []Range{{Start: 5, Stop: 6}, {Start: 0, Stop: 1}},
+			ExpectedOutput: "56",
+			ExpectedError:  "ranger: ranges must be sorted, and must not overlap",
+		},
+		{ // past the end
+			Ranges:         []Range{{Start: 8, Stop: 12}},
+			ExpectedOutput: "89",
+			ExpectedError:  "unexpected EOF",
+		},
+		{ // starting past the end
+			Ranges:        []Range{{Start: 20, Stop: 29}},
+			ExpectedError: "unexpected EOF",
+		},
+	}
+	for i, test := range tests {
+		// OneByteReader makes sure that the filter copes with short reads.
+		f := NewRangeFilter(iotest.OneByteReader(strings.NewReader("0123456789")), test.Ranges)
+		b, err := io.ReadAll(f)
+		if got, want := string(b), test.ExpectedOutput; got != want {
+			t.Errorf("test %d: bad output: got %q, want %q", i, got, want)
+		}
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+type streamHandlerTest struct {
+	Options       []ServeOption
+	Range         string
+	ExpectedBody  string
+	ExpectedOpens int
+}
+
+func TestStreamHandler(t *testing.T) {
+	tests := []streamHandlerTest{
+		{ // all of the content
+			ExpectedBody:  "0123456789",
+			ExpectedOpens: 1,
+		},
+		{ // single range
+			Range:         "bytes=3-5",
+			ExpectedBody:  "345",
+			ExpectedOpens: 1,
+		},
+		{ // several ranges, in order
+			Options:       []ServeOption{WithBoundary("B")},
+			Range:         "bytes=7-8,1-2",
+			ExpectedBody:  "--B\r\nContent-Range: bytes 1-2/10\r\nContent-Type: text/plain\r\n\r\n12\r\n--B\r\nContent-Range: bytes 7-8/10\r\nContent-Type: text/plain\r\n\r\n78\r\n--B--\r\n",
+			ExpectedOpens: 1,
+		},
+		{ // several ranges, out of order
+			Options:       []ServeOption{WithBoundary("B"), WithOrder(ClientOrder)},
+			Range:         "bytes=7-8,1-2",
+			ExpectedBody:  "--B\r\nContent-Range: bytes 7-8/10\r\nContent-Type: text/plain\r\n\r\n78\r\n--B\r\nContent-Range: bytes 1-2/10\r\nContent-Type: text/plain\r\n\r\n12\r\n--B--\r\n",
+			ExpectedOpens: 2,
+		},
+	}
+	for i, test := range tests {
+		opens, closes := 0, 0
+		open := func(ctx context.Context) (io.ReadCloser, error) {
+			opens++
+			return closeCounter{Reader: strings.NewReader("0123456789"), closes: &closes}, nil
+		}
+		h := StreamHandler(open, 10, append(test.Options, WithContentType("text/plain"))...)
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.Range != "" {
+			req.Header.Set("Range", test.Range)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Body.String(), test.ExpectedBody; got != want {
+			t.Errorf("test %d: bad body: got %q, want %q", i, got, want)
+		}
+		if got, want := opens, test.ExpectedOpens; got != want {
+			t.Errorf("test %d: bad number of opens: got %d, want %d", i, got, want)
+		}
+		if got, want := closes, opens; got != want {
+			t.Errorf("test %d: bad number of closes: got %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestStreamHandlerHead(t *testing.T) {
+	open := func(ctx context.Context) (io.ReadCloser, error) {
+		t.Error("stream opened for a HEAD request")
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	rec := httptest.NewRecorder()
+	StreamHandler(open, 10).ServeHTTP(rec, httptest.NewRequest("HEAD", "/", nil))
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+}
+
+// closeCounter counts the times it's closed.
+type closeCounter struct {
+	io.Reader
+	closes *int
+}
+
+func (c closeCounter) Close() error {
+	*c.closes++
+	return nil
+}
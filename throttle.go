@@ -0,0 +1,122 @@
+package ranger
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// Limiter limits the rate at which bytes are written. It's satisfied by
+// *rate.Limiter, from golang.org/x/time/rate, with one token per byte.
+type Limiter interface {
+	// WaitN blocks until n bytes may be written, or ctx is done.
+	WaitN(ctx context.Context, n int) error
+}
+
+// limitedChunk is how many bytes are written at a time, unless the limiter
+// has a smaller burst size.
+const limitedChunk = 32 << 10
+
+type limitedWriter struct {
+	ctx   context.Context
+	w     io.Writer
+	l     Limiter
+	chunk int
+}
+
+// NewLimitedWriter returns an io.Writer that writes to w no faster than l
+// allows, waiting on l before writing each chunk. Writes are split into chunks
+// of up to 32KB, or the limiter's burst size if it has a Burst method, as
+// *rate.Limiter does. Once ctx is done, Write returns ctx.Err().
+func NewLimitedWriter(ctx context.Context, w io.Writer, l Limiter) io.Writer {
+	chunk := limitedChunk
+	if b, ok := l.(interface{ Burst() int }); ok && b.Burst() > 0 {
+		chunk = min(chunk, b.Burst())
+	}
+	return &limitedWriter{ctx: ctx, w: w, l: l, chunk: chunk}
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), lw.chunk)
+		if err := lw.l.WaitN(lw.ctx, n); err != nil {
+			return written, err
+		}
+		n, err := lw.w.Write(p[:n])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+type limitedReader struct {
+	ctx   context.Context
+	r     io.Reader
+	l     Limiter
+	chunk int
+}
+
+// NewLimitedReader returns an io.Reader that reads from r no faster than l
+// allows, waiting on l after reading each chunk, which are split up as they
+// are by NewLimitedWriter. Once ctx is done, Read returns ctx.Err().
+func NewLimitedReader(ctx context.Context, r io.Reader, l Limiter) io.Reader {
+	chunk := limitedChunk
+	if b, ok := l.(interface{ Burst() int }); ok && b.Burst() > 0 {
+		chunk = min(chunk, b.Burst())
+	}
+	return &limitedReader{ctx: ctx, r: r, l: l, chunk: chunk}
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p[:min(len(p), lr.chunk)])
+	if n > 0 {
+		if werr := lr.l.WaitN(lr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// limitBody throttles body to the rate each of limiters allows, skipping any
+// that are nil.
+func limitBody(ctx context.Context, body io.ReadCloser, limiters ...Limiter) io.ReadCloser {
+	var r io.Reader = body
+	limited := false
+	for _, l := range limiters {
+		if l != nil {
+			r = NewLimitedReader(ctx, r, l)
+			limited = true
+		}
+	}
+	if !limited {
+		return body
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, body}
+}
+
+// WithLimiter throttles each response to the rate allowed by the limiter that
+// limiter returns for its request. Returning a new limiter for each request
+// limits each response separately, and returning the same one limits all of
+// them together. If limiter returns nil, the response isn't throttled.
+func WithLimiter(limiter func(*http.Request) Limiter) ServeOption {
+	return func(c *serveConfig) {
+		c.limiter = limiter
+	}
+}
+
+// limitedResponseWriter throttles the body of a response.
+type limitedResponseWriter struct {
+	http.ResponseWriter
+	body io.Writer
+}
+
+func (w *limitedResponseWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
@@ -0,0 +1,171 @@
+package ranger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeLimiter records the waits it's asked for.
+type fakeLimiter struct {
+	burst int
+	err   error
+
+	mu    sync.Mutex
+	waits []int
+}
+
+func (l *fakeLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	l.waits = append(l.waits, n)
+	return nil
+}
+
+func (l *fakeLimiter) Burst() int {
+	return l.burst
+}
+
+// total returns the number of bytes l has been asked to wait for.
+func (l *fakeLimiter) total() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	total := 0
+	for _, n := range l.waits {
+		total += n
+	}
+	return total
+}
+
+func TestLimitedWriter(t *testing.T) {
+	var b strings.Builder
+	l := &fakeLimiter{burst: 4}
+	w := NewLimitedWriter(context.Background(), &b, l)
+	n, err := w.Write([]byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, 10; got != want {
+		t.Errorf("bad count: got %d, want %d", got, want)
+	}
+	if got, want := b.String(), "0123456789"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := l.waits, []int{4, 4, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad waits: got %v, want %v", got, want)
+	}
+
+	l.err = errors.New("rate exceeded")
+	if _, err := w.Write([]byte("x")); err != l.err {
+		t.Errorf("bad error: got %v, want %v", err, l.err)
+	}
+}
+
+func TestHandlerLimiter(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	var limiters []*fakeLimiter
+	h := Handler(strings.NewReader(content), 100, WithLimiter(func(r *http.Request) Limiter {
+		l := &fakeLimiter{burst: 30}
+		limiters = append(limiters, l)
+		return l
+	}))
+	for _, rng := range []string{"", "bytes=0-49"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+	}
+	if got, want := len(limiters), 2; got != want {
+		t.Fatalf("bad number of limiters: got %d, want %d", got, want)
+	}
+	for i, want := range []int{100, 50} {
+		if total := limiters[i].total(); total != want {
+			t.Errorf("response %d: bad bytes limited: got %d, want %d", i, limiters[i].total(), want)
+		}
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	l := &fakeLimiter{burst: 4}
+	r := NewLimitedReader(context.Background(), strings.NewReader("0123456789"), l)
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "0123456789"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := l.waits, []int{4, 4, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad waits: got %v, want %v", got, want)
+	}
+}
+
+func TestDownloaderLimiter(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, _ := countingServer(t, content)
+	global := &fakeLimiter{burst: 8}
+	var mu sync.Mutex
+	var conns []*fakeLimiter
+	d := &Downloader{
+		Client:    srv.Client(),
+		ChunkSize: 10,
+		Workers:   2,
+		Limiter:   global,
+		ConnLimiter: func() Limiter {
+			mu.Lock()
+			defer mu.Unlock()
+			l := &fakeLimiter{burst: 8}
+			conns = append(conns, l)
+			return l
+		},
+	}
+	dst := memFile(make([]byte, 100))
+	if _, err := d.Download(context.Background(), srv.URL, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := global.total(), 100; got != want {
+		t.Errorf("bad bytes limited: got %d, want %d", got, want)
+	}
+	if got, want := len(conns), 2; got != want {
+		t.Fatalf("bad number of connection limiters: got %d, want %d", got, want)
+	}
+	if got, want := conns[0].total()+conns[1].total(), 100; got != want {
+		t.Errorf("bad bytes limited by connection: got %d, want %d", got, want)
+	}
+}
+
+func TestTransportLimiter(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv, _ := countingServer(t, content)
+	global := &fakeLimiter{burst: 8}
+	client := &http.Client{Transport: &Transport{Base: srv.Client().Transport, ChunkSize: 30, Limiter: global}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := global.total(), 100; got != want {
+		t.Errorf("bad bytes limited: got %d, want %d", got, want)
+	}
+}
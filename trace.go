@@ -0,0 +1,62 @@
+package ranger
+
+import "context"
+
+// Tracer starts spans, for tracing requests with a library such as
+// OpenTelemetry, without this package depending on it. Over OpenTelemetry,
+// for instance:
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (o otelTracer) Start(ctx context.Context, name string, attrs ...ranger.Attr) (context.Context, ranger.Span) {
+//		ctx, span := o.t.Start(ctx, name)
+//		s := otelSpan{span}
+//		s.SetAttributes(attrs...)
+//		return ctx, s
+//	}
+//
+// where otelSpan converts each Attr with attribute.KeyValue, and records the
+// error passed to End with RecordError and SetStatus.
+type Tracer interface {
+	// Start starts a span with the given name and attributes, as a child of
+	// any span in ctx, and returns a context holding it.
+	Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttributes adds attributes to the span.
+	SetAttributes(attrs ...Attr)
+
+	// End ends the span, with the error that the operation failed with, if
+	// any.
+	End(err error)
+}
+
+// Attr is an attribute of a span. Value is a string, an int, or an int64.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// The spans and attributes this package traces.
+const (
+	// SpanServe is the span of serving a request. It has the attributes
+	// AttrMethod, AttrStatus and AttrBytes.
+	SpanServe = "ranger.serve"
+
+	// SpanParse is the span of parsing a request's Range header, and
+	// evaluating its preconditions, within SpanServe. It has the attributes
+	// AttrRange and AttrRangeCount.
+	SpanParse = "ranger.parse"
+
+	// SpanFetch is the span of fetching a single chunk for a Downloader,
+	// including any retries. It has the attributes AttrRange and AttrBytes.
+	SpanFetch = "ranger.fetch"
+
+	AttrMethod     = "http.request.method"
+	AttrStatus     = "http.response.status_code"
+	AttrRange      = "ranger.range"
+	AttrRangeCount = "ranger.range_count"
+	AttrBytes      = "ranger.bytes"
+)
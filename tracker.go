@@ -0,0 +1,94 @@
+package ranger
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracker records which ranges of content of a known length have been
+// completed, such as the parts of a file fetched by a parallel downloader. It's
+// safe for concurrent use by multiple goroutines.
+type Tracker struct {
+	length int64
+	done   chan struct{}
+
+	mu  sync.Mutex
+	set RangeSet
+}
+
+// NewTracker returns a Tracker for content of length bytes, with nothing
+// completed yet.
+func NewTracker(length int64) *Tracker {
+	t := &Tracker{length: max(length, 0), done: make(chan struct{})}
+	if t.length == 0 {
+		close(t.done)
+	}
+	return t
+}
+
+// Length returns the length of the content being tracked.
+func (t *Tracker) Length() int64 {
+	return t.length
+}
+
+// Mark records r as completed. Any part of r that falls outside of the content
+// is ignored, as is a reversed range.
+func (t *Tracker) Mark(r Range) {
+	r.Start = max(r.Start, 0)
+	r.Stop = min(r.Stop, t.length-1)
+	if r.Start > r.Stop {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.set.Len() == t.length {
+		return
+	}
+	t.set = t.set.Union(NewRangeSet(r))
+	if t.set.Len() == t.length {
+		close(t.done)
+	}
+}
+
+// Completed returns the set of ranges completed so far.
+func (t *Tracker) Completed() RangeSet {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.set
+}
+
+// Missing returns the ranges that haven't been completed yet.
+func (t *Tracker) Missing() []Range {
+	return t.Completed().Complement(t.length).Ranges()
+}
+
+// Covered returns the number of bytes completed so far.
+func (t *Tracker) Covered() int64 {
+	return t.Completed().Len()
+}
+
+// Progress returns the fraction of the content completed so far, from 0 to 1.
+// Empty content is always complete.
+func (t *Tracker) Progress() float64 {
+	if t.length == 0 {
+		return 1
+	}
+	return float64(t.Covered()) / float64(t.length)
+}
+
+// Done returns a channel that's closed once all of the content has been
+// completed.
+func (t *Tracker) Done() <-chan struct{} {
+	return t.done
+}
+
+// Wait blocks until all of the content has been completed, or ctx is done, in
+// which case it returns ctx.Err().
+func (t *Tracker) Wait(ctx context.Context) error {
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
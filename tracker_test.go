@@ -0,0 +1,81 @@
+package ranger
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker(100)
+	tr.Mark(Range{Start: 0, Stop: 9})
+	tr.Mark(Range{Start: 90, Stop: 199})
+	tr.Mark(Range{Start: 20, Stop: 10})
+	if got, want := tr.Covered(), int64(20); got != want {
+		t.Errorf("bad coverage: got %d, want %d", got, want)
+	}
+	if got, want := tr.Progress(), 0.2; got != want {
+		t.Errorf("bad progress: got %v, want %v", got, want)
+	}
+	if got, want := tr.Missing(), []Range{{Start: 10, Stop: 89}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad missing ranges: got %+v, want %+v", got, want)
+	}
+	select {
+	case <-tr.Done():
+		t.Fatal("done before complete")
+	default:
+	}
+	tr.Mark(Range{Start: 10, Stop: 89})
+	select {
+	case <-tr.Done():
+	default:
+		t.Fatal("not done when complete")
+	}
+	if got, want := tr.Progress(), 1.0; got != want {
+		t.Errorf("bad progress: got %v, want %v", got, want)
+	}
+	// Marking a complete tracker again must not close done twice.
+	tr.Mark(Range{Start: 0, Stop: 99})
+}
+
+func TestTrackerConcurrent(t *testing.T) {
+	tr := NewTracker(1000)
+	var wg sync.WaitGroup
+	for _, r := range Chunks(1000, 7) {
+		wg.Add(1)
+		go func(r Range) {
+			defer wg.Done()
+			tr.Mark(r)
+		}(r)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tr.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if got, want := tr.Covered(), int64(1000); got != want {
+		t.Errorf("bad coverage: got %d, want %d", got, want)
+	}
+}
+
+func TestTrackerWaitContext(t *testing.T) {
+	tr := NewTracker(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got, want := tr.Wait(ctx), context.Canceled; got != want {
+		t.Errorf("bad error: got %v, want %v", got, want)
+	}
+}
+
+func TestTrackerEmpty(t *testing.T) {
+	tr := NewTracker(0)
+	if err := tr.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tr.Progress(), 1.0; got != want {
+		t.Errorf("bad progress: got %v, want %v", got, want)
+	}
+}
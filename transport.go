@@ -0,0 +1,214 @@
+package ranger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Transport is an http.RoundTripper that fetches large resources with several
+// concurrent range requests, and stitches the parts back together, in order,
+// into a single 200 response. Code that uses an http.Client gets the
+// throughput of several connections without any other changes.
+//
+// Only GET requests without a Range header of their own are split up. The
+// first ChunkSize bytes are requested with a Range header; if the server
+// doesn't support ranges, its response is passed through as it is.
+// Otherwise, the rest of the resource, if any, is fetched in chunks, made
+// conditional on its validators with If-Range, and the response becomes a
+// 200 with all of the resource as its body, and no Content-Range, even if it
+// fit in that one chunk. If the resource changes along the way, reading the
+// body fails with ErrResourceChanged.
+type Transport struct {
+	// Base makes the underlying requests. If it's nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// ChunkSize is the size of each range request. Resources no larger than
+	// it are fetched with a single request. If it's zero, 4MB is used.
+	ChunkSize int64
+
+	// Workers is the most range requests to make at once for a single
+	// resource, and so the most chunks that are held in memory. If it's zero,
+	// 4 are made.
+	Workers int
+
+	// Limiter, if set, limits the rate of every resource fetched, across all
+	// of their range requests.
+	Limiter Limiter
+
+	// ConnLimiter, if set, is called for each range request, and the Limiter
+	// it returns limits the rate of that request. If it returns nil, the
+	// request isn't limited.
+	ConnLimiter func() Limiter
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base()
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || (req.Body != nil && req.Body != http.NoBody) {
+		return base.RoundTrip(req)
+	}
+	chunkSize := t.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 4 << 20
+	}
+	workers := t.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	first := req.Clone(req.Context())
+	first.Header.Set("Range", Format([]Range{{Start: 0, Stop: chunkSize - 1}}))
+	resp, err := base.RoundTrip(first)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The resource is empty, so there's nothing to split up.
+		resp.Body.Close()
+		return base.RoundTrip(req)
+	}
+	resp.Body = limitBody(req.Context(), resp.Body, t.Limiter, t.connLimiter())
+	if resp.StatusCode != http.StatusPartialContent {
+		return resp, nil
+	}
+	got, total, err := ParseContentRange(resp.Header)
+	if err != nil || got.Start != 0 || total < 0 {
+		resp.Body.Close()
+		return base.RoundTrip(req)
+	}
+
+	chunks := Range{Start: got.Stop + 1, Stop: total - 1}.Chunks(chunkSize)
+	ctx, cancel := context.WithCancel(req.Context())
+	body := &stitchedBody{
+		ctx:    ctx,
+		cur:    resp.Body,
+		parts:  make([]chan partResult, len(chunks)),
+		sem:    make(chan struct{}, workers),
+		cancel: cancel,
+	}
+	for i := range body.parts {
+		body.parts[i] = make(chan partResult, 1)
+	}
+	go body.fetch(ctx, t, req, chunks, newValidators(resp.Header))
+
+	out := *resp
+	out.Status = "200 OK"
+	out.StatusCode = http.StatusOK
+	out.Header = resp.Header.Clone()
+	out.Header.Del("Content-Range")
+	out.Header.Set("Content-Length", strconv.FormatInt(total, 10))
+	out.ContentLength = total
+	out.Body = body
+	out.Request = req
+	return &out, nil
+}
+
+type partResult struct {
+	b   []byte
+	err error
+}
+
+// stitchedBody reads the body of the first response, and then each of the
+// other parts, in order, as they're fetched.
+type stitchedBody struct {
+	ctx    context.Context
+	err    error
+	cur    io.ReadCloser
+	parts  []chan partResult
+	next   int
+	sem    chan struct{} // held for each part fetched but not yet read
+	cancel context.CancelFunc
+}
+
+// fetch fetches the chunks, with at most cap(b.sem) of them in memory at once.
+func (b *stitchedBody) fetch(ctx context.Context, t *Transport, req *http.Request, chunks []Range, v validators) {
+	for i, r := range chunks {
+		select {
+		case b.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		go func(i int, r Range) {
+			buf, err := t.fetchPart(ctx, req, r, v)
+			b.parts[i] <- partResult{b: buf, err: err}
+		}(i, r)
+	}
+}
+
+// connLimiter returns the Limiter for a new range request, if there is one.
+func (t *Transport) connLimiter() Limiter {
+	if t.ConnLimiter == nil {
+		return nil
+	}
+	return t.ConnLimiter()
+}
+
+func (t *Transport) fetchPart(ctx context.Context, req *http.Request, r Range, v validators) ([]byte, error) {
+	sub := req.Clone(ctx)
+	sub.Header.Set("Range", Format([]Range{r}))
+	if ifRange := v.ifRange(); ifRange != "" {
+		sub.Header.Set("If-Range", ifRange)
+	}
+	resp, err := t.base().RoundTrip(sub)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	resp.Body = limitBody(ctx, resp.Body, t.Limiter, t.connLimiter())
+	return readPart(resp, r, v)
+}
+
+func (b *stitchedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	for {
+		if b.cur != nil {
+			n, err := b.cur.Read(p)
+			if err != io.EOF {
+				return n, err
+			}
+			b.cur.Close()
+			b.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+		}
+		if b.next == len(b.parts) {
+			return 0, io.EOF
+		}
+		var res partResult
+		select {
+		case res = <-b.parts[b.next]:
+		case <-b.ctx.Done():
+			res.err = b.ctx.Err()
+		}
+		if res.err != nil {
+			b.err = res.err
+			return 0, res.err
+		}
+		b.next++
+		<-b.sem
+		b.cur = io.NopCloser(bytes.NewReader(res.b))
+	}
+}
+
+// Close stops fetching any more parts.
+func (b *stitchedBody) Close() error {
+	b.cancel()
+	if b.cur != nil {
+		return b.cur.Close()
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package ranger
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rangeRecorder records the Range header of each request it passes on.
+type rangeRecorder struct {
+	mu     sync.Mutex
+	ranges []string
+	h      http.Handler
+}
+
+func (rr *rangeRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rr.mu.Lock()
+	rr.ranges = append(rr.ranges, r.Header.Get("Range"))
+	rr.mu.Unlock()
+	rr.h.ServeHTTP(w, r)
+}
+
+func TestTransport(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	rr := &rangeRecorder{h: Handler(strings.NewReader(content), int64(len(content)))}
+	srv := httptest.NewServer(rr)
+	defer srv.Close()
+	client := &http.Client{Transport: &Transport{Base: srv.Client().Transport, ChunkSize: 300, Workers: 2}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+	if got, want := resp.ContentLength, int64(len(content)); got != want {
+		t.Errorf("bad length: got %d, want %d", got, want)
+	}
+	if got := resp.Header.Get("Content-Range"); got != "" {
+		t.Errorf("unexpected Content-Range: %q", got)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), content; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+	if got, want := len(rr.ranges), 4; got != want {
+		t.Errorf("bad number of requests: got %d, want %d", got, want)
+	}
+}
+
+func TestTransportPassThrough(t *testing.T) {
+	content := "0123456789"
+	tests := map[string]http.Handler{
+		"small":         Handler(strings.NewReader(content), int64(len(content))),
+		"not supported": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, content) }),
+	}
+	for name, h := range tests {
+		srv := httptest.NewServer(h)
+		client := &http.Client{Transport: &Transport{Base: srv.Client().Transport, ChunkSize: 100}}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(b), content; got != want {
+			t.Errorf("%s: bad content: got %q, want %q", name, got, want)
+		}
+		srv.Close()
+	}
+}
+
+func TestTransportEmpty(t *testing.T) {
+	srv := httptest.NewServer(Handler(strings.NewReader(""), 0))
+	defer srv.Close()
+	client := &http.Client{Transport: &Transport{Base: srv.Client().Transport}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("bad status: got %d, want %d", got, want)
+	}
+}
+
+func TestTransportResourceChanged(t *testing.T) {
+	cs := &changingServer{useETag: true}
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cs.ServeHTTP(w, r)
+		once.Do(cs.change)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: &Transport{Base: srv.Client().Transport, ChunkSize: 4}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err != ErrResourceChanged {
+		t.Errorf("bad error: got %v, want %v", err, ErrResourceChanged)
+	}
+}
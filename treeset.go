@@ -0,0 +1,212 @@
+package ranger
+
+import (
+	"iter"
+	"math"
+	"math/rand/v2"
+)
+
+// TreeSet is a mutable set of offsets, like a RangeSet, for sets of very many
+// ranges, such as the piece map of a large download or the index of a cache.
+// A RangeSet copies its ranges with every change, so building one up a range
+// at a time takes quadratic time. A TreeSet keeps its ranges, merged as by
+// Merge, in a balanced tree, so Add, Remove and Contains take logarithmic
+// time.
+//
+// The zero value is the empty set. A TreeSet isn't safe for concurrent use.
+type TreeSet struct {
+	root  *treeNode
+	count int
+	len   int64
+}
+
+// treeNode is a node of the treap holding a TreeSet's ranges, ordered by
+// Start, and heap-ordered by pri.
+type treeNode struct {
+	r           Range
+	pri         uint64
+	left, right *treeNode
+}
+
+// NewTreeSet returns a TreeSet holding the offsets covered by ranges.
+func NewTreeSet(ranges ...Range) *TreeSet {
+	s := new(TreeSet)
+	for _, r := range ranges {
+		s.Add(r)
+	}
+	return s
+}
+
+// Add adds the offsets covered by r to s. A reversed range covers nothing, and
+// is ignored.
+func (s *TreeSet) Add(r Range) {
+	if r.Start > r.Stop {
+		return
+	}
+	left, right := splitTree(s.root, r.Start)
+	if last := lastNode(left); last != nil && last.r.Stop >= r.Start-1 {
+		r.Start = last.r.Start
+		r.Stop = max(r.Stop, last.r.Stop)
+		s.drop(last.r)
+		left = removeLast(left)
+	}
+	var mid *treeNode
+	if r.Stop < math.MaxInt64-1 {
+		mid, right = splitTree(right, r.Stop+2)
+	} else {
+		mid, right = right, nil
+	}
+	if last := lastNode(mid); last != nil {
+		r.Stop = max(r.Stop, last.r.Stop)
+	}
+	for m := range walkTree(mid) {
+		s.drop(m)
+	}
+	s.count++
+	s.len += r.Len()
+	s.root = mergeTrees(mergeTrees(left, &treeNode{r: r, pri: rand.Uint64()}), right)
+}
+
+// Remove removes the offsets covered by r from s, splitting any range that r
+// falls in the middle of.
+func (s *TreeSet) Remove(r Range) {
+	if r.Start > r.Stop {
+		return
+	}
+	var keep []Range
+	left, right := splitTree(s.root, r.Start)
+	if last := lastNode(left); last != nil && last.r.Stop >= r.Start {
+		s.drop(last.r)
+		left = removeLast(left)
+		keep = append(keep, Range{Start: last.r.Start, Stop: r.Start - 1})
+		if last.r.Stop > r.Stop {
+			keep = append(keep, Range{Start: r.Stop + 1, Stop: last.r.Stop})
+		}
+	}
+	var mid *treeNode
+	if r.Stop < math.MaxInt64 {
+		mid, right = splitTree(right, r.Stop+1)
+	} else {
+		mid, right = right, nil
+	}
+	if last := lastNode(mid); last != nil && last.r.Stop > r.Stop {
+		keep = append(keep, Range{Start: r.Stop + 1, Stop: last.r.Stop})
+	}
+	for m := range walkTree(mid) {
+		s.drop(m)
+	}
+	s.root = mergeTrees(left, right)
+	for _, k := range keep {
+		s.Add(k)
+	}
+}
+
+// drop accounts for the removal of r from s.
+func (s *TreeSet) drop(r Range) {
+	s.count--
+	s.len -= r.Len()
+}
+
+// Contains reports whether offset is in s.
+func (s *TreeSet) Contains(offset int64) bool {
+	return s.Covers(Range{Start: offset, Stop: offset})
+}
+
+// Covers reports whether every offset in r is in s.
+func (s *TreeSet) Covers(r Range) bool {
+	// Find the range with the greatest Start not after r.Start.
+	var found *treeNode
+	for n := s.root; n != nil; {
+		if n.r.Start <= r.Start {
+			found, n = n, n.right
+		} else {
+			n = n.left
+		}
+	}
+	return found != nil && found.r.Stop >= r.Stop && r.Start <= r.Stop
+}
+
+// Len returns the number of offsets in s.
+func (s *TreeSet) Len() int64 {
+	return s.len
+}
+
+// Count returns the number of ranges s holds, once merged.
+func (s *TreeSet) Count() int {
+	return s.count
+}
+
+// All returns a sequence of the ranges in s, sorted and merged as by Merge.
+// s must not be modified until the sequence is done.
+func (s *TreeSet) All() iter.Seq[Range] {
+	return walkTree(s.root)
+}
+
+// Ranges returns the ranges in s, sorted and merged as by Merge.
+func (s *TreeSet) Ranges() []Range {
+	result := make([]Range, 0, s.count)
+	for r := range s.All() {
+		result = append(result, r)
+	}
+	return result
+}
+
+// RangeSet returns the offsets in s as a RangeSet.
+func (s *TreeSet) RangeSet() RangeSet {
+	return RangeSet{ranges: s.Ranges()}
+}
+
+// splitTree splits t into the nodes with a Start before k, and the rest.
+func splitTree(t *treeNode, k int64) (left, right *treeNode) {
+	if t == nil {
+		return nil, nil
+	}
+	if t.r.Start < k {
+		t.right, right = splitTree(t.right, k)
+		return t, right
+	}
+	left, t.left = splitTree(t.left, k)
+	return left, t
+}
+
+// mergeTrees joins a and b, every node of which comes after those of a.
+func mergeTrees(a, b *treeNode) *treeNode {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.pri > b.pri:
+		a.right = mergeTrees(a.right, b)
+		return a
+	default:
+		b.left = mergeTrees(a, b.left)
+		return b
+	}
+}
+
+func lastNode(t *treeNode) *treeNode {
+	for t != nil && t.right != nil {
+		t = t.right
+	}
+	return t
+}
+
+func removeLast(t *treeNode) *treeNode {
+	if t.right == nil {
+		return t.left
+	}
+	t.right = removeLast(t.right)
+	return t
+}
+
+// walkTree yields the ranges of t in order.
+func walkTree(t *treeNode) iter.Seq[Range] {
+	return func(yield func(Range) bool) {
+		walkNodes(t, yield)
+	}
+}
+
+func walkNodes(t *treeNode, yield func(Range) bool) bool {
+	return t == nil || (walkNodes(t.left, yield) && yield(t.r) && walkNodes(t.right, yield))
+}
@@ -0,0 +1,81 @@
+package ranger
+
+import (
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestTreeSet(t *testing.T) {
+	s := NewTreeSet(Range{Start: 10, Stop: 19}, Range{Start: 30, Stop: 39})
+	s.Add(Range{Start: 20, Stop: 24})
+	if got, want := s.Ranges(), []Range{{Start: 10, Stop: 24}, {Start: 30, Stop: 39}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+	s.Add(Range{Start: 0, Stop: 100})
+	if got, want := s.Ranges(), []Range{{Start: 0, Stop: 100}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+	s.Remove(Range{Start: 40, Stop: 49})
+	s.Remove(Range{Start: 95, Stop: 200})
+	if got, want := s.Ranges(), []Range{{Start: 0, Stop: 39}, {Start: 50, Stop: 94}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad ranges: got %v, want %v", got, want)
+	}
+	if got, want := s.Len(), int64(85); got != want {
+		t.Errorf("bad length: got %d, want %d", got, want)
+	}
+	if got, want := s.Count(), 2; got != want {
+		t.Errorf("bad count: got %d, want %d", got, want)
+	}
+	if !s.Contains(39) || s.Contains(40) || !s.Covers(Range{Start: 50, Stop: 94}) || s.Covers(Range{Start: 30, Stop: 50}) {
+		t.Error("bad membership")
+	}
+	s.Add(Range{Start: math.MaxInt64 - 1, Stop: math.MaxInt64})
+	s.Add(Range{Start: math.MaxInt64 - 3, Stop: math.MaxInt64 - 2})
+	s.Remove(Range{Start: math.MaxInt64, Stop: math.MaxInt64})
+	if got, want := s.Ranges()[2], (Range{Start: math.MaxInt64 - 3, Stop: math.MaxInt64 - 1}); got != want {
+		t.Errorf("bad range at the largest offset: got %v, want %v", got, want)
+	}
+}
+
+func TestTreeSetMatchesRangeSet(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var set RangeSet
+	tree := new(TreeSet)
+	for i := 0; i < 5000; i++ {
+		start := rng.Int64N(10000)
+		r := Range{Start: start, Stop: start + rng.Int64N(100)}
+		if rng.IntN(3) == 0 {
+			set = set.Subtract(NewRangeSet(r))
+			tree.Remove(r)
+		} else {
+			set = set.Union(NewRangeSet(r))
+			tree.Add(r)
+		}
+		if i%100 != 0 {
+			continue
+		}
+		if !tree.RangeSet().Equal(set) {
+			t.Fatalf("step %d: bad ranges: got %v, want %v", i, tree.Ranges(), set.Ranges())
+		}
+		if tree.Len() != set.Len() || tree.Count() != len(set.ranges) {
+			t.Fatalf("step %d: bad length or count: got %d, %d, want %d, %d", i, tree.Len(), tree.Count(), set.Len(), len(set.ranges))
+		}
+		for j := 0; j < 100; j++ {
+			off := rng.Int64N(10200)
+			if tree.Contains(off) != set.Contains(off) {
+				t.Fatalf("step %d: bad Contains(%d)", i, off)
+			}
+		}
+	}
+}
+
+func BenchmarkTreeSetAdd(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		s := new(TreeSet)
+		for j := int64(0); j < 100000; j++ {
+			s.Add(Range{Start: j * 10, Stop: j*10 + 4})
+		}
+	}
+}
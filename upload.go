@@ -0,0 +1,243 @@
+package ranger
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// ErrUploadConflict is returned when part of an upload conflicts with what's
+// been received already: it overlaps bytes already received, or disagrees
+// about the length of the object.
+var ErrUploadConflict = errors.New("ranger: upload conflict")
+
+// ParseUploadRange parses the Content-Range field of a PUT or PATCH request
+// carrying part of an object, as sent by clients of chunked and resumable
+// upload APIs. The body must hold exactly the bytes of the range, so if the
+// request's Content-Length is known, it must agree.
+//
+// The 'bytes first-last/*' form, for an object whose length the client doesn't
+// know yet, has a Length of -1. The 'bytes */length' form, which carries no
+// bytes, gives the length of the object, and is used to finish an upload or to
+// ask how much of it has been received. A request without a Content-Range
+// carries the whole object.
+//
+// If the field is malformed, or disagrees with the Content-Length, an error
+// wrapping ErrContentRange is returned.
+func ParseUploadRange(r *http.Request) (ContentRange, error) {
+	v := r.Header.Get("Content-Range")
+	if v == "" {
+		if r.ContentLength < 0 {
+			return ContentRange{}, fmt.Errorf("%w: no Content-Range or Content-Length", ErrContentRange)
+		}
+		if r.ContentLength == 0 {
+			return ContentRange{Length: 0, Unsatisfied: true}, nil
+		}
+		return ContentRange{Range: Range{Start: 0, Stop: r.ContentLength - 1}, Length: r.ContentLength}, nil
+	}
+	cr, err := ParseContentRangeValue(v)
+	if err != nil {
+		return ContentRange{}, err
+	}
+	if r.ContentLength >= 0 {
+		want := cr.Range.Len()
+		if cr.Unsatisfied {
+			want = 0
+		}
+		if r.ContentLength != want {
+			return ContentRange{}, fmt.Errorf("%w: %d bytes for %q", ErrContentRange, r.ContentLength, v)
+		}
+	}
+	return cr, nil
+}
+
+// UploadTracker does the bookkeeping for an object uploaded in parts, which
+// may arrive out of order: which bytes have been received, whether any are
+// missing, and whether the object is complete. The length of the object need
+// not be known until the last part arrives. It's safe for concurrent use by
+// multiple goroutines.
+type UploadTracker struct {
+	mu       sync.Mutex
+	length   int64
+	received RangeSet
+	reserved RangeSet // ranges being stored, by Reserve
+}
+
+// NewUploadTracker returns an UploadTracker for an object of length bytes, or
+// of unknown length if length is negative, with nothing received yet.
+func NewUploadTracker(length int64) *UploadTracker {
+	return &UploadTracker{length: max(length, -1)}
+}
+
+// Add records the part cr as received, and reports whether the object is now
+// complete. It should be called once the part's bytes have been stored.
+//
+// If cr gives the length of the object, it's taken as known from then on. If
+// it disagrees with the length already known, or with the bytes already
+// received, or if the part overlaps bytes already received, nothing is
+// recorded, and an error wrapping ErrUploadConflict is returned.
+func (u *UploadTracker) Add(cr ContentRange) (complete bool, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	length, set, err := u.add(cr)
+	if err != nil {
+		return false, err
+	}
+	u.length, u.received = length, set
+	return u.complete(), nil
+}
+
+// Check reports whether the part cr could be added, without recording it. A
+// server can check a part before storing its bytes, so that a conflicting
+// part doesn't overwrite bytes already received. A server that stores parts
+// concurrently should use Reserve, since another part may be added between
+// Check and Add.
+func (u *UploadTracker) Check(cr ContentRange) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	_, _, err := u.add(cr)
+	return err
+}
+
+// Reserve checks the part cr as Check does, and holds its range while its
+// bytes are stored, so that no part overlapping it can be reserved or added
+// until the reservation is committed or released. Of two concurrent requests
+// for overlapping parts, only one can store its bytes.
+func (u *UploadTracker) Reserve(cr ContentRange) (*UploadReservation, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, _, err := u.add(cr); err != nil {
+		return nil, err
+	}
+	if !cr.Unsatisfied {
+		u.reserved = u.reserved.Union(NewRangeSet(cr.Range))
+	}
+	return &UploadReservation{u: u, cr: cr}, nil
+}
+
+// UploadReservation is a part of an upload reserved by UploadTracker.Reserve.
+type UploadReservation struct {
+	u    *UploadTracker
+	cr   ContentRange
+	done bool
+}
+
+// Commit records the reserved part as received, as Add does, once its bytes
+// have been stored, and releases the reservation.
+func (r *UploadReservation) Commit() (complete bool, err error) {
+	u := r.u
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if r.done {
+		return false, errors.New("ranger: upload reservation already released")
+	}
+	r.release()
+	length, set, err := u.add(r.cr)
+	if err != nil {
+		return false, err
+	}
+	u.length, u.received = length, set
+	return u.complete(), nil
+}
+
+// Release gives up the reservation without recording the part, such as when
+// its bytes couldn't be stored, so that the part can be sent again. It does
+// nothing once the reservation has been committed or released.
+func (r *UploadReservation) Release() {
+	r.u.mu.Lock()
+	defer r.u.mu.Unlock()
+	r.release()
+}
+
+func (r *UploadReservation) release() {
+	if r.done {
+		return
+	}
+	r.done = true
+	if !r.cr.Unsatisfied {
+		r.u.reserved = r.u.reserved.Subtract(NewRangeSet(r.cr.Range))
+	}
+}
+
+// add returns the length and the received set with cr added.
+func (u *UploadTracker) add(cr ContentRange) (int64, RangeSet, error) {
+	length := u.length
+	if cr.Length >= 0 {
+		if length >= 0 && length != cr.Length {
+			return 0, RangeSet{}, fmt.Errorf("%w: length %d, want %d", ErrUploadConflict, cr.Length, length)
+		}
+		length = cr.Length
+	}
+	set := u.received
+	if !cr.Unsatisfied {
+		if length >= 0 && cr.Range.Stop >= length {
+			return 0, RangeSet{}, fmt.Errorf("%w: range %v beyond length %d", ErrUploadConflict, cr.Range, length)
+		}
+		part := NewRangeSet(cr.Range)
+		if set.Intersect(part).Len() > 0 {
+			return 0, RangeSet{}, fmt.Errorf("%w: range %v overlaps bytes received", ErrUploadConflict, cr.Range)
+		}
+		if u.reserved.Intersect(part).Len() > 0 {
+			return 0, RangeSet{}, fmt.Errorf("%w: range %v overlaps bytes being received", ErrUploadConflict, cr.Range)
+		}
+		set = set.Union(part)
+	}
+	if length >= 0 && set.Len() > 0 && set.ranges[len(set.ranges)-1].Stop >= length {
+		return 0, RangeSet{}, fmt.Errorf("%w: length %d, but bytes beyond it were received", ErrUploadConflict, length)
+	}
+	return length, set, nil
+}
+
+// Length returns the length of the object, or -1 if it isn't known yet.
+func (u *UploadTracker) Length() int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.length
+}
+
+// Received returns the set of ranges received so far.
+func (u *UploadTracker) Received() RangeSet {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.received
+}
+
+// Committed returns the number of bytes received contiguously from the start
+// of the object: the offset from which a client resuming the upload should
+// continue.
+func (u *UploadTracker) Committed() int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.received.ranges) == 0 || u.received.ranges[0].Start != 0 {
+		return 0
+	}
+	return u.received.ranges[0].Stop + 1
+}
+
+// Missing returns the gaps between the ranges received so far, and, once the
+// length is known, any bytes missing from the end.
+func (u *UploadTracker) Missing() []Range {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	length := u.length
+	if length < 0 {
+		if len(u.received.ranges) == 0 {
+			return nil
+		}
+		length = u.received.ranges[len(u.received.ranges)-1].Stop + 1
+	}
+	return u.received.Complement(length).Ranges()
+}
+
+// Complete reports whether the length of the object is known, and all of it
+// has been received.
+func (u *UploadTracker) Complete() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.complete()
+}
+
+func (u *UploadTracker) complete() bool {
+	return u.length >= 0 && u.received.Len() == u.length
+}
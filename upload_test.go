@@ -0,0 +1,165 @@
+package ranger
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type parseUploadRangeTest struct {
+	ContentRange  string
+	Body          string
+	Expected      ContentRange
+	ExpectedError string
+}
+
+func TestParseUploadRange(t *testing.T) {
+	tests := []parseUploadRangeTest{
+		{ // a part
+			ContentRange:  "bytes 0-3/10",
+			Body:          "0123",
+			Expected:      ContentRange{Range: Range{Start: 0, Stop: 3}, Length: 10},
+			ExpectedError: "<nil>",
+		},
+		{ // unknown length
+			ContentRange:  "bytes 4-5/*",
+			Body:          "45",
+			Expected:      ContentRange{Range: Range{Start: 4, Stop: 5}, Length: -1},
+			ExpectedError: "<nil>",
+		},
+		{ // length only
+			ContentRange:  "bytes */10",
+			Expected:      ContentRange{Length: 10, Unsatisfied: true},
+			ExpectedError: "<nil>",
+		},
+		{ // the whole object
+			Body:          "0123",
+			Expected:      ContentRange{Range: Range{Start: 0, Stop: 3}, Length: 4},
+			ExpectedError: "<nil>",
+		},
+		{ // an empty object
+			Expected:      ContentRange{Length: 0, Unsatisfied: true},
+			ExpectedError: "<nil>",
+		},
+		{ // body disagrees
+			ContentRange:  "bytes 0-3/10",
+			Body:          "012",
+			ExpectedError: `ranger: invalid content-range: 3 bytes for "bytes 0-3/10"`,
+		},
+		{ // malformed
+			ContentRange:  "bytes 0-3",
+			Body:          "0123",
+			ExpectedError: `ranger: invalid content-range: "bytes 0-3"`,
+		},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("PUT", "/", strings.NewReader(test.Body))
+		if test.ContentRange != "" {
+			req.Header.Set("Content-Range", test.ContentRange)
+		}
+		cr, err := ParseUploadRange(req)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %v, want %v", i, got, want)
+		}
+		if got, want := cr, test.Expected; got != want {
+			t.Errorf("test %d: bad content range: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+type uploadTrackerTest struct {
+	ContentRange     string
+	ExpectedComplete bool
+	ExpectedError    string
+}
+
+func TestUploadTracker(t *testing.T) {
+	u := NewUploadTracker(-1)
+	tests := []uploadTrackerTest{
+		{ // first part, length unknown
+			ContentRange:  "bytes 0-3/*",
+			ExpectedError: "<nil>",
+		},
+		{ // out of order
+			ContentRange:  "bytes 6-7/*",
+			ExpectedError: "<nil>",
+		},
+		{ // overlap
+			ContentRange:  "bytes 3-4/*",
+			ExpectedError: "ranger: upload conflict: range 3-4 overlaps bytes received",
+		},
+		{ // length too short for what's been received
+			ContentRange:  "bytes */5",
+			ExpectedError: "ranger: upload conflict: length 5, but bytes beyond it were received",
+		},
+		{ // last part gives the length, but there's a gap
+			ContentRange:  "bytes 8-9/10",
+			ExpectedError: "<nil>",
+		},
+		{ // length disagrees
+			ContentRange:  "bytes 4-5/11",
+			ExpectedError: "ranger: upload conflict: length 11, want 10",
+		},
+		{ // the gap is filled
+			ContentRange:     "bytes 4-5/*",
+			ExpectedComplete: true,
+			ExpectedError:    "<nil>",
+		},
+	}
+	for i, test := range tests {
+		cr, err := ParseContentRangeValue(test.ContentRange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		complete, err := u.Add(cr)
+		if got, want := fmt.Sprintf("%v", err), test.ExpectedError; got != want {
+			t.Errorf("test %d: bad error: got %v, want %v", i, got, want)
+		}
+		if got, want := complete, test.ExpectedComplete; got != want {
+			t.Errorf("test %d: bad complete: got %v, want %v", i, got, want)
+		}
+		if i == 4 {
+			if got, want := fmt.Sprint(u.Missing()), "[4-5]"; got != want {
+				t.Errorf("test %d: bad missing: got %v, want %v", i, got, want)
+			}
+			if got, want := u.Committed(), int64(4); got != want {
+				t.Errorf("test %d: bad committed: got %d, want %d", i, got, want)
+			}
+		}
+	}
+	if !u.Complete() || u.Length() != 10 || u.Committed() != 10 {
+		t.Errorf("bad final state: complete %v, length %d, committed %d", u.Complete(), u.Length(), u.Committed())
+	}
+}
+
+func TestUploadTrackerReserve(t *testing.T) {
+	u := NewUploadTracker(10)
+	first, err := u.Reserve(ContentRange{Range: Range{Start: 0, Stop: 4}, Length: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlap := ContentRange{Range: Range{Start: 4, Stop: 5}, Length: 10}
+	want := "ranger: upload conflict: range 4-5 overlaps bytes being received"
+	if _, err := u.Reserve(overlap); fmt.Sprintf("%v", err) != want {
+		t.Errorf("bad error: got %v, want %v", err, want)
+	}
+	if _, err := u.Add(overlap); fmt.Sprintf("%v", err) != want {
+		t.Errorf("bad error: got %v, want %v", err, want)
+	}
+	first.Release()
+	second, err := u.Reserve(overlap)
+	if err != nil {
+		t.Fatalf("reserving after release: %v", err)
+	}
+	if _, err := second.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	second.Release()
+	if got, want := fmt.Sprint(u.Received().Ranges()), "[4-5]"; got != want {
+		t.Errorf("bad received: got %v, want %v", got, want)
+	}
+	if _, err := second.Commit(); err == nil {
+		t.Error("committed twice")
+	}
+}
@@ -0,0 +1,119 @@
+package ranger
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderReport is what ValidateHeader finds in the Range header of a request,
+// for scoring it without serving it.
+type HeaderReport struct {
+	// Fields is the number of Range fields.
+	Fields int
+
+	// Ranges is the number of ranges written, malformed or not.
+	Ranges int
+
+	// Malformed is the number of ranges that are malformed, and
+	// FirstMalformed is the first of them: the error Parse would return.
+	Malformed      int
+	FirstMalformed *SpecError
+
+	// Unsatisfiable is the number of ranges that are well formed, but that
+	// fall entirely outside of the content.
+	Unsatisfiable int
+
+	// Bytes is the number of bytes the satisfiable ranges ask for, clamped to
+	// the content, with overlapping bytes counted once for each range that
+	// asks for them, as for ParseOptions.MaxBytes. UniqueBytes counts them
+	// once, as they would be served once merged.
+	Bytes       int64
+	UniqueBytes int64
+
+	// Overlaps is the number of satisfiable ranges that overlap one written
+	// before them.
+	Overlaps int
+}
+
+// Satisfiable reports whether a server would serve partial content for the
+// header, as RFC 7233 says it should: none of the ranges is malformed, and at
+// least one is satisfiable. Parse is stricter unless ParseOptions.Clamp is
+// set: it refuses the header if any range is unsatisfiable, or extends past
+// the end of the content.
+func (r HeaderReport) Satisfiable() bool {
+	return r.Ranges > 0 && r.Malformed == 0 && r.Unsatisfiable < r.Ranges
+}
+
+// ValidateHeader reports on the Range header in h, for content of size bytes,
+// without parsing it into ranges, so that rate limiters, firewalls and loggers
+// can score a request cheaply before deciding whether to serve or forward it.
+// Unlike Parse, it reads all of the ranges, however many there are, and stops
+// at none of the errors. Only a header with a malformed range, or with many
+// ranges that don't overlap each other, makes it allocate.
+func ValidateHeader(h http.Header, size int64) HeaderReport {
+	values := h["Range"]
+	report := HeaderReport{Fields: len(values)}
+	var buf [16]Range
+	merged := buf[:0]
+	for _, value := range values {
+		for rest, more := strings.TrimPrefix(value, "bytes="), true; more; {
+			var r string
+			r, rest, more = strings.Cut(rest, ",")
+			r = trimOWS(r)
+			index := report.Ranges
+			report.Ranges++
+			spec, reason := parseSpec(r)
+			if reason != "" {
+				if report.Malformed == 0 {
+					report.FirstMalformed = &SpecError{Spec: r, Index: index, Reason: reason, Err: ErrMalformed}
+				}
+				report.Malformed++
+				continue
+			}
+			rng, _, ok := spec.clamp(size)
+			if !ok {
+				report.Unsatisfiable++
+				continue
+			}
+			report.Bytes += rng.Len()
+			var overlaps bool
+			merged, overlaps = addMerged(merged, rng)
+			if overlaps {
+				report.Overlaps++
+			}
+		}
+	}
+	for _, r := range merged {
+		report.UniqueBytes += r.Len()
+	}
+	return report
+}
+
+// addMerged adds r to merged, which is sorted, with no two ranges of it
+// overlapping, and keeps it so. It reports whether r overlapped any of them.
+func addMerged(merged []Range, r Range) ([]Range, bool) {
+	// Find the first range that doesn't stop before r starts.
+	lo, hi := 0, len(merged)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if merged[mid].Stop < r.Start {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	j := lo
+	for j < len(merged) && merged[j].Start <= r.Stop {
+		r.Start = min(r.Start, merged[j].Start)
+		r.Stop = max(r.Stop, merged[j].Stop)
+		j++
+	}
+	if j > lo {
+		merged[lo] = r
+		return append(merged[:lo+1], merged[j:]...), true
+	}
+	merged = append(merged, Range{})
+	copy(merged[lo+1:], merged[lo:])
+	merged[lo] = r
+	return merged, false
+}
@@ -0,0 +1,100 @@
+package ranger
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type validateHeaderTest struct {
+	Range               []string
+	Size                int64
+	ExpectedReport      HeaderReport
+	ExpectedMalformed   string
+	ExpectedSatisfiable bool
+}
+
+func TestValidateHeader(t *testing.T) {
+	tests := []validateHeaderTest{
+		{ // no header
+			Size:              100,
+			ExpectedMalformed: "<nil>",
+		},
+		{ // single
+			Range:               []string{"bytes=0-9"},
+			Size:                100,
+			ExpectedReport:      HeaderReport{Fields: 1, Ranges: 1, Bytes: 10, UniqueBytes: 10},
+			ExpectedMalformed:   "<nil>",
+			ExpectedSatisfiable: true,
+		},
+		{ // overlapping, and clamped
+			Range:               []string{"bytes=0-9, 5-14,20-29,-10,95-200"},
+			Size:                100,
+			ExpectedReport:      HeaderReport{Fields: 1, Ranges: 5, Bytes: 45, UniqueBytes: 35, Overlaps: 2},
+			ExpectedMalformed:   "<nil>",
+			ExpectedSatisfiable: true,
+		},
+		{ // a range covering earlier ones
+			Range:               []string{"bytes=10-19,30-39,0-99"},
+			Size:                100,
+			ExpectedReport:      HeaderReport{Fields: 1, Ranges: 3, Bytes: 120, UniqueBytes: 100, Overlaps: 1},
+			ExpectedMalformed:   "<nil>",
+			ExpectedSatisfiable: true,
+		},
+		{ // adjacent ranges don't overlap
+			Range:               []string{"bytes=0-9,10-19"},
+			Size:                100,
+			ExpectedReport:      HeaderReport{Fields: 1, Ranges: 2, Bytes: 20, UniqueBytes: 20},
+			ExpectedMalformed:   "<nil>",
+			ExpectedSatisfiable: true,
+		},
+		{ // malformed, counted on past the first
+			Range:             []string{"bytes=0-9,x-1", "9-5,200-"},
+			Size:              100,
+			ExpectedReport:    HeaderReport{Fields: 2, Ranges: 4, Malformed: 2, Unsatisfiable: 1, Bytes: 10, UniqueBytes: 10},
+			ExpectedMalformed: "invalid range: malformed: range 1 \"x-1\": bad number",
+		},
+		{ // unsatisfiable
+			Range:             []string{"bytes=100-,-0"},
+			Size:              100,
+			ExpectedReport:    HeaderReport{Fields: 1, Ranges: 2, Unsatisfiable: 2},
+			ExpectedMalformed: "<nil>",
+		},
+	}
+	for i, test := range tests {
+		h := http.Header{}
+		for _, v := range test.Range {
+			h.Add("Range", v)
+		}
+		report := ValidateHeader(h, test.Size)
+		if got, want := fmt.Sprintf("%v", report.FirstMalformed), test.ExpectedMalformed; got != want {
+			t.Errorf("test %d: bad malformed range: got %q, want %q", i, got, want)
+		}
+		report.FirstMalformed = nil
+		if got, want := report, test.ExpectedReport; got != want {
+			t.Errorf("test %d: bad report: got %+v, want %+v", i, got, want)
+		}
+		if got, want := report.Satisfiable(), test.ExpectedSatisfiable; got != want {
+			t.Errorf("test %d: bad satisfiable: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestValidateHeaderAllocs(t *testing.T) {
+	h := http.Header{"Range": {"bytes=200-299,0-99,50-149," + strings.Repeat("1-1,", 20) + "-5"}}
+	allocs := testing.AllocsPerRun(100, func() {
+		ValidateHeader(h, 1000)
+	})
+	if allocs != 0 {
+		t.Errorf("bad allocations: got %v, want 0", allocs)
+	}
+}
+
+func BenchmarkValidateHeader(b *testing.B) {
+	h := http.Header{"Range": {"bytes=200-299,0-99,50-149"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ValidateHeader(h, 1000)
+	}
+}
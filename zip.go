@@ -0,0 +1,28 @@
+package ranger
+
+import (
+	"archive/zip"
+	"net/http"
+)
+
+// zipTail is how much of the end of a ZIP archive OpenZip fetches up front:
+// enough for the end of central directory record and the longest possible
+// archive comment, plus some of the central directory itself.
+const zipTail = 128 << 10
+
+// OpenZip opens the remote ZIP archive at url, fetched with client, or
+// http.DefaultClient if client is nil, with range requests. Only the central
+// directory is fetched to open it, with a single request for the end of the
+// archive unless the directory is large, so that individual files can be
+// listed and extracted from huge archives without downloading all of them.
+//
+// The archive is read through an HTTPReader made with opts, which detects
+// whether the archive changes while it's being read.
+func OpenZip(client *http.Client, url string, opts ...HTTPReaderOption) (*zip.Reader, error) {
+	opts = append([]HTTPReaderOption{WithTailPrefetch(zipTail), WithReadAhead(zipTail)}, opts...)
+	hr, err := NewHTTPReader(client, url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(hr, hr.Size())
+}
@@ -0,0 +1,82 @@
+package ranger
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOpenZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		// Incompressible content, so that the archive is much larger than
+		// the tail that's fetched up front.
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("file%d", i), Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.CopyN(w, rnd, 100<<10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := zw.Create("hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "hello, world")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, requests := countingServer(t, buf.String())
+	zr, err := OpenZip(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(zr.File), 11; got != want {
+		t.Errorf("bad number of files: got %d, want %d", got, want)
+	}
+	if got, want := atomic.LoadInt64(requests), int64(1); got != want {
+		t.Errorf("bad number of requests to open: got %d, want %d", got, want)
+	}
+	f, err := zr.Open("hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "hello, world"; got != want {
+		t.Errorf("bad content: got %q, want %q", got, want)
+	}
+}
+
+func TestOpenZipSmall(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "a")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(Handler(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	defer srv.Close()
+	zr, err := OpenZip(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := zr.File[0].Name, "a.txt"; got != want {
+		t.Errorf("bad name: got %q, want %q", got, want)
+	}
+}